@@ -0,0 +1,449 @@
+package aac
+
+import (
+	"errors"
+	"fmt"
+
+	"media-go/core"
+)
+
+// Audio object types, ISO/IEC 14496-3 1.5.1.1
+const (
+	AOT_NULL         = 0
+	AOT_AAC_MAIN     = 1
+	AOT_AAC_LC       = 2
+	AOT_AAC_SSR      = 3
+	AOT_AAC_LTP      = 4
+	AOT_SBR          = 5 // HE-AAC
+	AOT_AAC_SCALABLE = 6
+	AOT_TWINVQ       = 7
+	AOT_ER_AAC_LC    = 17
+	AOT_ER_AAC_LTP   = 19
+	AOT_ER_AAC_SCAL  = 20
+	AOT_ER_TWINVQ    = 21
+	AOT_ER_BSAC      = 22
+	AOT_ER_AAC_LD    = 23
+	AOT_PS           = 29 // HE-AACv2
+	AOT_ESCAPE       = 31
+	AOT_ER_AAC_ELD   = 39
+)
+
+var ObjectTypeMap = map[int]string{
+	AOT_AAC_MAIN:     "AAC Main",
+	AOT_AAC_LC:       "AAC LC",
+	AOT_AAC_SSR:      "AAC SSR",
+	AOT_AAC_LTP:      "AAC LTP",
+	AOT_SBR:          "HE-AAC",
+	AOT_AAC_SCALABLE: "AAC Scalable",
+	AOT_TWINVQ:       "TwinVQ",
+	AOT_ER_AAC_LC:    "ER AAC LC",
+	AOT_ER_AAC_LTP:   "ER AAC LTP",
+	AOT_ER_AAC_SCAL:  "ER AAC Scalable",
+	AOT_ER_TWINVQ:    "ER TwinVQ",
+	AOT_ER_BSAC:      "ER BSAC",
+	AOT_ER_AAC_LD:    "ER AAC LD",
+	AOT_PS:           "HE-AACv2",
+	AOT_ER_AAC_ELD:   "ER AAC ELD",
+}
+
+// SampleRates is indexed by samplingFrequencyIndex, 0xf means an explicit
+// 24 bit frequency follows.
+var SampleRates = []int{
+	96000, 88200, 64000, 48000, 44100, 32000,
+	24000, 22050, 16000, 12000, 11025, 8000, 7350,
+}
+
+// channelCounts is indexed by channelConfiguration, 0 means a program
+// config element describes the layout.
+var channelCounts = []int{0, 1, 2, 3, 4, 5, 6, 8}
+
+const (
+	syncExtensionSBR = 0x2b7
+	syncExtensionPS  = 0x548
+)
+
+var (
+	ErrInvalidConfig = errors.New("aac: invalid AudioSpecificConfig")
+	ErrUnsupported   = errors.New("aac: unsupported audio object type")
+)
+
+// AudioSpecificConfig is the AAC decoder configuration carried by the FLV
+// sequence header and the MP4 esds box.
+type AudioSpecificConfig struct {
+	ObjectType         int
+	SampleRateIndex    int
+	SampleRate         int // core coder rate, half the output rate with SBR
+	ChannelConfig      int
+	Channels           int
+	ExtensionType      int // AOT_SBR when SBR is signalled, AOT_NULL otherwise
+	ExtensionRateIndex int
+	ExtensionRate      int
+	SBR                bool
+	PS                 bool
+
+	// GASpecificConfig
+	FrameLengthFlag    bool // 960 instead of 1024 samples per frame
+	DependsOnCoreCoder bool
+	CoreCoderDelay     int
+	ExtensionFlag      bool
+}
+
+// reader keeps the first error, later reads return zeros.
+type reader struct {
+	bs  *core.BitStream
+	err error
+}
+
+func (r *reader) u(n int) int {
+	if r.err != nil {
+		return 0
+	}
+
+	v, err := r.bs.ReadBits(n)
+	if err != nil {
+		r.err = ErrInvalidConfig
+		return 0
+	}
+
+	return v
+}
+
+func (r *reader) skip(n int) {
+	for ; n > 0; n -= 8 {
+		if n < 8 {
+			r.u(n)
+			return
+		}
+		r.u(8)
+	}
+}
+
+func (r *reader) flag() bool { return r.u(1) == 1 }
+
+func (r *reader) objectType() int {
+	aot := r.u(5)
+	if aot == AOT_ESCAPE {
+		aot = 32 + r.u(6)
+	}
+
+	return aot
+}
+
+func (r *reader) sampleRate() (int, int) {
+	index := r.u(4)
+	if index == 0x0f {
+		return index, r.u(24)
+	}
+
+	if index >= len(SampleRates) {
+		r.err = ErrInvalidConfig
+		return index, 0
+	}
+
+	return index, SampleRates[index]
+}
+
+// ParseAudioSpecificConfig parses an AudioSpecificConfig, both the explicit
+// hierarchical and the backward compatible SBR/PS signalling are handled.
+func ParseAudioSpecificConfig(data []byte) (*AudioSpecificConfig, error) {
+	r := &reader{bs: core.NewBitStream(data)}
+	c := &AudioSpecificConfig{}
+
+	c.ObjectType = r.objectType()
+	c.SampleRateIndex, c.SampleRate = r.sampleRate()
+	c.ChannelConfig = r.u(4)
+
+	if c.ObjectType == AOT_SBR || c.ObjectType == AOT_PS {
+		c.ExtensionType = AOT_SBR
+		c.SBR = true
+		c.PS = c.ObjectType == AOT_PS
+		c.ExtensionRateIndex, c.ExtensionRate = r.sampleRate()
+		c.ObjectType = r.objectType()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	switch c.ObjectType {
+	case AOT_AAC_MAIN, AOT_AAC_LC, AOT_AAC_SSR, AOT_AAC_LTP, AOT_AAC_SCALABLE, AOT_TWINVQ,
+		AOT_ER_AAC_LC, AOT_ER_AAC_LTP, AOT_ER_AAC_SCAL, AOT_ER_TWINVQ, AOT_ER_BSAC, AOT_ER_AAC_LD:
+		c.readGASpecificConfig(r)
+	default:
+		return nil, ErrUnsupported
+	}
+
+	switch c.ObjectType {
+	case AOT_ER_AAC_LC, AOT_ER_AAC_LTP, AOT_ER_AAC_SCAL, AOT_ER_TWINVQ, AOT_ER_BSAC, AOT_ER_AAC_LD:
+		r.u(2) // epConfig
+	}
+
+	if c.ChannelConfig > 0 && c.ChannelConfig < len(channelCounts) {
+		c.Channels = channelCounts[c.ChannelConfig]
+	}
+
+	if c.ExtensionType != AOT_SBR && r.err == nil && r.bs.Left() >= 16 {
+		c.readSyncExtension(r)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return c, nil
+}
+
+func (c *AudioSpecificConfig) readGASpecificConfig(r *reader) {
+	c.FrameLengthFlag = r.flag()
+	if c.DependsOnCoreCoder = r.flag(); c.DependsOnCoreCoder {
+		c.CoreCoderDelay = r.u(14)
+	}
+	c.ExtensionFlag = r.flag()
+
+	if c.ChannelConfig == 0 {
+		c.Channels = readProgramConfigElement(r)
+	}
+
+	if c.ObjectType == AOT_AAC_SCALABLE || c.ObjectType == AOT_ER_AAC_SCAL {
+		r.u(3) // layerNr
+	}
+
+	if c.ExtensionFlag {
+		if c.ObjectType == AOT_ER_BSAC {
+			r.u(5)  // numOfSubFrame
+			r.u(11) // layer_length
+		}
+
+		switch c.ObjectType {
+		case AOT_ER_AAC_LC, AOT_ER_AAC_LTP, AOT_ER_AAC_SCAL, AOT_ER_AAC_LD:
+			r.u(3) // resilience flags
+		}
+
+		r.u(1) // extensionFlag3
+	}
+}
+
+// readProgramConfigElement returns the number of output channels described
+// by a program_config_element, coupling channels are not counted.
+func readProgramConfigElement(r *reader) int {
+	r.u(4) // element_instance_tag
+	r.u(2) // object_type
+	r.u(4) // sampling_frequency_index
+
+	front, side, back := r.u(4), r.u(4), r.u(4)
+	lfe, assoc, cc := r.u(2), r.u(3), r.u(4)
+
+	if r.flag() { // mono_mixdown_present
+		r.u(4)
+	}
+	if r.flag() { // stereo_mixdown_present
+		r.u(4)
+	}
+	if r.flag() { // matrix_mixdown_idx_present
+		r.u(3)
+	}
+
+	channels := lfe
+	for i := 0; i < front+side+back; i++ {
+		if r.flag() { // is_cpe
+			channels += 2
+		} else {
+			channels++
+		}
+		r.u(4)
+	}
+
+	r.skip(4*lfe + 4*assoc + 5*cc)
+
+	if pad := r.bs.Pos() % 8; pad != 0 {
+		r.u(8 - pad)
+	}
+	r.skip(8 * r.u(8)) // comment_field_data
+
+	return channels
+}
+
+func (c *AudioSpecificConfig) readSyncExtension(r *reader) {
+	if r.u(11) != syncExtensionSBR {
+		return
+	}
+
+	if r.objectType() != AOT_SBR {
+		return
+	}
+
+	if c.SBR = r.flag(); !c.SBR {
+		return
+	}
+
+	c.ExtensionType = AOT_SBR
+	c.ExtensionRateIndex, c.ExtensionRate = r.sampleRate()
+
+	if r.err == nil && r.bs.Left() >= 12 && r.u(11) == syncExtensionPS {
+		c.PS = r.flag()
+	}
+}
+
+type writer struct {
+	data []byte
+	bits int
+}
+
+func (w *writer) u(n, v int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte((v>>uint(i))&1) << uint(7-w.bits%8)
+		w.bits++
+	}
+}
+
+func (w *writer) objectType(aot int) {
+	if aot >= 32 {
+		w.u(5, AOT_ESCAPE)
+		w.u(6, aot-32)
+		return
+	}
+
+	w.u(5, aot)
+}
+
+func (w *writer) sampleRate(index, rate int) {
+	w.u(4, index)
+	if index == 0x0f {
+		w.u(24, rate)
+	}
+}
+
+// Bytes serializes c the way FLV sequence headers carry it, SBR and PS use
+// the explicit hierarchical signalling. Only AAC Main, LC, SSR and LTP are
+// supported, the other types carry fields ParseAudioSpecificConfig does not
+// keep, and so are configs with a program config element.
+func (c *AudioSpecificConfig) Bytes() ([]byte, error) {
+	if c.ChannelConfig == 0 || c.ObjectType < AOT_AAC_MAIN || c.ObjectType > AOT_AAC_LTP {
+		return nil, ErrUnsupported
+	}
+
+	w := &writer{}
+	switch {
+	case c.PS:
+		w.objectType(AOT_PS)
+	case c.SBR:
+		w.objectType(AOT_SBR)
+	default:
+		w.objectType(c.ObjectType)
+	}
+
+	w.sampleRate(c.SampleRateIndex, c.SampleRate)
+	w.u(4, c.ChannelConfig)
+
+	if c.SBR {
+		w.sampleRate(c.ExtensionRateIndex, c.ExtensionRate)
+		w.objectType(c.ObjectType)
+	}
+
+	flag := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	w.u(1, flag(c.FrameLengthFlag))
+	w.u(1, flag(c.DependsOnCoreCoder))
+	if c.DependsOnCoreCoder {
+		w.u(14, c.CoreCoderDelay)
+	}
+	w.u(1, flag(c.ExtensionFlag))
+	if c.ExtensionFlag {
+		w.u(1, 0) // extensionFlag3
+	}
+
+	return w.data, nil
+}
+
+// OutputSampleRate is the decoded sample rate, the SBR rate when present.
+func (c *AudioSpecificConfig) OutputSampleRate() int {
+	if c.SBR && c.ExtensionRate > 0 {
+		return c.ExtensionRate
+	}
+
+	return c.SampleRate
+}
+
+// OutputChannels is the decoded channel count, PS upmixes mono to stereo.
+func (c *AudioSpecificConfig) OutputChannels() int {
+	if c.PS && c.Channels == 1 {
+		return 2
+	}
+
+	return c.Channels
+}
+
+// FrameLength is the number of decoded samples per raw frame.
+func (c *AudioSpecificConfig) FrameLength() int {
+	n := 1024
+	if c.ObjectType == AOT_ER_AAC_LD || c.ObjectType == AOT_ER_AAC_ELD {
+		n = 512
+	}
+
+	if c.FrameLengthFlag {
+		n = n * 15 / 16
+	}
+
+	if c.SBR && c.OutputSampleRate() != c.SampleRate {
+		n *= 2
+	}
+
+	return n
+}
+
+func (c *AudioSpecificConfig) Profile() string {
+	if c.PS {
+		return ObjectTypeMap[AOT_PS]
+	}
+
+	if c.SBR {
+		return ObjectTypeMap[AOT_SBR]
+	}
+
+	if name, ok := ObjectTypeMap[c.ObjectType]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("aot %d", c.ObjectType)
+}
+
+func (c *AudioSpecificConfig) String() string {
+	return fmt.Sprintf("|%s|%dHz|%dch", c.Profile(), c.OutputSampleRate(), c.OutputChannels())
+}
+
+// Frame describes one raw_data_block as decoded with a config.
+type Frame struct {
+	SampleRate int
+	Channels   int
+	Samples    int
+	Duration   float64 // milliseconds
+	Data       []byte
+}
+
+// Frame returns the decoded properties of a raw frame.
+func (c *AudioSpecificConfig) Frame(raw []byte) *Frame {
+	f := &Frame{
+		SampleRate: c.OutputSampleRate(),
+		Channels:   c.OutputChannels(),
+		Samples:    c.FrameLength(),
+		Data:       raw,
+	}
+
+	if f.SampleRate > 0 {
+		f.Duration = float64(f.Samples) * 1000 / float64(f.SampleRate)
+	}
+
+	return f
+}
+
+func (f *Frame) String() string {
+	return fmt.Sprintf("|%dHz|%dch|%d samples|%d bytes", f.SampleRate, f.Channels, f.Samples, len(f.Data))
+}
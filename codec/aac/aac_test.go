@@ -0,0 +1,75 @@
+package aac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAudioSpecificConfig(t *testing.T) {
+	c, err := ParseAudioSpecificConfig([]byte{0x11, 0x90})
+	assert.Nil(t, err)
+	assert.Equal(t, AOT_AAC_LC, c.ObjectType)
+	assert.Equal(t, 48000, c.OutputSampleRate())
+	assert.Equal(t, 2, c.OutputChannels())
+	assert.Equal(t, 1024, c.FrameLength())
+	assert.Equal(t, "|AAC LC|48000Hz|2ch", c.String())
+
+	// explicit frequency
+	c, err = ParseAudioSpecificConfig([]byte{0x17, 0x80, 0x5d, 0xc0, 0x08})
+	assert.Nil(t, err)
+	assert.Equal(t, 0x0f, c.SampleRateIndex)
+	assert.Equal(t, 48000, c.SampleRate)
+	assert.Equal(t, 1, c.Channels)
+
+	_, err = ParseAudioSpecificConfig([]byte{0x12})
+	assert.Equal(t, ErrInvalidConfig, err)
+
+	_, err = ParseAudioSpecificConfig([]byte{0x41, 0x90})
+	assert.Equal(t, ErrUnsupported, err)
+}
+
+func TestParseHEAAC(t *testing.T) {
+	// explicit hierarchical signalling
+	c, err := ParseAudioSpecificConfig([]byte{0x2b, 0x11, 0x88, 0x00})
+	assert.Nil(t, err)
+	assert.True(t, c.SBR)
+	assert.False(t, c.PS)
+	assert.Equal(t, AOT_AAC_LC, c.ObjectType)
+	assert.Equal(t, 24000, c.SampleRate)
+	assert.Equal(t, 48000, c.OutputSampleRate())
+	assert.Equal(t, 2048, c.FrameLength())
+	assert.Equal(t, "HE-AAC", c.Profile())
+
+	c, err = ParseAudioSpecificConfig([]byte{0xeb, 0x09, 0x88, 0x00})
+	assert.Nil(t, err)
+	assert.True(t, c.PS)
+	assert.Equal(t, 1, c.Channels)
+	assert.Equal(t, 2, c.OutputChannels())
+	assert.Equal(t, "HE-AACv2", c.Profile())
+
+	// backward compatible sync extensions
+	c, err = ParseAudioSpecificConfig([]byte{0x13, 0x10, 0x56, 0xe5, 0x9d, 0x48, 0x80})
+	assert.Nil(t, err)
+	assert.True(t, c.SBR)
+	assert.True(t, c.PS)
+	assert.Equal(t, AOT_SBR, c.ExtensionType)
+	assert.Equal(t, 48000, c.OutputSampleRate())
+}
+
+func TestParseProgramConfigElement(t *testing.T) {
+	c, err := ParseAudioSpecificConfig([]byte{0x11, 0x80, 0x04, 0xc8, 0x05, 0x00, 0x01, 0x08, 0x80, 0x00})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, c.ChannelConfig)
+	assert.Equal(t, 6, c.Channels)
+}
+
+func TestFrame(t *testing.T) {
+	c, err := ParseAudioSpecificConfig([]byte{0x11, 0x90})
+	assert.Nil(t, err)
+
+	f := c.Frame([]byte{0x21, 0x00})
+	assert.Equal(t, 48000, f.SampleRate)
+	assert.Equal(t, 1024, f.Samples)
+	assert.InDelta(t, 21.333, f.Duration, 0.001)
+}
@@ -0,0 +1,271 @@
+package aac
+
+import (
+	"errors"
+	"io"
+
+	"media-go/core"
+)
+
+const (
+	ADTSHeaderSize   = 7
+	ADTSCRCSize      = 2
+	adtsSyncWord     = 0xfff
+	adtsMaxFrameSize = 0x1fff
+	adtsFullnessVBR  = 0x7ff
+)
+
+var (
+	ErrInvalidADTS = errors.New("aac: invalid ADTS header")
+	ErrNoADTS      = errors.New("aac: config cannot be carried in ADTS")
+	ErrADTSBlocks  = errors.New("aac: ADTS frame of several raw data blocks without CRC")
+)
+
+// ADTSHeader is the fixed and variable ADTS header, ISO/IEC 13818-7 6.2.
+type ADTSHeader struct {
+	ID               int // 0 MPEG-4, 1 MPEG-2
+	ProtectionAbsent bool
+	Profile          int // audio object type - 1
+	SampleRateIndex  int
+	PrivateBit       int
+	ChannelConfig    int
+	Original         int
+	Home             int
+	FrameLength      int // whole frame, header included
+	BufferFullness   int
+	RawBlocks        int   // number_of_raw_data_blocks_in_frame + 1
+	BlockPositions   []int // raw_data_block_position of the blocks after the first, with CRC
+	CRC              int
+}
+
+// Size is the header size, with adts_header_error_check when present: the
+// block positions of a frame of several blocks and the CRC.
+func (h *ADTSHeader) Size() int {
+	if h.ProtectionAbsent {
+		return ADTSHeaderSize
+	}
+
+	return ADTSHeaderSize + ADTSCRCSize*h.RawBlocks
+}
+
+// Config returns the AudioSpecificConfig an FLV or MP4 muxer needs.
+func (h *ADTSHeader) Config() *AudioSpecificConfig {
+	c := &AudioSpecificConfig{
+		ObjectType:      h.Profile + 1,
+		SampleRateIndex: h.SampleRateIndex,
+		ChannelConfig:   h.ChannelConfig,
+	}
+
+	if h.SampleRateIndex < len(SampleRates) {
+		c.SampleRate = SampleRates[h.SampleRateIndex]
+	}
+
+	if h.ChannelConfig < len(channelCounts) {
+		c.Channels = channelCounts[h.ChannelConfig]
+	}
+
+	return c
+}
+
+// ParseADTSHeader parses the header at the start of data.
+func ParseADTSHeader(data []byte) (*ADTSHeader, error) {
+	if len(data) < ADTSHeaderSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	r := &reader{bs: core.NewBitStream(data)}
+	if r.u(12) != adtsSyncWord {
+		return nil, ErrInvalidADTS
+	}
+
+	h := &ADTSHeader{}
+	h.ID = r.u(1)
+	if r.u(2) != 0 { // layer
+		return nil, ErrInvalidADTS
+	}
+	h.ProtectionAbsent = r.flag()
+	h.Profile = r.u(2)
+	h.SampleRateIndex = r.u(4)
+	h.PrivateBit = r.u(1)
+	h.ChannelConfig = r.u(3)
+	h.Original = r.u(1)
+	h.Home = r.u(1)
+	r.u(2) // copyright identification bit and start
+	h.FrameLength = r.u(13)
+	h.BufferFullness = r.u(11)
+	h.RawBlocks = r.u(2) + 1
+
+	if h.SampleRateIndex >= len(SampleRates) || h.FrameLength < h.Size() {
+		return nil, ErrInvalidADTS
+	}
+
+	if !h.ProtectionAbsent {
+		if len(data) < h.Size() {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := 1; i < h.RawBlocks; i++ {
+			h.BlockPositions = append(h.BlockPositions, r.u(16))
+		}
+		h.CRC = r.u(16)
+	}
+
+	return h, nil
+}
+
+// Bytes serializes the header, the block positions and CRC are included when
+// ProtectionAbsent is false.
+func (h *ADTSHeader) Bytes() []byte {
+	b := make([]byte, h.Size())
+	absent := 1
+	if !h.ProtectionAbsent {
+		absent = 0
+	}
+
+	b[0] = 0xff
+	b[1] = byte(0xf0 | h.ID<<3 | absent)
+	b[2] = byte(h.Profile<<6 | h.SampleRateIndex<<2 | h.PrivateBit<<1 | h.ChannelConfig>>2)
+	b[3] = byte(h.ChannelConfig<<6 | h.Original<<5 | h.Home<<4 | h.FrameLength>>11)
+	b[4] = byte(h.FrameLength >> 3)
+	b[5] = byte(h.FrameLength<<5 | h.BufferFullness>>6)
+	b[6] = byte(h.BufferFullness<<2 | (h.RawBlocks-1)&0x03)
+
+	if !h.ProtectionAbsent {
+		pos := ADTSHeaderSize
+		for i := 1; i < h.RawBlocks; i++ {
+			if i <= len(h.BlockPositions) {
+				b[pos] = byte(h.BlockPositions[i-1] >> 8)
+				b[pos+1] = byte(h.BlockPositions[i-1])
+			}
+			pos += 2
+		}
+		b[pos] = byte(h.CRC >> 8)
+		b[pos+1] = byte(h.CRC)
+	}
+
+	return b
+}
+
+// ADTSWriter wraps raw AAC frames in ADTS headers.
+type ADTSWriter struct {
+	w      io.Writer
+	header ADTSHeader
+}
+
+// NewADTSWriter checks that config fits in an ADTS header. HE-AAC is written
+// with its core object type and rate, decoders find the SBR data implicitly.
+// Frames go out without CRC, the protected bits of a CRC depend on where the
+// channels of a raw data block end, which takes decoding the spectral data.
+func NewADTSWriter(w io.Writer, config *AudioSpecificConfig) (*ADTSWriter, error) {
+	if config.ObjectType < AOT_AAC_MAIN || config.ObjectType > AOT_AAC_LTP ||
+		config.SampleRateIndex >= len(SampleRates) ||
+		config.ChannelConfig == 0 || config.ChannelConfig > 7 {
+		return nil, ErrNoADTS
+	}
+
+	return &ADTSWriter{w: w, header: ADTSHeader{
+		ProtectionAbsent: true,
+		Profile:          config.ObjectType - 1,
+		SampleRateIndex:  config.SampleRateIndex,
+		ChannelConfig:    config.ChannelConfig,
+		BufferFullness:   adtsFullnessVBR,
+		RawBlocks:        1,
+	}}, nil
+}
+
+// WriteFrame writes one raw_data_block as an ADTS frame.
+func (aw *ADTSWriter) WriteFrame(raw []byte) error {
+	h := aw.header
+	h.FrameLength = h.Size() + len(raw)
+	if h.FrameLength > adtsMaxFrameSize {
+		return ErrInvalidADTS
+	}
+
+	if _, err := aw.w.Write(h.Bytes()); err != nil {
+		return err
+	}
+
+	_, err := aw.w.Write(raw)
+	return err
+}
+
+// ADTSFrame is one raw_data_block read back from an ADTS stream, ready to be
+// an FLV or MP4 sample. The blocks of a frame share its header.
+type ADTSFrame struct {
+	Header *ADTSHeader
+	Data   []byte
+}
+
+// ADTSReader splits an ADTS stream into raw data blocks.
+type ADTSReader struct {
+	r       io.Reader
+	buf     [ADTSHeaderSize + 4*ADTSCRCSize]byte
+	pending []*ADTSFrame // the other blocks of the last frame
+}
+
+func NewADTSReader(r io.Reader) *ADTSReader {
+	return &ADTSReader{r: r}
+}
+
+// ReadFrame returns the next raw data block, io.EOF at the end of the stream
+// and io.ErrUnexpectedEOF for a truncated frame. The blocks of a frame are
+// located with its raw_data_block_position fields, a frame of several
+// blocks without them is skipped with ErrADTSBlocks.
+func (ar *ADTSReader) ReadFrame() (*ADTSFrame, error) {
+	if len(ar.pending) > 0 {
+		frame := ar.pending[0]
+		ar.pending = ar.pending[1:]
+		return frame, nil
+	}
+
+	if _, err := io.ReadFull(ar.r, ar.buf[:ADTSHeaderSize]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	h, err := ParseADTSHeader(ar.buf[:ADTSHeaderSize])
+	if err == io.ErrUnexpectedEOF {
+		n := ADTSHeaderSize + ADTSCRCSize*(int(ar.buf[6]&0x03)+1)
+		if _, err := io.ReadFull(ar.r, ar.buf[ADTSHeaderSize:n]); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		h, err = ParseADTSHeader(ar.buf[:n])
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, h.FrameLength-h.Size())
+	if _, err := io.ReadFull(ar.r, data); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if h.RawBlocks == 1 {
+		return &ADTSFrame{Header: h, Data: data}, nil
+	}
+
+	if h.ProtectionAbsent {
+		return nil, ErrADTSBlocks
+	}
+
+	// positions count from the first block, every block ends in its CRC
+	start := 0
+	for i := 0; i < h.RawBlocks; i++ {
+		end := len(data)
+		if i < len(h.BlockPositions) {
+			end = h.BlockPositions[i]
+		}
+
+		if end-ADTSCRCSize <= start || end > len(data) {
+			ar.pending = nil
+			return nil, ErrInvalidADTS
+		}
+
+		ar.pending = append(ar.pending, &ADTSFrame{Header: h, Data: data[start : end-ADTSCRCSize]})
+		start = end
+	}
+
+	return ar.ReadFrame()
+}
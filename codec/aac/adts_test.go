@@ -0,0 +1,135 @@
+package aac
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestADTSRoundTrip(t *testing.T) {
+	asc := []byte{0x11, 0x90}
+	config, err := ParseAudioSpecificConfig(asc)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	w, err := NewADTSWriter(&buf, config)
+	assert.Nil(t, err)
+	assert.Nil(t, w.WriteFrame([]byte{0x21, 0x10, 0x04}))
+	assert.Nil(t, w.WriteFrame([]byte{0x21}))
+
+	// LC, 48 kHz, stereo, 10 bytes, VBR
+	assert.Equal(t, []byte{0xff, 0xf1, 0x4c, 0x80, 0x01, 0x5f, 0xfc}, buf.Bytes()[:ADTSHeaderSize])
+
+	r := NewADTSReader(&buf)
+	frame, err := r.ReadFrame()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x21, 0x10, 0x04}, frame.Data)
+
+	out, err := frame.Header.Config().Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, asc, out)
+
+	frame, err = r.ReadFrame()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x21}, frame.Data)
+
+	_, err = r.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestADTSReadCRC(t *testing.T) {
+	raw := []byte{0x21, 0x10, 0x04, 0x60}
+	h := &ADTSHeader{Profile: 1, SampleRateIndex: 4, ChannelConfig: 2, BufferFullness: adtsFullnessVBR,
+		RawBlocks: 1, CRC: 0x1234}
+	h.FrameLength = h.Size() + len(raw)
+	data := append(h.Bytes(), raw...)
+	assert.Equal(t, ADTSHeaderSize+ADTSCRCSize+len(raw), len(data))
+
+	frame, err := NewADTSReader(bytes.NewReader(data)).ReadFrame()
+	assert.Nil(t, err)
+	assert.False(t, frame.Header.ProtectionAbsent)
+	assert.Equal(t, 0x1234, frame.Header.CRC)
+	assert.Equal(t, raw, frame.Data)
+
+	_, err = NewADTSReader(bytes.NewReader(data[:10])).ReadFrame()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestADTSReadBlocks(t *testing.T) {
+	// three blocks with CRC, each followed by its own CRC word
+	h := &ADTSHeader{Profile: 1, SampleRateIndex: 3, ChannelConfig: 2, BufferFullness: adtsFullnessVBR,
+		RawBlocks: 3, BlockPositions: []int{4, 7}, CRC: 0xabcd}
+	blocks := []byte{0x21, 0x10, 0xee, 0xee, 0x21, 0xee, 0xee, 0x21, 0x00, 0x04, 0xee, 0xee}
+	h.FrameLength = h.Size() + len(blocks)
+	data := append(h.Bytes(), blocks...)
+	assert.Equal(t, ADTSHeaderSize+3*ADTSCRCSize, h.Size())
+
+	// several blocks without CRC cannot be split
+	unprotected := &ADTSHeader{ProtectionAbsent: true, Profile: 1, SampleRateIndex: 3, ChannelConfig: 2, RawBlocks: 2}
+	unprotected.FrameLength = unprotected.Size() + 2
+	data = append(data, append(unprotected.Bytes(), 0x21, 0x21)...)
+
+	var buf bytes.Buffer
+	w, err := NewADTSWriter(&buf, &AudioSpecificConfig{ObjectType: AOT_AAC_LC, SampleRateIndex: 3, ChannelConfig: 2})
+	assert.Nil(t, err)
+	assert.Nil(t, w.WriteFrame([]byte{0x21, 0x01}))
+	data = append(data, buf.Bytes()...)
+
+	r := NewADTSReader(bytes.NewReader(data))
+	for _, want := range [][]byte{{0x21, 0x10}, {0x21}, {0x21, 0x00, 0x04}} {
+		frame, err := r.ReadFrame()
+		assert.Nil(t, err)
+		assert.Equal(t, want, frame.Data)
+		assert.Equal(t, []int{4, 7}, frame.Header.BlockPositions)
+		assert.Equal(t, 0xabcd, frame.Header.CRC)
+	}
+
+	_, err = r.ReadFrame()
+	assert.Equal(t, ErrADTSBlocks, err)
+
+	frame, err := r.ReadFrame()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x21, 0x01}, frame.Data)
+
+	_, err = r.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+
+	// a position past the frame
+	h.BlockPositions[1] = 20
+	_, err = NewADTSReader(bytes.NewReader(append(h.Bytes(), blocks...))).ReadFrame()
+	assert.Equal(t, ErrInvalidADTS, err)
+}
+
+func TestADTSErrors(t *testing.T) {
+	_, err := ParseADTSHeader([]byte{0xff, 0xe1, 0x4c, 0x80, 0x01, 0x5f, 0xfc})
+	assert.Equal(t, ErrInvalidADTS, err)
+
+	_, err = NewADTSWriter(&bytes.Buffer{}, &AudioSpecificConfig{ObjectType: AOT_ER_AAC_LD, ChannelConfig: 2})
+	assert.Equal(t, ErrNoADTS, err)
+
+	// HE-AAC goes out as its LC core
+	config, err := ParseAudioSpecificConfig([]byte{0x2b, 0x11, 0x88, 0x00})
+	assert.Nil(t, err)
+	_, err = NewADTSWriter(&bytes.Buffer{}, config)
+	assert.Nil(t, err)
+
+	out, err := config.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x2b, 0x11, 0x88, 0x00}, out)
+
+	// extensionFlag set
+	config, err = ParseAudioSpecificConfig([]byte{0x11, 0x91, 0x00})
+	assert.Nil(t, err)
+	assert.True(t, config.ExtensionFlag)
+	out, err = config.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x11, 0x91, 0x00}, out)
+
+	// layerNr and epConfig are not kept
+	for _, aot := range []int{AOT_AAC_SCALABLE, AOT_ER_AAC_LC, AOT_ER_AAC_LD} {
+		_, err = (&AudioSpecificConfig{ObjectType: aot, SampleRateIndex: 3, ChannelConfig: 2}).Bytes()
+		assert.Equal(t, ErrUnsupported, err)
+	}
+}
@@ -0,0 +1,95 @@
+package amf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Go representation of AMF values:
+//
+//	number, AMF3 double      float64
+//	AMF3 integer             int
+//	boolean                  bool
+//	string, long string      string
+//	null                     nil
+//	undefined                Undefined
+//	object                   Object
+//	typed object             *TypedObject
+//	ECMA array               ECMAArray
+//	strict array             []interface{}
+//	date                     time.Time
+//	xml document             XMLDocument
+//	AMF3 byte array          []byte
+//	AMF3 vectors             []int32, []uint32, []float64, *ObjectVector
+//
+// An AMF0 avmplus marker is transparent when decoding, the AMF3 value it
+// wraps is returned. Wrap a value in AVMPlus to encode it that way.
+
+type Undefined struct{}
+
+type Object map[string]interface{}
+
+type ECMAArray map[string]interface{}
+
+type TypedObject struct {
+	ClassName string
+	Object    Object
+}
+
+type XMLDocument string
+
+type ObjectVector struct {
+	TypeName string
+	Fixed    bool
+	Items    []interface{}
+}
+
+type AVMPlus struct {
+	Value interface{}
+}
+
+var (
+	ErrInvalidReference = errors.New("amf: invalid reference")
+	ErrUnsupported      = errors.New("amf: unsupported type")
+	ErrTooDeep          = errors.New("amf: nesting too deep")
+)
+
+// MarkerError reports an unknown type marker.
+type MarkerError struct {
+	Marker byte
+	Offset int
+}
+
+func (e *MarkerError) Error() string {
+	return fmt.Sprintf("amf: unknown marker 0x%02x at offset %d", e.Marker, e.Offset)
+}
+
+const maxDepth = 64
+
+// Decode decodes every AMF0 value in data.
+func Decode(data []byte) ([]interface{}, error) {
+	d := NewDecoder(data)
+	values := make([]interface{}, 0)
+	for d.Len() > 0 {
+		v, err := d.Decode()
+		if err != nil {
+			return values, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// Encode encodes values as a sequence of AMF0 values.
+func Encode(values ...interface{}) ([]byte, error) {
+	e := NewEncoder()
+	for _, v := range values {
+		if err := e.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.Bytes(), nil
+}
@@ -0,0 +1,415 @@
+package amf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	AMF0_NUMBER       = 0x00
+	AMF0_BOOLEAN      = 0x01
+	AMF0_STRING       = 0x02
+	AMF0_OBJECT       = 0x03
+	AMF0_MOVIECLIP    = 0x04
+	AMF0_NULL         = 0x05
+	AMF0_UNDEFINED    = 0x06
+	AMF0_REFERENCE    = 0x07
+	AMF0_ECMA_ARRAY   = 0x08
+	AMF0_OBJECT_END   = 0x09
+	AMF0_STRICT_ARRAY = 0x0a
+	AMF0_DATE         = 0x0b
+	AMF0_LONG_STRING  = 0x0c
+	AMF0_UNSUPPORTED  = 0x0d
+	AMF0_RECORDSET    = 0x0e
+	AMF0_XML_DOCUMENT = 0x0f
+	AMF0_TYPED_OBJECT = 0x10
+	AMF0_AVMPLUS      = 0x11
+)
+
+// Decoder reads AMF0 and AMF3 values from a byte slice, keeping the
+// reference tables of both formats.
+type Decoder struct {
+	data  []byte
+	pos   int
+	depth int
+
+	refs    []interface{} // AMF0 complex objects
+	strings []string      // AMF3 string table
+	objects []interface{} // AMF3 object table
+	traits  []*traits3
+}
+
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Len returns the number of bytes left.
+func (d *Decoder) Len() int { return len(d.data) - d.pos }
+
+// Offset returns the number of bytes consumed.
+func (d *Decoder) Offset() int { return d.pos }
+
+func (d *Decoder) read(n int) ([]byte, error) {
+	if n < 0 || d.Len() < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *Decoder) readU8() (byte, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (d *Decoder) readU16() (int, error) {
+	b, err := d.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(b)), nil
+}
+
+func (d *Decoder) readU32() (uint32, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+func (d *Decoder) readDouble() (float64, error) {
+	b, err := d.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+}
+
+func (d *Decoder) readUTF8(long bool) (string, error) {
+	var n int
+	var err error
+	if long {
+		var u uint32
+		u, err = d.readU32()
+		n = int(u)
+	} else {
+		n, err = d.readU16()
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	b, err := d.read(n)
+	return string(b), err
+}
+
+// Decode reads one AMF0 value.
+func (d *Decoder) Decode() (interface{}, error) {
+	if d.depth >= maxDepth {
+		return nil, ErrTooDeep
+	}
+
+	d.depth++
+	defer func() { d.depth-- }()
+
+	offset := d.pos
+	marker, err := d.readU8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch marker {
+	case AMF0_NUMBER:
+		return d.readDouble()
+	case AMF0_BOOLEAN:
+		b, err := d.readU8()
+		return b != 0, err
+	case AMF0_STRING:
+		return d.readUTF8(false)
+	case AMF0_LONG_STRING:
+		return d.readUTF8(true)
+	case AMF0_XML_DOCUMENT:
+		s, err := d.readUTF8(true)
+		return XMLDocument(s), err
+	case AMF0_NULL, AMF0_UNSUPPORTED:
+		return nil, nil
+	case AMF0_UNDEFINED:
+		return Undefined{}, nil
+	case AMF0_OBJECT:
+		obj := Object{}
+		d.refs = append(d.refs, obj)
+		return obj, d.readProperties(obj, false)
+	case AMF0_TYPED_OBJECT:
+		name, err := d.readUTF8(false)
+		if err != nil {
+			return nil, err
+		}
+
+		obj := &TypedObject{ClassName: name, Object: Object{}}
+		d.refs = append(d.refs, obj)
+		return obj, d.readProperties(obj.Object, false)
+	case AMF0_ECMA_ARRAY:
+		// the associative count is only a hint, the end marker terminates
+		if _, err := d.readU32(); err != nil {
+			return nil, err
+		}
+
+		arr := ECMAArray{}
+		d.refs = append(d.refs, arr)
+		return arr, d.readProperties(arr, true)
+	case AMF0_STRICT_ARRAY:
+		n, err := d.readU32()
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(n) > int64(d.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		arr := make([]interface{}, n)
+		d.refs = append(d.refs, arr)
+		for i := range arr {
+			if arr[i], err = d.Decode(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case AMF0_DATE:
+		ms, err := d.readDouble()
+		if err != nil {
+			return nil, err
+		}
+
+		// the time zone is reserved and should be 0
+		if _, err := d.readU16(); err != nil {
+			return nil, err
+		}
+		return msToTime(ms), nil
+	case AMF0_REFERENCE:
+		idx, err := d.readU16()
+		if err != nil {
+			return nil, err
+		}
+
+		if idx >= len(d.refs) {
+			return nil, ErrInvalidReference
+		}
+		return d.refs[idx], nil
+	case AMF0_AVMPLUS:
+		d.strings, d.objects, d.traits = nil, nil, nil
+		return d.DecodeAMF3()
+	case AMF0_MOVIECLIP, AMF0_RECORDSET:
+		return nil, ErrUnsupported
+	}
+
+	return nil, &MarkerError{Marker: marker, Offset: offset}
+}
+
+// readProperties reads key/value pairs up to the object end marker. ECMA
+// arrays written by some muxers lack the end marker at the end of the data.
+func (d *Decoder) readProperties(obj map[string]interface{}, lenient bool) error {
+	for {
+		if lenient && d.Len() == 0 {
+			return nil
+		}
+
+		key, err := d.readUTF8(false)
+		if err != nil {
+			return err
+		}
+
+		if len(key) == 0 {
+			if lenient && d.Len() == 0 {
+				return nil
+			}
+
+			marker, err := d.readU8()
+			if err != nil {
+				return err
+			}
+
+			if marker != AMF0_OBJECT_END {
+				return &MarkerError{Marker: marker, Offset: d.pos - 1}
+			}
+			return nil
+		}
+
+		if obj[key], err = d.Decode(); err != nil {
+			return err
+		}
+	}
+}
+
+func msToTime(ms float64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+}
+
+func timeToMs(t time.Time) float64 {
+	return float64(t.UnixNano() / int64(time.Millisecond))
+}
+
+// Encoder writes AMF0 and AMF3 values. Objects are always written inline,
+// AMF3 strings use the string reference table.
+type Encoder struct {
+	buf     bytes.Buffer
+	depth   int
+	strings map[string]int
+}
+
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+func (e *Encoder) Bytes() []byte { return e.buf.Bytes() }
+
+func (e *Encoder) writeU16(v int) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	e.buf.Write(b[:])
+}
+
+func (e *Encoder) writeU32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
+
+func (e *Encoder) writeDouble(v float64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+	e.buf.Write(b[:])
+}
+
+func (e *Encoder) writeUTF8(s string) {
+	e.writeU16(len(s))
+	e.buf.WriteString(s)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// toFloat converts Go numeric types to float64.
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+
+	return 0, false
+}
+
+// Encode writes one AMF0 value.
+func (e *Encoder) Encode(v interface{}) error {
+	if e.depth >= maxDepth {
+		return ErrTooDeep
+	}
+
+	e.depth++
+	defer func() { e.depth-- }()
+
+	switch val := v.(type) {
+	case nil:
+		e.buf.WriteByte(AMF0_NULL)
+	case Undefined:
+		e.buf.WriteByte(AMF0_UNDEFINED)
+	case bool:
+		e.buf.WriteByte(AMF0_BOOLEAN)
+		if val {
+			e.buf.WriteByte(1)
+		} else {
+			e.buf.WriteByte(0)
+		}
+	case string:
+		if len(val) > 0xffff {
+			e.buf.WriteByte(AMF0_LONG_STRING)
+			e.writeU32(uint32(len(val)))
+			e.buf.WriteString(val)
+		} else {
+			e.buf.WriteByte(AMF0_STRING)
+			e.writeUTF8(val)
+		}
+	case XMLDocument:
+		e.buf.WriteByte(AMF0_XML_DOCUMENT)
+		e.writeU32(uint32(len(val)))
+		e.buf.WriteString(string(val))
+	case time.Time:
+		e.buf.WriteByte(AMF0_DATE)
+		e.writeDouble(timeToMs(val))
+		e.writeU16(0)
+	case Object:
+		e.buf.WriteByte(AMF0_OBJECT)
+		return e.writeProperties(val)
+	case map[string]interface{}:
+		e.buf.WriteByte(AMF0_OBJECT)
+		return e.writeProperties(val)
+	case *TypedObject:
+		e.buf.WriteByte(AMF0_TYPED_OBJECT)
+		e.writeUTF8(val.ClassName)
+		return e.writeProperties(val.Object)
+	case ECMAArray:
+		e.buf.WriteByte(AMF0_ECMA_ARRAY)
+		e.writeU32(uint32(len(val)))
+		return e.writeProperties(val)
+	case []interface{}:
+		e.buf.WriteByte(AMF0_STRICT_ARRAY)
+		e.writeU32(uint32(len(val)))
+		for _, item := range val {
+			if err := e.Encode(item); err != nil {
+				return err
+			}
+		}
+	case AVMPlus:
+		e.buf.WriteByte(AMF0_AVMPLUS)
+		e.strings = nil
+		return e.EncodeAMF3(val.Value)
+	default:
+		f, ok := toFloat(v)
+		if !ok {
+			return fmt.Errorf("%w: cannot encode %T", ErrUnsupported, v)
+		}
+
+		e.buf.WriteByte(AMF0_NUMBER)
+		e.writeDouble(f)
+	}
+
+	return nil
+}
+
+func (e *Encoder) writeProperties(obj map[string]interface{}) error {
+	for _, key := range sortedKeys(obj) {
+		e.writeUTF8(key)
+		if err := e.Encode(obj[key]); err != nil {
+			return err
+		}
+	}
+
+	e.writeU16(0)
+	e.buf.WriteByte(AMF0_OBJECT_END)
+	return nil
+}
@@ -0,0 +1,567 @@
+package amf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	AMF3_UNDEFINED     = 0x00
+	AMF3_NULL          = 0x01
+	AMF3_FALSE         = 0x02
+	AMF3_TRUE          = 0x03
+	AMF3_INTEGER       = 0x04
+	AMF3_DOUBLE        = 0x05
+	AMF3_STRING        = 0x06
+	AMF3_XML_DOC       = 0x07
+	AMF3_DATE          = 0x08
+	AMF3_ARRAY         = 0x09
+	AMF3_OBJECT        = 0x0a
+	AMF3_XML           = 0x0b
+	AMF3_BYTE_ARRAY    = 0x0c
+	AMF3_VECTOR_INT    = 0x0d
+	AMF3_VECTOR_UINT   = 0x0e
+	AMF3_VECTOR_DOUBLE = 0x0f
+	AMF3_VECTOR_OBJECT = 0x10
+	AMF3_DICTIONARY    = 0x11
+)
+
+const (
+	amf3IntMin = -(1 << 28)
+	amf3IntMax = 1<<28 - 1
+)
+
+type traits3 struct {
+	className string
+	dynamic   bool
+	members   []string
+}
+
+// readU29 reads a variable length 29-bit unsigned integer.
+func (d *Decoder) readU29() (int, error) {
+	var v int
+	for i := 0; i < 4; i++ {
+		b, err := d.readU8()
+		if err != nil {
+			return 0, err
+		}
+
+		if i == 3 {
+			return v<<8 | int(b), nil
+		}
+
+		v = v<<7 | int(b&0x7f)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	return v, nil
+}
+
+// readRef reads a U29 header, reporting whether it is an inline value. For
+// references the table index is returned, otherwise the remaining bits.
+func (d *Decoder) readRef() (int, bool, error) {
+	h, err := d.readU29()
+	return h >> 1, h&0x01 == 1, err
+}
+
+func (d *Decoder) objectRef(idx int) (interface{}, error) {
+	if idx >= len(d.objects) {
+		return nil, ErrInvalidReference
+	}
+	return d.objects[idx], nil
+}
+
+func (d *Decoder) readString3() (string, error) {
+	v, inline, err := d.readRef()
+	if err != nil {
+		return "", err
+	}
+
+	if !inline {
+		if v >= len(d.strings) {
+			return "", ErrInvalidReference
+		}
+		return d.strings[v], nil
+	}
+
+	b, err := d.read(v)
+	if err != nil {
+		return "", err
+	}
+
+	s := string(b)
+	if len(s) > 0 {
+		d.strings = append(d.strings, s)
+	}
+	return s, nil
+}
+
+// DecodeAMF3 reads one AMF3 value.
+func (d *Decoder) DecodeAMF3() (interface{}, error) {
+	if d.depth >= maxDepth {
+		return nil, ErrTooDeep
+	}
+
+	d.depth++
+	defer func() { d.depth-- }()
+
+	offset := d.pos
+	marker, err := d.readU8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch marker {
+	case AMF3_UNDEFINED:
+		return Undefined{}, nil
+	case AMF3_NULL:
+		return nil, nil
+	case AMF3_FALSE:
+		return false, nil
+	case AMF3_TRUE:
+		return true, nil
+	case AMF3_INTEGER:
+		v, err := d.readU29()
+		if v&0x10000000 != 0 {
+			v -= 1 << 29
+		}
+		return v, err
+	case AMF3_DOUBLE:
+		return d.readDouble()
+	case AMF3_STRING:
+		return d.readString3()
+	case AMF3_XML_DOC, AMF3_XML:
+		v, inline, err := d.readRef()
+		if err != nil {
+			return nil, err
+		}
+
+		if !inline {
+			return d.objectRef(v)
+		}
+
+		b, err := d.read(v)
+		if err != nil {
+			return nil, err
+		}
+
+		doc := XMLDocument(b)
+		d.objects = append(d.objects, doc)
+		return doc, nil
+	case AMF3_DATE:
+		v, inline, err := d.readRef()
+		if err != nil {
+			return nil, err
+		}
+
+		if !inline {
+			return d.objectRef(v)
+		}
+
+		ms, err := d.readDouble()
+		if err != nil {
+			return nil, err
+		}
+
+		t := msToTime(ms)
+		d.objects = append(d.objects, t)
+		return t, nil
+	case AMF3_ARRAY:
+		return d.readArray3()
+	case AMF3_OBJECT:
+		return d.readObject3()
+	case AMF3_BYTE_ARRAY:
+		v, inline, err := d.readRef()
+		if err != nil {
+			return nil, err
+		}
+
+		if !inline {
+			return d.objectRef(v)
+		}
+
+		b, err := d.read(v)
+		if err != nil {
+			return nil, err
+		}
+
+		data := append([]byte(nil), b...)
+		d.objects = append(d.objects, data)
+		return data, nil
+	case AMF3_VECTOR_INT, AMF3_VECTOR_UINT, AMF3_VECTOR_DOUBLE, AMF3_VECTOR_OBJECT:
+		return d.readVector3(marker)
+	case AMF3_DICTIONARY:
+		return nil, ErrUnsupported
+	}
+
+	return nil, &MarkerError{Marker: marker, Offset: offset}
+}
+
+func (d *Decoder) readArray3() (interface{}, error) {
+	n, inline, err := d.readRef()
+	if err != nil {
+		return nil, err
+	}
+
+	if !inline {
+		return d.objectRef(n)
+	}
+
+	// reserve the table slot before the members may reference it
+	idx := len(d.objects)
+	d.objects = append(d.objects, nil)
+
+	assoc := ECMAArray{}
+	for {
+		key, err := d.readString3()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(key) == 0 {
+			break
+		}
+
+		if assoc[key], err = d.DecodeAMF3(); err != nil {
+			return nil, err
+		}
+	}
+
+	if n > d.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	dense := make([]interface{}, n)
+	for i := range dense {
+		if dense[i], err = d.DecodeAMF3(); err != nil {
+			return nil, err
+		}
+	}
+
+	var arr interface{} = dense
+	if len(assoc) > 0 {
+		for i, v := range dense {
+			assoc[fmt.Sprint(i)] = v
+		}
+		arr = assoc
+	}
+
+	d.objects[idx] = arr
+	return arr, nil
+}
+
+func (d *Decoder) readObject3() (interface{}, error) {
+	h, err := d.readU29()
+	if err != nil {
+		return nil, err
+	}
+
+	if h&0x01 == 0 {
+		return d.objectRef(h >> 1)
+	}
+
+	var t *traits3
+	if h&0x02 == 0 {
+		idx := h >> 2
+		if idx >= len(d.traits) {
+			return nil, ErrInvalidReference
+		}
+		t = d.traits[idx]
+	} else {
+		if h&0x04 != 0 {
+			// externalizable objects need the class implementation
+			return nil, ErrUnsupported
+		}
+
+		t = &traits3{dynamic: h&0x08 != 0}
+		if t.className, err = d.readString3(); err != nil {
+			return nil, err
+		}
+
+		count := h >> 4
+		if count > d.Len() {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		for i := 0; i < count; i++ {
+			name, err := d.readString3()
+			if err != nil {
+				return nil, err
+			}
+			t.members = append(t.members, name)
+		}
+
+		d.traits = append(d.traits, t)
+	}
+
+	obj := Object{}
+	var v interface{} = obj
+	if len(t.className) > 0 {
+		v = &TypedObject{ClassName: t.className, Object: obj}
+	}
+	d.objects = append(d.objects, v)
+
+	for _, name := range t.members {
+		if obj[name], err = d.DecodeAMF3(); err != nil {
+			return nil, err
+		}
+	}
+
+	for t.dynamic {
+		key, err := d.readString3()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(key) == 0 {
+			break
+		}
+
+		if obj[key], err = d.DecodeAMF3(); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+func (d *Decoder) readVector3(marker byte) (interface{}, error) {
+	n, inline, err := d.readRef()
+	if err != nil {
+		return nil, err
+	}
+
+	if !inline {
+		return d.objectRef(n)
+	}
+
+	fixed, err := d.readU8()
+	if err != nil {
+		return nil, err
+	}
+
+	size := 4
+	if marker == AMF3_VECTOR_DOUBLE {
+		size = 8
+	} else if marker == AMF3_VECTOR_OBJECT {
+		size = 1
+	}
+
+	if n > d.Len()/size {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var vec interface{}
+	switch marker {
+	case AMF3_VECTOR_INT:
+		b, _ := d.read(n * 4)
+		items := make([]int32, n)
+		for i := range items {
+			items[i] = int32(binary.BigEndian.Uint32(b[i*4:]))
+		}
+		vec = items
+	case AMF3_VECTOR_UINT:
+		b, _ := d.read(n * 4)
+		items := make([]uint32, n)
+		for i := range items {
+			items[i] = binary.BigEndian.Uint32(b[i*4:])
+		}
+		vec = items
+	case AMF3_VECTOR_DOUBLE:
+		b, _ := d.read(n * 8)
+		items := make([]float64, n)
+		for i := range items {
+			items[i] = math.Float64frombits(binary.BigEndian.Uint64(b[i*8:]))
+		}
+		vec = items
+	default:
+		ov := &ObjectVector{Fixed: fixed != 0, Items: make([]interface{}, n)}
+		if ov.TypeName, err = d.readString3(); err != nil {
+			return nil, err
+		}
+
+		d.objects = append(d.objects, ov)
+		for i := range ov.Items {
+			if ov.Items[i], err = d.DecodeAMF3(); err != nil {
+				return nil, err
+			}
+		}
+		return ov, nil
+	}
+
+	d.objects = append(d.objects, vec)
+	return vec, nil
+}
+
+func (e *Encoder) writeU29(v int) {
+	v &= 0x1fffffff
+	switch {
+	case v < 0x80:
+		e.buf.WriteByte(byte(v))
+	case v < 0x4000:
+		e.buf.Write([]byte{byte(v>>7) | 0x80, byte(v & 0x7f)})
+	case v < 0x200000:
+		e.buf.Write([]byte{byte(v>>14) | 0x80, byte(v>>7) | 0x80, byte(v & 0x7f)})
+	default:
+		e.buf.Write([]byte{byte(v>>22) | 0x80, byte(v>>15) | 0x80, byte(v>>8) | 0x80, byte(v)})
+	}
+}
+
+func (e *Encoder) writeString3(s string) {
+	if len(s) == 0 {
+		e.buf.WriteByte(0x01)
+		return
+	}
+
+	if idx, ok := e.strings[s]; ok {
+		e.writeU29(idx << 1)
+		return
+	}
+
+	if e.strings == nil {
+		e.strings = make(map[string]int)
+	}
+
+	e.strings[s] = len(e.strings)
+	e.writeU29(len(s)<<1 | 0x01)
+	e.buf.WriteString(s)
+}
+
+// EncodeAMF3 writes one AMF3 value.
+func (e *Encoder) EncodeAMF3(v interface{}) error {
+	if e.depth >= maxDepth {
+		return ErrTooDeep
+	}
+
+	e.depth++
+	defer func() { e.depth-- }()
+
+	switch val := v.(type) {
+	case nil:
+		e.buf.WriteByte(AMF3_NULL)
+	case Undefined:
+		e.buf.WriteByte(AMF3_UNDEFINED)
+	case bool:
+		if val {
+			e.buf.WriteByte(AMF3_TRUE)
+		} else {
+			e.buf.WriteByte(AMF3_FALSE)
+		}
+	case string:
+		e.buf.WriteByte(AMF3_STRING)
+		e.writeString3(val)
+	case XMLDocument:
+		e.buf.WriteByte(AMF3_XML_DOC)
+		e.writeU29(len(val)<<1 | 0x01)
+		e.buf.WriteString(string(val))
+	case time.Time:
+		e.buf.WriteByte(AMF3_DATE)
+		e.writeU29(0x01)
+		e.writeDouble(timeToMs(val))
+	case []byte:
+		e.buf.WriteByte(AMF3_BYTE_ARRAY)
+		e.writeU29(len(val)<<1 | 0x01)
+		e.buf.Write(val)
+	case []interface{}:
+		e.buf.WriteByte(AMF3_ARRAY)
+		e.writeU29(len(val)<<1 | 0x01)
+		e.writeString3("")
+		for _, item := range val {
+			if err := e.EncodeAMF3(item); err != nil {
+				return err
+			}
+		}
+	case ECMAArray:
+		e.buf.WriteByte(AMF3_ARRAY)
+		e.writeU29(0x01)
+		return e.writeDynamic3(val)
+	case Object:
+		return e.writeObject3("", val)
+	case map[string]interface{}:
+		return e.writeObject3("", val)
+	case *TypedObject:
+		return e.writeObject3(val.ClassName, val.Object)
+	case []int32:
+		e.buf.WriteByte(AMF3_VECTOR_INT)
+		e.writeU29(len(val)<<1 | 0x01)
+		e.buf.WriteByte(0)
+		for _, item := range val {
+			e.writeU32(uint32(item))
+		}
+	case []uint32:
+		e.buf.WriteByte(AMF3_VECTOR_UINT)
+		e.writeU29(len(val)<<1 | 0x01)
+		e.buf.WriteByte(0)
+		for _, item := range val {
+			e.writeU32(item)
+		}
+	case []float64:
+		e.buf.WriteByte(AMF3_VECTOR_DOUBLE)
+		e.writeU29(len(val)<<1 | 0x01)
+		e.buf.WriteByte(0)
+		for _, item := range val {
+			e.writeDouble(item)
+		}
+	case *ObjectVector:
+		e.buf.WriteByte(AMF3_VECTOR_OBJECT)
+		e.writeU29(len(val.Items)<<1 | 0x01)
+		if val.Fixed {
+			e.buf.WriteByte(1)
+		} else {
+			e.buf.WriteByte(0)
+		}
+
+		e.writeString3(val.TypeName)
+		for _, item := range val.Items {
+			if err := e.EncodeAMF3(item); err != nil {
+				return err
+			}
+		}
+	default:
+		f, ok := toFloat(v)
+		if !ok {
+			return fmt.Errorf("%w: cannot encode %T", ErrUnsupported, v)
+		}
+
+		switch v.(type) {
+		case float32, float64:
+		default:
+			if f >= amf3IntMin && f <= amf3IntMax {
+				e.buf.WriteByte(AMF3_INTEGER)
+				e.writeU29(int(f))
+				return nil
+			}
+		}
+
+		e.buf.WriteByte(AMF3_DOUBLE)
+		e.writeDouble(f)
+	}
+
+	return nil
+}
+
+// writeObject3 writes an object with inline dynamic traits and no sealed
+// members.
+func (e *Encoder) writeObject3(className string, obj map[string]interface{}) error {
+	e.buf.WriteByte(AMF3_OBJECT)
+	e.writeU29(0x0b)
+	e.writeString3(className)
+	return e.writeDynamic3(obj)
+}
+
+func (e *Encoder) writeDynamic3(obj map[string]interface{}) error {
+	for _, key := range sortedKeys(obj) {
+		e.writeString3(key)
+		if err := e.EncodeAMF3(obj[key]); err != nil {
+			return err
+		}
+	}
+
+	e.writeString3("")
+	return nil
+}
@@ -0,0 +1,113 @@
+package amf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAMF0RoundTrip(t *testing.T) {
+	date := time.Unix(1600000000, 0).UTC()
+	values := []interface{}{
+		"onMetaData",
+		ECMAArray{
+			"duration": 12.5,
+			"stereo":   true,
+			"encoder":  "Lavf58.45.100",
+			"keyframes": Object{
+				"times":         []interface{}{0.0, 2.0},
+				"filepositions": []interface{}{13.0, 4096.0},
+			},
+		},
+		nil,
+		Undefined{},
+		date,
+		&TypedObject{ClassName: "flash.Point", Object: Object{"x": 1.0}},
+		XMLDocument("<a/>"),
+	}
+
+	data, err := Encode(values...)
+	assert.Nil(t, err)
+
+	decoded, err := Decode(data)
+	assert.Nil(t, err)
+	assert.Equal(t, values, decoded)
+}
+
+func TestAMF0Reference(t *testing.T) {
+	// strict array holding an object and a reference to it
+	data := []byte{AMF0_STRICT_ARRAY, 0, 0, 0, 2,
+		AMF0_OBJECT, 0, 1, 'a', AMF0_BOOLEAN, 1, 0, 0, AMF0_OBJECT_END,
+		AMF0_REFERENCE, 0, 1}
+
+	values, err := Decode(data)
+	assert.Nil(t, err)
+	arr := values[0].([]interface{})
+	assert.Equal(t, Object{"a": true}, arr[1])
+
+	_, err = Decode([]byte{AMF0_REFERENCE, 0, 5})
+	assert.Equal(t, ErrInvalidReference, err)
+}
+
+func TestAMF0Errors(t *testing.T) {
+	_, err := Decode([]byte{AMF0_STRING, 0, 10, 'a'})
+	assert.NotNil(t, err)
+
+	_, err = Decode([]byte{0x42})
+	assert.IsType(t, &MarkerError{}, err)
+
+	// ECMA array missing its end marker
+	values, err := Decode([]byte{AMF0_ECMA_ARRAY, 0, 0, 0, 1, 0, 1, 'a', AMF0_NULL})
+	assert.Nil(t, err)
+	assert.Equal(t, ECMAArray{"a": nil}, values[0])
+}
+
+func TestAMF3RoundTrip(t *testing.T) {
+	value := AVMPlus{Value: Object{
+		"int":     -5,
+		"big":     1 << 30,
+		"double":  0.5,
+		"name":    "name",
+		"again":   "name",
+		"list":    []interface{}{1, "name", false},
+		"bytes":   []byte{1, 2, 3},
+		"vector":  []int32{-1, 2},
+		"typed":   &TypedObject{ClassName: "Foo", Object: Object{"name": nil}},
+		"assoc":   ECMAArray{"k": true},
+		"undef":   Undefined{},
+		"doubles": []float64{1.5},
+	}}
+
+	data, err := Encode(value)
+	assert.Nil(t, err)
+
+	decoded, err := Decode(data)
+	assert.Nil(t, err)
+
+	obj := decoded[0].(Object)
+	assert.Equal(t, -5, obj["int"])
+	assert.Equal(t, float64(1<<30), obj["big"])
+	assert.Equal(t, 0.5, obj["double"])
+	assert.Equal(t, "name", obj["again"])
+	assert.Equal(t, []interface{}{1, "name", false}, obj["list"])
+	assert.Equal(t, []byte{1, 2, 3}, obj["bytes"])
+	assert.Equal(t, []int32{-1, 2}, obj["vector"])
+	assert.Equal(t, &TypedObject{ClassName: "Foo", Object: Object{"name": nil}}, obj["typed"])
+	assert.Equal(t, ECMAArray{"k": true}, obj["assoc"])
+	assert.Equal(t, Undefined{}, obj["undef"])
+	assert.Equal(t, []float64{1.5}, obj["doubles"])
+}
+
+func TestAMF3SealedTraits(t *testing.T) {
+	// object with one sealed member "a", then a second object reusing the traits
+	data := []byte{AMF3_ARRAY, 0x05, 0x01,
+		AMF3_OBJECT, 0x13, 0x07, 'F', 'o', 'o', 0x03, 'a', AMF3_INTEGER, 0x01,
+		AMF3_OBJECT, 0x01, AMF3_INTEGER, 0x02}
+
+	v, err := NewDecoder(data).DecodeAMF3()
+	assert.Nil(t, err)
+	arr := v.([]interface{})
+	assert.Equal(t, &TypedObject{ClassName: "Foo", Object: Object{"a": 1}}, arr[0])
+	assert.Equal(t, &TypedObject{ClassName: "Foo", Object: Object{"a": 2}}, arr[1])
+}
@@ -0,0 +1,150 @@
+package av1
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// obu_type, AV1 6.2.2
+const (
+	OBU_SEQUENCE_HEADER        = 1
+	OBU_TEMPORAL_DELIMITER     = 2
+	OBU_FRAME_HEADER           = 3
+	OBU_TILE_GROUP             = 4
+	OBU_METADATA               = 5
+	OBU_FRAME                  = 6
+	OBU_REDUNDANT_FRAME_HEADER = 7
+	OBU_TILE_LIST              = 8
+	OBU_PADDING                = 15
+)
+
+var obuTypeMap = map[int]string{
+	OBU_SEQUENCE_HEADER:        "sequence_header",
+	OBU_TEMPORAL_DELIMITER:     "temporal_delimiter",
+	OBU_FRAME_HEADER:           "frame_header",
+	OBU_TILE_GROUP:             "tile_group",
+	OBU_METADATA:               "metadata",
+	OBU_FRAME:                  "frame",
+	OBU_REDUNDANT_FRAME_HEADER: "redundant_frame_header",
+	OBU_TILE_LIST:              "tile_list",
+	OBU_PADDING:                "padding",
+}
+
+var (
+	ErrInvalidOBU = errors.New("av1: invalid OBU")
+	ErrNoSequence = errors.New("av1: frame before the sequence header")
+)
+
+// OBU is one open bitstream unit, 5.3. Offset is its position in the
+// split data, Data holds it with the header and Payload the part after the
+// header and size field.
+type OBU struct {
+	Offset        int
+	Data          []byte
+	Type          int
+	ExtensionFlag int
+	HasSizeField  int
+	TemporalId    int
+	SpatialId     int
+	Payload       []byte
+}
+
+func (o *OBU) String() string {
+	typ := obuTypeMap[o.Type]
+	if len(typ) == 0 {
+		typ = "reserved"
+	}
+
+	return fmt.Sprintf("|type: %s|tid: %d|sid: %d|size: %d", typ, o.TemporalId, o.SpatialId, len(o.Payload))
+}
+
+// readLeb128 reads a leb128() value, 4.10.5, and returns it with the bytes
+// it took, 0 if data ends first or the value needs more than 8 bytes or 32
+// bits.
+func readLeb128(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < 8 && i < len(data); i++ {
+		value |= uint64(data[i]&0x7f) << uint(i*7)
+		if data[i]&0x80 == 0 {
+			if value > math.MaxUint32 {
+				return 0, 0
+			}
+			return value, i + 1
+		}
+	}
+
+	return 0, 0
+}
+
+// appendLeb128 appends v in the fewest leb128 bytes.
+func appendLeb128(data []byte, v uint64) []byte {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(data, b)
+		}
+		data = append(data, b|0x80)
+	}
+}
+
+// SplitOBUs splits OBUs in the low overhead bitstream format of 5.2, as
+// carried in av1C records and FLV or MP4 samples. An OBU without obu_size
+// extends to the end of data.
+func SplitOBUs(data []byte) ([]*OBU, error) {
+	var obus []*OBU
+	for pos := 0; pos < len(data); {
+		obu := &OBU{Offset: pos}
+		h := data[pos]
+		if h&0x80 != 0 { // obu_forbidden_bit
+			return nil, ErrInvalidOBU
+		}
+
+		obu.Type = int(h>>3) & 0x0f
+		obu.ExtensionFlag = int(h>>2) & 0x01
+		obu.HasSizeField = int(h>>1) & 0x01
+
+		start := pos + 1
+		if obu.ExtensionFlag == 1 {
+			if start >= len(data) {
+				return nil, ErrInvalidOBU
+			}
+
+			obu.TemporalId = int(data[start] >> 5)
+			obu.SpatialId = int(data[start]>>3) & 0x03
+			start++
+		}
+
+		end := len(data)
+		if obu.HasSizeField == 1 {
+			size, n := readLeb128(data[start:])
+			if n == 0 || size > uint64(len(data)-start-n) {
+				return nil, ErrInvalidOBU
+			}
+
+			start += n
+			end = start + int(size)
+		}
+
+		obu.Data = data[pos:end]
+		obu.Payload = data[start:end]
+		obus = append(obus, obu)
+		pos = end
+	}
+
+	return obus, nil
+}
+
+// AppendOBU appends an OBU with obu_size, the extension header is written if
+// the temporal or spatial id is set.
+func AppendOBU(data []byte, typ, temporalId, spatialId int, payload []byte) []byte {
+	if temporalId == 0 && spatialId == 0 {
+		data = append(data, byte(typ<<3|0x02))
+	} else {
+		data = append(data, byte(typ<<3|0x06), byte(temporalId<<5|spatialId<<3))
+	}
+
+	data = appendLeb128(data, uint64(len(payload)))
+	return append(data, payload...)
+}
@@ -0,0 +1,62 @@
+package av1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeb128(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, 1<<32 - 1} {
+		data := appendLeb128(nil, v)
+		got, n := readLeb128(data)
+		assert.Equal(t, v, got)
+		assert.Equal(t, len(data), n)
+	}
+
+	assert.Equal(t, []byte{0xac, 0x02}, appendLeb128(nil, 300))
+
+	// padded encodings are valid, values past 32 bits are not
+	v, n := readLeb128([]byte{0x81, 0x80, 0x00})
+	assert.Equal(t, uint64(1), v)
+	assert.Equal(t, 3, n)
+	_, n = readLeb128([]byte{0x80, 0x80, 0x80, 0x80, 0x10})
+	assert.Equal(t, 0, n)
+	_, n = readLeb128([]byte{0x80})
+	assert.Equal(t, 0, n)
+}
+
+func TestSplitOBUs(t *testing.T) {
+	data := AppendOBU(nil, OBU_TEMPORAL_DELIMITER, 0, 0, nil)
+	data = AppendOBU(data, OBU_FRAME, 2, 1, []byte{1, 2, 3})
+	assert.Equal(t, []byte{0x12, 0x00, 0x36, 0x48, 0x03, 1, 2, 3}, data)
+
+	// an OBU without obu_size takes the rest
+	data = append(data, OBU_PADDING<<3, 9, 9)
+
+	obus, err := SplitOBUs(data)
+	assert.Nil(t, err)
+	assert.Len(t, obus, 3)
+	assert.Equal(t, OBU_TEMPORAL_DELIMITER, obus[0].Type)
+	assert.Len(t, obus[0].Payload, 0)
+	assert.Equal(t, OBU_FRAME, obus[1].Type)
+	assert.Equal(t, 1, obus[1].ExtensionFlag)
+	assert.Equal(t, 2, obus[1].TemporalId)
+	assert.Equal(t, 1, obus[1].SpatialId)
+	assert.Equal(t, 2, obus[1].Offset)
+	assert.Equal(t, []byte{1, 2, 3}, obus[1].Payload)
+	assert.Equal(t, data[2:8], obus[1].Data)
+	assert.Equal(t, 0, obus[2].HasSizeField)
+	assert.Equal(t, []byte{9, 9}, obus[2].Payload)
+	assert.Equal(t, "|type: frame|tid: 2|sid: 1|size: 3", obus[1].String())
+
+	for _, bad := range [][]byte{
+		{0x92, 0x00},       // forbidden bit
+		{0x12, 0x02, 0x00}, // size past the end
+		{0x12, 0x80},       // truncated size
+		{0x16},             // missing extension
+	} {
+		_, err = SplitOBUs(bad)
+		assert.Equal(t, ErrInvalidOBU, err)
+	}
+}
@@ -0,0 +1,115 @@
+package av1
+
+import "errors"
+
+var ErrInvalidConfig = errors.New("av1: invalid AV1CodecConfigurationRecord")
+
+const av1cHeaderSize = 4
+
+// AV1Config is the AV1CodecConfigurationRecord of the AV1 ISOBMFF binding
+// 2.3.3, the AV1 sequence start of Enhanced FLV and the payload of the MP4
+// av1C box. ConfigOBUs holds the sequence header and metadata OBUs, if any.
+type AV1Config struct {
+	Marker                           int
+	Version                          int
+	SeqProfile                       int
+	SeqLevelIdx0                     int
+	SeqTier0                         int
+	HighBitdepth                     int
+	TwelveBit                        int
+	Monochrome                       int
+	ChromaSubsamplingX               int
+	ChromaSubsamplingY               int
+	ChromaSamplePosition             int
+	InitialPresentationDelayPresent  int
+	InitialPresentationDelayMinusOne int
+	ConfigOBUs                       []byte
+}
+
+// NewAV1Config builds a record from a sequence header OBU, which is kept as
+// its only config OBU.
+func NewAV1Config(obu []byte) (*AV1Config, error) {
+	obus, err := SplitOBUs(obu)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(obus) != 1 || obus[0].Type != OBU_SEQUENCE_HEADER {
+		return nil, ErrInvalidConfig
+	}
+
+	seq, err := DecodeSequenceHeader(obus[0].Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	cc := &seq.ColorConfig
+	op := seq.OperatingPoints[0]
+	return &AV1Config{
+		Marker:               1,
+		Version:              1,
+		SeqProfile:           seq.SeqProfile,
+		SeqLevelIdx0:         op.SeqLevelIdx,
+		SeqTier0:             op.SeqTier,
+		HighBitdepth:         cc.HighBitdepth,
+		TwelveBit:            cc.TwelveBit,
+		Monochrome:           cc.MonoChrome,
+		ChromaSubsamplingX:   cc.SubsamplingX,
+		ChromaSubsamplingY:   cc.SubsamplingY,
+		ChromaSamplePosition: cc.ChromaSamplePosition,
+		ConfigOBUs:           obu,
+	}, nil
+}
+
+// ParseAV1Config parses a record, ConfigOBUs shares data.
+func ParseAV1Config(data []byte) (*AV1Config, error) {
+	if len(data) < av1cHeaderSize || data[0]>>7 != 1 {
+		return nil, ErrInvalidConfig
+	}
+
+	c := &AV1Config{}
+	c.Marker = int(data[0] >> 7)
+	c.Version = int(data[0] & 0x7f)
+	c.SeqProfile = int(data[1] >> 5)
+	c.SeqLevelIdx0 = int(data[1] & 0x1f)
+	c.SeqTier0 = int(data[2] >> 7)
+	c.HighBitdepth = int(data[2]>>6) & 0x01
+	c.TwelveBit = int(data[2]>>5) & 0x01
+	c.Monochrome = int(data[2]>>4) & 0x01
+	c.ChromaSubsamplingX = int(data[2]>>3) & 0x01
+	c.ChromaSubsamplingY = int(data[2]>>2) & 0x01
+	c.ChromaSamplePosition = int(data[2] & 0x03)
+	c.InitialPresentationDelayPresent = int(data[3]>>4) & 0x01
+	if c.InitialPresentationDelayPresent == 1 {
+		c.InitialPresentationDelayMinusOne = int(data[3] & 0x0f)
+	}
+
+	c.ConfigOBUs = data[av1cHeaderSize:]
+	return c, nil
+}
+
+// BitDepth is the bit depth the record signals.
+func (c *AV1Config) BitDepth() int {
+	switch {
+	case c.TwelveBit == 1:
+		return 12
+	case c.HighBitdepth == 1:
+		return 10
+	}
+
+	return 8
+}
+
+// Bytes serializes the record.
+func (c *AV1Config) Bytes() []byte {
+	data := make([]byte, av1cHeaderSize, av1cHeaderSize+len(c.ConfigOBUs))
+	data[0] = 0x80 | byte(c.Version&0x7f)
+	data[1] = byte(c.SeqProfile<<5 | c.SeqLevelIdx0&0x1f)
+	data[2] = byte(c.SeqTier0<<7 | c.HighBitdepth<<6 | c.TwelveBit<<5 | c.Monochrome<<4 |
+		c.ChromaSubsamplingX<<3 | c.ChromaSubsamplingY<<2 | c.ChromaSamplePosition&0x03)
+	if c.InitialPresentationDelayPresent == 1 {
+		data[3] = 0x10 | byte(c.InitialPresentationDelayMinusOne&0x0f)
+	}
+
+	return append(data, c.ConfigOBUs...)
+}
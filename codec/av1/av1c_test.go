@@ -0,0 +1,41 @@
+package av1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAV1Config(t *testing.T) {
+	obu := AppendOBU(nil, OBU_SEQUENCE_HEADER, 0, 0, sequenceHeader(0, 8, 0))
+	c, err := NewAV1Config(obu)
+	assert.Nil(t, err)
+	assert.Equal(t, 8, c.SeqLevelIdx0)
+	assert.Equal(t, 8, c.BitDepth())
+	assert.Equal(t, 1, c.ChromaSubsamplingX)
+
+	c.InitialPresentationDelayPresent = 1
+	c.InitialPresentationDelayMinusOne = 3
+	data := c.Bytes()
+	assert.Equal(t, []byte{0x81, 0x08, 0x0c, 0x13}, data[:4])
+	assert.Equal(t, obu, data[4:])
+
+	parsed, err := ParseAV1Config(data)
+	assert.Nil(t, err)
+	assert.Equal(t, c, parsed)
+
+	// 10 bit main tier at level 5.1, no config OBUs
+	parsed, err = ParseAV1Config([]byte{0x81, 0x2d, 0x40, 0x00})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, parsed.SeqProfile)
+	assert.Equal(t, 13, parsed.SeqLevelIdx0)
+	assert.Equal(t, 10, parsed.BitDepth())
+	assert.Len(t, parsed.ConfigOBUs, 0)
+
+	_, err = ParseAV1Config([]byte{0x01, 0x08, 0x0c, 0x00})
+	assert.Equal(t, ErrInvalidConfig, err)
+	_, err = ParseAV1Config(data[:3])
+	assert.Equal(t, ErrInvalidConfig, err)
+	_, err = NewAV1Config(AppendOBU(nil, OBU_TEMPORAL_DELIMITER, 0, 0, nil))
+	assert.Equal(t, ErrInvalidConfig, err)
+}
@@ -0,0 +1,205 @@
+package av1
+
+import (
+	"errors"
+
+	"media-go/core"
+)
+
+// frame_type, 6.8.2
+const (
+	FRAME_KEY        = 0
+	FRAME_INTER      = 1
+	FRAME_INTRA_ONLY = 2
+	FRAME_SWITCH     = 3
+)
+
+const (
+	NUM_REF_FRAMES   = 8
+	PRIMARY_REF_NONE = 7
+	allFrames        = 1<<NUM_REF_FRAMES - 1
+)
+
+var frameTypeMap = map[int]string{
+	FRAME_KEY:        "key",
+	FRAME_INTER:      "inter",
+	FRAME_INTRA_ONLY: "intra_only",
+	FRAME_SWITCH:     "switch",
+}
+
+var ErrInvalidFrame = errors.New("av1: invalid frame header")
+
+// FrameHeader is the start of an uncompressed_header(), 5.9.2, up to
+// refresh_frame_flags. For a shown existing frame FrameType is the type of
+// the frame it shows.
+type FrameHeader struct {
+	ShowExistingFrame        int   `json:"show_existing_frame"`
+	FrameToShowMapIdx        int   `json:"frame_to_show_map_idx"`
+	FramePresentationTime    int   `json:"frame_presentation_time"`
+	DisplayFrameId           int   `json:"display_frame_id"`
+	FrameType                int   `json:"frame_type"`
+	ShowFrame                int   `json:"show_frame"`
+	ShowableFrame            int   `json:"showable_frame"`
+	ErrorResilientMode       int   `json:"error_resilient_mode"`
+	DisableCdfUpdate         int   `json:"disable_cdf_update"`
+	AllowScreenContentTools  int   `json:"allow_screen_content_tools"`
+	ForceIntegerMv           int   `json:"force_integer_mv"`
+	CurrentFrameId           int   `json:"current_frame_id"`
+	FrameSizeOverrideFlag    int   `json:"frame_size_override_flag"`
+	OrderHint                int   `json:"order_hint"`
+	PrimaryRefFrame          int   `json:"primary_ref_frame"`
+	BufferRemovalTimePresent int   `json:"buffer_removal_time_present_flag"`
+	BufferRemovalTimes       []int `json:"buffer_removal_times,omitempty"`
+	RefreshFrameFlags        int   `json:"refresh_frame_flags"`
+}
+
+// TypeName names frame_type.
+func (fh *FrameHeader) TypeName() string {
+	return frameTypeMap[fh.FrameType]
+}
+
+// IsIntra is FrameIsIntra.
+func (fh *FrameHeader) IsIntra() bool {
+	return fh.FrameType == FRAME_KEY || fh.FrameType == FRAME_INTRA_ONLY
+}
+
+// IsKeyFrame reports a random access point: a shown key frame, or a key
+// frame shown with show_existing_frame, which resets the decoder like one.
+func (fh *FrameHeader) IsKeyFrame() bool {
+	return fh.FrameType == FRAME_KEY && (fh.ShowFrame == 1 || fh.ShowExistingFrame == 1)
+}
+
+// IsShown reports if the frame is output, either now or as an existing frame.
+func (fh *FrameHeader) IsShown() bool {
+	return fh.ShowFrame == 1 || fh.ShowExistingFrame == 1
+}
+
+// DecodeFrameHeader parses the start of a frame header or frame OBU.
+// refFrameType holds the frame types of the reference slots, needed for
+// show_existing_frame; obu gives the temporal and spatial ids.
+func DecodeFrameHeader(obu *OBU, seq *SequenceHeader, refFrameType *[NUM_REF_FRAMES]int) (*FrameHeader, error) {
+	r := core.NewBitReader(obu.Payload)
+	fh := &FrameHeader{PrimaryRefFrame: PRIMARY_REF_NONE}
+
+	idLen := 0
+	if seq.FrameIdNumbersPresentFlag == 1 {
+		idLen = seq.AdditionalFrameIdLengthMinus1 + seq.DeltaFrameIdLengthMinus2 + 3
+	}
+
+	temporalPointInfo := seq.DecoderModelInfoPresentFlag == 1 && seq.EqualPictureInterval == 0
+	if seq.ReducedStillPictureHeader == 1 {
+		fh.FrameType = FRAME_KEY
+		fh.ShowFrame = 1
+	} else {
+		if fh.ShowExistingFrame = r.U(1); fh.ShowExistingFrame == 1 {
+			fh.FrameToShowMapIdx = r.U(3)
+			if temporalPointInfo {
+				fh.FramePresentationTime = r.U(seq.FramePresentationTimeLengthMinus1 + 1)
+			}
+
+			if seq.FrameIdNumbersPresentFlag == 1 {
+				fh.DisplayFrameId = r.U(idLen)
+			}
+
+			fh.FrameType = refFrameType[fh.FrameToShowMapIdx]
+			if fh.FrameType == FRAME_KEY {
+				fh.RefreshFrameFlags = allFrames
+			}
+
+			if r.Err != nil {
+				return nil, ErrInvalidFrame
+			}
+
+			return fh, nil
+		}
+
+		fh.FrameType = r.U(2)
+		if fh.ShowFrame = r.U(1); fh.ShowFrame == 1 && temporalPointInfo {
+			fh.FramePresentationTime = r.U(seq.FramePresentationTimeLengthMinus1 + 1)
+		}
+
+		if fh.ShowFrame == 1 {
+			fh.ShowableFrame = b2i(fh.FrameType != FRAME_KEY)
+		} else {
+			fh.ShowableFrame = r.U(1)
+		}
+
+		if fh.FrameType == FRAME_SWITCH || fh.FrameType == FRAME_KEY && fh.ShowFrame == 1 {
+			fh.ErrorResilientMode = 1
+		} else {
+			fh.ErrorResilientMode = r.U(1)
+		}
+	}
+
+	fh.DisableCdfUpdate = r.U(1)
+	if seq.SeqForceScreenContentTools == SELECT_SCREEN_CONTENT_TOOLS {
+		fh.AllowScreenContentTools = r.U(1)
+	} else {
+		fh.AllowScreenContentTools = seq.SeqForceScreenContentTools
+	}
+
+	if fh.AllowScreenContentTools > 0 {
+		if seq.SeqForceIntegerMv == SELECT_INTEGER_MV {
+			fh.ForceIntegerMv = r.U(1)
+		} else {
+			fh.ForceIntegerMv = seq.SeqForceIntegerMv
+		}
+	}
+
+	if fh.IsIntra() {
+		fh.ForceIntegerMv = 1
+	}
+
+	if seq.FrameIdNumbersPresentFlag == 1 {
+		fh.CurrentFrameId = r.U(idLen)
+	}
+
+	switch {
+	case fh.FrameType == FRAME_SWITCH:
+		fh.FrameSizeOverrideFlag = 1
+	case seq.ReducedStillPictureHeader == 0:
+		fh.FrameSizeOverrideFlag = r.U(1)
+	}
+
+	fh.OrderHint = r.U(seq.OrderHintBits())
+	if !fh.IsIntra() && fh.ErrorResilientMode == 0 {
+		fh.PrimaryRefFrame = r.U(3)
+	}
+
+	if seq.DecoderModelInfoPresentFlag == 1 {
+		if fh.BufferRemovalTimePresent = r.U(1); fh.BufferRemovalTimePresent == 1 {
+			for _, op := range seq.OperatingPoints {
+				if op.DecoderModelPresent == 0 {
+					continue
+				}
+
+				inTemporalLayer := op.Idc>>uint(obu.TemporalId)&1 == 1
+				inSpatialLayer := op.Idc>>uint(obu.SpatialId+8)&1 == 1
+				if op.Idc == 0 || inTemporalLayer && inSpatialLayer {
+					n := seq.BufferRemovalTimeLengthMinus1 + 1
+					fh.BufferRemovalTimes = append(fh.BufferRemovalTimes, r.U(n))
+				}
+			}
+		}
+	}
+
+	if fh.FrameType == FRAME_SWITCH || fh.FrameType == FRAME_KEY && fh.ShowFrame == 1 {
+		fh.RefreshFrameFlags = allFrames
+	} else {
+		fh.RefreshFrameFlags = r.U(8)
+	}
+
+	if r.Err != nil || fh.FrameType == FRAME_INTRA_ONLY && fh.RefreshFrameFlags == allFrames {
+		return nil, ErrInvalidFrame
+	}
+
+	return fh, nil
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,104 @@
+package av1
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// keyFrame writes a shown or hidden key frame header for the sequence of
+// sequenceHeader, hidden ones refresh the slots of refresh.
+func keyFrame(show, refresh int) []byte {
+	w := &bittest.Writer{}
+	w.U(1, 0) // show_existing_frame
+	w.U(2, FRAME_KEY)
+	w.U(1, show)
+	if show == 0 {
+		w.U(1, 1) // showable_frame
+		w.U(1, 0) // error_resilient_mode
+	}
+	w.U(1, 0) // disable_cdf_update
+	w.U(1, 0) // allow_screen_content_tools
+	w.U(1, 0) // frame_size_override_flag
+	w.U(7, 0) // order_hint
+	if show == 0 {
+		w.U(8, refresh)
+	}
+	w.U(8, 0xa5) // rest of the header
+	return w.Trailing()
+}
+
+// interFrame writes an inter frame header.
+func interFrame(show, orderHint, refresh int) []byte {
+	w := &bittest.Writer{}
+	w.U(1, 0)
+	w.U(2, FRAME_INTER)
+	w.U(1, show)
+	if show == 0 {
+		w.U(1, 1)
+	}
+	w.U(1, 0) // error_resilient_mode
+	w.U(1, 0)
+	w.U(1, 1) // allow_screen_content_tools
+	w.U(1, 1) // force_integer_mv
+	w.U(1, 0)
+	w.U(7, orderHint)
+	w.U(3, 0) // primary_ref_frame
+	w.U(8, refresh)
+	return w.Trailing()
+}
+
+func showExisting(idx int) []byte {
+	w := &bittest.Writer{}
+	w.U(1, 1)
+	w.U(3, idx)
+	return w.Trailing()
+}
+
+func TestDecodeFrameHeader(t *testing.T) {
+	seq, err := DecodeSequenceHeader(sequenceHeader(0, 8, 0))
+	assert.Nil(t, err)
+
+	var refs [NUM_REF_FRAMES]int
+	fh, err := DecodeFrameHeader(&OBU{Payload: keyFrame(1, 0)}, seq, &refs)
+	assert.Nil(t, err)
+	assert.True(t, fh.IsKeyFrame())
+	assert.True(t, fh.IsShown())
+	assert.Equal(t, 1, fh.ErrorResilientMode)
+	assert.Equal(t, 1, fh.ForceIntegerMv)
+	assert.Equal(t, PRIMARY_REF_NONE, fh.PrimaryRefFrame)
+	assert.Equal(t, 0xff, fh.RefreshFrameFlags)
+	assert.Equal(t, "key", fh.TypeName())
+
+	fh, err = DecodeFrameHeader(&OBU{Payload: keyFrame(0, 0x04)}, seq, &refs)
+	assert.Nil(t, err)
+	assert.False(t, fh.IsKeyFrame())
+	assert.Equal(t, 1, fh.ShowableFrame)
+	assert.Equal(t, 0x04, fh.RefreshFrameFlags)
+
+	fh, err = DecodeFrameHeader(&OBU{Payload: interFrame(0, 9, 0x10)}, seq, &refs)
+	assert.Nil(t, err)
+	assert.False(t, fh.IsShown())
+	assert.Equal(t, 1, fh.ForceIntegerMv)
+	assert.Equal(t, 9, fh.OrderHint)
+	assert.Equal(t, 0, fh.PrimaryRefFrame)
+	assert.Equal(t, 0x10, fh.RefreshFrameFlags)
+
+	// the type of a shown existing frame comes from its slot
+	refs[2] = FRAME_KEY
+	refs[4] = FRAME_INTER
+	fh, err = DecodeFrameHeader(&OBU{Payload: showExisting(2)}, seq, &refs)
+	assert.Nil(t, err)
+	assert.True(t, fh.IsKeyFrame())
+	assert.Equal(t, 0xff, fh.RefreshFrameFlags)
+	fh, err = DecodeFrameHeader(&OBU{Payload: showExisting(4)}, seq, &refs)
+	assert.Nil(t, err)
+	assert.False(t, fh.IsKeyFrame())
+	assert.True(t, fh.IsShown())
+	assert.Equal(t, 0, fh.RefreshFrameFlags)
+
+	_, err = DecodeFrameHeader(&OBU{Payload: []byte{0x30}}, seq, &refs)
+	assert.Equal(t, ErrInvalidFrame, err)
+}
@@ -0,0 +1,180 @@
+package av1
+
+import (
+	"fmt"
+)
+
+// OBUError reports an OBU of a temporal unit that could not be decoded,
+// Offset is its position in the sample.
+type OBUError struct {
+	Offset int
+	Type   int
+	Err    error
+}
+
+func (e *OBUError) Error() string {
+	return fmt.Sprintf("%v: type %d OBU at offset %d", e.Err, e.Type, e.Offset)
+}
+
+func (e *OBUError) Unwrap() error { return e.Err }
+
+// VideoFrameInfo describes a sequence start or one temporal unit.
+type VideoFrameInfo struct {
+	CodecType string
+	Type      string
+	Config    *AV1Config      // seq header only
+	Sequence  *SequenceHeader // the sequence header of the record or the unit, if any
+	OBUs      []*OBU
+	Frames    []*FrameHeader // the frame headers of the selected operating point
+	KeyFrame  bool
+}
+
+func (f *VideoFrameInfo) obus() string {
+	s := ""
+	for _, o := range f.OBUs {
+		s += o.String()
+	}
+
+	return s
+}
+
+func (f *VideoFrameInfo) String() string {
+	if len(f.OBUs) == 0 {
+		return fmt.Sprintf("code: %s\ttype: %s", f.CodecType, f.Type)
+	}
+
+	return fmt.Sprintf("code: %s\ttype: %s\tframes: %d\tobu: %s", f.CodecType, f.Type, len(f.Frames), f.obus())
+}
+
+// Parser decodes the AV1 video of one stream. It keeps the active sequence
+// header and the frame types of the reference slots, so every stream needs
+// its own Parser. Operating point 0 is decoded.
+type Parser struct {
+	config       *AV1Config
+	seq          *SequenceHeader
+	refFrameType [NUM_REF_FRAMES]int
+}
+
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+// Config returns the active decoder configuration, nil before the first
+// sequence start.
+func (p *Parser) Config() *AV1Config { return p.config }
+
+// Sequence returns the active sequence header, nil before the first one.
+func (p *Parser) Sequence() *SequenceHeader { return p.seq }
+
+// ParseConfig decodes an AV1CodecConfigurationRecord and the sequence header
+// in it.
+func (p *Parser) ParseConfig(record []byte) (*VideoFrameInfo, error) {
+	config, err := ParseAV1Config(record)
+	if err != nil {
+		return nil, err
+	}
+
+	obus, err := SplitOBUs(config.ConfigOBUs)
+	if err != nil {
+		return nil, err
+	}
+
+	vf := &VideoFrameInfo{CodecType: "seq", Type: "seq header", Config: config, OBUs: obus}
+	for _, obu := range obus {
+		if obu.Type != OBU_SEQUENCE_HEADER {
+			continue
+		}
+
+		seq, err := DecodeSequenceHeader(obu.Payload)
+		if err != nil {
+			return nil, &OBUError{Offset: obu.Offset, Type: obu.Type, Err: err}
+		}
+		vf.Sequence = seq
+	}
+
+	if vf.Sequence != nil {
+		p.seq = vf.Sequence
+	}
+
+	p.config = config
+	return vf, nil
+}
+
+// ParseOBUs decodes the OBUs of one temporal unit, temporal units must be
+// passed in decoding order.
+func (p *Parser) ParseOBUs(data []byte) (*VideoFrameInfo, error) {
+	obus, err := SplitOBUs(data)
+	if err != nil {
+		return nil, err
+	}
+
+	vf := &VideoFrameInfo{CodecType: "obu", OBUs: obus}
+	for _, obu := range obus {
+		switch obu.Type {
+		case OBU_SEQUENCE_HEADER:
+			seq, err := DecodeSequenceHeader(obu.Payload)
+			if err != nil {
+				return nil, &OBUError{Offset: obu.Offset, Type: obu.Type, Err: err}
+			}
+			p.seq, vf.Sequence = seq, seq
+		case OBU_FRAME_HEADER, OBU_FRAME:
+			if p.seq == nil {
+				return nil, &OBUError{Offset: obu.Offset, Type: obu.Type, Err: ErrNoSequence}
+			}
+
+			if !p.selected(obu) {
+				break
+			}
+
+			fh, err := DecodeFrameHeader(obu, p.seq, &p.refFrameType)
+			if err != nil {
+				return nil, &OBUError{Offset: obu.Offset, Type: obu.Type, Err: err}
+			}
+
+			for i := 0; i < NUM_REF_FRAMES; i++ {
+				if fh.RefreshFrameFlags>>uint(i)&1 == 1 {
+					p.refFrameType[i] = fh.FrameType
+				}
+			}
+
+			vf.Frames = append(vf.Frames, fh)
+			vf.KeyFrame = vf.KeyFrame || fh.IsKeyFrame()
+		}
+	}
+
+	vf.Type = vf.frameType()
+	return vf, nil
+}
+
+// selected reports if the OBU belongs to operating point 0, others are
+// dropped as in 7.5.
+func (p *Parser) selected(obu *OBU) bool {
+	idc := p.seq.OperatingPoints[0].Idc
+	if obu.ExtensionFlag == 0 || idc == 0 {
+		return true
+	}
+
+	inTemporalLayer := idc>>uint(obu.TemporalId)&1 == 1
+	inSpatialLayer := idc>>uint(obu.SpatialId+8)&1 == 1
+	return inTemporalLayer && inSpatialLayer
+}
+
+// frameType names the unit by its shown frame: "key" for a random access
+// point, "hidden" if no frame is shown.
+func (f *VideoFrameInfo) frameType() string {
+	if f.KeyFrame {
+		return "key"
+	}
+
+	for _, fh := range f.Frames {
+		if fh.IsShown() {
+			return fh.TypeName()
+		}
+	}
+
+	if len(f.Frames) == 0 {
+		return "none"
+	}
+
+	return "hidden"
+}
@@ -0,0 +1,101 @@
+package av1
+
+import (
+	"errors"
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// temporalUnit writes a temporal delimiter and frame header OBUs.
+func temporalUnit(headers ...[]byte) []byte {
+	data := AppendOBU(nil, OBU_TEMPORAL_DELIMITER, 0, 0, nil)
+	for _, h := range headers {
+		data = AppendOBU(data, OBU_FRAME, 0, 0, h)
+	}
+
+	return data
+}
+
+func TestParser(t *testing.T) {
+	p := NewParser()
+	_, err := p.ParseOBUs(temporalUnit(keyFrame(1, 0)))
+	assert.True(t, errors.Is(err, ErrNoSequence))
+
+	c, err := NewAV1Config(AppendOBU(nil, OBU_SEQUENCE_HEADER, 0, 0, sequenceHeader(0, 8, 0)))
+	assert.Nil(t, err)
+	vf, err := p.ParseConfig(c.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, "seq", vf.CodecType)
+	assert.Equal(t, "av01.0.08M.08", vf.Sequence.Codecs())
+	assert.Equal(t, 8, p.Config().SeqLevelIdx0)
+	assert.Equal(t, 1920, p.Sequence().Width())
+
+	vf, err = p.ParseOBUs(temporalUnit(keyFrame(1, 0)))
+	assert.Nil(t, err)
+	assert.True(t, vf.KeyFrame)
+	assert.Equal(t, "key", vf.Type)
+	assert.Equal(t, "code: obu\ttype: key\tframes: 1\tobu: |type: temporal_delimiter|tid: 0|sid: 0|size: 0"+
+		"|type: frame|tid: 0|sid: 0|size: 3", vf.String())
+
+	// a hidden key frame and an inter frame, the key frame is shown later
+	vf, err = p.ParseOBUs(temporalUnit(keyFrame(0, 0x04), interFrame(1, 1, 0x01)))
+	assert.Nil(t, err)
+	assert.False(t, vf.KeyFrame)
+	assert.Equal(t, "inter", vf.Type)
+	assert.Len(t, vf.Frames, 2)
+
+	vf, err = p.ParseOBUs(temporalUnit(interFrame(0, 4, 0x10)))
+	assert.Nil(t, err)
+	assert.Equal(t, "hidden", vf.Type)
+
+	vf, err = p.ParseOBUs(AppendOBU(nil, OBU_FRAME_HEADER, 0, 0, showExisting(4)))
+	assert.Nil(t, err)
+	assert.Equal(t, "inter", vf.Type)
+	vf, err = p.ParseOBUs(AppendOBU(nil, OBU_FRAME_HEADER, 0, 0, showExisting(2)))
+	assert.Nil(t, err)
+	assert.True(t, vf.KeyFrame)
+
+	vf, err = p.ParseOBUs(temporalUnit())
+	assert.Nil(t, err)
+	assert.Equal(t, "none", vf.Type)
+
+	_, err = p.ParseOBUs(temporalUnit([]byte{0x30}))
+	var oe *OBUError
+	assert.True(t, errors.As(err, &oe))
+	assert.Equal(t, 2, oe.Offset)
+	assert.True(t, errors.Is(err, ErrInvalidFrame))
+}
+
+func TestParserOperatingPoint(t *testing.T) {
+	// operating point 0 holds temporal layer 0 of spatial layer 0
+	w := &bittest.Writer{}
+	w.U(5, 0) // seq_profile, still_picture, reduced_still_picture_header
+	w.U(1, 0) // timing_info_present_flag
+	w.U(1, 0) // initial_display_delay_present_flag
+	w.U(5, 1) // operating_points_cnt_minus_1
+	w.U(12, 0x101)
+	w.U(5, 8)
+	w.U(1, 0)
+	w.U(12, 0x103)
+	w.U(5, 8)
+	w.U(1, 0)
+	writeSequenceTail(w, 0, 0)
+
+	p := NewParser()
+	c, err := NewAV1Config(AppendOBU(nil, OBU_SEQUENCE_HEADER, 0, 0, w.Trailing()))
+	assert.Nil(t, err)
+	_, err = p.ParseConfig(c.Bytes())
+	assert.Nil(t, err)
+	assert.Len(t, p.Sequence().OperatingPoints, 2)
+
+	data := AppendOBU(nil, OBU_FRAME, 0, 0, keyFrame(1, 0))
+	data = AppendOBU(data, OBU_FRAME, 1, 0, []byte{0x30})
+	vf, err := p.ParseOBUs(data)
+	assert.Nil(t, err)
+	assert.Len(t, vf.OBUs, 2)
+	assert.Len(t, vf.Frames, 1)
+	assert.True(t, vf.KeyFrame)
+}
@@ -0,0 +1,350 @@
+package av1
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"media-go/core"
+)
+
+var ErrInvalidSequence = errors.New("av1: invalid sequence header")
+
+const (
+	SELECT_SCREEN_CONTENT_TOOLS = 2
+	SELECT_INTEGER_MV           = 2
+)
+
+// color_primaries, transfer_characteristics and matrix_coefficients values
+// of 6.4.2 the colour config depends on
+const (
+	CP_BT_709      = 1
+	CP_UNSPECIFIED = 2
+	TC_UNSPECIFIED = 2
+	TC_SRGB        = 13
+	MC_IDENTITY    = 0
+	MC_UNSPECIFIED = 2
+)
+
+// readUvlc reads a uvlc(), 4.10.3.
+func readUvlc(r *core.BitReader) uint32 {
+	zeros := 0
+	for r.U(1) == 0 && r.Err == nil {
+		zeros++
+	}
+
+	if zeros >= 32 {
+		return math.MaxUint32
+	}
+
+	return uint32(r.U(zeros)) + (1<<uint(zeros) - 1)
+}
+
+// OperatingPoint is one operating point of a sequence header.
+type OperatingPoint struct {
+	Idc                        int `json:"operating_point_idc"`
+	SeqLevelIdx                int `json:"seq_level_idx"`
+	SeqTier                    int `json:"seq_tier"`
+	DecoderModelPresent        int `json:"decoder_model_present_for_this_op"`
+	DecoderBufferDelay         int `json:"decoder_buffer_delay"`
+	EncoderBufferDelay         int `json:"encoder_buffer_delay"`
+	LowDelayModeFlag           int `json:"low_delay_mode_flag"`
+	InitialDisplayDelayPresent int `json:"initial_display_delay_present_for_this_op"`
+	InitialDisplayDelayMinus1  int `json:"initial_display_delay_minus_1"`
+}
+
+// SequenceHeader is a sequence_header_obu(), 5.5.
+type SequenceHeader struct {
+	SeqProfile                        int              `json:"seq_profile"`
+	StillPicture                      int              `json:"still_picture"`
+	ReducedStillPictureHeader         int              `json:"reduced_still_picture_header"`
+	TimingInfoPresentFlag             int              `json:"timing_info_present_flag"`
+	NumUnitsInDisplayTick             uint32           `json:"num_units_in_display_tick"`
+	TimeScale                         uint32           `json:"time_scale"`
+	EqualPictureInterval              int              `json:"equal_picture_interval"`
+	NumTicksPerPictureMinus1          uint32           `json:"num_ticks_per_picture_minus_1"`
+	DecoderModelInfoPresentFlag       int              `json:"decoder_model_info_present_flag"`
+	BufferDelayLengthMinus1           int              `json:"buffer_delay_length_minus_1"`
+	NumUnitsInDecodingTick            int              `json:"num_units_in_decoding_tick"`
+	BufferRemovalTimeLengthMinus1     int              `json:"buffer_removal_time_length_minus_1"`
+	FramePresentationTimeLengthMinus1 int              `json:"frame_presentation_time_length_minus_1"`
+	InitialDisplayDelayPresentFlag    int              `json:"initial_display_delay_present_flag"`
+	OperatingPoints                   []OperatingPoint `json:"operating_points"`
+	FrameWidthBitsMinus1              int              `json:"frame_width_bits_minus_1"`
+	FrameHeightBitsMinus1             int              `json:"frame_height_bits_minus_1"`
+	MaxFrameWidthMinus1               int              `json:"max_frame_width_minus_1"`
+	MaxFrameHeightMinus1              int              `json:"max_frame_height_minus_1"`
+	FrameIdNumbersPresentFlag         int              `json:"frame_id_numbers_present_flag"`
+	DeltaFrameIdLengthMinus2          int              `json:"delta_frame_id_length_minus_2"`
+	AdditionalFrameIdLengthMinus1     int              `json:"additional_frame_id_length_minus_1"`
+	Use128x128Superblock              int              `json:"use_128x128_superblock"`
+	EnableFilterIntra                 int              `json:"enable_filter_intra"`
+	EnableIntraEdgeFilter             int              `json:"enable_intra_edge_filter"`
+	EnableInterintraCompound          int              `json:"enable_interintra_compound"`
+	EnableMaskedCompound              int              `json:"enable_masked_compound"`
+	EnableWarpedMotion                int              `json:"enable_warped_motion"`
+	EnableDualFilter                  int              `json:"enable_dual_filter"`
+	EnableOrderHint                   int              `json:"enable_order_hint"`
+	EnableJntComp                     int              `json:"enable_jnt_comp"`
+	EnableRefFrameMvs                 int              `json:"enable_ref_frame_mvs"`
+	SeqChooseScreenContentTools       int              `json:"seq_choose_screen_content_tools"`
+	SeqForceScreenContentTools        int              `json:"seq_force_screen_content_tools"`
+	SeqChooseIntegerMv                int              `json:"seq_choose_integer_mv"`
+	SeqForceIntegerMv                 int              `json:"seq_force_integer_mv"`
+	OrderHintBitsMinus1               int              `json:"order_hint_bits_minus_1"`
+	EnableSuperres                    int              `json:"enable_superres"`
+	EnableCdef                        int              `json:"enable_cdef"`
+	EnableRestoration                 int              `json:"enable_restoration"`
+	ColorConfig                       ColorConfig      `json:"color_config"`
+	FilmGrainParamsPresent            int              `json:"film_grain_params_present"`
+}
+
+// ColorConfig is the color_config() of a sequence header, 5.5.2.
+type ColorConfig struct {
+	HighBitdepth                int `json:"high_bitdepth"`
+	TwelveBit                   int `json:"twelve_bit"`
+	BitDepth                    int `json:"bit_depth"`
+	MonoChrome                  int `json:"mono_chrome"`
+	ColorDescriptionPresentFlag int `json:"color_description_present_flag"`
+	ColorPrimaries              int `json:"color_primaries"`
+	TransferCharacteristics     int `json:"transfer_characteristics"`
+	MatrixCoefficients          int `json:"matrix_coefficients"`
+	ColorRange                  int `json:"color_range"`
+	SubsamplingX                int `json:"subsampling_x"`
+	SubsamplingY                int `json:"subsampling_y"`
+	ChromaSamplePosition        int `json:"chroma_sample_position"`
+	SeparateUvDeltaQ            int `json:"separate_uv_delta_q"`
+}
+
+// DecodeSequenceHeader parses the payload of a sequence header OBU.
+func DecodeSequenceHeader(payload []byte) (*SequenceHeader, error) {
+	r := core.NewBitReader(payload)
+	seq := &SequenceHeader{}
+	seq.SeqProfile = r.U(3)
+	seq.StillPicture = r.U(1)
+	seq.ReducedStillPictureHeader = r.U(1)
+	if seq.SeqProfile > 2 {
+		return nil, ErrInvalidSequence
+	}
+
+	if seq.ReducedStillPictureHeader == 1 {
+		seq.OperatingPoints = []OperatingPoint{{SeqLevelIdx: r.U(5)}}
+	} else {
+		if seq.TimingInfoPresentFlag = r.U(1); seq.TimingInfoPresentFlag == 1 {
+			seq.NumUnitsInDisplayTick = uint32(r.U(32))
+			seq.TimeScale = uint32(r.U(32))
+			if seq.EqualPictureInterval = r.U(1); seq.EqualPictureInterval == 1 {
+				seq.NumTicksPerPictureMinus1 = readUvlc(r)
+			}
+
+			if seq.DecoderModelInfoPresentFlag = r.U(1); seq.DecoderModelInfoPresentFlag == 1 {
+				seq.BufferDelayLengthMinus1 = r.U(5)
+				seq.NumUnitsInDecodingTick = r.U(32)
+				seq.BufferRemovalTimeLengthMinus1 = r.U(5)
+				seq.FramePresentationTimeLengthMinus1 = r.U(5)
+			}
+		}
+
+		seq.InitialDisplayDelayPresentFlag = r.U(1)
+		count := r.U(5) + 1
+		for i := 0; i < count && r.Err == nil; i++ {
+			op := OperatingPoint{Idc: r.U(12), SeqLevelIdx: r.U(5)}
+			if op.SeqLevelIdx > 7 {
+				op.SeqTier = r.U(1)
+			}
+
+			if seq.DecoderModelInfoPresentFlag == 1 {
+				if op.DecoderModelPresent = r.U(1); op.DecoderModelPresent == 1 {
+					n := seq.BufferDelayLengthMinus1 + 1
+					op.DecoderBufferDelay = r.U(n)
+					op.EncoderBufferDelay = r.U(n)
+					op.LowDelayModeFlag = r.U(1)
+				}
+			}
+
+			if seq.InitialDisplayDelayPresentFlag == 1 {
+				if op.InitialDisplayDelayPresent = r.U(1); op.InitialDisplayDelayPresent == 1 {
+					op.InitialDisplayDelayMinus1 = r.U(4)
+				}
+			}
+
+			seq.OperatingPoints = append(seq.OperatingPoints, op)
+		}
+	}
+
+	seq.FrameWidthBitsMinus1 = r.U(4)
+	seq.FrameHeightBitsMinus1 = r.U(4)
+	seq.MaxFrameWidthMinus1 = r.U(seq.FrameWidthBitsMinus1 + 1)
+	seq.MaxFrameHeightMinus1 = r.U(seq.FrameHeightBitsMinus1 + 1)
+	if seq.ReducedStillPictureHeader == 0 {
+		if seq.FrameIdNumbersPresentFlag = r.U(1); seq.FrameIdNumbersPresentFlag == 1 {
+			seq.DeltaFrameIdLengthMinus2 = r.U(4)
+			seq.AdditionalFrameIdLengthMinus1 = r.U(3)
+		}
+	}
+
+	seq.Use128x128Superblock = r.U(1)
+	seq.EnableFilterIntra = r.U(1)
+	seq.EnableIntraEdgeFilter = r.U(1)
+
+	seq.SeqForceScreenContentTools = SELECT_SCREEN_CONTENT_TOOLS
+	seq.SeqForceIntegerMv = SELECT_INTEGER_MV
+	if seq.ReducedStillPictureHeader == 0 {
+		seq.EnableInterintraCompound = r.U(1)
+		seq.EnableMaskedCompound = r.U(1)
+		seq.EnableWarpedMotion = r.U(1)
+		seq.EnableDualFilter = r.U(1)
+		if seq.EnableOrderHint = r.U(1); seq.EnableOrderHint == 1 {
+			seq.EnableJntComp = r.U(1)
+			seq.EnableRefFrameMvs = r.U(1)
+		}
+
+		if seq.SeqChooseScreenContentTools = r.U(1); seq.SeqChooseScreenContentTools == 0 {
+			seq.SeqForceScreenContentTools = r.U(1)
+		}
+
+		if seq.SeqForceScreenContentTools > 0 {
+			if seq.SeqChooseIntegerMv = r.U(1); seq.SeqChooseIntegerMv == 0 {
+				seq.SeqForceIntegerMv = r.U(1)
+			}
+		}
+
+		if seq.EnableOrderHint == 1 {
+			seq.OrderHintBitsMinus1 = r.U(3)
+		}
+	}
+
+	seq.EnableSuperres = r.U(1)
+	seq.EnableCdef = r.U(1)
+	seq.EnableRestoration = r.U(1)
+	readColorConfig(r, seq.SeqProfile, &seq.ColorConfig)
+	seq.FilmGrainParamsPresent = r.U(1)
+
+	if r.Err != nil {
+		return nil, ErrInvalidSequence
+	}
+
+	return seq, nil
+}
+
+// readColorConfig reads a color_config(), 5.5.2.
+func readColorConfig(r *core.BitReader, profile int, cc *ColorConfig) {
+	cc.HighBitdepth = r.U(1)
+	cc.BitDepth = 8 + 2*cc.HighBitdepth
+	if profile == 2 && cc.HighBitdepth == 1 {
+		if cc.TwelveBit = r.U(1); cc.TwelveBit == 1 {
+			cc.BitDepth = 12
+		}
+	}
+
+	if profile != 1 {
+		cc.MonoChrome = r.U(1)
+	}
+
+	cc.ColorPrimaries, cc.TransferCharacteristics, cc.MatrixCoefficients = CP_UNSPECIFIED, TC_UNSPECIFIED, MC_UNSPECIFIED
+	if cc.ColorDescriptionPresentFlag = r.U(1); cc.ColorDescriptionPresentFlag == 1 {
+		cc.ColorPrimaries = r.U(8)
+		cc.TransferCharacteristics = r.U(8)
+		cc.MatrixCoefficients = r.U(8)
+	}
+
+	switch {
+	case cc.MonoChrome == 1:
+		cc.ColorRange = r.U(1)
+		cc.SubsamplingX, cc.SubsamplingY = 1, 1
+		return
+	case cc.ColorPrimaries == CP_BT_709 && cc.TransferCharacteristics == TC_SRGB &&
+		cc.MatrixCoefficients == MC_IDENTITY:
+		cc.ColorRange = 1
+	default:
+		cc.ColorRange = r.U(1)
+		switch {
+		case profile == 0:
+			cc.SubsamplingX, cc.SubsamplingY = 1, 1
+		case profile == 1:
+		case cc.BitDepth == 12:
+			if cc.SubsamplingX = r.U(1); cc.SubsamplingX == 1 {
+				cc.SubsamplingY = r.U(1)
+			}
+		default:
+			cc.SubsamplingX = 1
+		}
+
+		if cc.SubsamplingX == 1 && cc.SubsamplingY == 1 {
+			cc.ChromaSamplePosition = r.U(2)
+		}
+	}
+
+	cc.SeparateUvDeltaQ = r.U(1)
+}
+
+// OrderHintBits is OrderHintBits, 0 without order hints.
+func (seq *SequenceHeader) OrderHintBits() int {
+	if seq.EnableOrderHint == 0 {
+		return 0
+	}
+
+	return seq.OrderHintBitsMinus1 + 1
+}
+
+// Width is the maximum frame width.
+func (seq *SequenceHeader) Width() int { return seq.MaxFrameWidthMinus1 + 1 }
+
+// Height is the maximum frame height.
+func (seq *SequenceHeader) Height() int { return seq.MaxFrameHeightMinus1 + 1 }
+
+// FrameRate is the frame rate of the timing info for a constant picture
+// interval, 0 otherwise.
+func (seq *SequenceHeader) FrameRate() float64 {
+	if seq.TimingInfoPresentFlag == 0 || seq.EqualPictureInterval == 0 || seq.NumUnitsInDisplayTick == 0 {
+		return 0
+	}
+
+	return float64(seq.TimeScale) / (float64(seq.NumUnitsInDisplayTick) * (float64(seq.NumTicksPerPictureMinus1) + 1))
+}
+
+// ProfileName names seq_profile, Annex A.2.
+func (seq *SequenceHeader) ProfileName() string {
+	switch seq.SeqProfile {
+	case 0:
+		return "Main"
+	case 1:
+		return "High"
+	case 2:
+		return "Professional"
+	}
+
+	return fmt.Sprintf("unknown(%d)", seq.SeqProfile)
+}
+
+// LevelName returns the level of the first operating point as written in
+// Annex A.3, e.g. "4.0", seq_level_idx 31 has no level constraints.
+func (seq *SequenceHeader) LevelName() string {
+	idx := seq.OperatingPoints[0].SeqLevelIdx
+	if idx == 31 {
+		return "max"
+	}
+
+	return fmt.Sprintf("%d.%d", 2+idx>>2, idx&3)
+}
+
+// Codecs returns the codecs parameter of the AV1 ISOBMFF binding, "av01.P.LLT.DD",
+// used in HLS and DASH manifests.
+func (seq *SequenceHeader) Codecs() string {
+	op := seq.OperatingPoints[0]
+	tier := 'M'
+	if op.SeqTier == 1 {
+		tier = 'H'
+	}
+
+	return fmt.Sprintf("av01.%d.%02d%c.%02d", seq.SeqProfile, op.SeqLevelIdx, tier, seq.ColorConfig.BitDepth)
+}
+
+func (seq *SequenceHeader) String() string {
+	s := fmt.Sprintf("%s %s@%s %dx%d", seq.Codecs(), seq.ProfileName(), seq.LevelName(), seq.Width(), seq.Height())
+	if fps := seq.FrameRate(); fps > 0 {
+		s += fmt.Sprintf(" %.3gfps", fps)
+	}
+
+	return s
+}
@@ -0,0 +1,138 @@
+package av1
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSequenceHeader writes a 1920x1080 sequence header of the profile and
+// level with 59.94fps timing, 7 order hint bits and BT.709 colour.
+func writeSequenceHeader(w *bittest.Writer, profile, level, highBitdepth int) {
+	w.U(3, profile)
+	w.U(1, 0) // still_picture
+	w.U(1, 0) // reduced_still_picture_header
+	w.U(1, 1) // timing_info_present_flag
+	w.U(32, 1001)
+	w.U(32, 60000)
+	w.U(1, 1) // equal_picture_interval
+	w.UE(0)
+	w.U(1, 0) // decoder_model_info_present_flag
+	w.U(1, 0) // initial_display_delay_present_flag
+	w.U(5, 0) // operating_points_cnt_minus_1
+	w.U(12, 0)
+	w.U(5, level)
+	if level > 7 {
+		w.U(1, 0) // seq_tier
+	}
+
+	writeSequenceTail(w, profile, highBitdepth)
+}
+
+// writeSequenceTail writes the part of writeSequenceHeader after the
+// operating points.
+func writeSequenceTail(w *bittest.Writer, profile, highBitdepth int) {
+	w.U(4, 10)
+	w.U(4, 10)
+	w.U(11, 1919)
+	w.U(11, 1079)
+	w.U(1, 0)    // frame_id_numbers_present_flag
+	w.U(1, 0)    // use_128x128_superblock
+	w.U(7, 0x7f) // filter intra to dual filter, enable_order_hint
+	w.U(2, 3)    // enable_jnt_comp, enable_ref_frame_mvs
+	w.U(1, 1)    // seq_choose_screen_content_tools
+	w.U(1, 1)    // seq_choose_integer_mv
+	w.U(3, 6)    // order_hint_bits_minus_1
+	w.U(3, 3)    // superres, cdef, restoration
+
+	w.U(1, highBitdepth)
+	if profile != 1 {
+		w.U(1, 0) // mono_chrome
+	}
+	w.U(1, 1) // color_description_present_flag
+	w.U(24, 0x010101)
+	w.U(1, 0) // color_range
+	if profile == 0 {
+		w.U(2, 0) // chroma_sample_position
+	}
+	w.U(1, 0) // separate_uv_delta_q
+	w.U(1, 0) // film_grain_params_present
+}
+
+func sequenceHeader(profile, level, highBitdepth int) []byte {
+	w := &bittest.Writer{}
+	writeSequenceHeader(w, profile, level, highBitdepth)
+	return w.Trailing()
+}
+
+func TestDecodeSequenceHeader(t *testing.T) {
+	seq, err := DecodeSequenceHeader(sequenceHeader(0, 8, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, seq.SeqProfile)
+	assert.Equal(t, 1920, seq.Width())
+	assert.Equal(t, 1080, seq.Height())
+	assert.InDelta(t, 59.94, seq.FrameRate(), 0.01)
+	assert.Equal(t, 7, seq.OrderHintBits())
+	assert.Equal(t, SELECT_SCREEN_CONTENT_TOOLS, seq.SeqForceScreenContentTools)
+	assert.Equal(t, SELECT_INTEGER_MV, seq.SeqForceIntegerMv)
+	assert.Equal(t, 1, seq.EnableCdef)
+
+	cc := seq.ColorConfig
+	assert.Equal(t, 8, cc.BitDepth)
+	assert.Equal(t, CP_BT_709, cc.ColorPrimaries)
+	assert.Equal(t, 1, cc.TransferCharacteristics)
+	assert.Equal(t, 1, cc.SubsamplingX)
+	assert.Equal(t, 1, cc.SubsamplingY)
+
+	assert.Equal(t, "av01.0.08M.08", seq.Codecs())
+	assert.Equal(t, "Main", seq.ProfileName())
+	assert.Equal(t, "4.0", seq.LevelName())
+	assert.Equal(t, "av01.0.08M.08 Main@4.0 1920x1080 59.9fps", seq.String())
+
+	// 10 bit 4:4:4 at level 5.1
+	seq, err = DecodeSequenceHeader(sequenceHeader(1, 13, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, 10, seq.ColorConfig.BitDepth)
+	assert.Equal(t, 0, seq.ColorConfig.SubsamplingX)
+	assert.Equal(t, "av01.1.13M.10", seq.Codecs())
+	assert.Equal(t, "5.1", seq.LevelName())
+
+	_, err = DecodeSequenceHeader(sequenceHeader(0, 8, 0)[:10])
+	assert.Equal(t, ErrInvalidSequence, err)
+	_, err = DecodeSequenceHeader([]byte{0xe0})
+	assert.Equal(t, ErrInvalidSequence, err)
+}
+
+func TestReducedStillPicture(t *testing.T) {
+	w := &bittest.Writer{}
+	w.U(3, 2)
+	w.U(2, 3) // still_picture, reduced_still_picture_header
+	w.U(5, 31)
+	w.U(4, 11)
+	w.U(4, 11)
+	w.U(12, 4095)
+	w.U(12, 2999)
+	w.U(3, 0) // superblock and intra tools
+	w.U(3, 0) // superres, cdef, restoration
+	w.U(2, 3) // high_bitdepth, twelve_bit
+	w.U(2, 0) // mono_chrome, color_description_present_flag
+	w.U(1, 1) // color_range
+	w.U(2, 3) // subsampling_x, subsampling_y
+	w.U(2, 1) // chroma_sample_position
+	w.U(2, 0) // separate_uv_delta_q, film_grain_params_present
+
+	seq, err := DecodeSequenceHeader(w.Trailing())
+	assert.Nil(t, err)
+	assert.Len(t, seq.OperatingPoints, 1)
+	assert.Equal(t, 4096, seq.Width())
+	assert.Equal(t, 3000, seq.Height())
+	assert.Equal(t, 12, seq.ColorConfig.BitDepth)
+	assert.Equal(t, CP_UNSPECIFIED, seq.ColorConfig.ColorPrimaries)
+	assert.Equal(t, 1, seq.ColorConfig.ChromaSamplePosition)
+	assert.Equal(t, 0, seq.OrderHintBits())
+	assert.Equal(t, float64(0), seq.FrameRate())
+	assert.Equal(t, "av01.2.31M.12", seq.Codecs())
+	assert.Equal(t, "max", seq.LevelName())
+}
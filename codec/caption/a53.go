@@ -0,0 +1,99 @@
+package caption
+
+import (
+	"bytes"
+	"errors"
+
+	"media-go/codec/h264"
+)
+
+// cc_type of a cc_data triplet, CEA-708 4.4
+const (
+	CC_TYPE_NTSC_FIELD1 = 0
+	CC_TYPE_NTSC_FIELD2 = 1
+	CC_TYPE_DTVCC_DATA  = 2
+	CC_TYPE_DTVCC_START = 3
+)
+
+const (
+	t35CountryUSA    = 0xb5
+	t35ProviderATSC  = 0x0031
+	a53TypeCCData    = 0x03
+	a53HeaderSize    = 7 // provider code, user identifier and type code
+	ccDataHeaderSize = 2
+)
+
+var a53Identifier = []byte("GA94")
+
+var (
+	ErrNoCCData      = errors.New("caption: not ATSC A/53 cc_data")
+	ErrInvalidCCData = errors.New("caption: invalid cc_data")
+)
+
+// Triplet is one cc_data_pkt: two bytes of CEA-608 or CEA-708 data.
+type Triplet struct {
+	Valid bool
+	Type  int
+	Data  [2]byte
+}
+
+// ParseA53 reads the cc_data of an ATSC A/53 user_data_registered_itu_t_t35
+// payload, data starts after the country code. Other T.35 payloads give
+// ErrNoCCData.
+func ParseA53(data []byte) ([]Triplet, error) {
+	if len(data) < a53HeaderSize || int(data[0])<<8|int(data[1]) != t35ProviderATSC ||
+		!bytes.Equal(data[2:6], a53Identifier) || data[6] != a53TypeCCData {
+		return nil, ErrNoCCData
+	}
+
+	data = data[a53HeaderSize:]
+	if len(data) < ccDataHeaderSize {
+		return nil, ErrInvalidCCData
+	}
+
+	// process_em_data_flag, process_cc_data_flag, additional_data_flag,
+	// cc_count, then em_data
+	if data[0]&0x40 == 0 {
+		return nil, nil
+	}
+
+	count := int(data[0] & 0x1f)
+	data = data[ccDataHeaderSize:]
+	if len(data) < count*3 {
+		return nil, ErrInvalidCCData
+	}
+
+	triplets := make([]Triplet, 0, count)
+	for i := 0; i < count; i++ {
+		b := data[i*3 : i*3+3]
+		triplets = append(triplets, Triplet{
+			Valid: b[0]&0x04 != 0,
+			Type:  int(b[0] & 0x03),
+			Data:  [2]byte{b[1], b[2]},
+		})
+	}
+
+	return triplets, nil
+}
+
+// FromSEI collects the cc_data of the SEI messages of one access unit.
+func FromSEI(msgs []*h264.SEIMessage) ([]Triplet, error) {
+	var triplets []Triplet
+	for _, msg := range msgs {
+		ud, ok := msg.Payload.(*h264.UserDataRegistered)
+		if !ok || ud.CountryCode != t35CountryUSA {
+			continue
+		}
+
+		cc, err := ParseA53(ud.Data)
+		if err == ErrNoCCData {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		triplets = append(triplets, cc...)
+	}
+
+	return triplets, nil
+}
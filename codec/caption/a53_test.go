@@ -0,0 +1,59 @@
+package caption
+
+import (
+	"testing"
+
+	"media-go/codec/h264"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a53 builds the T.35 payload after the country code carrying cc_data with
+// the given field 1 byte pairs.
+func a53(pairs ...byte) []byte {
+	data := []byte{0x00, 0x31, 'G', 'A', '9', '4', 0x03, 0x40 | byte(len(pairs)/2), 0xff}
+	for i := 0; i < len(pairs); i += 2 {
+		data = append(data, 0xfc, pairs[i], pairs[i+1])
+	}
+
+	return append(data, 0xff)
+}
+
+func TestParseA53(t *testing.T) {
+	triplets, err := ParseA53([]byte{0x00, 0x31, 'G', 'A', '9', '4', 0x03, 0x42, 0xff,
+		0xfc, 0x94, 0x20, 0xfd, 0x80, 0x80, 0xff})
+	assert.Nil(t, err)
+	assert.Equal(t, []Triplet{
+		{Valid: true, Type: CC_TYPE_NTSC_FIELD1, Data: [2]byte{0x94, 0x20}},
+		{Valid: true, Type: CC_TYPE_NTSC_FIELD2, Data: [2]byte{0x80, 0x80}},
+	}, triplets)
+
+	// process_cc_data_flag unset
+	triplets, err = ParseA53([]byte{0x00, 0x31, 'G', 'A', '9', '4', 0x03, 0x02, 0xff})
+	assert.Nil(t, err)
+	assert.Empty(t, triplets)
+
+	_, err = ParseA53([]byte{0x00, 0x2f, 'D', 'T', 'G', '1', 0x03})
+	assert.Equal(t, ErrNoCCData, err)
+	_, err = ParseA53([]byte{0x00, 0x31, 'G', 'A', '9', '4', 0x06, 0x42, 0xff})
+	assert.Equal(t, ErrNoCCData, err)
+	_, err = ParseA53([]byte{0x00, 0x31, 'G', 'A', '9', '4', 0x03, 0x42, 0xff, 0xfc, 0x94})
+	assert.Equal(t, ErrInvalidCCData, err)
+}
+
+func TestFromSEI(t *testing.T) {
+	msgs := []*h264.SEIMessage{
+		{Type: h264.SEI_RECOVERY_POINT, Payload: &h264.RecoveryPoint{}},
+		{Type: h264.SEI_USER_DATA_REGISTERED, Payload: &h264.UserDataRegistered{CountryCode: 0x26, Data: a53(0x41, 0x42)}},
+		{Type: h264.SEI_USER_DATA_REGISTERED, Payload: &h264.UserDataRegistered{CountryCode: 0xb5, Data: a53(0x41, 0x42)}},
+		{Type: h264.SEI_USER_DATA_REGISTERED, Payload: &h264.UserDataRegistered{CountryCode: 0xb5, Data: []byte{0x00, 0x2f}}},
+	}
+
+	triplets, err := FromSEI(msgs)
+	assert.Nil(t, err)
+	assert.Equal(t, []Triplet{{Valid: true, Data: [2]byte{0x41, 0x42}}}, triplets)
+
+	msgs[2].Payload.(*h264.UserDataRegistered).Data = a53(0x41, 0x42)[:10]
+	_, err = FromSEI(msgs)
+	assert.Equal(t, ErrInvalidCCData, err)
+}
@@ -0,0 +1,337 @@
+package caption
+
+import (
+	"strings"
+)
+
+const (
+	rows    = 15
+	columns = 32
+)
+
+const (
+	modePopOn = iota
+	modeRollUp
+	modePaintOn
+	modeText // text service, not a caption
+)
+
+// characters that differ from ASCII in the basic set, CEA-608 Table 50
+var basicChars = map[byte]rune{
+	0x2a: 'á', 0x5c: 'é', 0x5e: 'í', 0x5f: 'ó', 0x60: 'ú',
+	0x7b: 'ç', 0x7c: '÷', 0x7d: 'Ñ', 0x7e: 'ñ', 0x7f: '█',
+}
+
+// special characters 0x11 0x30-0x3f, Table 49, the transparent space
+// as a space
+var specialChars = []rune("®°½¿™¢£♪à èâêîôû")
+
+// extended characters 0x12 0x20-0x3f and 0x13 0x20-0x3f, Tables 5 and 6
+var extendedChars = [2][]rune{
+	[]rune("ÁÉÓÚÜü‘¡*’─©℠•“”ÀÂÇÈÊËëÎÏïÔÙùÛ«»"),
+	[]rune("ÃãÍÌìÒòÕõ{}\\^_|~ÄäÖöß¥¤│ÅåØø┌┐└┘"),
+}
+
+// rows of a preamble address code by the low bits of the first byte and
+// bit 5 of the second, 0 based
+var pacRows = [8][2]int{{10, 10}, {0, 1}, {2, 3}, {11, 12}, {13, 14}, {4, 5}, {6, 7}, {8, 9}}
+
+type screen [rows][columns]rune
+
+func (s *screen) clear() { *s = screen{} }
+
+// text returns the rows with characters, trimmed, one per line.
+func (s *screen) text() string {
+	var lines []string
+	for _, row := range s {
+		line := strings.TrimSpace(strings.Map(func(r rune) rune {
+			if r == 0 {
+				return ' '
+			}
+			return r
+		}, string(row[:])))
+
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Cue is a caption shown from Start to End, in milliseconds.
+type Cue struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Decoder turns the CEA-608 byte pairs of one caption channel into cues.
+// Roll-up and paint-on captions become a cue per completed line, pop-on
+// captions a cue per displayed caption.
+type Decoder struct {
+	channel int // 1 to 4
+	active  bool
+	xds     bool // inside an XDS packet, its characters are not captions
+	mode    int
+	rollUp  int // rows of the roll-up window
+	base    int // bottom row of the roll-up window
+
+	displayed screen
+	hidden    screen
+	row, col  int
+	dirty     bool // displayed memory changed since the last cue
+	changed   int  // when it first changed
+
+	last [2]byte // last control code, sent twice for robustness
+	cue  *Cue    // the cue on screen
+	cues []*Cue
+}
+
+// NewDecoder decodes channel CC1 to CC4, CC1 and CC2 are carried in field 1,
+// CC3 and CC4 in field 2.
+func NewDecoder(channel int) *Decoder {
+	return &Decoder{channel: channel, base: rows - 1}
+}
+
+// Field returns the cc_type of the field the channel is carried in.
+func (d *Decoder) Field() int {
+	if d.channel > 2 {
+		return CC_TYPE_NTSC_FIELD2
+	}
+
+	return CC_TYPE_NTSC_FIELD1
+}
+
+// Decode takes the next byte pair of the channel's field, shown at ms, and
+// returns the cues completed by it.
+func (d *Decoder) Decode(ms int, b1, b2 byte) []*Cue {
+	b1, b2 = b1&0x7f, b2&0x7f // drop the parity bits
+	if b1 == 0 && b2 == 0 {
+		return d.take()
+	}
+
+	// XDS packets start with a class code 0x01-0x0e and end with 0x0f and
+	// the checksum, a caption control code also resumes the captions
+	if b1 >= 0x01 && b1 <= 0x0f {
+		d.xds = b1 != 0x0f
+		d.last = [2]byte{}
+		return d.take()
+	}
+
+	if b1 >= 0x10 && b1 <= 0x1f {
+		d.xds = false
+		if d.last == [2]byte{b1, b2} {
+			d.last = [2]byte{}
+			return d.take()
+		}
+		d.last = [2]byte{b1, b2}
+
+		// the data channel within the field
+		d.active = (b1&0x08 != 0) == (d.channel%2 == 0)
+		if d.active {
+			d.control(ms, b1&^0x08, b2)
+		}
+
+		return d.take()
+	}
+
+	d.last = [2]byte{}
+	if d.active && !d.xds && d.mode != modeText {
+		for _, b := range []byte{b1, b2} {
+			if b >= 0x20 {
+				d.put(ms, basicChar(b))
+			}
+		}
+	}
+
+	return d.take()
+}
+
+// Flush closes the cue on screen at ms.
+func (d *Decoder) Flush(ms int) []*Cue {
+	if d.dirty {
+		d.commit(d.changed)
+	}
+
+	if d.cue != nil {
+		d.cue.End = ms
+		d.emit(d.cue)
+		d.cue = nil
+	}
+
+	return d.take()
+}
+
+func (d *Decoder) take() []*Cue {
+	cues := d.cues
+	d.cues = nil
+	return cues
+}
+
+func basicChar(b byte) rune {
+	if r, ok := basicChars[b]; ok {
+		return r
+	}
+
+	return rune(b)
+}
+
+// memory is the memory characters go to: non-displayed for pop-on
+// captions, displayed otherwise.
+func (d *Decoder) memory() *screen {
+	if d.mode == modePopOn {
+		return &d.hidden
+	}
+
+	return &d.displayed
+}
+
+func (d *Decoder) put(ms int, r rune) {
+	d.memory()[d.row][d.col] = r
+	if d.col < columns-1 {
+		d.col++
+	}
+
+	d.touch(ms)
+}
+
+// touch notes a change of the memory characters go to at ms, roll-up and
+// paint-on text is shown as it arrives.
+func (d *Decoder) touch(ms int) {
+	if d.mode == modePopOn || d.dirty {
+		return
+	}
+
+	d.dirty = true
+	d.changed = ms
+}
+
+func (d *Decoder) control(ms int, b1, b2 byte) {
+	// roll-up and paint-on text changed since the last control code
+	if d.dirty {
+		d.commit(d.changed)
+	}
+
+	switch {
+	case (b1 == 0x14 || b1 == 0x15) && b2 >= 0x20 && b2 <= 0x2f:
+		d.command(ms, b2)
+	case b1 == 0x17 && b2 >= 0x21 && b2 <= 0x23: // tab offset
+		d.col += int(b2 - 0x20)
+		if d.col >= columns {
+			d.col = columns - 1
+		}
+	case b1 == 0x11 && b2 >= 0x20 && b2 <= 0x2f: // mid-row code, shown as a space
+		d.put(ms, ' ')
+	case b1 == 0x11 && b2 >= 0x30 && b2 <= 0x3f:
+		d.put(ms, specialChars[b2-0x30])
+	case (b1 == 0x12 || b1 == 0x13) && b2 >= 0x20 && b2 <= 0x3f:
+		// replaces the standard character sent before for older decoders
+		if d.col > 0 {
+			d.col--
+		}
+		d.put(ms, extendedChars[b1-0x12][b2-0x20])
+	case b2 >= 0x40:
+		d.preamble(b1, b2)
+	}
+}
+
+// preamble handles a preamble address code: the row and indent of the
+// following text.
+func (d *Decoder) preamble(b1, b2 byte) {
+	row := pacRows[b1&0x07][(b2>>5)&0x01]
+	if d.mode == modeRollUp && row != d.base {
+		// move the roll-up window to the new base row
+		moved := screen{}
+		for i := 0; i < d.rollUp; i++ {
+			from, to := d.base-i, row-i
+			if from >= 0 && to >= 0 {
+				moved[to] = d.displayed[from]
+			}
+		}
+		d.displayed = moved
+		d.base = row
+	}
+
+	d.row, d.col = row, 0
+	if b2&0x10 != 0 {
+		d.col = int(b2&0x0e) << 1
+	}
+}
+
+func (d *Decoder) command(ms int, cmd byte) {
+	switch cmd {
+	case 0x20: // resume caption loading
+		d.mode = modePopOn
+	case 0x21: // backspace
+		if d.col > 0 {
+			d.col--
+			d.memory()[d.row][d.col] = 0
+			d.touch(ms)
+		}
+	case 0x24: // delete to end of row
+		for i := d.col; i < columns; i++ {
+			d.memory()[d.row][i] = 0
+		}
+		d.touch(ms)
+	case 0x25, 0x26, 0x27: // roll-up captions, 2 to 4 rows
+		if d.mode != modeRollUp {
+			d.mode = modeRollUp
+			d.displayed.clear()
+			d.hidden.clear()
+			d.commit(ms)
+			d.base, d.row, d.col = rows-1, rows-1, 0
+		}
+		d.rollUp = int(cmd-0x25) + 2
+	case 0x29: // resume direct captioning
+		d.mode = modePaintOn
+	case 0x2a, 0x2b: // text restart, resume text display
+		d.mode = modeText
+	case 0x2c: // erase displayed memory
+		d.displayed.clear()
+		d.commit(ms)
+	case 0x2d: // carriage return
+		if d.mode == modeRollUp {
+			for i := d.base - d.rollUp + 1; i < d.base; i++ {
+				if i >= 0 {
+					d.displayed[i] = d.displayed[i+1]
+				}
+			}
+			d.displayed[d.base] = [columns]rune{}
+			d.row, d.col = d.base, 0
+			d.commit(ms)
+		}
+	case 0x2e: // erase non-displayed memory
+		d.hidden.clear()
+	case 0x2f: // end of caption, flip memories
+		d.mode = modePopOn
+		d.displayed, d.hidden = d.hidden, d.displayed
+		d.commit(ms)
+	}
+}
+
+// commit ends the cue on screen at ms if the displayed text changed, and
+// starts one with the new text.
+func (d *Decoder) commit(ms int) {
+	d.dirty = false
+	text := d.displayed.text()
+	if d.cue != nil && d.cue.Text == text {
+		return
+	}
+
+	if d.cue != nil {
+		d.cue.End = ms
+		d.emit(d.cue)
+		d.cue = nil
+	}
+
+	if len(text) > 0 {
+		d.cue = &Cue{Start: ms, Text: text}
+	}
+}
+
+func (d *Decoder) emit(cue *Cue) {
+	if cue.End > cue.Start {
+		d.cues = append(d.cues, cue)
+	}
+}
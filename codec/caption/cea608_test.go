@@ -0,0 +1,85 @@
+package caption
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decode feeds the byte pairs at ms and collects the completed cues.
+func decode(d *Decoder, ms int, pairs ...byte) []*Cue {
+	var cues []*Cue
+	for i := 0; i < len(pairs); i += 2 {
+		cues = append(cues, d.Decode(ms, pairs[i], pairs[i+1])...)
+	}
+
+	return cues
+}
+
+func TestDecoderPopOn(t *testing.T) {
+	d := NewDecoder(1)
+	assert.Equal(t, CC_TYPE_NTSC_FIELD1, d.Field())
+
+	// resume caption loading and erase non-displayed memory sent twice, with
+	// parity bits
+	assert.Empty(t, decode(d, 0, 0x94, 0x20, 0x94, 0x20, 0x94, 0xae, 0x94, 0xae))
+	// row 14 then row 15, "HELLO" and "WORLD"
+	assert.Empty(t, decode(d, 0, 0x94, 0x50, 0x48, 0x45, 0x4c, 0x4c, 0x4f, 0x80,
+		0x94, 0x70, 0x57, 0x4f, 0x52, 0x4c, 0x44, 0x80))
+	// end of caption shows it, nothing is complete yet
+	assert.Empty(t, decode(d, 1000, 0x94, 0x2f, 0x94, 0x2f))
+
+	// erase displayed memory ends it
+	cues := decode(d, 3000, 0x94, 0x2c)
+	assert.Equal(t, []*Cue{{Start: 1000, End: 3000, Text: "HELLO\nWORLD"}}, cues)
+	assert.Empty(t, d.Flush(4000))
+}
+
+func TestDecoderRollUp(t *testing.T) {
+	d := NewDecoder(1)
+	assert.Empty(t, decode(d, 0, 0x14, 0x25, 0x14, 0x70))
+	assert.Empty(t, decode(d, 100, 0x41, 0x42))
+	assert.Empty(t, decode(d, 500, 0x14, 0x2d))
+	assert.Empty(t, decode(d, 600, 0x43, 0x44))
+
+	// text shows as it arrives, the carriage return rolls the second line
+	// up and the first out of the two row window
+	cues := decode(d, 1000, 0x14, 0x2d)
+	assert.Equal(t, []*Cue{{Start: 100, End: 600, Text: "AB"}, {Start: 600, End: 1000, Text: "AB\nCD"}}, cues)
+	assert.Equal(t, []*Cue{{Start: 1000, End: 2000, Text: "CD"}}, d.Flush(2000))
+
+	// three rows
+	d = NewDecoder(1)
+	decode(d, 0, 0x14, 0x26, 0x41, 0x80, 0x14, 0x2d, 0x42, 0x80, 0x14, 0x2d, 0x43, 0x80, 0x14, 0x2d)
+	decode(d, 100, 0x44, 0x80)
+	assert.Equal(t, []*Cue{{Start: 0, End: 100, Text: "B\nC"}, {Start: 100, End: 200, Text: "B\nC\nD"}}, d.Flush(200))
+}
+
+func TestDecoderCharacters(t *testing.T) {
+	d := NewDecoder(1)
+	// paint-on: á, a note, "e" replaced by É, a tab and ñ
+	decode(d, 0, 0x14, 0x29, 0x14, 0x70, 0x2a, 0x80, 0x11, 0x37, 0x65, 0x80,
+		0x12, 0x21, 0x17, 0x22, 0x7e, 0x80)
+	assert.Equal(t, []*Cue{{Start: 0, End: 100, Text: "á♪É  ñ"}}, d.Flush(100))
+}
+
+func TestDecoderChannels(t *testing.T) {
+	d := NewDecoder(2)
+	assert.Equal(t, CC_TYPE_NTSC_FIELD1, d.Field())
+	assert.Equal(t, CC_TYPE_NTSC_FIELD2, NewDecoder(3).Field())
+
+	// CC1 text is skipped, CC2 control codes have the channel bit set
+	decode(d, 0, 0x14, 0x29, 0x14, 0x70, 0x41, 0x80)
+	decode(d, 0, 0x1c, 0x29, 0x1c, 0x70, 0x42, 0x80)
+	assert.Equal(t, []*Cue{{Start: 0, End: 100, Text: "B"}}, d.Flush(100))
+}
+
+func TestDecoderXDS(t *testing.T) {
+	d := NewDecoder(3)
+	decode(d, 0, 0x15, 0x29, 0x15, 0x70, 0x41, 0x80)
+	// a program name packet between the captions, its end and checksum
+	decode(d, 0, 0x01, 0x03, 0x54, 0x56, 0x0f, 0x1d, 0x42, 0x80)
+	// a caption control code ends an XDS packet as well
+	decode(d, 0, 0x01, 0x05, 0x54, 0x56, 0x17, 0x21, 0x43, 0x80)
+	assert.Equal(t, []*Cue{{Start: 0, End: 100, Text: "AB C"}}, d.Flush(100))
+}
@@ -0,0 +1,113 @@
+package caption
+
+import (
+	"errors"
+	"sort"
+
+	"media-go/codec/h264"
+)
+
+// reorderDepth is the most pictures held back for presentation order, the
+// largest decoded picture buffer of Annex A.
+const reorderDepth = 16
+
+var ErrInvalidChannel = errors.New("caption: channel must be 1 to 4")
+
+type picture struct {
+	poc      int
+	pts      int
+	triplets []Triplet
+}
+
+// Extractor decodes the CEA-608 captions of one channel carried in H.264
+// SEI. Access units are passed in decoding order; their cc_data is decoded
+// in presentation order, sorted by picture order count.
+type Extractor struct {
+	dec     *Decoder
+	w       CueWriter
+	pending []*picture
+	last    int // latest presentation time seen
+	cues    int
+}
+
+func NewExtractor(channel int, w CueWriter) (*Extractor, error) {
+	if channel < 1 || channel > 4 {
+		return nil, ErrInvalidChannel
+	}
+
+	return &Extractor{dec: NewDecoder(channel), w: w}, nil
+}
+
+// Cues returns the number of cues written.
+func (e *Extractor) Cues() int { return e.cues }
+
+// Push takes an access unit presented at pts milliseconds.
+func (e *Extractor) Push(pts int, vf *h264.VideoFrameInfo) error {
+	if vf.Slice == nil {
+		return nil
+	}
+
+	triplets, err := FromSEI(vf.SEI)
+	if err != nil {
+		return err
+	}
+
+	if pts > e.last {
+		e.last = pts
+	}
+
+	// order counts restart, everything before is presented first
+	if vf.Slice.ResetsOrder() {
+		if err := e.drain(0); err != nil {
+			return err
+		}
+	}
+
+	pic := &picture{poc: vf.Slice.PicOrderCnt, pts: pts, triplets: triplets}
+	i := sort.Search(len(e.pending), func(i int) bool { return e.pending[i].poc > pic.poc })
+	e.pending = append(e.pending, nil)
+	copy(e.pending[i+1:], e.pending[i:])
+	e.pending[i] = pic
+
+	return e.drain(reorderDepth)
+}
+
+// Flush decodes the held back pictures and ends the last cue.
+func (e *Extractor) Flush() error {
+	if err := e.drain(0); err != nil {
+		return err
+	}
+
+	return e.write(e.dec.Flush(e.last))
+}
+
+// drain decodes pictures until at most depth are held back.
+func (e *Extractor) drain(depth int) error {
+	for len(e.pending) > depth {
+		pic := e.pending[0]
+		e.pending = e.pending[1:]
+
+		for _, t := range pic.triplets {
+			if !t.Valid || t.Type != e.dec.Field() {
+				continue
+			}
+
+			if err := e.write(e.dec.Decode(pic.pts, t.Data[0], t.Data[1])); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *Extractor) write(cues []*Cue) error {
+	for _, cue := range cues {
+		if err := e.w.WriteCue(cue); err != nil {
+			return err
+		}
+		e.cues++
+	}
+
+	return nil
+}
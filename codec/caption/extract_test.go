@@ -0,0 +1,44 @@
+package caption
+
+import (
+	"bytes"
+	"testing"
+
+	"media-go/codec/h264"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unit builds an access unit with the given order count and field 1
+// cc_data.
+func unit(nalType, poc int, pairs ...byte) *h264.VideoFrameInfo {
+	return &h264.VideoFrameInfo{
+		Slice: &h264.SliceHeader{NalUnitType: nalType, PicOrderCnt: poc},
+		SEI: []*h264.SEIMessage{{Type: h264.SEI_USER_DATA_REGISTERED,
+			Payload: &h264.UserDataRegistered{CountryCode: 0xb5, Data: a53(pairs...)}}},
+	}
+}
+
+func TestExtractor(t *testing.T) {
+	_, err := NewExtractor(5, nil)
+	assert.Equal(t, ErrInvalidChannel, err)
+
+	var out bytes.Buffer
+	ex, err := NewExtractor(1, NewSRTWriter(&out))
+	assert.Nil(t, err)
+
+	// decoding order I P B P, the B picture carries the first characters
+	assert.Nil(t, ex.Push(0, unit(h264.NAL_IDR_SLICE, 0, 0x94, 0x20, 0x94, 0x70)))
+	assert.Nil(t, ex.Push(200, unit(h264.NAL_SLICE, 4, 0x43, 0x44)))
+	assert.Nil(t, ex.Push(100, unit(h264.NAL_SLICE, 2, 0x41, 0x42)))
+	assert.Nil(t, ex.Push(300, unit(h264.NAL_SLICE, 6, 0x94, 0x2f)))
+	// a new IDR picture presents everything before it
+	assert.Nil(t, ex.Push(1300, unit(h264.NAL_IDR_SLICE, 0, 0x94, 0x2c)))
+	assert.Equal(t, 0, out.Len())
+
+	// pictures without slices are skipped
+	assert.Nil(t, ex.Push(1400, &h264.VideoFrameInfo{}))
+	assert.Nil(t, ex.Flush())
+	assert.Equal(t, 1, ex.Cues())
+	assert.Equal(t, "1\n00:00:00,300 --> 00:00:01,300\nABCD\n\n", out.String())
+}
@@ -0,0 +1,65 @@
+package caption
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CueWriter writes cues in order of their start.
+type CueWriter interface {
+	WriteCue(cue *Cue) error
+}
+
+// timestamp formats ms as HH:MM:SS followed by sep and the milliseconds.
+func timestamp(ms int, sep string) string {
+	if ms < 0 {
+		ms = 0
+	}
+
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// SRTWriter writes SubRip subtitles.
+type SRTWriter struct {
+	w     io.Writer
+	count int
+}
+
+func NewSRTWriter(w io.Writer) *SRTWriter {
+	return &SRTWriter{w: w}
+}
+
+func (sw *SRTWriter) WriteCue(cue *Cue) error {
+	sw.count++
+	_, err := fmt.Fprintf(sw.w, "%d\n%s --> %s\n%s\n\n",
+		sw.count, timestamp(cue.Start, ","), timestamp(cue.End, ","), cue.Text)
+	return err
+}
+
+// WebVTTWriter writes WebVTT subtitles, the file header goes out with the
+// first cue.
+type WebVTTWriter struct {
+	w      io.Writer
+	header bool
+}
+
+func NewWebVTTWriter(w io.Writer) *WebVTTWriter {
+	return &WebVTTWriter{w: w}
+}
+
+func (vw *WebVTTWriter) WriteCue(cue *Cue) error {
+	if !vw.header {
+		vw.header = true
+		if _, err := io.WriteString(vw.w, "WEBVTT\n\n"); err != nil {
+			return err
+		}
+	}
+
+	// cue text is markup, and "-->" may not appear in it
+	text := strings.Replace(cue.Text, "&", "&amp;", -1)
+	text = strings.Replace(text, "<", "&lt;", -1)
+	text = strings.Replace(text, "-->", "--&gt;", -1)
+	_, err := fmt.Fprintf(vw.w, "%s --> %s\n%s\n\n", timestamp(cue.Start, "."), timestamp(cue.End, "."), text)
+	return err
+}
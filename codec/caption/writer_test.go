@@ -0,0 +1,27 @@
+package caption
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSRTWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewSRTWriter(&out)
+	assert.Nil(t, w.WriteCue(&Cue{Start: 1000, End: 3500, Text: "HELLO\nWORLD"}))
+	assert.Nil(t, w.WriteCue(&Cue{Start: 3723004, End: 3724000, Text: "<b>"}))
+	assert.Equal(t, "1\n00:00:01,000 --> 00:00:03,500\nHELLO\nWORLD\n\n"+
+		"2\n01:02:03,004 --> 01:02:04,000\n<b>\n\n", out.String())
+}
+
+func TestWebVTTWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWebVTTWriter(&out)
+	assert.Equal(t, 0, out.Len())
+	assert.Nil(t, w.WriteCue(&Cue{Start: 1000, End: 3500, Text: "A & <B> -->"}))
+	assert.Nil(t, w.WriteCue(&Cue{Start: 3500, End: 4000, Text: "C"}))
+	assert.Equal(t, "WEBVTT\n\n00:00:01.000 --> 00:00:03.500\nA &amp; &lt;B> --&gt;\n\n"+
+		"00:00:03.500 --> 00:00:04.000\nC\n\n", out.String())
+}
@@ -1,30 +1,16 @@
 package golomb
 
-import (
-	"media-go/core"
-)
+import "media-go/core"
 
-//Exp-Golomb
-func ReadUEV(bs *core.BitStream) int {
-	leadingZerosBit := 0
-	for {
-		x := bs.Next()
-		if x == 1 {
-			break
-		}
+var ErrInvalidCode = core.ErrInvalidCode
 
-		leadingZerosBit++
-	}
-
-	if leadingZerosBit >= 31 {
-		panic("invalid UEV")
-	}
-
-	v := (1 << leadingZerosBit) - 1
-	for i := 0; i < leadingZerosBit; i++ {
-		b := bs.Next()
-		v += b << (leadingZerosBit - 1 - i)
-	}
+// ReadUE reads ue(v), running out of data or a code longer than 32 bits is
+// an error.
+func ReadUE(bs *core.BitStream) (int, error) {
+	return bs.ReadUE()
+}
 
-	return v
+// ReadSE reads se(v).
+func ReadSE(bs *core.BitStream) (int, error) {
+	return bs.ReadSE()
 }
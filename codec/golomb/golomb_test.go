@@ -0,0 +1,32 @@
+package golomb
+
+import (
+	"testing"
+
+	"media-go/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadUE(t *testing.T) {
+	// 1, 010, 011, 00100, 0001000
+	bs := core.NewBitStream([]byte{0xa6, 0x41, 0x00})
+	for _, want := range []int{0, 1, 2, 3, 7} {
+		v, err := ReadUE(bs)
+		assert.Nil(t, err)
+		assert.Equal(t, want, v)
+	}
+
+	_, err := ReadUE(bs)
+	assert.Equal(t, ErrInvalidCode, err)
+}
+
+func TestReadSE(t *testing.T) {
+	// ue 1, 2, 3, 4 are se 1, -1, 2, -2
+	bs := core.NewBitStream([]byte{0x4c, 0x85, 0x00})
+	for _, want := range []int{1, -1, 2, -2} {
+		v, err := ReadSE(bs)
+		assert.Nil(t, err)
+		assert.Equal(t, want, v)
+	}
+}
@@ -0,0 +1,224 @@
+package h264
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"media-go/codec/golomb"
+	"media-go/core"
+)
+
+var StartCode = []byte{0, 0, 0, 1}
+
+var (
+	ErrInvalidConfig = errors.New("h264: invalid AVCDecoderConfigurationRecord")
+	ErrInvalidNalu   = errors.New("h264: NALU length exceeds the frame")
+	ErrNoConfig      = errors.New("h264: frame before the decoder configuration")
+)
+
+// NaluError reports a NALU of an access unit that could not be split or
+// decoded, Offset is its position in the payload and Size its length or the
+// bytes left for a truncated length field.
+type NaluError struct {
+	Offset int
+	Size   int
+	Err    error
+}
+
+func (e *NaluError) Error() string {
+	return fmt.Sprintf("%v: NALU of %d bytes at offset %d", e.Err, e.Size, e.Offset)
+}
+
+func (e *NaluError) Unwrap() error { return e.Err }
+
+// SplitNalus splits length-prefixed NALUs as found in FLV and MP4 samples,
+// naluSize is the length field size from the decoder configuration.
+func SplitNalus(data []byte, naluSize int) ([][]byte, error) {
+	if naluSize < 1 || naluSize > 4 {
+		return nil, ErrInvalidConfig
+	}
+
+	nalus := make([][]byte, 0, 1)
+	for len(data) > 0 {
+		if len(data) < naluSize {
+			return nil, ErrInvalidNalu
+		}
+
+		length := 0
+		for _, b := range data[:naluSize] {
+			length = length<<8 | int(b)
+		}
+		data = data[naluSize:]
+
+		if length > len(data) {
+			return nil, ErrInvalidNalu
+		}
+
+		nalus = append(nalus, data[:length])
+		data = data[length:]
+	}
+
+	return nalus, nil
+}
+
+// FindStartCode returns the position and size (3 or 4) of the first start
+// code at or after from, -1 if there is none.
+func FindStartCode(data []byte, from int) (int, int) {
+	for i := from; i+3 <= len(data); i++ {
+		if data[i+2] > 1 {
+			i += 2
+			continue
+		}
+
+		if data[i] == 0 && data[i+1] == 0 && data[i+2] == 1 {
+			if i > from && data[i-1] == 0 {
+				return i - 1, 4
+			}
+			return i, 3
+		}
+	}
+
+	return -1, 0
+}
+
+// SplitAnnexB returns the NALUs of an Annex B byte stream, trailing zero
+// bytes before a start code are dropped.
+func SplitAnnexB(data []byte) [][]byte {
+	nalus := make([][]byte, 0, 1)
+
+	pos, size := FindStartCode(data, 0)
+	for pos >= 0 {
+		start := pos + size
+		next, nextSize := FindStartCode(data, start)
+
+		end := next
+		if end < 0 {
+			end = len(data)
+		}
+
+		for end > start && data[end-1] == 0 {
+			end--
+		}
+
+		if end > start {
+			nalus = append(nalus, data[start:end])
+		}
+
+		pos, size = next, nextSize
+	}
+
+	return nalus
+}
+
+// FirstMbInSlice reads first_mb_in_slice from a slice NALU, header byte
+// included. Zero starts a new picture.
+func FirstMbInSlice(nalu []byte) (int, error) {
+	if len(nalu) > 16 {
+		nalu = nalu[:16]
+	}
+
+	return golomb.ReadUE(core.NewBitStream(decodeNalu(nalu).Rbsp))
+}
+
+// AnnexBWriter converts AVCC samples into an Annex B byte stream. The SPS and
+// PPS of the decoder configuration are repeated in every IDR access unit that
+// does not carry its own, so the stream can be decoded from any key frame.
+type AnnexBWriter struct {
+	w        io.Writer
+	naluSize int
+	sps      [][]byte
+	pps      [][]byte
+}
+
+func NewAnnexBWriter(w io.Writer) *AnnexBWriter {
+	return &AnnexBWriter{w: w}
+}
+
+// WriteConfig takes an AVCDecoderConfigurationRecord, a later one replaces
+// the parameter sets in use.
+func (aw *AnnexBWriter) WriteConfig(record []byte) error {
+	config, err := ParseAVCConfig(record)
+	if err != nil {
+		return err
+	}
+
+	aw.naluSize, aw.sps, aw.pps = config.NaluSize, config.SPS, config.PPS
+	return nil
+}
+
+// WriteFrame writes the length-prefixed NALUs of one sample.
+func (aw *AnnexBWriter) WriteFrame(data []byte) error {
+	if aw.naluSize == 0 {
+		return ErrNoConfig
+	}
+
+	nalus, err := SplitNalus(data, aw.naluSize)
+	if err != nil {
+		return err
+	}
+
+	var hasSPS, hasPPS, idr bool
+	for _, nalu := range nalus {
+		if len(nalu) == 0 {
+			continue
+		}
+
+		switch int(nalu[0] & 0x1f) {
+		case NAL_SPS:
+			hasSPS = true
+		case NAL_PPS:
+			hasPPS = true
+		case NAL_IDR_SLICE:
+			idr = true
+		}
+	}
+
+	// the missing sets go after the AUD, an SPS ahead of any PPS
+	needSPS, needPPS := idr && !hasSPS, idr && !hasPPS
+	for _, nalu := range nalus {
+		if len(nalu) == 0 {
+			continue
+		}
+
+		typ := int(nalu[0] & 0x1f)
+		if needSPS && typ != NAL_AUD {
+			if err := aw.writeNalus(aw.sps); err != nil {
+				return err
+			}
+			needSPS = false
+		}
+
+		if needPPS && typ != NAL_AUD && typ != NAL_SPS {
+			if err := aw.writeNalus(aw.pps); err != nil {
+				return err
+			}
+			needPPS = false
+		}
+
+		if err := aw.writeNalu(nalu); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (aw *AnnexBWriter) writeNalus(nalus [][]byte) error {
+	for _, nalu := range nalus {
+		if err := aw.writeNalu(nalu); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (aw *AnnexBWriter) writeNalu(nalu []byte) error {
+	if _, err := aw.w.Write(StartCode); err != nil {
+		return err
+	}
+
+	_, err := aw.w.Write(nalu)
+	return err
+}
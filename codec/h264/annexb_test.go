@@ -0,0 +1,84 @@
+package h264
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRecord = []byte{1, 0x64, 0, 0x1f, 0xff,
+	0xe1, 0, 3, 0x67, 0x64, 0x1f,
+	1, 0, 2, 0x68, 0xee}
+
+func TestSplitNalus(t *testing.T) {
+	nalus, err := SplitNalus([]byte{0, 0, 0, 2, 0x09, 0xf0, 0, 0, 0, 1, 0x65}, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{{0x09, 0xf0}, {0x65}}, nalus)
+
+	nalus, err = SplitNalus([]byte{0, 1, 0x41, 0, 1, 0x41}, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(nalus))
+
+	_, err = SplitNalus([]byte{0, 0, 0, 5, 0x65}, 4)
+	assert.Equal(t, ErrInvalidNalu, err)
+
+	_, err = SplitNalus([]byte{0, 0, 0}, 4)
+	assert.Equal(t, ErrInvalidNalu, err)
+}
+
+func TestAnnexBWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewAnnexBWriter(&buf)
+	assert.Equal(t, ErrNoConfig, w.WriteFrame([]byte{0, 0, 0, 1, 0x41}))
+	assert.Equal(t, ErrInvalidConfig, w.WriteConfig(testRecord[:8]))
+	assert.Nil(t, w.WriteConfig(testRecord))
+
+	// AUD then IDR, parameter sets go after the AUD
+	assert.Nil(t, w.WriteFrame([]byte{0, 0, 0, 2, 0x09, 0xf0, 0, 0, 0, 1, 0x65}))
+	assert.Equal(t, []byte{0, 0, 0, 1, 0x09, 0xf0,
+		0, 0, 0, 1, 0x67, 0x64, 0x1f,
+		0, 0, 0, 1, 0x68, 0xee,
+		0, 0, 0, 1, 0x65}, buf.Bytes())
+
+	buf.Reset()
+	assert.Nil(t, w.WriteFrame([]byte{0, 0, 0, 1, 0x41}))
+	assert.Equal(t, []byte{0, 0, 0, 1, 0x41}, buf.Bytes())
+
+	// in-band parameter sets are not repeated
+	buf.Reset()
+	assert.Nil(t, w.WriteFrame([]byte{0, 0, 0, 1, 0x67, 0, 0, 0, 1, 0x68, 0, 0, 0, 1, 0x65}))
+	assert.Equal(t, 3*4+3, buf.Len())
+
+	// only the missing set is added, the SPS ahead of an in-band PPS
+	buf.Reset()
+	assert.Nil(t, w.WriteFrame([]byte{0, 0, 0, 2, 0x68, 0xce, 0, 0, 0, 1, 0x65}))
+	assert.Equal(t, []byte{0, 0, 0, 1, 0x67, 0x64, 0x1f,
+		0, 0, 0, 1, 0x68, 0xce,
+		0, 0, 0, 1, 0x65}, buf.Bytes())
+
+	buf.Reset()
+	assert.Nil(t, w.WriteFrame([]byte{0, 0, 0, 2, 0x09, 0xf0, 0, 0, 0, 2, 0x67, 0x42, 0, 0, 0, 1, 0x06, 0, 0, 0, 1, 0x65}))
+	assert.Equal(t, []byte{0, 0, 0, 1, 0x09, 0xf0,
+		0, 0, 0, 1, 0x67, 0x42,
+		0, 0, 0, 1, 0x68, 0xee,
+		0, 0, 0, 1, 0x06,
+		0, 0, 0, 1, 0x65}, buf.Bytes())
+}
+
+func TestSplitAnnexB(t *testing.T) {
+	data := []byte{0xff, 0, 0, 0, 1, 0x67, 0x42, 0, 0, 1, 0x68, 0xce, 0, 0, 0, 0, 1, 0x65, 0x88, 0, 0, 3, 1}
+	nalus := SplitAnnexB(data)
+	assert.Equal(t, [][]byte{{0x67, 0x42}, {0x68, 0xce}, {0x65, 0x88, 0, 0, 3, 1}}, nalus)
+
+	pos, size := FindStartCode(data, 0)
+	assert.Equal(t, 1, pos)
+	assert.Equal(t, 4, size)
+
+	pos, size = FindStartCode(data, 5)
+	assert.Equal(t, 7, pos)
+	assert.Equal(t, 3, size)
+
+	pos, _ = FindStartCode([]byte{0x65, 0x88, 0, 0}, 0)
+	assert.Equal(t, -1, pos)
+}
@@ -0,0 +1,176 @@
+package h264
+
+import (
+	"encoding/binary"
+)
+
+// AVCConfig is the AVCDecoderConfigurationRecord of ISO/IEC 14496-15 5.3.3,
+// the AVC sequence header of FLV and the payload of the MP4 avcC box.
+type AVCConfig struct {
+	ConfigurationVersion int
+	ProfileIndication    int
+	ProfileCompatibility int
+	LevelIndication      int
+	NaluSize             int // lengthSizeMinusOne + 1
+	SPS                  [][]byte
+	PPS                  [][]byte
+
+	// trailer of the High profiles, many muxers leave it out
+	HasExtension         bool
+	ChromaFormat         int
+	BitDepthLumaMinus8   int
+	BitDepthChromaMinus8 int
+	SPSExt               [][]byte
+}
+
+// hasConfigExtension lists the profiles the record trailer is defined for.
+func hasConfigExtension(profile int) bool {
+	return profile == 100 || profile == 110 || profile == 122 || profile == 144
+}
+
+// NewAVCConfig builds a record with 4 byte NALU lengths. Profile and level
+// come from the first SPS, which is decoded for the High profile trailer.
+func NewAVCConfig(sps, pps [][]byte) (*AVCConfig, error) {
+	if len(sps) == 0 || len(sps[0]) < 4 {
+		return nil, ErrInvalidConfig
+	}
+
+	c := &AVCConfig{
+		ConfigurationVersion: 1,
+		ProfileIndication:    int(sps[0][1]),
+		ProfileCompatibility: int(sps[0][2]),
+		LevelIndication:      int(sps[0][3]),
+		NaluSize:             4,
+		SPS:                  sps,
+		PPS:                  pps,
+	}
+
+	if hasConfigExtension(c.ProfileIndication) {
+		s, err := DecodeSPS(sps[0])
+		if err != nil {
+			return nil, err
+		}
+
+		c.HasExtension = true
+		c.ChromaFormat = s.ChromaFormatIdc
+		c.BitDepthLumaMinus8 = s.BitDepthLumaMinus8
+		c.BitDepthChromaMinus8 = s.BitDepthChromaMinus8
+	}
+
+	return c, nil
+}
+
+// configReader reads big-endian fields, the first out of range read sets
+// ok to false.
+type configReader struct {
+	data []byte
+	pos  int
+	ok   bool
+}
+
+func (r *configReader) u8() int {
+	if !r.ok || r.pos >= len(r.data) {
+		r.ok = false
+		return 0
+	}
+
+	r.pos++
+	return int(r.data[r.pos-1])
+}
+
+func (r *configReader) sets(count int) [][]byte {
+	var sets [][]byte
+	for i := 0; i < count && r.ok; i++ {
+		size := r.u8()<<8 | r.u8()
+		if !r.ok || r.pos+size > len(r.data) {
+			r.ok = false
+			return nil
+		}
+
+		sets = append(sets, r.data[r.pos:r.pos+size])
+		r.pos += size
+	}
+
+	return sets
+}
+
+// ParseAVCConfig parses a record, the parameter sets share data.
+func ParseAVCConfig(data []byte) (*AVCConfig, error) {
+	r := &configReader{data: data, ok: true}
+	c := &AVCConfig{}
+
+	c.ConfigurationVersion = r.u8()
+	c.ProfileIndication = r.u8()
+	c.ProfileCompatibility = r.u8()
+	c.LevelIndication = r.u8()
+	c.NaluSize = r.u8()&0x03 + 1
+	c.SPS = r.sets(r.u8() & 0x1f)
+	c.PPS = r.sets(r.u8())
+
+	if !r.ok {
+		return nil, ErrInvalidConfig
+	}
+
+	if hasConfigExtension(c.ProfileIndication) && r.pos+4 <= len(data) {
+		c.HasExtension = true
+		c.ChromaFormat = r.u8() & 0x03
+		c.BitDepthLumaMinus8 = r.u8() & 0x07
+		c.BitDepthChromaMinus8 = r.u8() & 0x07
+		c.SPSExt = r.sets(r.u8())
+
+		if !r.ok {
+			return nil, ErrInvalidConfig
+		}
+	}
+
+	return c, nil
+}
+
+func appendSets(data []byte, sets [][]byte) []byte {
+	for _, set := range sets {
+		var size [2]byte
+		binary.BigEndian.PutUint16(size[:], uint16(len(set)))
+		data = append(data, size[:]...)
+		data = append(data, set...)
+	}
+
+	return data
+}
+
+// Bytes serializes the record.
+func (c *AVCConfig) Bytes() ([]byte, error) {
+	if c.NaluSize < 1 || c.NaluSize > 4 || len(c.SPS) > 31 || len(c.PPS) > 255 || len(c.SPSExt) > 255 {
+		return nil, ErrInvalidConfig
+	}
+
+	for _, sets := range [][][]byte{c.SPS, c.PPS, c.SPSExt} {
+		for _, set := range sets {
+			if len(set) > 0xffff {
+				return nil, ErrInvalidConfig
+			}
+		}
+	}
+
+	data := []byte{
+		byte(c.ConfigurationVersion),
+		byte(c.ProfileIndication),
+		byte(c.ProfileCompatibility),
+		byte(c.LevelIndication),
+		0xfc | byte(c.NaluSize-1),
+		0xe0 | byte(len(c.SPS)),
+	}
+	data = appendSets(data, c.SPS)
+	data = append(data, byte(len(c.PPS)))
+	data = appendSets(data, c.PPS)
+
+	if c.HasExtension {
+		data = append(data,
+			0xfc|byte(c.ChromaFormat),
+			0xf8|byte(c.BitDepthLumaMinus8),
+			0xf8|byte(c.BitDepthChromaMinus8),
+			byte(len(c.SPSExt)))
+		data = appendSets(data, c.SPSExt)
+	}
+
+	return data, nil
+}
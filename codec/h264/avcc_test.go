@@ -0,0 +1,80 @@
+package h264
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// High profile 4:2:0, 10 bit luma
+var highSPS = []byte{0x67, 0x64, 0x00, 0x1f, 0xa7, 0x2d, 0x02, 0x83, 0xf2}
+
+func TestParseAVCConfig(t *testing.T) {
+	data := []byte{1, 0x42, 0xc0, 0x1e, 0xfd,
+		0xe2, 0, 2, 0x67, 0x42, 0, 3, 0x67, 0x42, 0x01,
+		2, 0, 2, 0x68, 0xce, 0, 2, 0x68, 0xee}
+	c, err := ParseAVCConfig(data)
+	assert.Nil(t, err)
+	assert.Equal(t, 0x42, c.ProfileIndication)
+	assert.Equal(t, 0xc0, c.ProfileCompatibility)
+	assert.Equal(t, 0x1e, c.LevelIndication)
+	assert.Equal(t, 2, c.NaluSize)
+	assert.Equal(t, [][]byte{{0x67, 0x42}, {0x67, 0x42, 0x01}}, c.SPS)
+	assert.Equal(t, [][]byte{{0x68, 0xce}, {0x68, 0xee}}, c.PPS)
+	assert.False(t, c.HasExtension)
+
+	out, err := c.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, data, out)
+
+	for i := 1; i < len(data); i++ {
+		_, err = ParseAVCConfig(data[:i])
+		assert.Equal(t, ErrInvalidConfig, err)
+	}
+}
+
+func TestAVCConfigHighProfile(t *testing.T) {
+	data := []byte{1, 0x64, 0, 0x1f, 0xff,
+		0xe1, 0, 2, 0x67, 0x64,
+		1, 0, 2, 0x68, 0xee,
+		0xfd, 0xfa, 0xf8, 1, 0, 1, 0x6d}
+	c, err := ParseAVCConfig(data)
+	assert.Nil(t, err)
+	assert.True(t, c.HasExtension)
+	assert.Equal(t, 1, c.ChromaFormat)
+	assert.Equal(t, 2, c.BitDepthLumaMinus8)
+	assert.Equal(t, 0, c.BitDepthChromaMinus8)
+	assert.Equal(t, [][]byte{{0x6d}}, c.SPSExt)
+
+	out, err := c.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, data, out)
+
+	// the trailer is optional
+	c, err = ParseAVCConfig(data[:15])
+	assert.Nil(t, err)
+	assert.False(t, c.HasExtension)
+
+	_, err = ParseAVCConfig(data[:len(data)-1])
+	assert.Equal(t, ErrInvalidConfig, err)
+}
+
+func TestNewAVCConfig(t *testing.T) {
+	c, err := NewAVCConfig([][]byte{highSPS}, [][]byte{{0x68, 0xee}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, c.NaluSize)
+	assert.Equal(t, 100, c.ProfileIndication)
+	assert.Equal(t, 31, c.LevelIndication)
+	assert.True(t, c.HasExtension)
+	assert.Equal(t, 1, c.ChromaFormat)
+	assert.Equal(t, 2, c.BitDepthLumaMinus8)
+
+	data, err := c.Bytes()
+	assert.Nil(t, err)
+	back, err := ParseAVCConfig(data)
+	assert.Nil(t, err)
+	assert.Equal(t, c, back)
+
+	_, err = NewAVCConfig(nil, nil)
+	assert.Equal(t, ErrInvalidConfig, err)
+}
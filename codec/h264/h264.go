@@ -1,12 +1,7 @@
 package h264
 
 import (
-	"bytes"
-	"encoding/binary"
-	"encoding/json"
 	"fmt"
-	"media-go/codec/golomb"
-	"media-go/core"
 )
 
 const (
@@ -79,8 +74,13 @@ var nalTypeMap = map[int]string{
 // 	return 0, false
 // }
 
+// Nalu is one NAL unit. In an access unit Offset is its position in the
+// payload after the length field and Data holds it with the header byte,
+// still escaped; Rbsp is only filled in by the parameter set decoders.
 type Nalu struct {
 	Len             int
+	Offset          int
+	Data            []byte
 	ForbiddenBit    int
 	NalReferenceIdc int
 	NalUnitType     int
@@ -113,6 +113,7 @@ type SPS struct {
 	BitDepthChromaMinus8            int        `json:"bit_deptn_chroma_minus8"`
 	QpprimeYZeroTransformBypassFlag int        `json:"qpprime_y_zero_transform_bypass_flag"`
 	SeqScalingMatrixPresentFlag     int        `json:"seq_scaling_matrix_present_flag"`
+	SeqScalingListPresentFlag       [12]int    `json:"seq_scaling_list_present_flag"`
 	ScalingMatrix4                  [6][16]int `json:"-"`
 	ScalingMatrix8                  [6][64]int `json:"-"`
 	Log2MaxFrameNumMinus4           int        `json:"log2_max_frame_num_minus4"`
@@ -136,256 +137,159 @@ type SPS struct {
 	FrameCropTopOffset              int        `json:"frame_crop_top_offset"`
 	FrameCropButtomOffset           int        `json:"frame_crop_buttom_offset"`
 	VuiParametersPresentFlag        int        `json:"vui_parameters_present_flag"`
-	//...
+	AspectRatioInfoPresentFlag      int        `json:"aspect_ratio_info_present_flag"`
+	AspectRatioIdc                  int        `json:"aspect_ratio_idc"`
+	SarWidth                        int        `json:"sar_width"`
+	SarHeight                       int        `json:"sar_height"`
+	OverscanInfoPresentFlag         int        `json:"overscan_info_present_flag"`
+	OverscanAppropriateFlag         int        `json:"overscan_appropriate_flag"`
+	VideoSignalTypePresentFlag      int        `json:"video_signal_type_present_flag"`
+	VideoFormat                     int        `json:"video_format"`
+	VideoFullRangeFlag              int        `json:"video_full_range_flag"`
+	ColourDescriptionPresentFlag    int        `json:"colour_description_present_flag"`
+	ColourPrimaries                 int        `json:"colour_primaries"`
+	TransferCharacteristics         int        `json:"transfer_characteristics"`
+	MatrixCoefficients              int        `json:"matrix_coefficients"`
+	ChromaLocInfoPresentFlag        int        `json:"chroma_loc_info_present_flag"`
+	ChromaSampleLocTypeTopField     int        `json:"chroma_sample_loc_type_top_field"`
+	ChromaSampleLocTypeBottomField  int        `json:"chroma_sample_loc_type_bottom_field"`
+	TimingInfoPresentFlag           int        `json:"timing_info_present_flag"`
+	NumUnitsInTick                  int        `json:"num_units_in_tick"`
+	TimeScale                       int        `json:"time_scale"`
+	FixedFrameRateFlag              int        `json:"fixed_frame_rate_flag"`
+	NalHrdParametersPresentFlag     int        `json:"nal_hrd_parameters_present_flag"`
+	NalHrd                          *HRD       `json:"nal_hrd,omitempty"`
+	VclHrdParametersPresentFlag     int        `json:"vcl_hrd_parameters_present_flag"`
+	VclHrd                          *HRD       `json:"vcl_hrd,omitempty"`
+	LowDelayHrdFlag                 int        `json:"low_delay_hrd_flag"`
+	PicStructPresentFlag            int        `json:"pic_struct_present_flag"`
+	BitstreamRestrictionFlag        int        `json:"bitstream_restriction_flag"`
+	MotionVectorsOverPicBoundaries  int        `json:"motion_vectors_over_pic_boundaries_flag"`
+	MaxBytesPerPicDenom             int        `json:"max_bytes_per_pic_denom"`
+	MaxBitsPerMbDenom               int        `json:"max_bits_per_mb_denom"`
+	Log2MaxMvLengthHorizontal       int        `json:"log2_max_mv_length_horizontal"`
+	Log2MaxMvLengthVertical         int        `json:"log2_max_mv_length_vertical"`
+	MaxNumReorderFrames             int        `json:"max_num_reorder_frames"`
+	MaxDecFrameBuffering            int        `json:"max_dec_frame_buffering"`
 }
 
-type PPS struct {
-	PPSId                                 int `json:"pps_id"`
-	SPSId                                 int `json:"sps_id"`
-	EntropyCodingModeFlag                 int `json:"entropy_coding_mode_flag"`
-	BottomFieldPicOrderInFramePresentFlag int `json:"bottom_field_pic_order_in_frame_present_flag"`
-	NumSliceGroupsMinus1                  int `json:"num_slice_groups_minus1"`
-	// ……H.264-AVC-ISO_IEC_14496-10-2012.pdf P-65
-}
-
-var gNaluSize int
-
-func ParseCts(buffer *bytes.Buffer) int {
-	var cts int
-	for i := 0; i < 3; i++ {
-		b, _ := buffer.ReadByte()
-		cts = (cts << 8) | int(b)
-	}
-	return cts
+// HRD holds the hrd_parameters() of the VUI, E.1.2.
+type HRD struct {
+	CpbCntMinus1                       int   `json:"cpb_cnt_minus1"`
+	BitRateScale                       int   `json:"bit_rate_scale"`
+	CpbSizeScale                       int   `json:"cpb_size_scale"`
+	BitRateValueMinus1                 []int `json:"bit_rate_value_minus1"`
+	CpbSizeValueMinus1                 []int `json:"cpb_size_value_minus1"`
+	CbrFlag                            []int `json:"cbr_flag"`
+	InitialCpbRemovalDelayLengthMinus1 int   `json:"initial_cpb_removal_delay_length_minus1"`
+	CpbRemovalDelayLengthMinus1        int   `json:"cpb_removal_delay_length_minus1"`
+	DpbOutputDelayLengthMinus1         int   `json:"dpb_output_delay_length_minus1"`
+	TimeOffsetLength                   int   `json:"time_offset_length"`
 }
 
-func ParseNalu(vf *VideoFrameInfo, buffer *bytes.Buffer) {
-	cts := ParseCts(buffer)
-
-	if gNaluSize == 0 {
-		panic("nalu size is 0")
-	}
-
-	data := buffer.Next(gNaluSize)
-	length := binary.BigEndian.Uint32(data)
-
-	b := int(buffer.Bytes()[0])
-	vf.Cts = cts
-	vf.NaluInfo = &Nalu{ForbiddenBit: b >> 7, NalReferenceIdc: (b >> 5) & 0x03, NalUnitType: b & 0x1f, Len: int(length)}
-}
-
-// type SeqHeader struct {
-// 	version       int //8
-// 	provile       int //8
-// 	compatibility int //8
-// 	level         int //8
-// 	reserved   	  int //6
-// 	naluLenSize   int //2
-// 	//...
-// }
-
-// parse sps nalu and rbsp
-func ParseSPS(data []byte) {
-	nalu := decodeNalu(data)
-	if nalu.NalUnitType != 7 {
-		panic(fmt.Sprintf("nalu type not match %d", nalu.NalUnitType))
-	}
-
-	bs := core.NewBitStream(nalu.Rbsp)
-	var sps SPS
-	b, _ := bs.ReadByte()
-	sps.ProfileIdc = int(b)
-
-	b, _ = bs.ReadByte()
-	x := int(b)
-	sps.ConstraintSet0Flag = (x >> 7) & 0x01
-	sps.ConstraintSet1Flag = (x >> 6) & 0x01
-	sps.ConstraintSet2Flag = (x >> 5) & 0x01
-	sps.ConstraintSet3Flag = (x >> 4) & 0x01
-	sps.ConstraintSet4Flag = (x >> 3) & 0x01
-	sps.ConstraintSet5Flag = (x >> 2) & 0x01
-
-	b, _ = bs.ReadByte()
-	sps.LevelIdc = int(b)
-	sps.SPSId = golomb.ReadUEV(bs)
-
-	if sps.ProfileIdc == 100 || sps.ProfileIdc == 110 || sps.ProfileIdc == 122 ||
-		sps.ProfileIdc == 244 || sps.ProfileIdc == 44 || sps.ProfileIdc == 83 ||
-		sps.ProfileIdc == 86 || sps.ProfileIdc == 118 || sps.ProfileIdc == 128 {
-		sps.ChromaFormatIdc = golomb.ReadUEV(bs)
-
-		if sps.ChromaFormatIdc == 3 {
-			sps.ResidualColourTransformFlag = bs.Next()
-		}
-
-		sps.BitDepthLumaMinus8 = golomb.ReadUEV(bs)
-		sps.BitDepthChromaMinus8 = golomb.ReadUEV(bs)
-
-		sps.QpprimeYZeroTransformBypassFlag = bs.Next()
-		sps.SeqScalingMatrixPresentFlag = bs.Next()
-
-		if sps.SeqScalingMatrixPresentFlag == 1 {
-			scmpfsNum := 8
-			if sps.ChromaFormatIdc == 3 {
-				scmpfsNum = 12
-			}
-
-			for i := 0; i < scmpfsNum; i++ {
-				bs.Next()
-			}
-		}
-
-		sps.Log2MaxFrameNumMinus4 = golomb.ReadUEV(bs)
-		sps.PicOrderCntType = golomb.ReadUEV(bs)
-		if sps.PicOrderCntType == 0 {
-			sps.Log2MaxPicOrderCntLsbMinus4 = golomb.ReadUEV(bs)
-		} else if sps.PicOrderCntType == 1 {
-			sps.DeltaPicOrderAlwaysZeroFlag = bs.Next()
-			sps.OffsetForNonRefPic = golomb.ReadUEV(bs)
-			sps.OffsetForTopToBottomField = golomb.ReadUEV(bs)
-			sps.NumRefFramesInPicOrderCntCycle = golomb.ReadUEV(bs)
-
-			sps.OffsetForRefFrame = make([]int, 0)
-			fmt.Printf("NumRefFramesInPicOrderCntCycle: %d\n", sps.NumRefFramesInPicOrderCntCycle)
-			for i := 0; i < sps.NumRefFramesInPicOrderCntCycle; i++ {
-				sps.OffsetForRefFrame = append(sps.OffsetForRefFrame, golomb.ReadUEV(bs))
-			}
-		}
-
-		sps.NumRefFrames = golomb.ReadUEV(bs)
-		sps.GapsInFrameNumValueAllowedFlag = bs.Next()
-		sps.PicWidthInMbsMinus1 = golomb.ReadUEV(bs)
-		sps.PicHeightInMapUnitsMinus1 = golomb.ReadUEV(bs)
-
-		sps.FrameMbsOnlyFlag = bs.Next()
-		if sps.FrameMbsOnlyFlag == 0 {
-			sps.MbAdaptiveFrameFieldFlag = bs.Next()
-		}
-
-		sps.Direct8x8InferenceFlag = bs.Next()
-		sps.FrameCropingFlag = bs.Next()
-		if sps.FrameCropingFlag == 1 {
-			sps.FrameCropLeftOffset = golomb.ReadUEV(bs)
-			sps.FrameCropRightOffset = golomb.ReadUEV(bs)
-			sps.FrameCropTopOffset = golomb.ReadUEV(bs)
-			sps.FrameCropButtomOffset = golomb.ReadUEV(bs)
-		}
-
-		sps.VuiParametersPresentFlag = bs.Next()
-		// vui paramters
-	}
-
-	content, _ := json.MarshalIndent(sps, "", "\t")
-	fmt.Printf("\tsps: \n%v\n", string(content))
+type PPS struct {
+	PPSId                                 int        `json:"pps_id"`
+	SPSId                                 int        `json:"sps_id"`
+	EntropyCodingModeFlag                 int        `json:"entropy_coding_mode_flag"`
+	BottomFieldPicOrderInFramePresentFlag int        `json:"bottom_field_pic_order_in_frame_present_flag"`
+	NumSliceGroupsMinus1                  int        `json:"num_slice_groups_minus1"`
+	SliceGroupMapType                     int        `json:"slice_group_map_type"`
+	RunLengthMinus1                       []int      `json:"run_length_minus1,omitempty"`
+	TopLeft                               []int      `json:"top_left,omitempty"`
+	BottomRight                           []int      `json:"bottom_right,omitempty"`
+	SliceGroupChangeDirectionFlag         int        `json:"slice_group_change_direction_flag"`
+	SliceGroupChangeRateMinus1            int        `json:"slice_group_change_rate_minus1"`
+	PicSizeInMapUnitsMinus1               int        `json:"pic_size_in_map_units_minus1"`
+	SliceGroupId                          []int      `json:"slice_group_id,omitempty"`
+	NumRefIdxL0DefaultActiveMinus1        int        `json:"num_ref_idx_l0_default_active_minus1"`
+	NumRefIdxL1DefaultActiveMinus1        int        `json:"num_ref_idx_l1_default_active_minus1"`
+	WeightedPredFlag                      int        `json:"weighted_pred_flag"`
+	WeightedBipredIdc                     int        `json:"weighted_bipred_idc"`
+	PicInitQpMinus26                      int        `json:"pic_init_qp_minus26"`
+	PicInitQsMinus26                      int        `json:"pic_init_qs_minus26"`
+	ChromaQpIndexOffset                   int        `json:"chroma_qp_index_offset"`
+	DeblockingFilterControlPresentFlag    int        `json:"deblocking_filter_control_present_flag"`
+	ConstrainedIntraPredFlag              int        `json:"constrained_intra_pred_flag"`
+	RedundantPicCntPresentFlag            int        `json:"redundant_pic_cnt_present_flag"`
+	Transform8x8ModeFlag                  int        `json:"transform_8x8_mode_flag"`
+	PicScalingMatrixPresentFlag           int        `json:"pic_scaling_matrix_present_flag"`
+	PicScalingListPresentFlag             [12]int    `json:"pic_scaling_list_present_flag"`
+	ScalingMatrix4                        [6][16]int `json:"-"`
+	ScalingMatrix8                        [6][64]int `json:"-"`
+	SecondChromaQpIndexOffset             int        `json:"second_chroma_qp_index_offset"`
 }
 
 func decodeNalu(data []byte) *Nalu {
-	buffer := bytes.NewBuffer(data)
 	nalu := &Nalu{}
-	b, _ := buffer.ReadByte()
-	n := int(b)
+	if len(data) == 0 {
+		return nalu
+	}
 
+	n := int(data[0])
 	nalu.ForbiddenBit = n >> 7
 	nalu.NalReferenceIdc = (n >> 5) & 0x03
 	nalu.NalUnitType = n & 0x1f
 
-	nalu.Rbsp = make([]byte, len(data))
-	n = 0
-
-	for buffer.Len() > 0 {
-		nalu.Rbsp[n], _ = buffer.ReadByte()
-		if n > 2 && (nalu.Rbsp[n-2] == 0 && nalu.Rbsp[n-1] == 0 && nalu.Rbsp[n] == 3) {
-			if buffer.Len() == 0 {
-				break
-			}
+	// drop emulation_prevention_three_byte, zeros are counted in the
+	// escaped stream
+	nalu.Rbsp = make([]byte, 0, len(data)-1)
+	zeros := 0
+	for _, b := range data[1:] {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
 
-			nalu.Rbsp[n], _ = buffer.ReadByte()
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
 		}
 
-		n++
+		nalu.Rbsp = append(nalu.Rbsp, b)
 	}
 
-	nalu.RbspSize = n
+	nalu.RbspSize = len(nalu.Rbsp)
 	return nalu
 }
 
-func ParsePPS(data []byte) {
-	nalu := decodeNalu(data)
-	pps := PPS{}
-	bs := core.NewBitStream(nalu.Rbsp)
-	pps.PPSId = golomb.ReadUEV(bs)
-	pps.SPSId = golomb.ReadUEV(bs)
-	pps.EntropyCodingModeFlag = bs.Next()
-	pps.BottomFieldPicOrderInFramePresentFlag = bs.Next()
-	pps.NumSliceGroupsMinus1 = golomb.ReadUEV(bs)
-
-	content, _ := json.MarshalIndent(pps, "", "\t")
-	fmt.Printf("\tsps: \n%v\n", string(content))
-}
-
-func ParseSeq(vf *VideoFrameInfo, buffer *bytes.Buffer) {
-	cts := ParseCts(buffer)
-
-	buffer.ReadByte()         // Configuration VerSion
-	buffer.ReadByte()         // AVC Profile SPS[1]
-	buffer.ReadByte()         // profile_compatibility SPS[2]
-	buffer.ReadByte()         // AVC Level SPS[3]
-	b, _ := buffer.ReadByte() // lengthSizeMinusOne
-	gNaluSize = (int(b) & 0x03) + 1
-
-	vf.Type = "seq header"
-	vf.Cts = cts
-	vf.NaluSize = gNaluSize
-
-	buffer.ReadByte()
-	// spsNum := int(b) & 0x1f
-	// sps number shoud always be 1
-
-	spsSize := int(binary.BigEndian.Uint16(buffer.Next(2)))
-	data := buffer.Next(spsSize)
-	ParseSPS(data)
-
-	b, _ = buffer.ReadByte()
-	ppsNum := int(b)
-	for i := 0; i < ppsNum; i++ {
-		spsSize := int(binary.BigEndian.Uint16(buffer.Next(2)))
-		data := buffer.Next(spsSize)
-		ParsePPS(data)
-	}
-}
-
 type VideoFrameInfo struct {
 	CodecType string
 	Type      string
 	Cts       int
 	NaluSize  int
-	NaluInfo  *Nalu
+	Config    *AVCConfig // seq header only, with the decoded parameter sets
+	SPS       []*SPS
+	PPS       []*PPS
+	NaluInfo  *Nalu        // first NALU
+	Nalus     []*Nalu      // the access unit
+	Slice     *SliceHeader // first slice of the picture
+	SEI       []*SEIMessage
 	Frame     interface{} // TODO
 }
 
-func (f *VideoFrameInfo) String() string {
-	if f.NaluInfo == nil {
-		return fmt.Sprintf("code: %s\ttype: %s\tcts:%d", f.CodecType, f.Type, f.Cts)
+func (f *VideoFrameInfo) nalus() string {
+	if len(f.Nalus) == 0 {
+		return f.NaluInfo.String()
+	}
+
+	s := ""
+	for _, n := range f.Nalus {
+		s += n.String()
 	}
 
-	return fmt.Sprintf("code: %s\ttype: %s\tcts:%d\tnalu: %s", f.CodecType, f.Type, f.Cts, f.NaluInfo.String())
+	return s
 }
 
-func Parse(buffer *bytes.Buffer) *VideoFrameInfo {
-	vf := &VideoFrameInfo{}
-	b, _ := buffer.ReadByte()
-	switch int(b) {
-	case 0:
-		vf.CodecType = "seq"
-		ParseSeq(vf, buffer)
-	case 1:
-		vf.CodecType = "nalu"
-		ParseNalu(vf, buffer)
-	case 2:
-		vf.CodecType = "seq end"
+func (f *VideoFrameInfo) String() string {
+	if f.NaluInfo == nil {
+		return fmt.Sprintf("code: %s\ttype: %s\tcts:%d", f.CodecType, f.Type, f.Cts)
 	}
 
-	fmt.Printf("\t%s\n", vf.String())
+	if f.Slice == nil {
+		return fmt.Sprintf("code: %s\ttype: %s\tcts:%d\tnalu: %s", f.CodecType, f.Type, f.Cts, f.nalus())
+	}
 
-	return vf
+	return fmt.Sprintf("code: %s\ttype: %s\tcts:%d\tpoc:%d\tframe_num:%d\tnalu: %s",
+		f.CodecType, f.Type, f.Cts, f.Slice.PicOrderCnt, f.Slice.FrameNum, f.nalus())
 }
@@ -0,0 +1,247 @@
+package h264
+
+import "media-go/core"
+
+// Parser decodes the AVC video packets of one stream. It keeps the active
+// decoder configuration and the parameter sets by id, so every stream needs
+// its own Parser; separate Parsers may be used from different goroutines.
+type Parser struct {
+	config *AVCConfig
+	sps    map[int]*SPS
+	pps    map[int]*PPS
+	active *SPS // SPS of the last slice or buffering period
+	poc    pocState
+}
+
+func NewParser() *Parser {
+	return &Parser{
+		sps: make(map[int]*SPS),
+		pps: make(map[int]*PPS),
+	}
+}
+
+// Config returns the active decoder configuration, nil before the first
+// sequence header.
+func (p *Parser) Config() *AVCConfig { return p.config }
+
+// SPS returns the parameter set with the given id, nil if unknown.
+func (p *Parser) SPS(id int) *SPS { return p.sps[id] }
+
+// PPS returns the parameter set with the given id, nil if unknown.
+func (p *Parser) PPS(id int) *PPS { return p.pps[id] }
+
+// Parse decodes an AVC video packet: AVCPacketType, the composition time
+// and the payload, as found after the first byte of an FLV video tag.
+func (p *Parser) Parse(data []byte) (*VideoFrameInfo, error) {
+	if len(data) < 4 {
+		return nil, ErrInvalidNalu
+	}
+
+	vf := &VideoFrameInfo{Cts: int(int32(uint32(data[1])<<24|uint32(data[2])<<16|uint32(data[3])<<8) >> 8)}
+	payload := data[4:]
+
+	var err error
+	switch int(data[0]) {
+	case AVC_PKT_SEQ_HEADER:
+		err = p.parseSeq(vf, payload)
+	case AVC_PKT_NALU:
+		err = p.parseNalu(vf, payload)
+	case AVC_PKT_END_SEQ:
+		vf.CodecType = "seq end"
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return vf, nil
+}
+
+// ParseConfig decodes an AVCDecoderConfigurationRecord, for containers that
+// carry it without the FLV packet header.
+func (p *Parser) ParseConfig(record []byte) (*VideoFrameInfo, error) {
+	vf := &VideoFrameInfo{}
+	if err := p.parseSeq(vf, record); err != nil {
+		return nil, err
+	}
+
+	return vf, nil
+}
+
+// ParseNalus decodes the length-prefixed NALUs of one access unit.
+func (p *Parser) ParseNalus(data []byte) (*VideoFrameInfo, error) {
+	vf := &VideoFrameInfo{}
+	if err := p.parseNalu(vf, data); err != nil {
+		return nil, err
+	}
+
+	return vf, nil
+}
+
+func (p *Parser) parseSeq(vf *VideoFrameInfo, data []byte) error {
+	vf.CodecType = "seq"
+	vf.Type = "seq header"
+	config, err := ParseAVCConfig(data)
+	if err != nil {
+		return err
+	}
+
+	for _, nalu := range config.SPS {
+		sps, err := DecodeSPS(nalu)
+		if err != nil {
+			return err
+		}
+		p.sps[sps.SPSId] = sps
+		vf.SPS = append(vf.SPS, sps)
+	}
+
+	for _, nalu := range config.PPS {
+		pps, err := DecodePPS(nalu, p.sps)
+		if err != nil {
+			return err
+		}
+		p.pps[pps.PPSId] = pps
+		vf.PPS = append(vf.PPS, pps)
+	}
+
+	p.config = config
+	vf.Config = config
+	vf.NaluSize = config.NaluSize
+	return nil
+}
+
+func (p *Parser) parseNalu(vf *VideoFrameInfo, data []byte) error {
+	vf.CodecType = "nalu"
+	if p.config == nil {
+		return ErrNoConfig
+	}
+
+	size := p.config.NaluSize
+	vf.NaluSize = size
+
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < size {
+			return &NaluError{Offset: pos, Size: len(data) - pos, Err: ErrInvalidNalu}
+		}
+
+		length := 0
+		for _, b := range data[pos : pos+size] {
+			length = length<<8 | int(b)
+		}
+
+		start := pos + size
+		if length == 0 || length > len(data)-start {
+			return &NaluError{Offset: pos, Size: length, Err: ErrInvalidNalu}
+		}
+
+		payload := data[start : start+length]
+		b := int(payload[0])
+		nalu := &Nalu{Len: length, Offset: start, Data: payload,
+			ForbiddenBit: b >> 7, NalReferenceIdc: (b >> 5) & 0x03, NalUnitType: b & 0x1f}
+		vf.Nalus = append(vf.Nalus, nalu)
+		pos = start + length
+
+		if err := p.parseUnit(vf, nalu); err != nil {
+			return &NaluError{Offset: start, Size: length, Err: err}
+		}
+	}
+
+	if len(vf.Nalus) > 0 {
+		vf.NaluInfo = vf.Nalus[0]
+	}
+
+	return nil
+}
+
+// parseUnit keeps in-band parameter sets and decodes the first slice, which
+// gives the picture type and order.
+func (p *Parser) parseUnit(vf *VideoFrameInfo, nalu *Nalu) error {
+	switch nalu.NalUnitType {
+	case NAL_SPS:
+		sps, err := DecodeSPS(nalu.Data)
+		if err != nil {
+			return err
+		}
+		p.sps[sps.SPSId] = sps
+		vf.SPS = append(vf.SPS, sps)
+	case NAL_PPS:
+		pps, err := DecodePPS(nalu.Data, p.sps)
+		if err != nil {
+			return err
+		}
+		p.pps[pps.PPSId] = pps
+		vf.PPS = append(vf.PPS, pps)
+	case NAL_SEI:
+		// a broken SEI does not make the picture undecodable, keep the
+		// messages that could be split
+		msgs, _ := DecodeSEI(nalu.Data, p.sps, p.activeSPS())
+		for _, msg := range msgs {
+			if bp, ok := msg.Payload.(*BufferingPeriod); ok {
+				p.active = p.sps[bp.SPSId]
+			}
+		}
+		vf.SEI = append(vf.SEI, msgs...)
+	case NAL_SLICE, NAL_IDR_SLICE:
+		if vf.Slice != nil {
+			break
+		}
+
+		sh, err := p.ParseSlice(nalu.Data)
+		if err != nil {
+			return err
+		}
+		vf.Slice = sh
+		vf.Type = sh.TypeName()
+	}
+
+	return nil
+}
+
+// ParseSlice decodes a slice header, header byte included, with the
+// parameter sets it refers to and derives its picture order count. Slices
+// must be passed in decoding order.
+func (p *Parser) ParseSlice(nalu []byte) (*SliceHeader, error) {
+	prefix := nalu
+	if len(prefix) > 16 {
+		prefix = prefix[:16]
+	}
+
+	r := core.NewBitReader(decodeNalu(prefix).Rbsp)
+	r.UE() // first_mb_in_slice
+	r.UE() // slice_type
+	id := r.UE()
+	if r.Err != nil {
+		return nil, ErrInvalidSlice
+	}
+
+	pps := p.pps[id]
+	if pps == nil || p.sps[pps.SPSId] == nil {
+		return nil, ErrInvalidSlice
+	}
+
+	sps := p.sps[pps.SPSId]
+	sh, err := DecodeSliceHeader(nalu, sps, pps)
+	if err != nil {
+		return nil, err
+	}
+
+	p.poc.compute(sh, sps)
+	p.active = sps
+	return sh, nil
+}
+
+// activeSPS is the SPS SEI messages refer to: the one of the last picture or
+// buffering period, or the only one known before the first picture.
+func (p *Parser) activeSPS() *SPS {
+	if p.active != nil {
+		return p.active
+	}
+
+	if len(p.sps) == 1 {
+		for _, sps := range p.sps {
+			return sps
+		}
+	}
+
+	return nil
+}
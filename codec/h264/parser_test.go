@@ -0,0 +1,141 @@
+package h264
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var basePPS = []byte{0x68, 0xce, 0x38, 0x80}
+
+func seqPacket(t *testing.T, naluSize int) []byte {
+	c, err := NewAVCConfig([][]byte{highSPS}, [][]byte{basePPS})
+	assert.Nil(t, err)
+	c.NaluSize = naluSize
+
+	record, err := c.Bytes()
+	assert.Nil(t, err)
+	return append([]byte{AVC_PKT_SEQ_HEADER, 0, 0, 0}, record...)
+}
+
+// naluPacket holds the header of an IDR I slice behind a naluSize length
+// field, cts -2.
+func naluPacket(naluSize int) []byte {
+	data := []byte{AVC_PKT_NALU, 0xff, 0xff, 0xfe}
+	data = append(data, make([]byte, naluSize-1)...)
+	return append(data, 4, 0x65, 0x88, 0x84, 0x80)
+}
+
+func TestParser(t *testing.T) {
+	p := NewParser()
+	_, err := p.Parse(naluPacket(4))
+	assert.Equal(t, ErrNoConfig, err)
+
+	vf, err := p.Parse(seqPacket(t, 4))
+	assert.Nil(t, err)
+	assert.Equal(t, "seq", vf.CodecType)
+	assert.Len(t, vf.SPS, 1)
+	assert.Len(t, vf.PPS, 1)
+	assert.Equal(t, 4, p.Config().NaluSize)
+	assert.Equal(t, 100, p.SPS(0).ProfileIdc)
+	assert.Equal(t, 0, p.PPS(0).SPSId)
+
+	vf, err = p.Parse(naluPacket(4))
+	assert.Nil(t, err)
+	assert.Equal(t, -2, vf.Cts)
+	assert.Equal(t, NAL_IDR_SLICE, vf.NaluInfo.NalUnitType)
+	assert.Equal(t, "I", vf.Type)
+	assert.Equal(t, 0, vf.Slice.PicOrderCnt)
+	assert.Equal(t, 4, vf.NaluInfo.Len)
+
+	_, err = p.Parse([]byte{AVC_PKT_NALU, 0, 0, 0, 0, 0})
+	assert.True(t, errors.Is(err, ErrInvalidNalu))
+}
+
+func TestParserAccessUnit(t *testing.T) {
+	p := NewParser()
+	_, err := p.Parse(seqPacket(t, 2))
+	assert.Nil(t, err)
+
+	// AUD, SEI, in-band SPS and PPS, then two slices of an IDR picture
+	au := []byte{AVC_PKT_NALU, 0, 0, 0}
+	for _, nalu := range [][]byte{{0x09, 0xf0}, {0x06, 0x06, 0x01, 0x84, 0x80}, highSPS, basePPS,
+		{0x65, 0x88, 0x84, 0x80}, {0x65, 0xb8, 0x21, 0x00}} {
+		au = append(au, byte(len(nalu)>>8), byte(len(nalu)))
+		au = append(au, nalu...)
+	}
+
+	vf, err := p.Parse(au)
+	assert.Nil(t, err)
+	assert.Len(t, vf.Nalus, 6)
+	assert.Equal(t, vf.Nalus[0], vf.NaluInfo)
+	assert.Equal(t, NAL_AUD, vf.Nalus[0].NalUnitType)
+	assert.Equal(t, 2, vf.Nalus[0].Offset)
+	assert.Equal(t, []byte{0x09, 0xf0}, vf.Nalus[0].Data)
+	assert.Equal(t, NAL_SEI, vf.Nalus[1].NalUnitType)
+	assert.Equal(t, 6, vf.Nalus[1].Offset)
+	assert.Equal(t, &RecoveryPoint{}, vf.SEI[0].Payload)
+	assert.Equal(t, 3, vf.Nalus[4].NalReferenceIdc)
+	assert.Equal(t, len(au)-4-4, vf.Nalus[5].Offset)
+	assert.Len(t, vf.SPS, 1)
+	assert.Len(t, vf.PPS, 1)
+	assert.Equal(t, 0, vf.Slice.FirstMbInSlice)
+	assert.Equal(t, "I", vf.Type)
+
+	// one byte left over for a 2 byte length field
+	_, err = p.Parse(append(au, 0))
+	var ne *NaluError
+	assert.True(t, errors.As(err, &ne))
+	assert.Equal(t, len(au)-4, ne.Offset)
+	assert.Equal(t, 1, ne.Size)
+
+	// the last length runs past the tag
+	bad := append([]byte(nil), au...)
+	bad[len(bad)-5]++
+	_, err = p.Parse(bad)
+	assert.True(t, errors.As(err, &ne))
+	assert.Equal(t, ErrInvalidNalu, ne.Err)
+	assert.Equal(t, 5, ne.Size)
+
+	_, err = p.Parse([]byte{AVC_PKT_NALU, 0, 0, 0, 0, 0})
+	assert.True(t, errors.Is(err, ErrInvalidNalu))
+
+	// an empty registered and a short unregistered message do not fail the
+	// picture, nor does an SEI that cannot be split
+	au = []byte{AVC_PKT_NALU, 0, 0, 0}
+	for _, nalu := range [][]byte{{0x06, 0x04, 0x00, 0x05, 0x02, 0x01, 0x02, 0x80}, {0x06, 0x06, 0x09, 0x80},
+		{0x65, 0x88, 0x84, 0x80}} {
+		au = append(au, byte(len(nalu)>>8), byte(len(nalu)))
+		au = append(au, nalu...)
+	}
+
+	vf, err = p.Parse(au)
+	assert.Nil(t, err)
+	assert.Len(t, vf.SEI, 2)
+	assert.Nil(t, vf.SEI[0].Payload)
+	assert.Nil(t, vf.SEI[1].Payload)
+	assert.Equal(t, "I", vf.Type)
+}
+
+func TestParserConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for _, size := range []int{1, 2, 4} {
+		wg.Add(1)
+		go func(size int) {
+			defer wg.Done()
+			p := NewParser()
+			_, err := p.Parse(seqPacket(t, size))
+			assert.Nil(t, err)
+
+			for i := 0; i < 100; i++ {
+				vf, err := p.Parse(naluPacket(size))
+				assert.Nil(t, err)
+				assert.Equal(t, size, vf.NaluSize)
+				assert.Equal(t, 4, vf.NaluInfo.Len)
+			}
+		}(size)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,129 @@
+package h264
+
+import (
+	"errors"
+
+	"media-go/core"
+)
+
+var ErrInvalidPPS = errors.New("h264: invalid PPS")
+
+// DecodePPS parses a PPS NALU, header byte included, 7.3.2.2. The referenced
+// SPS is looked up in sps; it supplies the chroma format and the fall-back
+// scaling lists of the High profile extension.
+func DecodePPS(data []byte, sps map[int]*SPS) (*PPS, error) {
+	nalu := decodeNalu(data)
+	if nalu.NalUnitType != NAL_PPS {
+		return nil, ErrInvalidPPS
+	}
+
+	rbsp := nalu.Rbsp[:nalu.RbspSize]
+	r := core.NewBitReader(rbsp)
+	pps := &PPS{}
+	pps.PPSId = r.UE()
+	pps.SPSId = r.UE()
+	pps.EntropyCodingModeFlag = r.U(1)
+	pps.BottomFieldPicOrderInFramePresentFlag = r.U(1)
+	pps.NumSliceGroupsMinus1 = r.UE()
+
+	if pps.PPSId > 255 || pps.SPSId > 31 || pps.NumSliceGroupsMinus1 > 7 {
+		return nil, ErrInvalidPPS
+	}
+
+	ref := sps[pps.SPSId]
+	if ref == nil {
+		return nil, ErrInvalidPPS
+	}
+
+	if pps.NumSliceGroupsMinus1 > 0 && !readSliceGroups(r, pps) {
+		return nil, ErrInvalidPPS
+	}
+
+	pps.NumRefIdxL0DefaultActiveMinus1 = r.UE()
+	pps.NumRefIdxL1DefaultActiveMinus1 = r.UE()
+	pps.WeightedPredFlag = r.U(1)
+	pps.WeightedBipredIdc = r.U(2)
+	pps.PicInitQpMinus26 = r.SE()
+	pps.PicInitQsMinus26 = r.SE()
+	pps.ChromaQpIndexOffset = r.SE()
+	pps.DeblockingFilterControlPresentFlag = r.U(1)
+	pps.ConstrainedIntraPredFlag = r.U(1)
+	pps.RedundantPicCntPresentFlag = r.U(1)
+
+	if pps.NumRefIdxL0DefaultActiveMinus1 > 31 || pps.NumRefIdxL1DefaultActiveMinus1 > 31 ||
+		pps.ChromaQpIndexOffset < -12 || pps.ChromaQpIndexOffset > 12 {
+		return nil, ErrInvalidPPS
+	}
+
+	pps.SecondChromaQpIndexOffset = pps.ChromaQpIndexOffset
+	pps.ScalingMatrix4, pps.ScalingMatrix8 = ref.ScalingMatrix4, ref.ScalingMatrix8
+
+	if moreRBSPData(r, rbsp) {
+		pps.Transform8x8ModeFlag = r.U(1)
+		pps.PicScalingMatrixPresentFlag = r.U(1)
+		if pps.PicScalingMatrixPresentFlag == 1 {
+			lists := 6
+			if pps.Transform8x8ModeFlag == 1 && ref.ChromaFormatIdc == 3 {
+				lists += 6
+			} else if pps.Transform8x8ModeFlag == 1 {
+				lists += 2
+			}
+
+			// fall-back rule A without sequence level lists, B with them
+			var fallback *SPS
+			if ref.SeqScalingMatrixPresentFlag == 1 {
+				fallback = ref
+			}
+
+			readScalingMatrix(r, lists, &pps.PicScalingListPresentFlag, &pps.ScalingMatrix4, &pps.ScalingMatrix8, fallback)
+		}
+
+		pps.SecondChromaQpIndexOffset = r.SE()
+	}
+
+	if r.Err != nil {
+		return nil, ErrInvalidPPS
+	}
+
+	return pps, nil
+}
+
+// readSliceGroups reads the slice group map of FMO, Baseline and Extended
+// profiles only.
+func readSliceGroups(r *core.BitReader, pps *PPS) bool {
+	pps.SliceGroupMapType = r.UE()
+	switch pps.SliceGroupMapType {
+	case 0:
+		for i := 0; i <= pps.NumSliceGroupsMinus1; i++ {
+			pps.RunLengthMinus1 = append(pps.RunLengthMinus1, r.UE())
+		}
+	case 2:
+		for i := 0; i < pps.NumSliceGroupsMinus1; i++ {
+			pps.TopLeft = append(pps.TopLeft, r.UE())
+			pps.BottomRight = append(pps.BottomRight, r.UE())
+		}
+	case 3, 4, 5:
+		pps.SliceGroupChangeDirectionFlag = r.U(1)
+		pps.SliceGroupChangeRateMinus1 = r.UE()
+	case 6:
+		pps.PicSizeInMapUnitsMinus1 = r.UE()
+		if r.Err != nil || pps.PicSizeInMapUnitsMinus1 > r.Left() {
+			return false
+		}
+
+		// Ceil(Log2(num_slice_groups_minus1 + 1)) bits each
+		bits := 0
+		for 1<<uint(bits) < pps.NumSliceGroupsMinus1+1 {
+			bits++
+		}
+
+		for i := 0; i <= pps.PicSizeInMapUnitsMinus1; i++ {
+			pps.SliceGroupId = append(pps.SliceGroupId, r.U(bits))
+		}
+	case 1:
+	default:
+		return false
+	}
+
+	return r.Err == nil
+}
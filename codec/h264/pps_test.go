@@ -0,0 +1,103 @@
+package h264
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePPS(t *testing.T) {
+	sps := map[int]*SPS{1: {SPSId: 1, ChromaFormatIdc: 1}}
+	flatScaling(&sps[1].ScalingMatrix4, &sps[1].ScalingMatrix8)
+
+	w := &bittest.Writer{}
+	w.U(8, 0x68)
+	w.UE(3) // pps_id
+	w.UE(1) // sps_id
+	w.U(1, 1)
+	w.U(1, 0)
+	w.UE(0)
+	w.UE(2) // num_ref_idx_l0_default_active_minus1
+	w.UE(1)
+	w.U(1, 1)
+	w.U(2, 2)
+	w.SE(-3) // pic_init_qp_minus26
+	w.SE(0)
+	w.SE(-2) // chroma_qp_index_offset
+	w.U(1, 1)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 1) // transform_8x8_mode_flag
+	w.U(1, 1) // pic_scaling_matrix_present_flag
+	w.U(1, 0) // list 0, rule A gives the default
+	for i := 1; i < 8; i++ {
+		w.U(1, 0)
+	}
+	w.SE(4) // second_chroma_qp_index_offset
+	data := w.NALU()
+
+	pps, err := DecodePPS(data, sps)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, pps.PPSId)
+	assert.Equal(t, 1, pps.EntropyCodingModeFlag)
+	assert.Equal(t, 2, pps.NumRefIdxL0DefaultActiveMinus1)
+	assert.Equal(t, 2, pps.WeightedBipredIdc)
+	assert.Equal(t, -3, pps.PicInitQpMinus26)
+	assert.Equal(t, -2, pps.ChromaQpIndexOffset)
+	assert.Equal(t, 1, pps.DeblockingFilterControlPresentFlag)
+	assert.Equal(t, 1, pps.Transform8x8ModeFlag)
+	assert.Equal(t, 4, pps.SecondChromaQpIndexOffset)
+	assert.Equal(t, defaultScaling4[0], pps.ScalingMatrix4[2])
+	assert.Equal(t, defaultScaling8[1], pps.ScalingMatrix8[1])
+
+	// rule B takes the sequence level lists
+	sps[1].SeqScalingMatrixPresentFlag = 1
+	pps, err = DecodePPS(data, sps)
+	assert.Nil(t, err)
+	assert.Equal(t, sps[1].ScalingMatrix4[0], pps.ScalingMatrix4[0])
+	assert.Equal(t, sps[1].ScalingMatrix8[1], pps.ScalingMatrix8[1])
+
+	_, err = DecodePPS(data, nil)
+	assert.Equal(t, ErrInvalidPPS, err)
+
+	_, err = DecodePPS(data[:5], sps)
+	assert.Equal(t, ErrInvalidPPS, err)
+}
+
+func TestDecodePPSSliceGroups(t *testing.T) {
+	sps := map[int]*SPS{0: {ChromaFormatIdc: 1}}
+
+	w := &bittest.Writer{}
+	w.U(8, 0x68)
+	w.UE(0)
+	w.UE(0)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.UE(2) // num_slice_groups_minus1
+	w.UE(6) // explicit map
+	w.UE(3)
+	for _, id := range []int{0, 1, 2, 1} {
+		w.U(2, id)
+	}
+	w.UE(0)
+	w.UE(0)
+	w.U(1, 0)
+	w.U(2, 0)
+	w.SE(0)
+	w.SE(0)
+	w.SE(5)
+	w.U(1, 0)
+	w.U(1, 1)
+	w.U(1, 1)
+
+	pps, err := DecodePPS(w.NALU(), sps)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, pps.SliceGroupMapType)
+	assert.Equal(t, []int{0, 1, 2, 1}, pps.SliceGroupId)
+	assert.Equal(t, 1, pps.ConstrainedIntraPredFlag)
+	assert.Equal(t, 1, pps.RedundantPicCntPresentFlag)
+	assert.Equal(t, 0, pps.Transform8x8ModeFlag)
+	assert.Equal(t, 5, pps.SecondChromaQpIndexOffset)
+}
@@ -0,0 +1,383 @@
+package h264
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"media-go/core"
+)
+
+const (
+	SEI_BUFFERING_PERIOD         = 0
+	SEI_PIC_TIMING               = 1
+	SEI_USER_DATA_REGISTERED     = 4
+	SEI_USER_DATA_UNREGISTERED   = 5
+	SEI_RECOVERY_POINT           = 6
+	SEI_FRAME_PACKING            = 45
+	SEI_DISPLAY_ORIENTATION      = 47
+	SEI_MASTERING_DISPLAY_COLOUR = 137
+	SEI_CONTENT_LIGHT_LEVEL      = 144
+	SEI_ALTERNATIVE_TRANSFER     = 147
+)
+
+var seiTypeMap = map[int]string{
+	SEI_BUFFERING_PERIOD:         "buffering_period",
+	SEI_PIC_TIMING:               "pic_timing",
+	SEI_USER_DATA_REGISTERED:     "user_data_registered_itu_t_t35",
+	SEI_USER_DATA_UNREGISTERED:   "user_data_unregistered",
+	SEI_RECOVERY_POINT:           "recovery_point",
+	SEI_FRAME_PACKING:            "frame_packing_arrangement",
+	SEI_DISPLAY_ORIENTATION:      "display_orientation",
+	SEI_MASTERING_DISPLAY_COLOUR: "mastering_display_colour_volume",
+	SEI_CONTENT_LIGHT_LEVEL:      "content_light_level_info",
+	SEI_ALTERNATIVE_TRANSFER:     "alternative_transfer_characteristics",
+}
+
+var ErrInvalidSEI = errors.New("h264: invalid SEI")
+
+// X264UUID tags the user_data_unregistered message x264 writes its version
+// and options into.
+var X264UUID = [16]byte{0xdc, 0x45, 0xe9, 0xbd, 0xe6, 0xd9, 0x48, 0xb7,
+	0x96, 0x2c, 0xd8, 0x20, 0xd9, 0x23, 0xee, 0xef}
+
+// SEIMessage is one sei_message(). Data is the payload with emulation
+// prevention removed, Payload its decoded form for the types below, nil for
+// others and for a message that could not be decoded:
+//
+//	SEI_BUFFERING_PERIOD        *BufferingPeriod
+//	SEI_PIC_TIMING              *PicTiming
+//	SEI_USER_DATA_REGISTERED    *UserDataRegistered
+//	SEI_USER_DATA_UNREGISTERED  *UserDataUnregistered
+//	SEI_RECOVERY_POINT          *RecoveryPoint
+type SEIMessage struct {
+	Type    int
+	Size    int
+	Data    []byte
+	Payload interface{}
+}
+
+func (m *SEIMessage) String() string {
+	name := seiTypeMap[m.Type]
+	if len(name) == 0 {
+		name = fmt.Sprintf("sei_%d", m.Type)
+	}
+
+	return fmt.Sprintf("%s(%d)", name, m.Size)
+}
+
+// BufferingPeriod is the buffering_period() of D.1.1, with the initial
+// delays per SchedSelIdx.
+type BufferingPeriod struct {
+	SPSId                         int   `json:"sps_id"`
+	NalInitialCpbRemovalDelay     []int `json:"nal_initial_cpb_removal_delay,omitempty"`
+	NalInitialCpbRemovalDelayOffs []int `json:"nal_initial_cpb_removal_delay_offset,omitempty"`
+	VclInitialCpbRemovalDelay     []int `json:"vcl_initial_cpb_removal_delay,omitempty"`
+	VclInitialCpbRemovalDelayOffs []int `json:"vcl_initial_cpb_removal_delay_offset,omitempty"`
+}
+
+// ClockTimestamp is one clock timestamp of pic_timing(), the timecode of a
+// frame or field.
+type ClockTimestamp struct {
+	CtType             int `json:"ct_type"`
+	NuitFieldBasedFlag int `json:"nuit_field_based_flag"`
+	CountingType       int `json:"counting_type"`
+	FullTimestampFlag  int `json:"full_timestamp_flag"`
+	DiscontinuityFlag  int `json:"discontinuity_flag"`
+	CntDroppedFlag     int `json:"cnt_dropped_flag"`
+	NFrames            int `json:"n_frames"`
+	Seconds            int `json:"seconds_value"`
+	Minutes            int `json:"minutes_value"`
+	Hours              int `json:"hours_value"`
+	TimeOffset         int `json:"time_offset"`
+}
+
+// Timecode formats the timestamp as HH:MM:SS:FF, ';' before the frames for
+// drop-frame counting.
+func (ct *ClockTimestamp) Timecode() string {
+	sep := ":"
+	if ct.CntDroppedFlag == 1 {
+		sep = ";"
+	}
+
+	return fmt.Sprintf("%02d:%02d:%02d%s%02d", ct.Hours, ct.Minutes, ct.Seconds, sep, ct.NFrames)
+}
+
+// PicTiming is the pic_timing() of D.1.2. Clock timestamps that are not
+// present are nil.
+type PicTiming struct {
+	CpbRemovalDelay int               `json:"cpb_removal_delay"`
+	DpbOutputDelay  int               `json:"dpb_output_delay"`
+	PicStruct       int               `json:"pic_struct"`
+	ClockTimestamps []*ClockTimestamp `json:"clock_timestamps,omitempty"`
+}
+
+// RecoveryPoint is the recovery_point() of D.1.8.
+type RecoveryPoint struct {
+	RecoveryFrameCnt      int `json:"recovery_frame_cnt"`
+	ExactMatchFlag        int `json:"exact_match_flag"`
+	BrokenLinkFlag        int `json:"broken_link_flag"`
+	ChangingSliceGroupIdc int `json:"changing_slice_group_idc"`
+}
+
+// UserDataRegistered is user_data_registered_itu_t_t35(), D.1.6. Data starts
+// after the country code, with the T.35 provider code.
+type UserDataRegistered struct {
+	CountryCode          int
+	CountryCodeExtension int
+	Data                 []byte
+}
+
+// UserDataUnregistered is user_data_unregistered(), D.1.7.
+type UserDataUnregistered struct {
+	UUID [16]byte
+	Data []byte
+}
+
+// UUIDString formats the UUID as 8-4-4-4-12 hex digits.
+func (u *UserDataUnregistered) UUIDString() string {
+	b := u.UUID
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// x264Info returns the text x264 writes, "x264 - core ... - options: ...".
+func (u *UserDataUnregistered) x264Info() string {
+	if u.UUID != X264UUID {
+		return ""
+	}
+
+	text := u.Data
+	if i := bytes.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+
+	return string(text)
+}
+
+// X264Version returns the x264 version, e.g. "core 164 r3095 baee400", empty
+// for other encoders.
+func (u *UserDataUnregistered) X264Version() string {
+	info := strings.TrimPrefix(u.x264Info(), "x264 - ")
+	if i := strings.Index(info, " - "); i >= 0 {
+		return info[:i]
+	}
+
+	return ""
+}
+
+// X264Options returns the encoder settings x264 lists after "options:".
+func (u *UserDataUnregistered) X264Options() map[string]string {
+	info := u.x264Info()
+	i := strings.Index(info, "options: ")
+	if i < 0 {
+		return nil
+	}
+
+	options := make(map[string]string)
+	for _, option := range strings.Fields(info[i+len("options: "):]) {
+		if kv := strings.SplitN(option, "=", 2); len(kv) == 2 {
+			options[kv[0]] = kv[1]
+		} else {
+			options[option] = ""
+		}
+	}
+
+	return options
+}
+
+// numClockTS is NumClockTS of Table D-1, indexed by pic_struct.
+var numClockTS = []int{1, 1, 1, 2, 2, 3, 3, 2, 3}
+
+// DecodeSEI splits an SEI NALU, header byte included, into its messages.
+// buffering_period is decoded with the SPS it names, pic_timing with active,
+// the SPS of the picture; without it the message is left undecoded. A message
+// whose payload does not decode is kept with a nil Payload; if the messages
+// cannot be split, those before the bad one are returned with ErrInvalidSEI.
+func DecodeSEI(data []byte, sps map[int]*SPS, active *SPS) ([]*SEIMessage, error) {
+	nalu := decodeNalu(data)
+	if nalu.NalUnitType != NAL_SEI {
+		return nil, ErrInvalidSEI
+	}
+
+	var msgs []*SEIMessage
+	rbsp := nalu.Rbsp
+	for pos := 0; pos < len(rbsp) && !(pos == len(rbsp)-1 && rbsp[pos] == 0x80); {
+		var typ, size int
+		var ok bool
+		if typ, pos, ok = seiValue(rbsp, pos); !ok {
+			return msgs, ErrInvalidSEI
+		}
+
+		if size, pos, ok = seiValue(rbsp, pos); !ok || size > len(rbsp)-pos {
+			return msgs, ErrInvalidSEI
+		}
+
+		msg := &SEIMessage{Type: typ, Size: size, Data: rbsp[pos : pos+size]}
+		pos += size
+
+		if err := msg.decode(sps, active); err != nil {
+			msg.Payload = nil
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+// seiValue reads a payload type or size, coded as a run of 0xff bytes plus
+// a last byte.
+func seiValue(rbsp []byte, pos int) (int, int, bool) {
+	v := 0
+	for ; pos < len(rbsp); pos++ {
+		v += int(rbsp[pos])
+		if rbsp[pos] != 0xff {
+			return v, pos + 1, true
+		}
+	}
+
+	return 0, pos, false
+}
+
+func (m *SEIMessage) decode(sps map[int]*SPS, active *SPS) error {
+	r := core.NewBitReader(m.Data)
+
+	switch m.Type {
+	case SEI_BUFFERING_PERIOD:
+		bp := &BufferingPeriod{SPSId: r.UE()}
+		ref := sps[bp.SPSId]
+		if ref == nil {
+			return nil
+		}
+
+		bp.NalInitialCpbRemovalDelay, bp.NalInitialCpbRemovalDelayOffs = readInitialDelays(r, ref.NalHrd)
+		bp.VclInitialCpbRemovalDelay, bp.VclInitialCpbRemovalDelayOffs = readInitialDelays(r, ref.VclHrd)
+		m.Payload = bp
+	case SEI_PIC_TIMING:
+		if active == nil {
+			return nil
+		}
+		m.Payload = readPicTiming(r, active)
+	case SEI_USER_DATA_REGISTERED:
+		if len(m.Data) < 1 {
+			return ErrInvalidSEI
+		}
+
+		ud := &UserDataRegistered{CountryCode: int(m.Data[0]), Data: m.Data[1:]}
+		if ud.CountryCode == 0xff {
+			if len(ud.Data) < 1 {
+				return ErrInvalidSEI
+			}
+			ud.CountryCodeExtension, ud.Data = int(ud.Data[0]), ud.Data[1:]
+		}
+		m.Payload = ud
+	case SEI_USER_DATA_UNREGISTERED:
+		if len(m.Data) < 16 {
+			return ErrInvalidSEI
+		}
+
+		ud := &UserDataUnregistered{Data: m.Data[16:]}
+		copy(ud.UUID[:], m.Data)
+		m.Payload = ud
+	case SEI_RECOVERY_POINT:
+		m.Payload = &RecoveryPoint{
+			RecoveryFrameCnt:      r.UE(),
+			ExactMatchFlag:        r.U(1),
+			BrokenLinkFlag:        r.U(1),
+			ChangingSliceGroupIdc: r.U(2),
+		}
+	}
+
+	if r.Err != nil {
+		return ErrInvalidSEI
+	}
+
+	return nil
+}
+
+func readInitialDelays(r *core.BitReader, hrd *HRD) ([]int, []int) {
+	if hrd == nil {
+		return nil, nil
+	}
+
+	var delays, offsets []int
+	bits := hrd.InitialCpbRemovalDelayLengthMinus1 + 1
+	for i := 0; i <= hrd.CpbCntMinus1; i++ {
+		delays = append(delays, r.U(bits))
+		offsets = append(offsets, r.U(bits))
+	}
+
+	return delays, offsets
+}
+
+func readPicTiming(r *core.BitReader, sps *SPS) *PicTiming {
+	pt := &PicTiming{}
+
+	// CpbDpbDelaysPresentFlag, both HRDs use the same lengths
+	hrd := sps.NalHrd
+	if hrd == nil {
+		hrd = sps.VclHrd
+	}
+
+	if hrd != nil {
+		pt.CpbRemovalDelay = r.U(hrd.CpbRemovalDelayLengthMinus1 + 1)
+		pt.DpbOutputDelay = r.U(hrd.DpbOutputDelayLengthMinus1 + 1)
+	}
+
+	if sps.PicStructPresentFlag == 0 {
+		return pt
+	}
+
+	pt.PicStruct = r.U(4)
+	if pt.PicStruct >= len(numClockTS) {
+		r.Err = ErrInvalidSEI
+		return pt
+	}
+
+	for i := 0; i < numClockTS[pt.PicStruct]; i++ {
+		if r.U(1) == 0 { // clock_timestamp_flag
+			pt.ClockTimestamps = append(pt.ClockTimestamps, nil)
+			continue
+		}
+
+		ct := &ClockTimestamp{}
+		ct.CtType = r.U(2)
+		ct.NuitFieldBasedFlag = r.U(1)
+		ct.CountingType = r.U(5)
+		ct.FullTimestampFlag = r.U(1)
+		ct.DiscontinuityFlag = r.U(1)
+		ct.CntDroppedFlag = r.U(1)
+		ct.NFrames = r.U(8)
+
+		if ct.FullTimestampFlag == 1 {
+			ct.Seconds = r.U(6)
+			ct.Minutes = r.U(6)
+			ct.Hours = r.U(5)
+		} else if r.U(1) == 1 { // seconds_flag
+			ct.Seconds = r.U(6)
+			if r.U(1) == 1 { // minutes_flag
+				ct.Minutes = r.U(6)
+				if r.U(1) == 1 { // hours_flag
+					ct.Hours = r.U(5)
+				}
+			}
+		}
+
+		// time_offset_length is 24 without HRD parameters
+		n := 24
+		if hrd != nil {
+			n = hrd.TimeOffsetLength
+		}
+
+		if n > 0 {
+			ct.TimeOffset = r.U(n)
+			if ct.TimeOffset >= 1<<uint(n-1) { // i(v)
+				ct.TimeOffset -= 1 << uint(n)
+			}
+		}
+
+		pt.ClockTimestamps = append(pt.ClockTimestamps, ct)
+	}
+
+	return pt
+}
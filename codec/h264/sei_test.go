@@ -0,0 +1,142 @@
+package h264
+
+import (
+	"strings"
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seiNalu wraps payloads of the given types in an escaped SEI NALU.
+func seiNalu(msgs ...interface{}) []byte {
+	w := &bittest.Writer{}
+	w.U(8, 0x06)
+	for i := 0; i < len(msgs); i += 2 {
+		for _, v := range []int{msgs[i].(int), len(msgs[i+1].([]byte))} {
+			for ; v >= 0xff; v -= 0xff {
+				w.U(8, 0xff)
+			}
+			w.U(8, v)
+		}
+
+		for _, b := range msgs[i+1].([]byte) {
+			w.U(8, int(b))
+		}
+	}
+
+	return w.NALU()
+}
+
+func TestDecodeSEIUserData(t *testing.T) {
+	text := "x264 - core 164 r3095 baee400 - H.264/MPEG-4 AVC codec - Copyleft 2003-2022 - " +
+		"http://www.videolan.org/x264.html - options: cabac=1 ref=3 deblock=1:0:0 analyse=0x3:0x113 " +
+		"me=hex subme=7 psy=1 psy_rd=1.00:0.00 mixed_ref=1 me_range=16 chroma_me=1 trellis=1 8x8dct=1 " +
+		"bframes=3 b_pyramid=2 keyint=250 rc=crf crf=23.0 qcomp=0.60"
+	payload := append(X264UUID[:], text...)
+	payload = append(payload, 0)
+
+	t35 := []byte{0xb5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03}
+
+	msgs, err := DecodeSEI(seiNalu(SEI_USER_DATA_UNREGISTERED, payload, SEI_USER_DATA_REGISTERED, t35), nil, nil)
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, len(payload), msgs[0].Size)
+	assert.True(t, msgs[0].Size > 255)
+	assert.Equal(t, "user_data_unregistered(338)", msgs[0].String())
+
+	ud := msgs[0].Payload.(*UserDataUnregistered)
+	assert.Equal(t, "dc45e9bd-e6d9-48b7-962c-d820d923eeef", ud.UUIDString())
+	assert.Equal(t, "core 164 r3095 baee400", ud.X264Version())
+	options := ud.X264Options()
+	assert.Equal(t, "3", options["bframes"])
+	assert.Equal(t, "23.0", options["crf"])
+	assert.Equal(t, "1:0:0", options["deblock"])
+
+	reg := msgs[1].Payload.(*UserDataRegistered)
+	assert.Equal(t, 0xb5, reg.CountryCode)
+	assert.Equal(t, t35[1:], reg.Data)
+
+	ud.UUID[0] = 0
+	assert.Equal(t, "", ud.X264Version())
+	assert.Nil(t, ud.X264Options())
+
+	// payload size past the end of the NALU, the messages before it are kept
+	nalu := seiNalu(SEI_USER_DATA_UNREGISTERED, payload, SEI_RECOVERY_POINT, []byte{0x80})
+	nalu[len(nalu)-3] = 9
+	msgs, err = DecodeSEI(nalu, nil, nil)
+	assert.Equal(t, ErrInvalidSEI, err)
+	assert.Len(t, msgs, 1)
+
+	// undecodable payloads are kept without Payload
+	msgs, err = DecodeSEI(seiNalu(SEI_USER_DATA_REGISTERED, []byte{}, SEI_USER_DATA_UNREGISTERED, []byte{1, 2},
+		SEI_RECOVERY_POINT, []byte{}), nil, nil)
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 3)
+	for _, msg := range msgs {
+		assert.Nil(t, msg.Payload)
+	}
+	assert.Equal(t, []byte{1, 2}, msgs[1].Data)
+}
+
+func TestDecodeSEITiming(t *testing.T) {
+	hrd := &HRD{CpbCntMinus1: 1, InitialCpbRemovalDelayLengthMinus1: 23,
+		CpbRemovalDelayLengthMinus1: 15, DpbOutputDelayLengthMinus1: 4, TimeOffsetLength: 8}
+	sps := &SPS{SPSId: 2, NalHrd: hrd, PicStructPresentFlag: 1}
+
+	bp := &bittest.Writer{}
+	bp.UE(2)
+	for i := 0; i < 2; i++ {
+		bp.U(24, 9000+i)
+		bp.U(24, 100+i)
+	}
+
+	pt := &bittest.Writer{}
+	pt.U(16, 4)
+	pt.U(5, 2)
+	pt.U(4, 3) // top bottom, two timestamps
+	pt.U(1, 1)
+	pt.U(2, 0)
+	pt.U(1, 0)
+	pt.U(5, 4)
+	pt.U(1, 1) // full_timestamp_flag
+	pt.U(1, 0)
+	pt.U(1, 1) // cnt_dropped_flag
+	pt.U(8, 17)
+	pt.U(6, 59)
+	pt.U(6, 1)
+	pt.U(5, 10)
+	pt.U(8, 0xfe) // time_offset -2
+	pt.U(1, 0)
+
+	rp := &bittest.Writer{}
+	rp.UE(3)
+	rp.U(1, 1)
+	rp.U(1, 0)
+	rp.U(2, 0)
+
+	msgs, err := DecodeSEI(seiNalu(SEI_BUFFERING_PERIOD, bp.Bytes(), SEI_PIC_TIMING, pt.Bytes(),
+		SEI_RECOVERY_POINT, rp.Bytes()), map[int]*SPS{2: sps}, sps)
+	assert.Nil(t, err)
+	assert.Equal(t, &BufferingPeriod{SPSId: 2,
+		NalInitialCpbRemovalDelay:     []int{9000, 9001},
+		NalInitialCpbRemovalDelayOffs: []int{100, 101}}, msgs[0].Payload)
+
+	timing := msgs[1].Payload.(*PicTiming)
+	assert.Equal(t, 4, timing.CpbRemovalDelay)
+	assert.Equal(t, 2, timing.DpbOutputDelay)
+	assert.Equal(t, 3, timing.PicStruct)
+	assert.Len(t, timing.ClockTimestamps, 2)
+	assert.Nil(t, timing.ClockTimestamps[1])
+	assert.Equal(t, "10:01:59;17", timing.ClockTimestamps[0].Timecode())
+	assert.Equal(t, -2, timing.ClockTimestamps[0].TimeOffset)
+
+	assert.Equal(t, &RecoveryPoint{RecoveryFrameCnt: 3, ExactMatchFlag: 1}, msgs[2].Payload)
+
+	// pic_timing needs the SPS of the picture
+	msgs, err = DecodeSEI(seiNalu(SEI_PIC_TIMING, pt.Bytes()), nil, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, msgs[0].Payload)
+	assert.True(t, strings.HasPrefix(msgs[0].String(), "pic_timing"))
+}
@@ -0,0 +1,462 @@
+package h264
+
+import (
+	"errors"
+
+	"media-go/core"
+)
+
+const (
+	SLICE_P  = 0
+	SLICE_B  = 1
+	SLICE_I  = 2
+	SLICE_SP = 3
+	SLICE_SI = 4
+)
+
+var sliceTypeMap = map[int]string{
+	SLICE_P:  "P",
+	SLICE_B:  "B",
+	SLICE_I:  "I",
+	SLICE_SP: "SP",
+	SLICE_SI: "SI",
+}
+
+var ErrInvalidSlice = errors.New("h264: invalid slice header")
+
+// MMCO is one memory_management_control_operation of dec_ref_pic_marking().
+type MMCO struct {
+	Op                        int `json:"memory_management_control_operation"`
+	DifferenceOfPicNumsMinus1 int `json:"difference_of_pic_nums_minus1"`
+	LongTermPicNum            int `json:"long_term_pic_num"`
+	LongTermFrameIdx          int `json:"long_term_frame_idx"`
+	MaxLongTermFrameIdxPlus1  int `json:"max_long_term_frame_idx_plus1"`
+}
+
+// RefPicListModification is one modification_of_pic_nums_idc entry.
+type RefPicListModification struct {
+	Idc                 int `json:"modification_of_pic_nums_idc"`
+	AbsDiffPicNumMinus1 int `json:"abs_diff_pic_num_minus1"`
+	LongTermPicNum      int `json:"long_term_pic_num"`
+}
+
+// SliceHeader is the slice_header() of 7.3.3. The order counts are filled in
+// by Parser.ParseSlice.
+type SliceHeader struct {
+	NalUnitType                   int                      `json:"nal_unit_type"`
+	NalRefIdc                     int                      `json:"nal_ref_idc"`
+	FirstMbInSlice                int                      `json:"first_mb_in_slice"`
+	SliceType                     int                      `json:"slice_type"` // 0-9, see Type
+	PPSId                         int                      `json:"pps_id"`
+	ColourPlaneId                 int                      `json:"colour_plane_id"`
+	FrameNum                      int                      `json:"frame_num"`
+	FieldPicFlag                  int                      `json:"field_pic_flag"`
+	BottomFieldFlag               int                      `json:"bottom_field_flag"`
+	IdrPicId                      int                      `json:"idr_pic_id"`
+	PicOrderCntLsb                int                      `json:"pic_order_cnt_lsb"`
+	DeltaPicOrderCntBottom        int                      `json:"delta_pic_order_cnt_bottom"`
+	DeltaPicOrderCnt              [2]int                   `json:"delta_pic_order_cnt"`
+	RedundantPicCnt               int                      `json:"redundant_pic_cnt"`
+	DirectSpatialMvPredFlag       int                      `json:"direct_spatial_mv_pred_flag"`
+	NumRefIdxActiveOverrideFlag   int                      `json:"num_ref_idx_active_override_flag"`
+	NumRefIdxL0ActiveMinus1       int                      `json:"num_ref_idx_l0_active_minus1"`
+	NumRefIdxL1ActiveMinus1       int                      `json:"num_ref_idx_l1_active_minus1"`
+	RefPicListModificationL0      []RefPicListModification `json:"ref_pic_list_modification_l0,omitempty"`
+	RefPicListModificationL1      []RefPicListModification `json:"ref_pic_list_modification_l1,omitempty"`
+	LumaLog2WeightDenom           int                      `json:"luma_log2_weight_denom"`
+	ChromaLog2WeightDenom         int                      `json:"chroma_log2_weight_denom"`
+	NoOutputOfPriorPicsFlag       int                      `json:"no_output_of_prior_pics_flag"`
+	LongTermReferenceFlag         int                      `json:"long_term_reference_flag"`
+	AdaptiveRefPicMarkingModeFlag int                      `json:"adaptive_ref_pic_marking_mode_flag"`
+	MMCO                          []MMCO                   `json:"mmco,omitempty"`
+	CabacInitIdc                  int                      `json:"cabac_init_idc"`
+	SliceQpDelta                  int                      `json:"slice_qp_delta"`
+	SpForSwitchFlag               int                      `json:"sp_for_switch_flag"`
+	SliceQsDelta                  int                      `json:"slice_qs_delta"`
+	DisableDeblockingFilterIdc    int                      `json:"disable_deblocking_filter_idc"`
+	SliceAlphaC0OffsetDiv2        int                      `json:"slice_alpha_c0_offset_div2"`
+	SliceBetaOffsetDiv2           int                      `json:"slice_beta_offset_div2"`
+	SliceGroupChangeCycle         int                      `json:"slice_group_change_cycle"`
+
+	TopFieldOrderCnt    int `json:"top_field_order_cnt"`
+	BottomFieldOrderCnt int `json:"bottom_field_order_cnt"`
+	PicOrderCnt         int `json:"pic_order_cnt"`
+}
+
+// Type is the slice type without the "all slices alike" offset of 5.
+func (sh *SliceHeader) Type() int { return sh.SliceType % 5 }
+
+// TypeName returns "I", "P", "B", "SP" or "SI".
+func (sh *SliceHeader) TypeName() string { return sliceTypeMap[sh.Type()] }
+
+// IsIDR tells whether the slice belongs to an IDR picture.
+func (sh *SliceHeader) IsIDR() bool { return sh.NalUnitType == NAL_IDR_SLICE }
+
+// ResetsOrder tells whether the picture starts a new picture order count
+// sequence: an IDR picture or one with memory_management_control_operation
+// 5. Pictures are only ordered by PicOrderCnt between such pictures.
+func (sh *SliceHeader) ResetsOrder() bool { return sh.IsIDR() || sh.hasMMCO5() }
+
+// hasMMCO5 tells whether the picture resets frame_num and the order counts.
+func (sh *SliceHeader) hasMMCO5() bool {
+	for _, op := range sh.MMCO {
+		if op.Op == 5 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DecodeSliceHeader parses the header of a slice NALU, header byte included.
+// pps and sps are the parameter sets the slice refers to.
+func DecodeSliceHeader(data []byte, sps *SPS, pps *PPS) (*SliceHeader, error) {
+	nalu := decodeNalu(data)
+	if nalu.NalUnitType != NAL_SLICE && nalu.NalUnitType != NAL_IDR_SLICE {
+		return nil, ErrInvalidSlice
+	}
+
+	sh, r := &SliceHeader{NalUnitType: nalu.NalUnitType, NalRefIdc: nalu.NalReferenceIdc}, core.NewBitReader(nalu.Rbsp)
+	sh.FirstMbInSlice = r.UE()
+	sh.SliceType = r.UE()
+	sh.PPSId = r.UE()
+	if r.Err != nil || sh.SliceType > 9 || sh.PPSId != pps.PPSId || pps.SPSId != sps.SPSId {
+		return nil, ErrInvalidSlice
+	}
+
+	typ := sh.Type()
+	if sps.ChromaFormatIdc == 3 && sps.ResidualColourTransformFlag == 1 {
+		sh.ColourPlaneId = r.U(2)
+	}
+
+	sh.FrameNum = r.U(sps.Log2MaxFrameNumMinus4 + 4)
+	if sps.FrameMbsOnlyFlag == 0 {
+		if sh.FieldPicFlag = r.U(1); sh.FieldPicFlag == 1 {
+			sh.BottomFieldFlag = r.U(1)
+		}
+	}
+
+	if sh.IsIDR() {
+		sh.IdrPicId = r.UE()
+	}
+
+	if sps.PicOrderCntType == 0 {
+		sh.PicOrderCntLsb = r.U(sps.Log2MaxPicOrderCntLsbMinus4 + 4)
+		if pps.BottomFieldPicOrderInFramePresentFlag == 1 && sh.FieldPicFlag == 0 {
+			sh.DeltaPicOrderCntBottom = r.SE()
+		}
+	}
+
+	if sps.PicOrderCntType == 1 && sps.DeltaPicOrderAlwaysZeroFlag == 0 {
+		sh.DeltaPicOrderCnt[0] = r.SE()
+		if pps.BottomFieldPicOrderInFramePresentFlag == 1 && sh.FieldPicFlag == 0 {
+			sh.DeltaPicOrderCnt[1] = r.SE()
+		}
+	}
+
+	if pps.RedundantPicCntPresentFlag == 1 {
+		sh.RedundantPicCnt = r.UE()
+	}
+
+	if typ == SLICE_B {
+		sh.DirectSpatialMvPredFlag = r.U(1)
+	}
+
+	sh.NumRefIdxL0ActiveMinus1 = pps.NumRefIdxL0DefaultActiveMinus1
+	sh.NumRefIdxL1ActiveMinus1 = pps.NumRefIdxL1DefaultActiveMinus1
+	if typ == SLICE_P || typ == SLICE_SP || typ == SLICE_B {
+		if sh.NumRefIdxActiveOverrideFlag = r.U(1); sh.NumRefIdxActiveOverrideFlag == 1 {
+			sh.NumRefIdxL0ActiveMinus1 = r.UE()
+			if typ == SLICE_B {
+				sh.NumRefIdxL1ActiveMinus1 = r.UE()
+			}
+		}
+	}
+
+	if sh.NumRefIdxL0ActiveMinus1 > 31 || sh.NumRefIdxL1ActiveMinus1 > 31 {
+		return nil, ErrInvalidSlice
+	}
+
+	if typ != SLICE_I && typ != SLICE_SI {
+		sh.RefPicListModificationL0 = readRefPicListModification(r)
+	}
+
+	if typ == SLICE_B {
+		sh.RefPicListModificationL1 = readRefPicListModification(r)
+	}
+
+	if (pps.WeightedPredFlag == 1 && (typ == SLICE_P || typ == SLICE_SP)) ||
+		(pps.WeightedBipredIdc == 1 && typ == SLICE_B) {
+		readPredWeightTable(r, sh, sps)
+	}
+
+	if sh.NalRefIdc != 0 {
+		readDecRefPicMarking(r, sh)
+	}
+
+	if pps.EntropyCodingModeFlag == 1 && typ != SLICE_I && typ != SLICE_SI {
+		sh.CabacInitIdc = r.UE()
+	}
+
+	sh.SliceQpDelta = r.SE()
+	if typ == SLICE_SP || typ == SLICE_SI {
+		if typ == SLICE_SP {
+			sh.SpForSwitchFlag = r.U(1)
+		}
+		sh.SliceQsDelta = r.SE()
+	}
+
+	if pps.DeblockingFilterControlPresentFlag == 1 {
+		if sh.DisableDeblockingFilterIdc = r.UE(); sh.DisableDeblockingFilterIdc != 1 {
+			sh.SliceAlphaC0OffsetDiv2 = r.SE()
+			sh.SliceBetaOffsetDiv2 = r.SE()
+		}
+	}
+
+	if pps.NumSliceGroupsMinus1 > 0 && pps.SliceGroupMapType >= 3 && pps.SliceGroupMapType <= 5 {
+		// Ceil(Log2(PicSizeInMapUnits ÷ SliceGroupChangeRate + 1)) bits
+		size := (sps.PicWidthInMbsMinus1 + 1) * (sps.PicHeightInMapUnitsMinus1 + 1)
+		rate := pps.SliceGroupChangeRateMinus1 + 1
+		bits := 0
+		for (1<<uint(bits))*rate < size+rate {
+			bits++
+		}
+		sh.SliceGroupChangeCycle = r.U(bits)
+	}
+
+	if r.Err != nil {
+		return nil, ErrInvalidSlice
+	}
+
+	return sh, nil
+}
+
+func readRefPicListModification(r *core.BitReader) []RefPicListModification {
+	if r.U(1) == 0 { // ref_pic_list_modification_flag
+		return nil
+	}
+
+	var list []RefPicListModification
+	for r.Err == nil {
+		m := RefPicListModification{Idc: r.UE()}
+		switch m.Idc {
+		case 0, 1:
+			m.AbsDiffPicNumMinus1 = r.UE()
+		case 2:
+			m.LongTermPicNum = r.UE()
+		case 3:
+			return list
+		default:
+			r.Err = ErrInvalidSlice
+		}
+
+		if len(list) > 32 {
+			r.Err = ErrInvalidSlice
+		}
+		list = append(list, m)
+	}
+
+	return list
+}
+
+// readPredWeightTable keeps the denominators and skips the weights.
+func readPredWeightTable(r *core.BitReader, sh *SliceHeader, sps *SPS) {
+	chroma := sps.ChromaFormatIdc != 0 && sps.ResidualColourTransformFlag == 0
+	sh.LumaLog2WeightDenom = r.UE()
+	if chroma {
+		sh.ChromaLog2WeightDenom = r.UE()
+	}
+
+	lists := []int{sh.NumRefIdxL0ActiveMinus1}
+	if sh.Type() == SLICE_B {
+		lists = append(lists, sh.NumRefIdxL1ActiveMinus1)
+	}
+
+	for _, last := range lists {
+		for i := 0; i <= last; i++ {
+			if r.U(1) == 1 { // luma_weight_flag
+				r.SE()
+				r.SE()
+			}
+
+			if chroma && r.U(1) == 1 { // chroma_weight_flag
+				for j := 0; j < 4; j++ {
+					r.SE()
+				}
+			}
+		}
+	}
+}
+
+func readDecRefPicMarking(r *core.BitReader, sh *SliceHeader) {
+	if sh.IsIDR() {
+		sh.NoOutputOfPriorPicsFlag = r.U(1)
+		sh.LongTermReferenceFlag = r.U(1)
+		return
+	}
+
+	if sh.AdaptiveRefPicMarkingModeFlag = r.U(1); sh.AdaptiveRefPicMarkingModeFlag == 0 {
+		return
+	}
+
+	for r.Err == nil {
+		op := MMCO{Op: r.UE()}
+		switch op.Op {
+		case 0:
+			return
+		case 1:
+			op.DifferenceOfPicNumsMinus1 = r.UE()
+		case 2:
+			op.LongTermPicNum = r.UE()
+		case 3:
+			op.DifferenceOfPicNumsMinus1 = r.UE()
+			op.LongTermFrameIdx = r.UE()
+		case 4:
+			op.MaxLongTermFrameIdxPlus1 = r.UE()
+		case 5:
+		case 6:
+			op.LongTermFrameIdx = r.UE()
+		default:
+			r.Err = ErrInvalidSlice
+		}
+
+		if len(sh.MMCO) > 66 {
+			r.Err = ErrInvalidSlice
+		}
+		sh.MMCO = append(sh.MMCO, op)
+	}
+}
+
+// pocState carries the values of the previous pictures the order count
+// derivation of 8.2.1 depends on.
+type pocState struct {
+	prevMsb            int
+	prevLsb            int
+	prevFrameNumOffset int
+	prevFrameNum       int
+}
+
+// frameNumOffset is FrameNumOffset of 8.2.1.2 and 8.2.1.3.
+func (s *pocState) frameNumOffset(sh *SliceHeader, sps *SPS) int {
+	if sh.IsIDR() {
+		return 0
+	}
+
+	if s.prevFrameNum > sh.FrameNum {
+		return s.prevFrameNumOffset + 1<<uint(sps.Log2MaxFrameNumMinus4+4)
+	}
+
+	return s.prevFrameNumOffset
+}
+
+// compute sets the order counts of sh and updates the state, slices must be
+// passed in decoding order.
+func (s *pocState) compute(sh *SliceHeader, sps *SPS) {
+	frameNumOffset := 0
+	switch sps.PicOrderCntType {
+	case 0:
+		if sh.IsIDR() {
+			s.prevMsb, s.prevLsb = 0, 0
+		}
+
+		maxLsb := 1 << uint(sps.Log2MaxPicOrderCntLsbMinus4+4)
+		lsb, msb := sh.PicOrderCntLsb, s.prevMsb
+		if lsb < s.prevLsb && s.prevLsb-lsb >= maxLsb/2 {
+			msb += maxLsb
+		} else if lsb > s.prevLsb && lsb-s.prevLsb > maxLsb/2 {
+			msb -= maxLsb
+		}
+
+		if sh.BottomFieldFlag == 0 {
+			sh.TopFieldOrderCnt = msb + lsb
+			if sh.FieldPicFlag == 0 {
+				sh.BottomFieldOrderCnt = sh.TopFieldOrderCnt + sh.DeltaPicOrderCntBottom
+			}
+		} else {
+			sh.BottomFieldOrderCnt = msb + lsb
+		}
+
+		if sh.NalRefIdc != 0 {
+			s.prevMsb, s.prevLsb = msb, lsb
+		}
+	case 1:
+		frameNumOffset = s.frameNumOffset(sh, sps)
+		n := sps.NumRefFramesInPicOrderCntCycle
+
+		absFrameNum := 0
+		if n != 0 {
+			absFrameNum = frameNumOffset + sh.FrameNum
+		}
+
+		if sh.NalRefIdc == 0 && absFrameNum > 0 {
+			absFrameNum--
+		}
+
+		expected := 0
+		if absFrameNum > 0 {
+			cycle, inCycle := (absFrameNum-1)/n, (absFrameNum-1)%n
+			delta := 0
+			for i, offset := range sps.OffsetForRefFrame {
+				delta += offset
+				if i <= inCycle {
+					expected += offset
+				}
+			}
+			expected += cycle * delta
+		}
+
+		if sh.NalRefIdc == 0 {
+			expected += sps.OffsetForNonRefPic
+		}
+
+		if sh.FieldPicFlag == 0 {
+			sh.TopFieldOrderCnt = expected + sh.DeltaPicOrderCnt[0]
+			sh.BottomFieldOrderCnt = sh.TopFieldOrderCnt + sps.OffsetForTopToBottomField + sh.DeltaPicOrderCnt[1]
+		} else if sh.BottomFieldFlag == 0 {
+			sh.TopFieldOrderCnt = expected + sh.DeltaPicOrderCnt[0]
+		} else {
+			sh.BottomFieldOrderCnt = expected + sps.OffsetForTopToBottomField + sh.DeltaPicOrderCnt[0]
+		}
+	case 2:
+		frameNumOffset = s.frameNumOffset(sh, sps)
+
+		temp := 0
+		if !sh.IsIDR() {
+			temp = 2 * (frameNumOffset + sh.FrameNum)
+			if sh.NalRefIdc == 0 {
+				temp--
+			}
+		}
+
+		sh.TopFieldOrderCnt, sh.BottomFieldOrderCnt = temp, temp
+	}
+
+	s.prevFrameNumOffset, s.prevFrameNum = frameNumOffset, sh.FrameNum
+
+	switch {
+	case sh.FieldPicFlag == 0:
+		sh.PicOrderCnt = sh.TopFieldOrderCnt
+		if sh.BottomFieldOrderCnt < sh.PicOrderCnt {
+			sh.PicOrderCnt = sh.BottomFieldOrderCnt
+		}
+	case sh.BottomFieldFlag == 0:
+		sh.PicOrderCnt = sh.TopFieldOrderCnt
+	default:
+		sh.PicOrderCnt = sh.BottomFieldOrderCnt
+	}
+
+	// memory_management_control_operation 5 makes the picture a new origin,
+	// 8.2.1
+	if sh.hasMMCO5() {
+		temp := sh.PicOrderCnt
+		sh.TopFieldOrderCnt -= temp
+		sh.BottomFieldOrderCnt -= temp
+		sh.PicOrderCnt = 0
+
+		s.prevFrameNumOffset, s.prevFrameNum = 0, 0
+		s.prevMsb, s.prevLsb = 0, 0
+		if sh.BottomFieldFlag == 0 {
+			s.prevLsb = sh.TopFieldOrderCnt
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package h264
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSlice(t *testing.T) {
+	p := NewParser()
+	p.sps[0] = &SPS{ChromaFormatIdc: 1, PicOrderCntType: 0, Log2MaxPicOrderCntLsbMinus4: 2}
+	p.pps[0] = &PPS{BottomFieldPicOrderInFramePresentFlag: 1, EntropyCodingModeFlag: 1,
+		WeightedBipredIdc: 1, DeblockingFilterControlPresentFlag: 1}
+
+	w := &bittest.Writer{}
+	w.U(8, 0x41)
+	w.UE(0)
+	w.UE(6) // B
+	w.UE(0)
+	w.U(4, 3) // frame_num
+	w.U(1, 0) // field_pic_flag
+	w.U(6, 10)
+	w.SE(-1) // delta_pic_order_cnt_bottom
+	w.U(1, 1)
+	w.U(1, 1) // num_ref_idx_active_override_flag
+	w.UE(1)
+	w.UE(0)
+	w.U(1, 1) // ref_pic_list_modification_flag_l0
+	w.UE(0)
+	w.UE(2)
+	w.UE(3)
+	w.U(1, 0)
+	w.UE(5) // luma_log2_weight_denom
+	w.UE(3)
+	w.U(1, 1)
+	w.SE(2)
+	w.SE(-1)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 1)
+	for i := 1; i <= 4; i++ {
+		w.SE(i)
+	}
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 1) // adaptive_ref_pic_marking_mode_flag
+	w.UE(1)
+	w.UE(4)
+	w.UE(5)
+	w.UE(0)
+	w.UE(2) // cabac_init_idc
+	w.SE(-3)
+	w.UE(0)
+	w.SE(1)
+	w.SE(-2)
+
+	sh, err := p.ParseSlice(w.NALU())
+	assert.Nil(t, err)
+	assert.Equal(t, "B", sh.TypeName())
+	assert.Equal(t, 3, sh.FrameNum)
+	assert.Equal(t, 10, sh.PicOrderCntLsb)
+	assert.Equal(t, -1, sh.DeltaPicOrderCntBottom)
+	assert.Equal(t, 1, sh.DirectSpatialMvPredFlag)
+	assert.Equal(t, 1, sh.NumRefIdxL0ActiveMinus1)
+	assert.Equal(t, []RefPicListModification{{Idc: 0, AbsDiffPicNumMinus1: 2}}, sh.RefPicListModificationL0)
+	assert.Nil(t, sh.RefPicListModificationL1)
+	assert.Equal(t, 5, sh.LumaLog2WeightDenom)
+	assert.Equal(t, []MMCO{{Op: 1, DifferenceOfPicNumsMinus1: 4}, {Op: 5}}, sh.MMCO)
+	assert.Equal(t, 2, sh.CabacInitIdc)
+	assert.Equal(t, -3, sh.SliceQpDelta)
+	assert.Equal(t, -2, sh.SliceBetaOffsetDiv2)
+
+	// 10 and 9 before, the memory_management_control_operation 5 makes the
+	// picture the new origin
+	assert.Equal(t, 1, sh.TopFieldOrderCnt)
+	assert.Equal(t, 0, sh.BottomFieldOrderCnt)
+	assert.Equal(t, 0, sh.PicOrderCnt)
+	assert.Equal(t, 1, p.poc.prevLsb)
+
+	_, err = p.ParseSlice(w.Bytes()[:8])
+	assert.Equal(t, ErrInvalidSlice, err)
+
+	delete(p.pps, 0)
+	_, err = p.ParseSlice(w.Bytes())
+	assert.Equal(t, ErrInvalidSlice, err)
+}
+
+type pocCase struct {
+	idr      bool
+	ref      bool
+	frameNum int
+	lsb      int
+	poc      int
+}
+
+func checkPOC(t *testing.T, sps *SPS, cases []pocCase) {
+	s := &pocState{}
+	for i, c := range cases {
+		sh := &SliceHeader{NalUnitType: NAL_SLICE, FrameNum: c.frameNum, PicOrderCntLsb: c.lsb}
+		if c.idr {
+			sh.NalUnitType = NAL_IDR_SLICE
+		}
+		if c.ref {
+			sh.NalRefIdc = 1
+		}
+
+		s.compute(sh, sps)
+		assert.Equal(t, c.poc, sh.PicOrderCnt, "picture %d", i)
+	}
+}
+
+func TestPOC(t *testing.T) {
+	// MaxPicOrderCntLsb 16
+	checkPOC(t, &SPS{PicOrderCntType: 0}, []pocCase{
+		{idr: true, ref: true, lsb: 0, poc: 0},
+		{ref: true, frameNum: 1, lsb: 8, poc: 8},
+		{frameNum: 2, lsb: 4, poc: 4},
+		{ref: true, frameNum: 2, lsb: 14, poc: 14},
+		{ref: true, frameNum: 3, lsb: 4, poc: 20},
+		{frameNum: 4, lsb: 2, poc: 18},
+		{idr: true, ref: true, lsb: 6, poc: 6},
+	})
+
+	// MaxFrameNum 16
+	checkPOC(t, &SPS{PicOrderCntType: 1, NumRefFramesInPicOrderCntCycle: 2,
+		OffsetForRefFrame: []int{2, 4}, OffsetForNonRefPic: -1}, []pocCase{
+		{idr: true, ref: true, poc: 0},
+		{ref: true, frameNum: 1, poc: 2},
+		{ref: true, frameNum: 2, poc: 6},
+		{ref: true, frameNum: 3, poc: 8},
+		{frameNum: 4, poc: 7},
+	})
+
+	checkPOC(t, &SPS{PicOrderCntType: 2}, []pocCase{
+		{idr: true, ref: true, poc: 0},
+		{ref: true, frameNum: 1, poc: 2},
+		{frameNum: 2, poc: 3},
+		{ref: true, frameNum: 2, poc: 4},
+		{ref: true, frameNum: 15, poc: 30},
+		{ref: true, frameNum: 0, poc: 32},
+	})
+}
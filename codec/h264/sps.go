@@ -0,0 +1,555 @@
+package h264
+
+import (
+	"errors"
+	"fmt"
+
+	"media-go/core"
+)
+
+var ErrInvalidSPS = errors.New("h264: invalid SPS")
+
+// moreRBSPData is more_rbsp_data(), 7.2: whether anything is left before
+// the rbsp_stop_one_bit.
+func moreRBSPData(r *core.BitReader, rbsp []byte) bool {
+	if r.Err != nil {
+		return false
+	}
+
+	last := len(rbsp) - 1
+	for last >= 0 && rbsp[last] == 0 {
+		last--
+	}
+
+	if last < 0 {
+		return false
+	}
+
+	stop := last*8 + 7
+	for b := rbsp[last]; b&1 == 0; b >>= 1 {
+		stop--
+	}
+
+	return r.Pos() < stop
+}
+
+func hasChromaInfo(profile int) bool {
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		return true
+	}
+
+	return false
+}
+
+// Default scaling lists in zigzag order, Tables 7-3 and 7-4.
+var (
+	defaultScaling4 = [2][16]int{
+		{6, 13, 13, 20, 20, 20, 28, 28, 28, 28, 32, 32, 32, 37, 37, 42},
+		{10, 14, 14, 20, 20, 20, 24, 24, 24, 24, 27, 27, 27, 30, 30, 34},
+	}
+
+	defaultScaling8 = [2][64]int{
+		{6, 10, 10, 13, 11, 13, 16, 16, 16, 16, 18, 18, 18, 18, 18, 23,
+			23, 23, 23, 23, 23, 25, 25, 25, 25, 25, 25, 25, 27, 27, 27, 27,
+			27, 27, 27, 27, 29, 29, 29, 29, 29, 29, 29, 31, 31, 31, 31, 31,
+			31, 33, 33, 33, 33, 33, 36, 36, 36, 36, 38, 38, 38, 40, 40, 42},
+		{9, 13, 13, 15, 13, 15, 17, 17, 17, 17, 19, 19, 19, 19, 19, 21,
+			21, 21, 21, 21, 21, 22, 22, 22, 22, 22, 22, 22, 24, 24, 24, 24,
+			24, 24, 24, 24, 25, 25, 25, 25, 25, 25, 25, 27, 27, 27, 27, 27,
+			27, 28, 28, 28, 28, 28, 30, 30, 30, 30, 32, 32, 32, 33, 33, 35},
+	}
+)
+
+// readScalingList reads a scaling_list(), 7.3.2.1.1.1, into list. It reports
+// whether the default list is to be used instead.
+func readScalingList(r *core.BitReader, list []int) bool {
+	last, next := 8, 8
+	for j := range list {
+		if next != 0 {
+			delta := r.SE()
+			if delta < -128 || delta > 127 {
+				r.Err = ErrInvalidSPS
+			}
+
+			next = (last + delta + 256) % 256
+			if j == 0 && next == 0 {
+				return true
+			}
+		}
+
+		if next != 0 {
+			list[j] = next
+		} else {
+			list[j] = last
+		}
+		last = list[j]
+	}
+
+	return false
+}
+
+// readScalingMatrix reads the scaling lists of an SPS or PPS. Lists that are
+// not present follow fall-back rule A of Table 7-2 when fallback is nil,
+// rule B otherwise, taking the lists of fallback (the SPS).
+func readScalingMatrix(r *core.BitReader, lists int, present *[12]int, m4 *[6][16]int, m8 *[6][64]int, fallback *SPS) {
+	for i := 0; i < 12; i++ {
+		if i < lists {
+			present[i] = r.U(1)
+		}
+
+		if i < 6 {
+			if present[i] == 1 && !readScalingList(r, m4[i][:]) {
+				continue
+			}
+
+			switch {
+			case present[i] == 1:
+				m4[i] = defaultScaling4[i/3]
+			case i == 0 || i == 3:
+				if fallback != nil {
+					m4[i] = fallback.ScalingMatrix4[i]
+				} else {
+					m4[i] = defaultScaling4[i/3]
+				}
+			default:
+				m4[i] = m4[i-1]
+			}
+		} else {
+			k := i - 6
+			if present[i] == 1 && !readScalingList(r, m8[k][:]) {
+				continue
+			}
+
+			switch {
+			case present[i] == 1:
+				m8[k] = defaultScaling8[k%2]
+			case k < 2:
+				if fallback != nil {
+					m8[k] = fallback.ScalingMatrix8[k]
+				} else {
+					m8[k] = defaultScaling8[k]
+				}
+			default:
+				m8[k] = m8[k-2]
+			}
+		}
+	}
+}
+
+// flatScaling sets the Flat_4x4_16 and Flat_8x8_16 lists.
+func flatScaling(m4 *[6][16]int, m8 *[6][64]int) {
+	for i := range m4 {
+		for j := range m4[i] {
+			m4[i][j] = 16
+		}
+	}
+
+	for i := range m8 {
+		for j := range m8[i] {
+			m8[i][j] = 16
+		}
+	}
+}
+
+// DecodeSPS parses an SPS NALU, header byte included, 7.3.2.1.1. Scaling
+// lists are stored in zigzag order with the defaults of Table 7-2 applied.
+func DecodeSPS(data []byte) (*SPS, error) {
+	nalu := decodeNalu(data)
+	if nalu.NalUnitType != NAL_SPS {
+		return nil, ErrInvalidSPS
+	}
+
+	r := core.NewBitReader(nalu.Rbsp[:nalu.RbspSize])
+	sps := &SPS{ChromaFormatIdc: 1}
+	sps.ProfileIdc = r.U(8)
+	x := r.U(8)
+	sps.ConstraintSet0Flag = (x >> 7) & 0x01
+	sps.ConstraintSet1Flag = (x >> 6) & 0x01
+	sps.ConstraintSet2Flag = (x >> 5) & 0x01
+	sps.ConstraintSet3Flag = (x >> 4) & 0x01
+	sps.ConstraintSet4Flag = (x >> 3) & 0x01
+	sps.ConstraintSet5Flag = (x >> 2) & 0x01
+	sps.ReservedZero2Bits = x & 0x03
+	sps.LevelIdc = r.U(8)
+	sps.SPSId = r.UE()
+
+	if hasChromaInfo(sps.ProfileIdc) {
+		sps.ChromaFormatIdc = r.UE()
+		if sps.ChromaFormatIdc == 3 {
+			sps.ResidualColourTransformFlag = r.U(1)
+		}
+
+		sps.BitDepthLumaMinus8 = r.UE()
+		sps.BitDepthChromaMinus8 = r.UE()
+		sps.QpprimeYZeroTransformBypassFlag = r.U(1)
+		sps.SeqScalingMatrixPresentFlag = r.U(1)
+	}
+
+	if sps.SeqScalingMatrixPresentFlag == 1 {
+		lists := 8
+		if sps.ChromaFormatIdc == 3 {
+			lists = 12
+		}
+
+		readScalingMatrix(r, lists, &sps.SeqScalingListPresentFlag, &sps.ScalingMatrix4, &sps.ScalingMatrix8, nil)
+	} else {
+		flatScaling(&sps.ScalingMatrix4, &sps.ScalingMatrix8)
+	}
+
+	if sps.SPSId > 31 || sps.ChromaFormatIdc > 3 || sps.BitDepthLumaMinus8 > 6 || sps.BitDepthChromaMinus8 > 6 {
+		return nil, ErrInvalidSPS
+	}
+
+	sps.Log2MaxFrameNumMinus4 = r.UE()
+	sps.PicOrderCntType = r.UE()
+	if sps.Log2MaxFrameNumMinus4 > 12 || sps.PicOrderCntType > 2 {
+		return nil, ErrInvalidSPS
+	}
+
+	if sps.PicOrderCntType == 0 {
+		sps.Log2MaxPicOrderCntLsbMinus4 = r.UE()
+		if sps.Log2MaxPicOrderCntLsbMinus4 > 12 {
+			return nil, ErrInvalidSPS
+		}
+	} else if sps.PicOrderCntType == 1 {
+		sps.DeltaPicOrderAlwaysZeroFlag = r.U(1)
+		sps.OffsetForNonRefPic = r.SE()
+		sps.OffsetForTopToBottomField = r.SE()
+		sps.NumRefFramesInPicOrderCntCycle = r.UE()
+		if sps.NumRefFramesInPicOrderCntCycle > 255 {
+			return nil, ErrInvalidSPS
+		}
+
+		sps.OffsetForRefFrame = make([]int, 0, sps.NumRefFramesInPicOrderCntCycle)
+		for i := 0; i < sps.NumRefFramesInPicOrderCntCycle; i++ {
+			sps.OffsetForRefFrame = append(sps.OffsetForRefFrame, r.SE())
+		}
+	}
+
+	sps.NumRefFrames = r.UE()
+	sps.GapsInFrameNumValueAllowedFlag = r.U(1)
+	sps.PicWidthInMbsMinus1 = r.UE()
+	sps.PicHeightInMapUnitsMinus1 = r.UE()
+
+	sps.FrameMbsOnlyFlag = r.U(1)
+	if sps.FrameMbsOnlyFlag == 0 {
+		sps.MbAdaptiveFrameFieldFlag = r.U(1)
+	}
+
+	sps.Direct8x8InferenceFlag = r.U(1)
+	sps.FrameCropingFlag = r.U(1)
+	if sps.FrameCropingFlag == 1 {
+		sps.FrameCropLeftOffset = r.UE()
+		sps.FrameCropRightOffset = r.UE()
+		sps.FrameCropTopOffset = r.UE()
+		sps.FrameCropButtomOffset = r.UE()
+	}
+
+	sps.VuiParametersPresentFlag = r.U(1)
+	if sps.VuiParametersPresentFlag == 1 {
+		readVui(r, sps)
+	}
+
+	if r.Err != nil {
+		return nil, ErrInvalidSPS
+	}
+
+	return sps, nil
+}
+
+// readVui reads the vui_parameters(), E.1.1.
+func readVui(r *core.BitReader, sps *SPS) {
+	if sps.AspectRatioInfoPresentFlag = r.U(1); sps.AspectRatioInfoPresentFlag == 1 {
+		sps.AspectRatioIdc = r.U(8)
+		if sps.AspectRatioIdc == 255 { // Extended_SAR
+			sps.SarWidth = r.U(16)
+			sps.SarHeight = r.U(16)
+		}
+	}
+
+	if sps.OverscanInfoPresentFlag = r.U(1); sps.OverscanInfoPresentFlag == 1 {
+		sps.OverscanAppropriateFlag = r.U(1)
+	}
+
+	// unspecified video format and colour, 2 is "unspecified" in Table E-3
+	// to E-5
+	sps.VideoFormat = 5
+	sps.ColourPrimaries, sps.TransferCharacteristics, sps.MatrixCoefficients = 2, 2, 2
+	if sps.VideoSignalTypePresentFlag = r.U(1); sps.VideoSignalTypePresentFlag == 1 {
+		sps.VideoFormat = r.U(3)
+		sps.VideoFullRangeFlag = r.U(1)
+		if sps.ColourDescriptionPresentFlag = r.U(1); sps.ColourDescriptionPresentFlag == 1 {
+			sps.ColourPrimaries = r.U(8)
+			sps.TransferCharacteristics = r.U(8)
+			sps.MatrixCoefficients = r.U(8)
+		}
+	}
+
+	if sps.ChromaLocInfoPresentFlag = r.U(1); sps.ChromaLocInfoPresentFlag == 1 {
+		sps.ChromaSampleLocTypeTopField = r.UE()
+		sps.ChromaSampleLocTypeBottomField = r.UE()
+	}
+
+	if sps.TimingInfoPresentFlag = r.U(1); sps.TimingInfoPresentFlag == 1 {
+		sps.NumUnitsInTick = r.U(32)
+		sps.TimeScale = r.U(32)
+		sps.FixedFrameRateFlag = r.U(1)
+	}
+
+	if sps.NalHrdParametersPresentFlag = r.U(1); sps.NalHrdParametersPresentFlag == 1 {
+		sps.NalHrd = readHrd(r)
+	}
+
+	if sps.VclHrdParametersPresentFlag = r.U(1); sps.VclHrdParametersPresentFlag == 1 {
+		sps.VclHrd = readHrd(r)
+	}
+
+	if sps.NalHrd != nil || sps.VclHrd != nil {
+		sps.LowDelayHrdFlag = r.U(1)
+	}
+
+	sps.PicStructPresentFlag = r.U(1)
+	if sps.BitstreamRestrictionFlag = r.U(1); sps.BitstreamRestrictionFlag == 1 {
+		sps.MotionVectorsOverPicBoundaries = r.U(1)
+		sps.MaxBytesPerPicDenom = r.UE()
+		sps.MaxBitsPerMbDenom = r.UE()
+		sps.Log2MaxMvLengthHorizontal = r.UE()
+		sps.Log2MaxMvLengthVertical = r.UE()
+		sps.MaxNumReorderFrames = r.UE()
+		sps.MaxDecFrameBuffering = r.UE()
+	}
+}
+
+// readHrd reads the hrd_parameters(), E.1.2.
+func readHrd(r *core.BitReader) *HRD {
+	hrd := &HRD{}
+	hrd.CpbCntMinus1 = r.UE()
+	if hrd.CpbCntMinus1 > 31 {
+		r.Err = ErrInvalidSPS
+		return hrd
+	}
+
+	hrd.BitRateScale = r.U(4)
+	hrd.CpbSizeScale = r.U(4)
+	for i := 0; i <= hrd.CpbCntMinus1; i++ {
+		hrd.BitRateValueMinus1 = append(hrd.BitRateValueMinus1, r.UE())
+		hrd.CpbSizeValueMinus1 = append(hrd.CpbSizeValueMinus1, r.UE())
+		hrd.CbrFlag = append(hrd.CbrFlag, r.U(1))
+	}
+
+	hrd.InitialCpbRemovalDelayLengthMinus1 = r.U(5)
+	hrd.CpbRemovalDelayLengthMinus1 = r.U(5)
+	hrd.DpbOutputDelayLengthMinus1 = r.U(5)
+	hrd.TimeOffsetLength = r.U(5)
+	return hrd
+}
+
+// FrameRate is the frame rate signalled in the VUI, 0 if absent.
+func (sps *SPS) FrameRate() float64 {
+	if sps.TimingInfoPresentFlag == 0 || sps.NumUnitsInTick == 0 {
+		return 0
+	}
+
+	// one tick per field
+	return float64(sps.TimeScale) / float64(2*sps.NumUnitsInTick)
+}
+
+// maxDpbMbs is MaxDpbMbs of Table A-1, indexed by level_idc.
+var maxDpbMbs = map[int]int{
+	9: 396, 10: 396, 11: 900, 12: 2376, 13: 2376, 20: 2376, 21: 4752, 22: 8100,
+	30: 8100, 31: 18000, 32: 20480, 40: 32768, 41: 32768, 42: 34816,
+	50: 110400, 51: 184320, 52: 184320, 60: 696320, 61: 696320, 62: 696320,
+}
+
+// ReorderFrames is max_num_reorder_frames, the most frames that precede a
+// frame in decoding order and follow it in output order. Without VUI
+// bitstream restrictions it is inferred as in E.2.1.
+func (sps *SPS) ReorderFrames() int {
+	if sps.BitstreamRestrictionFlag == 1 {
+		return sps.MaxNumReorderFrames
+	}
+
+	switch sps.ProfileIdc {
+	case 44, 86, 100, 110, 122, 244:
+		if sps.ConstraintSet3Flag == 1 {
+			// intra profiles
+			return 0
+		}
+	}
+
+	mbs := maxDpbMbs[sps.LevelIdc]
+	if sps.LevelName() == "1b" {
+		mbs = maxDpbMbs[9]
+	}
+
+	frameMbs := (sps.PicWidthInMbsMinus1 + 1) * (sps.PicHeightInMapUnitsMinus1 + 1) * (2 - sps.FrameMbsOnlyFlag)
+	if mbs == 0 || mbs/frameMbs > 16 {
+		return 16
+	}
+
+	return mbs / frameMbs
+}
+
+// Table E-1, indexed by aspect_ratio_idc
+var sampleAspectRatios = [][2]int{
+	{0, 0}, {1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11}, {32, 11},
+	{80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+}
+
+// chromaSubsampling returns SubWidthC and SubHeightC of Table 6-1, 0 for
+// monochrome and separate colour planes.
+func (sps *SPS) chromaSubsampling() (int, int) {
+	if sps.ChromaFormatIdc == 3 && sps.ResidualColourTransformFlag == 1 {
+		return 0, 0
+	}
+
+	switch sps.ChromaFormatIdc {
+	case 1:
+		return 2, 2
+	case 2:
+		return 2, 1
+	case 3:
+		return 1, 1
+	}
+
+	return 0, 0
+}
+
+// cropUnit returns CropUnitX and CropUnitY, 7.4.2.1.1.
+func (sps *SPS) cropUnit() (int, int) {
+	x, y := sps.chromaSubsampling()
+	if x == 0 {
+		x, y = 1, 1
+	}
+
+	return x, y * (2 - sps.FrameMbsOnlyFlag)
+}
+
+// Width is the width of the cropped frame in luma samples.
+func (sps *SPS) Width() int {
+	x, _ := sps.cropUnit()
+	return (sps.PicWidthInMbsMinus1+1)*16 - x*(sps.FrameCropLeftOffset+sps.FrameCropRightOffset)
+}
+
+// Height is the height of the cropped frame in luma samples, both fields
+// for interlaced video.
+func (sps *SPS) Height() int {
+	_, y := sps.cropUnit()
+	return (2-sps.FrameMbsOnlyFlag)*(sps.PicHeightInMapUnitsMinus1+1)*16 -
+		y*(sps.FrameCropTopOffset+sps.FrameCropButtomOffset)
+}
+
+// SampleAspectRatio returns the sample aspect ratio of the VUI, 0:0 if it is
+// unspecified.
+func (sps *SPS) SampleAspectRatio() (int, int) {
+	if sps.AspectRatioInfoPresentFlag == 0 {
+		return 0, 0
+	}
+
+	if sps.AspectRatioIdc == 255 { // Extended_SAR
+		return sps.SarWidth, sps.SarHeight
+	}
+
+	if sps.AspectRatioIdc < len(sampleAspectRatios) {
+		sar := sampleAspectRatios[sps.AspectRatioIdc]
+		return sar[0], sar[1]
+	}
+
+	return 0, 0
+}
+
+// ProfileName names the profile of Annex A, constrained variants included.
+func (sps *SPS) ProfileName() string {
+	switch sps.ProfileIdc {
+	case 66:
+		if sps.ConstraintSet1Flag == 1 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case 77:
+		return "Main"
+	case 88:
+		return "Extended"
+	case 100:
+		if sps.ConstraintSet4Flag == 1 && sps.ConstraintSet5Flag == 1 {
+			return "Constrained High"
+		} else if sps.ConstraintSet4Flag == 1 {
+			return "Progressive High"
+		}
+		return "High"
+	case 110:
+		if sps.ConstraintSet3Flag == 1 {
+			return "High 10 Intra"
+		}
+		return "High 10"
+	case 122:
+		if sps.ConstraintSet3Flag == 1 {
+			return "High 4:2:2 Intra"
+		}
+		return "High 4:2:2"
+	case 244:
+		if sps.ConstraintSet3Flag == 1 {
+			return "High 4:4:4 Intra"
+		}
+		return "High 4:4:4 Predictive"
+	case 44:
+		return "CAVLC 4:4:4 Intra"
+	case 83:
+		return "Scalable Baseline"
+	case 86:
+		return "Scalable High"
+	case 118:
+		return "Multiview High"
+	case 128:
+		return "Stereo High"
+	case 134:
+		return "MFC High"
+	case 138:
+		return "Multiview Depth High"
+	case 139:
+		return "Enhanced Multiview Depth High"
+	}
+
+	return fmt.Sprintf("unknown(%d)", sps.ProfileIdc)
+}
+
+// LevelName returns the level as written in Table A-1, e.g. "3.1" or "1b".
+func (sps *SPS) LevelName() string {
+	if sps.LevelIdc == 9 || (sps.LevelIdc == 11 && sps.ConstraintSet3Flag == 1 &&
+		(sps.ProfileIdc == 66 || sps.ProfileIdc == 77 || sps.ProfileIdc == 88)) {
+		return "1b"
+	}
+
+	if sps.LevelIdc%10 == 0 {
+		return fmt.Sprintf("%d", sps.LevelIdc/10)
+	}
+
+	return fmt.Sprintf("%d.%d", sps.LevelIdc/10, sps.LevelIdc%10)
+}
+
+// constraintFlags returns the profile compatibility byte of the SPS.
+func (sps *SPS) constraintFlags() int {
+	return sps.ConstraintSet0Flag<<7 | sps.ConstraintSet1Flag<<6 | sps.ConstraintSet2Flag<<5 |
+		sps.ConstraintSet3Flag<<4 | sps.ConstraintSet4Flag<<3 | sps.ConstraintSet5Flag<<2 |
+		sps.ReservedZero2Bits
+}
+
+// Codecs returns the RFC 6381 codecs parameter, "avc1.PPCCLL", used in HLS
+// and DASH manifests.
+func (sps *SPS) Codecs() string {
+	return fmt.Sprintf("avc1.%02x%02x%02x", sps.ProfileIdc, sps.constraintFlags(), sps.LevelIdc)
+}
+
+func (sps *SPS) String() string {
+	s := fmt.Sprintf("%s %s@%s %dx%d", sps.Codecs(), sps.ProfileName(), sps.LevelName(), sps.Width(), sps.Height())
+	if w, h := sps.SampleAspectRatio(); w > 0 && h > 0 {
+		s += fmt.Sprintf(" SAR %d:%d", w, h)
+	}
+
+	if fps := sps.FrameRate(); fps > 0 {
+		s += fmt.Sprintf(" %.3gfps", fps)
+	}
+
+	return s
+}
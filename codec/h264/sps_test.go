@@ -0,0 +1,259 @@
+package h264
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeSPSBaseline(t *testing.T) {
+	w := &bittest.Writer{}
+	w.U(8, 0x67)
+	w.U(8, 66)
+	w.U(8, 0xc0)
+	w.U(8, 30)
+	w.UE(1) // sps_id
+	w.UE(0) // log2_max_frame_num_minus4
+	w.UE(1) // pic_order_cnt_type
+	w.U(1, 0)
+	w.SE(-2) // offset_for_non_ref_pic
+	w.SE(1)
+	w.UE(2)
+	w.SE(3)
+	w.SE(-4)
+	w.UE(1) // num_ref_frames
+	w.U(1, 0)
+	w.UE(39) // 640
+	w.UE(29) // 480
+	w.U(1, 1)
+	w.U(1, 1)
+	w.U(1, 0)
+	w.U(1, 1) // vui_parameters_present_flag
+	w.U(1, 1) // aspect_ratio_info_present_flag
+	w.U(8, 255)
+	w.U(16, 4)
+	w.U(16, 3)
+	w.U(1, 0)         // overscan
+	w.U(1, 1)         // video_signal_type_present_flag
+	w.U(3, 5)         // video_format
+	w.U(1, 1)         // video_full_range_flag
+	w.U(1, 1)         // colour_description_present_flag
+	w.U(24, 0x010101) // BT.709
+	w.U(1, 0)         // chroma_loc_info
+	w.U(1, 1)         // timing_info_present_flag
+	w.U(32, 1001)
+	w.U(32, 60000)
+	w.U(1, 1)
+	w.U(1, 1) // nal_hrd_parameters_present_flag
+	w.UE(1)   // cpb_cnt_minus1
+	w.U(4, 2)
+	w.U(4, 3)
+	for i := 0; i < 2; i++ {
+		w.UE(1000 + i)
+		w.UE(2000 + i)
+		w.U(1, i)
+	}
+	w.U(5, 23)
+	w.U(5, 22)
+	w.U(5, 21)
+	w.U(5, 24)
+	w.U(1, 0) // vcl_hrd_parameters_present_flag
+	w.U(1, 0) // low_delay_hrd_flag
+	w.U(1, 1) // pic_struct_present_flag
+	w.U(1, 1) // bitstream_restriction_flag
+	w.U(1, 1)
+	w.UE(2)
+	w.UE(1)
+	w.UE(16)
+	w.UE(15)
+	w.UE(0) // max_num_reorder_frames
+	w.UE(1)
+
+	sps, err := DecodeSPS(w.NALU())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sps.SPSId)
+	assert.Equal(t, -2, sps.OffsetForNonRefPic)
+	assert.Equal(t, []int{3, -4}, sps.OffsetForRefFrame)
+	assert.Equal(t, 39, sps.PicWidthInMbsMinus1)
+	assert.Equal(t, 4, sps.SarWidth)
+	assert.Equal(t, 3, sps.SarHeight)
+	assert.Equal(t, 1, sps.VideoFullRangeFlag)
+	assert.Equal(t, 1, sps.MatrixCoefficients)
+	assert.Equal(t, 29.97, float64(int(sps.FrameRate()*100))/100)
+	assert.Nil(t, sps.VclHrd)
+	assert.Equal(t, &HRD{
+		CpbCntMinus1:                       1,
+		BitRateScale:                       2,
+		CpbSizeScale:                       3,
+		BitRateValueMinus1:                 []int{1000, 1001},
+		CpbSizeValueMinus1:                 []int{2000, 2001},
+		CbrFlag:                            []int{0, 1},
+		InitialCpbRemovalDelayLengthMinus1: 23,
+		CpbRemovalDelayLengthMinus1:        22,
+		DpbOutputDelayLengthMinus1:         21,
+		TimeOffsetLength:                   24,
+	}, sps.NalHrd)
+	assert.Equal(t, 1, sps.PicStructPresentFlag)
+	assert.Equal(t, 16, sps.Log2MaxMvLengthHorizontal)
+	assert.Equal(t, 0, sps.MaxNumReorderFrames)
+	assert.Equal(t, 1, sps.MaxDecFrameBuffering)
+	assert.Equal(t, 16, sps.ScalingMatrix8[1][63])
+
+	// truncated in the VUI
+	_, err = DecodeSPS(w.Bytes()[:len(w.Bytes())-6])
+	assert.Equal(t, ErrInvalidSPS, err)
+}
+
+func TestDecodeSPSScalingMatrix(t *testing.T) {
+	w := &bittest.Writer{}
+	w.U(8, 0x67)
+	w.U(8, 100)
+	w.U(8, 0)
+	w.U(8, 40)
+	w.UE(0)
+	w.UE(1) // chroma_format_idc
+	w.UE(0)
+	w.UE(0)
+	w.U(1, 0)
+	w.U(1, 1) // seq_scaling_matrix_present_flag
+
+	w.U(1, 1) // list 0: 8, 9, 10, then repeat
+	w.SE(0)
+	w.SE(1)
+	w.SE(1)
+	w.SE(-10)
+	w.U(1, 0) // list 1 falls back to list 0
+	w.U(1, 1) // list 2 uses the default
+	w.SE(-8)
+	w.U(1, 0) // list 3 is Default_4x4_Inter
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0) // list 6 is Default_8x8_Intra
+	w.U(1, 0)
+
+	w.UE(0)
+	w.UE(0) // pic_order_cnt_type
+	w.UE(2)
+	w.UE(4)
+	w.U(1, 0)
+	w.UE(119)
+	w.UE(33)
+	w.U(1, 0) // frame_mbs_only_flag
+	w.U(1, 1)
+	w.U(1, 1)
+	w.U(1, 1) // frame_cropping_flag
+	w.UE(0)
+	w.UE(0)
+	w.UE(0)
+	w.UE(2)
+	w.U(1, 0)
+
+	sps, err := DecodeSPS(w.NALU())
+	assert.Nil(t, err)
+	assert.Equal(t, [12]int{1, 0, 1, 0, 0, 0, 0, 0}, sps.SeqScalingListPresentFlag)
+	assert.Equal(t, [16]int{8, 9, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, sps.ScalingMatrix4[0])
+	assert.Equal(t, sps.ScalingMatrix4[0], sps.ScalingMatrix4[1])
+	assert.Equal(t, defaultScaling4[0], sps.ScalingMatrix4[2])
+	assert.Equal(t, defaultScaling4[1], sps.ScalingMatrix4[5])
+	assert.Equal(t, defaultScaling8[0], sps.ScalingMatrix8[0])
+	assert.Equal(t, defaultScaling8[1], sps.ScalingMatrix8[1])
+	assert.Equal(t, 2, sps.Log2MaxPicOrderCntLsbMinus4)
+	assert.Equal(t, 0, sps.FrameMbsOnlyFlag)
+	assert.Equal(t, 1, sps.MbAdaptiveFrameFieldFlag)
+	assert.Equal(t, 2, sps.FrameCropButtomOffset)
+	assert.Equal(t, 0, sps.VuiParametersPresentFlag)
+}
+
+func TestSPSProperties(t *testing.T) {
+	w := &bittest.Writer{}
+	w.U(8, 0x67)
+	w.U(8, 66)
+	w.U(8, 0xc0)
+	w.U(8, 30)
+	w.UE(0)
+	w.UE(0)
+	w.UE(2) // pic_order_cnt_type
+	w.UE(1)
+	w.U(1, 0)
+	w.UE(39)
+	w.UE(29)
+	w.U(1, 1)
+	w.U(1, 1)
+	w.U(1, 1) // frame_cropping_flag
+	w.UE(1)
+	w.UE(3)
+	w.UE(0)
+	w.UE(4)
+	w.U(1, 1)
+	w.U(1, 1) // aspect_ratio_info_present_flag
+	w.U(8, 14)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 1) // timing_info_present_flag
+	w.U(32, 1)
+	w.U(32, 50)
+	w.U(1, 1)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0)
+
+	sps, err := DecodeSPS(w.NALU())
+	assert.Nil(t, err)
+	assert.Equal(t, 632, sps.Width())
+	assert.Equal(t, 472, sps.Height())
+	sw, sh := sps.SampleAspectRatio()
+	assert.Equal(t, []int{4, 3}, []int{sw, sh})
+	assert.Equal(t, 25.0, sps.FrameRate())
+	assert.Equal(t, "Constrained Baseline", sps.ProfileName())
+	assert.Equal(t, "3", sps.LevelName())
+	assert.Equal(t, "avc1.42c01e", sps.Codecs())
+	assert.Equal(t, "avc1.42c01e Constrained Baseline@3 632x472 SAR 4:3 25fps", sps.String())
+
+	sps.LevelIdc = 11
+	sps.ConstraintSet3Flag = 1
+	assert.Equal(t, "1b", sps.LevelName())
+	sps.LevelIdc = 31
+	assert.Equal(t, "3.1", sps.LevelName())
+
+	// interlaced 4:2:0 crops in units of 4 lines
+	sps = &SPS{ProfileIdc: 100, ChromaFormatIdc: 1, LevelIdc: 40,
+		PicWidthInMbsMinus1: 119, PicHeightInMapUnitsMinus1: 33, FrameCropButtomOffset: 2}
+	assert.Equal(t, 1920, sps.Width())
+	assert.Equal(t, 1080, sps.Height())
+	assert.Equal(t, "High", sps.ProfileName())
+	assert.Equal(t, "avc1.640028", sps.Codecs())
+	sw, sh = sps.SampleAspectRatio()
+	assert.Equal(t, []int{0, 0}, []int{sw, sh})
+
+	sps.ConstraintSet4Flag = 1
+	assert.Equal(t, "Progressive High", sps.ProfileName())
+	sps.ProfileIdc, sps.ConstraintSet3Flag = 110, 1
+	assert.Equal(t, "High 10 Intra", sps.ProfileName())
+}
+
+func TestSPSReorderFrames(t *testing.T) {
+	// 1080p at level 4: 32768 / (120*68)
+	sps := &SPS{ProfileIdc: 100, LevelIdc: 40, PicWidthInMbsMinus1: 119, PicHeightInMapUnitsMinus1: 67, FrameMbsOnlyFlag: 1}
+	assert.Equal(t, 4, sps.ReorderFrames())
+
+	// interlaced, the map units are field pairs
+	sps.PicHeightInMapUnitsMinus1, sps.FrameMbsOnlyFlag = 33, 0
+	assert.Equal(t, 4, sps.ReorderFrames())
+
+	// at most 16 frames
+	sps.PicWidthInMbsMinus1, sps.PicHeightInMapUnitsMinus1 = 19, 14
+	assert.Equal(t, 16, sps.ReorderFrames())
+
+	sps.ConstraintSet3Flag = 1
+	assert.Equal(t, 0, sps.ReorderFrames())
+
+	sps = &SPS{ProfileIdc: 66, LevelIdc: 11, ConstraintSet3Flag: 1, PicWidthInMbsMinus1: 10, PicHeightInMapUnitsMinus1: 8, FrameMbsOnlyFlag: 1}
+	assert.Equal(t, 4, sps.ReorderFrames())
+
+	sps.BitstreamRestrictionFlag, sps.MaxNumReorderFrames = 1, 2
+	assert.Equal(t, 2, sps.ReorderFrames())
+}
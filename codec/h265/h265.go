@@ -0,0 +1,364 @@
+package h265
+
+import (
+	"fmt"
+)
+
+// nal_unit_type, Table 7-1
+const (
+	NAL_TRAIL_N        = 0
+	NAL_TRAIL_R        = 1
+	NAL_TSA_N          = 2
+	NAL_TSA_R          = 3
+	NAL_STSA_N         = 4
+	NAL_STSA_R         = 5
+	NAL_RADL_N         = 6
+	NAL_RADL_R         = 7
+	NAL_RASL_N         = 8
+	NAL_RASL_R         = 9
+	NAL_RSV_VCL_N14    = 14
+	NAL_BLA_W_LP       = 16
+	NAL_BLA_W_RADL     = 17
+	NAL_BLA_N_LP       = 18
+	NAL_IDR_W_RADL     = 19
+	NAL_IDR_N_LP       = 20
+	NAL_CRA_NUT        = 21
+	NAL_RSV_IRAP_22    = 22
+	NAL_RSV_IRAP_23    = 23
+	NAL_VPS            = 32
+	NAL_SPS            = 33
+	NAL_PPS            = 34
+	NAL_AUD            = 35
+	NAL_EOS_NUT        = 36
+	NAL_EOB_NUT        = 37
+	NAL_FD_NUT         = 38
+	NAL_SEI_PREFIX     = 39
+	NAL_SEI_SUFFIX     = 40
+	NAL_UNSPEC_FIRST   = 48
+	NAL_UNSPEC_LAST    = 63
+	NAL_VCL_LAST       = 31
+	NAL_IRAP_VCL_FIRST = NAL_BLA_W_LP
+	NAL_IRAP_VCL_LAST  = NAL_RSV_IRAP_23
+)
+
+var nalTypeMap = map[int]string{
+	NAL_TRAIL_N:     "trail_n",
+	NAL_TRAIL_R:     "trail_r",
+	NAL_TSA_N:       "tsa_n",
+	NAL_TSA_R:       "tsa_r",
+	NAL_STSA_N:      "stsa_n",
+	NAL_STSA_R:      "stsa_r",
+	NAL_RADL_N:      "radl_n",
+	NAL_RADL_R:      "radl_r",
+	NAL_RASL_N:      "rasl_n",
+	NAL_RASL_R:      "rasl_r",
+	NAL_BLA_W_LP:    "bla_w_lp",
+	NAL_BLA_W_RADL:  "bla_w_radl",
+	NAL_BLA_N_LP:    "bla_n_lp",
+	NAL_IDR_W_RADL:  "idr_w_radl",
+	NAL_IDR_N_LP:    "idr_n_lp",
+	NAL_CRA_NUT:     "cra",
+	NAL_RSV_IRAP_22: "rsv_irap_22",
+	NAL_RSV_IRAP_23: "rsv_irap_23",
+	NAL_VPS:         "vps",
+	NAL_SPS:         "sps",
+	NAL_PPS:         "pps",
+	NAL_AUD:         "aud",
+	NAL_EOS_NUT:     "eos",
+	NAL_EOB_NUT:     "eob",
+	NAL_FD_NUT:      "filler_data",
+	NAL_SEI_PREFIX:  "sei_prefix",
+	NAL_SEI_SUFFIX:  "sei_suffix",
+}
+
+// IsIRAP tells whether nal_unit_type is an intra random access point:
+// BLA, IDR or CRA.
+func IsIRAP(typ int) bool { return typ >= NAL_IRAP_VCL_FIRST && typ <= NAL_IRAP_VCL_LAST }
+
+// IsIDR tells whether nal_unit_type is an IDR picture.
+func IsIDR(typ int) bool { return typ == NAL_IDR_W_RADL || typ == NAL_IDR_N_LP }
+
+// IsBLA tells whether nal_unit_type is a broken link access picture.
+func IsBLA(typ int) bool { return typ >= NAL_BLA_W_LP && typ <= NAL_BLA_N_LP }
+
+// IsCRA tells whether nal_unit_type is a clean random access picture.
+func IsCRA(typ int) bool { return typ == NAL_CRA_NUT }
+
+// IsVCL tells whether nal_unit_type carries a slice segment.
+func IsVCL(typ int) bool { return typ <= NAL_VCL_LAST }
+
+// Nalu is one NAL unit with its two byte header, 7.3.1.2. In an access unit
+// Offset is its position in the payload after the length field and Data
+// holds it with the header, still escaped; Rbsp is only filled in by the
+// decoders.
+type Nalu struct {
+	Len                int
+	Offset             int
+	Data               []byte
+	ForbiddenBit       int
+	NalUnitType        int
+	NuhLayerId         int
+	NuhTemporalIdPlus1 int
+	Rbsp               []byte
+	RbspSize           int
+}
+
+func (n *Nalu) String() string {
+	nt := nalTypeMap[n.NalUnitType]
+	if len(nt) == 0 {
+		nt = "unknown"
+	}
+	return fmt.Sprintf("|layer: %d|tid: %d|type: %s", n.NuhLayerId, n.NuhTemporalIdPlus1-1, nt)
+}
+
+// parseHeader reads the NAL unit header, false if data is shorter.
+func (n *Nalu) parseHeader(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+
+	h := int(data[0])<<8 | int(data[1])
+	n.ForbiddenBit = h >> 15
+	n.NalUnitType = (h >> 9) & 0x3f
+	n.NuhLayerId = (h >> 3) & 0x3f
+	n.NuhTemporalIdPlus1 = h & 0x07
+	return true
+}
+
+func decodeNalu(data []byte) *Nalu {
+	nalu := &Nalu{}
+	if !nalu.parseHeader(data) {
+		return nalu
+	}
+
+	// drop emulation_prevention_three_byte, zeros are counted in the
+	// escaped stream
+	nalu.Rbsp = make([]byte, 0, len(data)-2)
+	zeros := 0
+	for _, b := range data[2:] {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+
+		nalu.Rbsp = append(nalu.Rbsp, b)
+	}
+
+	nalu.RbspSize = len(nalu.Rbsp)
+	return nalu
+}
+
+// ProfileTierLevel is the profile_tier_level() of a VPS or SPS, 7.3.3, with
+// the general profile always present.
+type ProfileTierLevel struct {
+	GeneralProfileSpace              int    `json:"general_profile_space"`
+	GeneralTierFlag                  int    `json:"general_tier_flag"`
+	GeneralProfileIdc                int    `json:"general_profile_idc"`
+	GeneralProfileCompatibilityFlags uint32 `json:"general_profile_compatibility_flags"`
+	GeneralConstraintIndicatorFlags  uint64 `json:"general_constraint_indicator_flags"` // 48 bits
+	GeneralLevelIdc                  int    `json:"general_level_idc"`
+	SubLayerProfilePresentFlag       []int  `json:"sub_layer_profile_present_flag,omitempty"`
+	SubLayerLevelPresentFlag         []int  `json:"sub_layer_level_present_flag,omitempty"`
+	SubLayerLevelIdc                 []int  `json:"sub_layer_level_idc,omitempty"`
+}
+
+// ProgressiveSource returns general_progressive_source_flag.
+func (ptl *ProfileTierLevel) ProgressiveSource() int {
+	return int(ptl.GeneralConstraintIndicatorFlags>>47) & 0x01
+}
+
+// InterlacedSource returns general_interlaced_source_flag.
+func (ptl *ProfileTierLevel) InterlacedSource() int {
+	return int(ptl.GeneralConstraintIndicatorFlags>>46) & 0x01
+}
+
+type VPS struct {
+	VPSId                       int              `json:"vps_id"`
+	BaseLayerInternalFlag       int              `json:"vps_base_layer_internal_flag"`
+	BaseLayerAvailableFlag      int              `json:"vps_base_layer_available_flag"`
+	MaxLayersMinus1             int              `json:"vps_max_layers_minus1"`
+	MaxSubLayersMinus1          int              `json:"vps_max_sub_layers_minus1"`
+	TemporalIdNestingFlag       int              `json:"vps_temporal_id_nesting_flag"`
+	ProfileTierLevel            ProfileTierLevel `json:"profile_tier_level"`
+	SubLayerOrderingInfoPresent int              `json:"vps_sub_layer_ordering_info_present_flag"`
+	MaxDecPicBufferingMinus1    []int            `json:"vps_max_dec_pic_buffering_minus1"`
+	MaxNumReorderPics           []int            `json:"vps_max_num_reorder_pics"`
+	MaxLatencyIncreasePlus1     []int            `json:"vps_max_latency_increase_plus1"`
+	MaxLayerId                  int              `json:"vps_max_layer_id"`
+	NumLayerSetsMinus1          int              `json:"vps_num_layer_sets_minus1"`
+	TimingInfoPresentFlag       int              `json:"vps_timing_info_present_flag"`
+	NumUnitsInTick              int              `json:"vps_num_units_in_tick"`
+	TimeScale                   int              `json:"vps_time_scale"`
+	PocProportionalToTimingFlag int              `json:"vps_poc_proportional_to_timing_flag"`
+	NumTicksPocDiffOneMinus1    int              `json:"vps_num_ticks_poc_diff_one_minus1"`
+	NumHrdParameters            int              `json:"vps_num_hrd_parameters"`
+}
+
+type SPS struct {
+	VPSId                                int              `json:"sps_video_parameter_set_id"`
+	MaxSubLayersMinus1                   int              `json:"sps_max_sub_layers_minus1"`
+	TemporalIdNestingFlag                int              `json:"sps_temporal_id_nesting_flag"`
+	ProfileTierLevel                     ProfileTierLevel `json:"profile_tier_level"`
+	SPSId                                int              `json:"sps_seq_parameter_set_id"`
+	ChromaFormatIdc                      int              `json:"chroma_format_idc"`
+	SeparateColourPlaneFlag              int              `json:"separate_colour_plane_flag"`
+	PicWidthInLumaSamples                int              `json:"pic_width_in_luma_samples"`
+	PicHeightInLumaSamples               int              `json:"pic_height_in_luma_samples"`
+	ConformanceWindowFlag                int              `json:"conformance_window_flag"`
+	ConfWinLeftOffset                    int              `json:"conf_win_left_offset"`
+	ConfWinRightOffset                   int              `json:"conf_win_right_offset"`
+	ConfWinTopOffset                     int              `json:"conf_win_top_offset"`
+	ConfWinBottomOffset                  int              `json:"conf_win_bottom_offset"`
+	BitDepthLumaMinus8                   int              `json:"bit_depth_luma_minus8"`
+	BitDepthChromaMinus8                 int              `json:"bit_depth_chroma_minus8"`
+	Log2MaxPicOrderCntLsbMinus4          int              `json:"log2_max_pic_order_cnt_lsb_minus4"`
+	SubLayerOrderingInfoPresentFlag      int              `json:"sps_sub_layer_ordering_info_present_flag"`
+	MaxDecPicBufferingMinus1             []int            `json:"sps_max_dec_pic_buffering_minus1"`
+	MaxNumReorderPics                    []int            `json:"sps_max_num_reorder_pics"`
+	MaxLatencyIncreasePlus1              []int            `json:"sps_max_latency_increase_plus1"`
+	Log2MinLumaCodingBlockSizeMinus3     int              `json:"log2_min_luma_coding_block_size_minus3"`
+	Log2DiffMaxMinLumaCodingBlockSize    int              `json:"log2_diff_max_min_luma_coding_block_size"`
+	Log2MinLumaTransformBlockSizeMinus2  int              `json:"log2_min_luma_transform_block_size_minus2"`
+	Log2DiffMaxMinLumaTransformBlockSize int              `json:"log2_diff_max_min_luma_transform_block_size"`
+	MaxTransformHierarchyDepthInter      int              `json:"max_transform_hierarchy_depth_inter"`
+	MaxTransformHierarchyDepthIntra      int              `json:"max_transform_hierarchy_depth_intra"`
+	ScalingListEnabledFlag               int              `json:"scaling_list_enabled_flag"`
+	ScalingListDataPresentFlag           int              `json:"sps_scaling_list_data_present_flag"`
+	AmpEnabledFlag                       int              `json:"amp_enabled_flag"`
+	SampleAdaptiveOffsetEnabledFlag      int              `json:"sample_adaptive_offset_enabled_flag"`
+	PcmEnabledFlag                       int              `json:"pcm_enabled_flag"`
+	PcmSampleBitDepthLumaMinus1          int              `json:"pcm_sample_bit_depth_luma_minus1"`
+	PcmSampleBitDepthChromaMinus1        int              `json:"pcm_sample_bit_depth_chroma_minus1"`
+	Log2MinPcmLumaCodingBlockSizeMinus3  int              `json:"log2_min_pcm_luma_coding_block_size_minus3"`
+	Log2DiffMaxMinPcmLumaCodingBlockSize int              `json:"log2_diff_max_min_pcm_luma_coding_block_size"`
+	PcmLoopFilterDisabledFlag            int              `json:"pcm_loop_filter_disabled_flag"`
+	NumShortTermRefPicSets               int              `json:"num_short_term_ref_pic_sets"`
+	LongTermRefPicsPresentFlag           int              `json:"long_term_ref_pics_present_flag"`
+	NumLongTermRefPicsSps                int              `json:"num_long_term_ref_pics_sps"`
+	TemporalMvpEnabledFlag               int              `json:"sps_temporal_mvp_enabled_flag"`
+	StrongIntraSmoothingEnabledFlag      int              `json:"strong_intra_smoothing_enabled_flag"`
+	VuiParametersPresentFlag             int              `json:"vui_parameters_present_flag"`
+	AspectRatioInfoPresentFlag           int              `json:"aspect_ratio_info_present_flag"`
+	AspectRatioIdc                       int              `json:"aspect_ratio_idc"`
+	SarWidth                             int              `json:"sar_width"`
+	SarHeight                            int              `json:"sar_height"`
+	OverscanInfoPresentFlag              int              `json:"overscan_info_present_flag"`
+	OverscanAppropriateFlag              int              `json:"overscan_appropriate_flag"`
+	VideoSignalTypePresentFlag           int              `json:"video_signal_type_present_flag"`
+	VideoFormat                          int              `json:"video_format"`
+	VideoFullRangeFlag                   int              `json:"video_full_range_flag"`
+	ColourDescriptionPresentFlag         int              `json:"colour_description_present_flag"`
+	ColourPrimaries                      int              `json:"colour_primaries"`
+	TransferCharacteristics              int              `json:"transfer_characteristics"`
+	MatrixCoeffs                         int              `json:"matrix_coeffs"`
+	ChromaLocInfoPresentFlag             int              `json:"chroma_loc_info_present_flag"`
+	ChromaSampleLocTypeTopField          int              `json:"chroma_sample_loc_type_top_field"`
+	ChromaSampleLocTypeBottomField       int              `json:"chroma_sample_loc_type_bottom_field"`
+	NeutralChromaIndicationFlag          int              `json:"neutral_chroma_indication_flag"`
+	FieldSeqFlag                         int              `json:"field_seq_flag"`
+	FrameFieldInfoPresentFlag            int              `json:"frame_field_info_present_flag"`
+	DefaultDisplayWindowFlag             int              `json:"default_display_window_flag"`
+	DefDispWinLeftOffset                 int              `json:"def_disp_win_left_offset"`
+	DefDispWinRightOffset                int              `json:"def_disp_win_right_offset"`
+	DefDispWinTopOffset                  int              `json:"def_disp_win_top_offset"`
+	DefDispWinBottomOffset               int              `json:"def_disp_win_bottom_offset"`
+	TimingInfoPresentFlag                int              `json:"vui_timing_info_present_flag"`
+	NumUnitsInTick                       int              `json:"vui_num_units_in_tick"`
+	TimeScale                            int              `json:"vui_time_scale"`
+	PocProportionalToTimingFlag          int              `json:"vui_poc_proportional_to_timing_flag"`
+	NumTicksPocDiffOneMinus1             int              `json:"vui_num_ticks_poc_diff_one_minus1"`
+	HrdParametersPresentFlag             int              `json:"vui_hrd_parameters_present_flag"`
+	BitstreamRestrictionFlag             int              `json:"bitstream_restriction_flag"`
+	TilesFixedStructureFlag              int              `json:"tiles_fixed_structure_flag"`
+	MotionVectorsOverPicBoundaries       int              `json:"motion_vectors_over_pic_boundaries_flag"`
+	RestrictedRefPicListsFlag            int              `json:"restricted_ref_pic_lists_flag"`
+	MinSpatialSegmentationIdc            int              `json:"min_spatial_segmentation_idc"`
+	MaxBytesPerPicDenom                  int              `json:"max_bytes_per_pic_denom"`
+	MaxBitsPerMinCuDenom                 int              `json:"max_bits_per_min_cu_denom"`
+	Log2MaxMvLengthHorizontal            int              `json:"log2_max_mv_length_horizontal"`
+	Log2MaxMvLengthVertical              int              `json:"log2_max_mv_length_vertical"`
+
+	numDeltaPocs []int // NumDeltaPocs of each st_ref_pic_set
+}
+
+type PPS struct {
+	PPSId                                  int   `json:"pps_pic_parameter_set_id"`
+	SPSId                                  int   `json:"pps_seq_parameter_set_id"`
+	DependentSliceSegmentsEnabledFlag      int   `json:"dependent_slice_segments_enabled_flag"`
+	OutputFlagPresentFlag                  int   `json:"output_flag_present_flag"`
+	NumExtraSliceHeaderBits                int   `json:"num_extra_slice_header_bits"`
+	SignDataHidingEnabledFlag              int   `json:"sign_data_hiding_enabled_flag"`
+	CabacInitPresentFlag                   int   `json:"cabac_init_present_flag"`
+	NumRefIdxL0DefaultActiveMinus1         int   `json:"num_ref_idx_l0_default_active_minus1"`
+	NumRefIdxL1DefaultActiveMinus1         int   `json:"num_ref_idx_l1_default_active_minus1"`
+	InitQpMinus26                          int   `json:"init_qp_minus26"`
+	ConstrainedIntraPredFlag               int   `json:"constrained_intra_pred_flag"`
+	TransformSkipEnabledFlag               int   `json:"transform_skip_enabled_flag"`
+	CuQpDeltaEnabledFlag                   int   `json:"cu_qp_delta_enabled_flag"`
+	DiffCuQpDeltaDepth                     int   `json:"diff_cu_qp_delta_depth"`
+	CbQpOffset                             int   `json:"pps_cb_qp_offset"`
+	CrQpOffset                             int   `json:"pps_cr_qp_offset"`
+	SliceChromaQpOffsetsPresentFlag        int   `json:"pps_slice_chroma_qp_offsets_present_flag"`
+	WeightedPredFlag                       int   `json:"weighted_pred_flag"`
+	WeightedBipredFlag                     int   `json:"weighted_bipred_flag"`
+	TransquantBypassEnabledFlag            int   `json:"transquant_bypass_enabled_flag"`
+	TilesEnabledFlag                       int   `json:"tiles_enabled_flag"`
+	EntropyCodingSyncEnabledFlag           int   `json:"entropy_coding_sync_enabled_flag"`
+	NumTileColumnsMinus1                   int   `json:"num_tile_columns_minus1"`
+	NumTileRowsMinus1                      int   `json:"num_tile_rows_minus1"`
+	UniformSpacingFlag                     int   `json:"uniform_spacing_flag"`
+	ColumnWidthMinus1                      []int `json:"column_width_minus1,omitempty"`
+	RowHeightMinus1                        []int `json:"row_height_minus1,omitempty"`
+	LoopFilterAcrossTilesEnabledFlag       int   `json:"loop_filter_across_tiles_enabled_flag"`
+	LoopFilterAcrossSlicesEnabledFlag      int   `json:"pps_loop_filter_across_slices_enabled_flag"`
+	DeblockingFilterControlPresentFlag     int   `json:"deblocking_filter_control_present_flag"`
+	DeblockingFilterOverrideEnabledFlag    int   `json:"deblocking_filter_override_enabled_flag"`
+	DeblockingFilterDisabledFlag           int   `json:"pps_deblocking_filter_disabled_flag"`
+	BetaOffsetDiv2                         int   `json:"pps_beta_offset_div2"`
+	TcOffsetDiv2                           int   `json:"pps_tc_offset_div2"`
+	ScalingListDataPresentFlag             int   `json:"pps_scaling_list_data_present_flag"`
+	ListsModificationPresentFlag           int   `json:"lists_modification_present_flag"`
+	Log2ParallelMergeLevelMinus2           int   `json:"log2_parallel_merge_level_minus2"`
+	SliceSegmentHeaderExtensionPresentFlag int   `json:"slice_segment_header_extension_present_flag"`
+	ExtensionPresentFlag                   int   `json:"pps_extension_present_flag"`
+}
+
+type VideoFrameInfo struct {
+	CodecType string
+	Type      string
+	NaluSize  int
+	Config    *HEVCConfig // seq header only, with the decoded parameter sets
+	VPS       []*VPS
+	SPS       []*SPS
+	PPS       []*PPS
+	NaluInfo  *Nalu        // first NALU
+	Nalus     []*Nalu      // the access unit
+	Slice     *SliceHeader // first slice segment of the picture
+}
+
+func (f *VideoFrameInfo) nalus() string {
+	s := ""
+	for _, n := range f.Nalus {
+		s += n.String()
+	}
+
+	return s
+}
+
+func (f *VideoFrameInfo) String() string {
+	if f.NaluInfo == nil {
+		return fmt.Sprintf("code: %s\ttype: %s", f.CodecType, f.Type)
+	}
+
+	if f.Slice == nil {
+		return fmt.Sprintf("code: %s\ttype: %s\tnalu: %s", f.CodecType, f.Type, f.nalus())
+	}
+
+	return fmt.Sprintf("code: %s\ttype: %s\tpoc:%d\tnalu: %s", f.CodecType, f.Type, f.Slice.PicOrderCnt, f.nalus())
+}
@@ -0,0 +1,205 @@
+package h265
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrInvalidConfig = errors.New("h265: invalid HEVCDecoderConfigurationRecord")
+	ErrInvalidNalu   = errors.New("h265: NALU length exceeds the frame")
+	ErrNoConfig      = errors.New("h265: frame before the decoder configuration")
+)
+
+const hvccHeaderSize = 23
+
+// NaluArray is one array of the record: the parameter sets or SEI of one
+// NAL unit type.
+type NaluArray struct {
+	Completeness int // array_completeness, 1 if no other units of the type are in the stream
+	NalUnitType  int
+	Nalus        [][]byte
+}
+
+// HEVCConfig is the HEVCDecoderConfigurationRecord of ISO/IEC 14496-15
+// 8.3.3.1, the HEVC sequence header of FLV and the payload of the MP4 hvcC
+// box.
+type HEVCConfig struct {
+	ConfigurationVersion             int
+	GeneralProfileSpace              int
+	GeneralTierFlag                  int
+	GeneralProfileIdc                int
+	GeneralProfileCompatibilityFlags uint32
+	GeneralConstraintIndicatorFlags  uint64 // 48 bits
+	GeneralLevelIdc                  int
+	MinSpatialSegmentationIdc        int
+	ParallelismType                  int
+	ChromaFormat                     int
+	BitDepthLumaMinus8               int
+	BitDepthChromaMinus8             int
+	AvgFrameRate                     int // frames per 256 seconds, 0 if unspecified
+	ConstantFrameRate                int
+	NumTemporalLayers                int
+	TemporalIdNested                 int
+	NaluSize                         int // lengthSizeMinusOne + 1
+	Arrays                           []*NaluArray
+}
+
+// NewHEVCConfig builds a record with 4 byte NALU lengths. The profile, tier,
+// level and picture format come from the first SPS.
+func NewHEVCConfig(vps, sps, pps [][]byte) (*HEVCConfig, error) {
+	if len(sps) == 0 {
+		return nil, ErrInvalidConfig
+	}
+
+	s, err := DecodeSPS(sps[0])
+	if err != nil {
+		return nil, err
+	}
+
+	ptl := &s.ProfileTierLevel
+	c := &HEVCConfig{
+		ConfigurationVersion:             1,
+		GeneralProfileSpace:              ptl.GeneralProfileSpace,
+		GeneralTierFlag:                  ptl.GeneralTierFlag,
+		GeneralProfileIdc:                ptl.GeneralProfileIdc,
+		GeneralProfileCompatibilityFlags: ptl.GeneralProfileCompatibilityFlags,
+		GeneralConstraintIndicatorFlags:  ptl.GeneralConstraintIndicatorFlags,
+		GeneralLevelIdc:                  ptl.GeneralLevelIdc,
+		MinSpatialSegmentationIdc:        s.MinSpatialSegmentationIdc,
+		ChromaFormat:                     s.ChromaFormatIdc,
+		BitDepthLumaMinus8:               s.BitDepthLumaMinus8,
+		BitDepthChromaMinus8:             s.BitDepthChromaMinus8,
+		NumTemporalLayers:                s.MaxSubLayersMinus1 + 1,
+		TemporalIdNested:                 s.TemporalIdNestingFlag,
+		NaluSize:                         4,
+	}
+
+	for _, a := range []struct {
+		typ  int
+		sets [][]byte
+	}{{NAL_VPS, vps}, {NAL_SPS, sps}, {NAL_PPS, pps}} {
+		if len(a.sets) > 0 {
+			c.Arrays = append(c.Arrays, &NaluArray{Completeness: 1, NalUnitType: a.typ, Nalus: a.sets})
+		}
+	}
+
+	return c, nil
+}
+
+// Nalus returns the units of the arrays of the given type.
+func (c *HEVCConfig) Nalus(typ int) [][]byte {
+	var nalus [][]byte
+	for _, a := range c.Arrays {
+		if a.NalUnitType == typ {
+			nalus = append(nalus, a.Nalus...)
+		}
+	}
+
+	return nalus
+}
+
+func (c *HEVCConfig) VPS() [][]byte { return c.Nalus(NAL_VPS) }
+func (c *HEVCConfig) SPS() [][]byte { return c.Nalus(NAL_SPS) }
+func (c *HEVCConfig) PPS() [][]byte { return c.Nalus(NAL_PPS) }
+
+// ParseHEVCConfig parses a record, the NAL units share data.
+func ParseHEVCConfig(data []byte) (*HEVCConfig, error) {
+	if len(data) < hvccHeaderSize {
+		return nil, ErrInvalidConfig
+	}
+
+	c := &HEVCConfig{}
+	c.ConfigurationVersion = int(data[0])
+	c.GeneralProfileSpace = int(data[1] >> 6)
+	c.GeneralTierFlag = int(data[1]>>5) & 0x01
+	c.GeneralProfileIdc = int(data[1] & 0x1f)
+	c.GeneralProfileCompatibilityFlags = binary.BigEndian.Uint32(data[2:])
+	c.GeneralConstraintIndicatorFlags = binary.BigEndian.Uint64(data[4:]) & (1<<48 - 1)
+	c.GeneralLevelIdc = int(data[12])
+	c.MinSpatialSegmentationIdc = int(binary.BigEndian.Uint16(data[13:]) & 0x0fff)
+	c.ParallelismType = int(data[15] & 0x03)
+	c.ChromaFormat = int(data[16] & 0x03)
+	c.BitDepthLumaMinus8 = int(data[17] & 0x07)
+	c.BitDepthChromaMinus8 = int(data[18] & 0x07)
+	c.AvgFrameRate = int(binary.BigEndian.Uint16(data[19:]))
+	c.ConstantFrameRate = int(data[21] >> 6)
+	c.NumTemporalLayers = int(data[21]>>3) & 0x07
+	c.TemporalIdNested = int(data[21]>>2) & 0x01
+	c.NaluSize = int(data[21]&0x03) + 1
+
+	count := int(data[22])
+	pos := hvccHeaderSize
+	for i := 0; i < count; i++ {
+		if pos+3 > len(data) {
+			return nil, ErrInvalidConfig
+		}
+
+		a := &NaluArray{Completeness: int(data[pos] >> 7), NalUnitType: int(data[pos] & 0x3f)}
+		n := int(binary.BigEndian.Uint16(data[pos+1:]))
+		pos += 3
+
+		for j := 0; j < n; j++ {
+			if pos+2 > len(data) {
+				return nil, ErrInvalidConfig
+			}
+
+			size := int(binary.BigEndian.Uint16(data[pos:]))
+			pos += 2
+			if pos+size > len(data) {
+				return nil, ErrInvalidConfig
+			}
+
+			a.Nalus = append(a.Nalus, data[pos:pos+size])
+			pos += size
+		}
+
+		c.Arrays = append(c.Arrays, a)
+	}
+
+	return c, nil
+}
+
+// Bytes serializes the record.
+func (c *HEVCConfig) Bytes() ([]byte, error) {
+	if c.NaluSize < 1 || c.NaluSize > 4 || c.NaluSize == 3 || len(c.Arrays) > 255 {
+		return nil, ErrInvalidConfig
+	}
+
+	data := make([]byte, hvccHeaderSize)
+	data[0] = byte(c.ConfigurationVersion)
+	data[1] = byte(c.GeneralProfileSpace<<6 | c.GeneralTierFlag<<5 | c.GeneralProfileIdc&0x1f)
+	binary.BigEndian.PutUint32(data[2:], c.GeneralProfileCompatibilityFlags)
+
+	var flags [8]byte
+	binary.BigEndian.PutUint64(flags[:], c.GeneralConstraintIndicatorFlags)
+	copy(data[6:12], flags[2:])
+
+	data[12] = byte(c.GeneralLevelIdc)
+	binary.BigEndian.PutUint16(data[13:], 0xf000|uint16(c.MinSpatialSegmentationIdc&0x0fff))
+	data[15] = 0xfc | byte(c.ParallelismType)
+	data[16] = 0xfc | byte(c.ChromaFormat)
+	data[17] = 0xf8 | byte(c.BitDepthLumaMinus8)
+	data[18] = 0xf8 | byte(c.BitDepthChromaMinus8)
+	binary.BigEndian.PutUint16(data[19:], uint16(c.AvgFrameRate))
+	data[21] = byte(c.ConstantFrameRate<<6 | c.NumTemporalLayers<<3 | c.TemporalIdNested<<2 | (c.NaluSize - 1))
+	data[22] = byte(len(c.Arrays))
+
+	for _, a := range c.Arrays {
+		if len(a.Nalus) > 0xffff {
+			return nil, ErrInvalidConfig
+		}
+
+		data = append(data, byte(a.Completeness<<7|a.NalUnitType&0x3f), byte(len(a.Nalus)>>8), byte(len(a.Nalus)))
+		for _, nalu := range a.Nalus {
+			if len(nalu) > 0xffff {
+				return nil, ErrInvalidConfig
+			}
+
+			data = append(data, byte(len(nalu)>>8), byte(len(nalu)))
+			data = append(data, nalu...)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,44 @@
+package h265
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHEVCConfig(t *testing.T) {
+	c, err := NewHEVCConfig([][]byte{x265VPS}, [][]byte{main10SPS}, [][]byte{x265PPS})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, c.GeneralProfileIdc)
+	assert.Equal(t, 123, c.GeneralLevelIdc)
+	assert.Equal(t, 1, c.ChromaFormat)
+	assert.Equal(t, 2, c.BitDepthLumaMinus8)
+	assert.Equal(t, 1, c.NumTemporalLayers)
+	assert.Len(t, c.Arrays, 3)
+
+	c.AvgFrameRate = 15360
+	c.ParallelismType = 2
+	data, err := c.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 0x02, 0x20, 0, 0, 0, 0x90, 0, 0, 0, 0, 0, 123, 0xf0, 0x00,
+		0xfe, 0xfd, 0xfa, 0xfa, 0x3c, 0x00, 0x0f, 3}, data[:23])
+
+	parsed, err := ParseHEVCConfig(data)
+	assert.Nil(t, err)
+	assert.Equal(t, c, parsed)
+	assert.Equal(t, [][]byte{x265VPS}, parsed.VPS())
+	assert.Equal(t, [][]byte{main10SPS}, parsed.SPS())
+	assert.Equal(t, [][]byte{x265PPS}, parsed.PPS())
+
+	_, err = ParseHEVCConfig(data[:len(data)-1])
+	assert.Equal(t, ErrInvalidConfig, err)
+	_, err = ParseHEVCConfig(data[:22])
+	assert.Equal(t, ErrInvalidConfig, err)
+
+	c.NaluSize = 3
+	_, err = c.Bytes()
+	assert.Equal(t, ErrInvalidConfig, err)
+
+	_, err = NewHEVCConfig(nil, nil, nil)
+	assert.Equal(t, ErrInvalidConfig, err)
+}
@@ -0,0 +1,205 @@
+package h265
+
+import (
+	"fmt"
+
+	"media-go/core"
+)
+
+// NaluError reports a NALU of an access unit that could not be split or
+// decoded, Offset is its position in the payload and Size its length or the
+// bytes left for a truncated length field.
+type NaluError struct {
+	Offset int
+	Size   int
+	Err    error
+}
+
+func (e *NaluError) Error() string {
+	return fmt.Sprintf("%v: NALU of %d bytes at offset %d", e.Err, e.Size, e.Offset)
+}
+
+func (e *NaluError) Unwrap() error { return e.Err }
+
+// Parser decodes the HEVC video of one stream. It keeps the active decoder
+// configuration and the parameter sets by id, so every stream needs its own
+// Parser; separate Parsers may be used from different goroutines.
+type Parser struct {
+	config *HEVCConfig
+	vps    map[int]*VPS
+	sps    map[int]*SPS
+	pps    map[int]*PPS
+	poc    pocState
+}
+
+func NewParser() *Parser {
+	return &Parser{
+		vps: make(map[int]*VPS),
+		sps: make(map[int]*SPS),
+		pps: make(map[int]*PPS),
+	}
+}
+
+// Config returns the active decoder configuration, nil before the first
+// sequence header.
+func (p *Parser) Config() *HEVCConfig { return p.config }
+
+// VPS returns the parameter set with the given id, nil if unknown.
+func (p *Parser) VPS(id int) *VPS { return p.vps[id] }
+
+// SPS returns the parameter set with the given id, nil if unknown.
+func (p *Parser) SPS(id int) *SPS { return p.sps[id] }
+
+// PPS returns the parameter set with the given id, nil if unknown.
+func (p *Parser) PPS(id int) *PPS { return p.pps[id] }
+
+// ParseConfig decodes an HEVCDecoderConfigurationRecord and the parameter
+// sets in it.
+func (p *Parser) ParseConfig(record []byte) (*VideoFrameInfo, error) {
+	config, err := ParseHEVCConfig(record)
+	if err != nil {
+		return nil, err
+	}
+
+	vf := &VideoFrameInfo{CodecType: "seq", Type: "seq header", Config: config, NaluSize: config.NaluSize}
+	for _, a := range config.Arrays {
+		for _, data := range a.Nalus {
+			nalu := decodeNalu(data)
+			if nalu.NalUnitType != a.NalUnitType {
+				return nil, ErrInvalidConfig
+			}
+
+			nalu.Data, nalu.Len = data, len(data)
+			if err := p.parseUnit(vf, nalu); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	p.config = config
+	return vf, nil
+}
+
+// ParseNalus decodes the length-prefixed NALUs of one access unit.
+func (p *Parser) ParseNalus(data []byte) (*VideoFrameInfo, error) {
+	vf := &VideoFrameInfo{CodecType: "nalu"}
+	if p.config == nil {
+		return nil, ErrNoConfig
+	}
+
+	size := p.config.NaluSize
+	vf.NaluSize = size
+
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < size {
+			return nil, &NaluError{Offset: pos, Size: len(data) - pos, Err: ErrInvalidNalu}
+		}
+
+		length := 0
+		for _, b := range data[pos : pos+size] {
+			length = length<<8 | int(b)
+		}
+
+		start := pos + size
+		if length < 2 || length > len(data)-start {
+			return nil, &NaluError{Offset: pos, Size: length, Err: ErrInvalidNalu}
+		}
+
+		nalu := &Nalu{Len: length, Offset: start, Data: data[start : start+length]}
+		nalu.parseHeader(nalu.Data)
+		vf.Nalus = append(vf.Nalus, nalu)
+		pos = start + length
+
+		if err := p.parseUnit(vf, nalu); err != nil {
+			return nil, &NaluError{Offset: start, Size: length, Err: err}
+		}
+	}
+
+	if len(vf.Nalus) > 0 {
+		vf.NaluInfo = vf.Nalus[0]
+	}
+
+	return vf, nil
+}
+
+// parseUnit keeps the parameter sets and decodes the first slice segment of
+// the picture, which gives its type and order.
+func (p *Parser) parseUnit(vf *VideoFrameInfo, nalu *Nalu) error {
+	switch {
+	case nalu.NalUnitType == NAL_VPS:
+		vps, err := DecodeVPS(nalu.Data)
+		if err != nil {
+			return err
+		}
+		p.vps[vps.VPSId] = vps
+		vf.VPS = append(vf.VPS, vps)
+	case nalu.NalUnitType == NAL_SPS:
+		sps, err := DecodeSPS(nalu.Data)
+		if err != nil {
+			return err
+		}
+		p.sps[sps.SPSId] = sps
+		vf.SPS = append(vf.SPS, sps)
+	case nalu.NalUnitType == NAL_PPS:
+		pps, err := DecodePPS(nalu.Data)
+		if err != nil {
+			return err
+		}
+		p.pps[pps.PPSId] = pps
+		vf.PPS = append(vf.PPS, pps)
+	case nalu.NalUnitType == NAL_EOS_NUT:
+		p.poc.afterEOS = true
+	case IsVCL(nalu.NalUnitType):
+		if vf.Slice != nil {
+			break
+		}
+
+		sh, err := p.ParseSlice(nalu.Data)
+		if err != nil {
+			return err
+		}
+		vf.Slice = sh
+		vf.Type = sh.TypeName()
+	}
+
+	return nil
+}
+
+// ParseSlice decodes the start of the first slice segment of a picture, NAL
+// header included, with the parameter sets it refers to and derives its
+// picture order count. Pictures must be passed in decoding order.
+func (p *Parser) ParseSlice(nalu []byte) (*SliceHeader, error) {
+	prefix := nalu
+	if len(prefix) > 8 {
+		prefix = prefix[:8]
+	}
+
+	h := decodeNalu(prefix)
+	r := core.NewBitReader(h.Rbsp)
+	if r.U(1) == 0 { // first_slice_segment_in_pic_flag
+		return nil, ErrInvalidSlice
+	}
+
+	if IsIRAP(h.NalUnitType) {
+		r.U(1) // no_output_of_prior_pics_flag
+	}
+
+	id := r.UE()
+	if r.Err != nil {
+		return nil, ErrInvalidSlice
+	}
+
+	pps := p.pps[id]
+	if pps == nil || p.sps[pps.SPSId] == nil {
+		return nil, ErrInvalidSlice
+	}
+
+	sps := p.sps[pps.SPSId]
+	sh, err := DecodeSliceHeader(nalu, sps, pps)
+	if err != nil {
+		return nil, err
+	}
+
+	p.poc.compute(sh, sps)
+	return sh, nil
+}
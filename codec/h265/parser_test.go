@@ -0,0 +1,102 @@
+package h265
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hvccRecord(t *testing.T, naluSize int) []byte {
+	c, err := NewHEVCConfig([][]byte{x265VPS}, [][]byte{main10SPS}, [][]byte{x265PPS})
+	assert.Nil(t, err)
+	c.NaluSize = naluSize
+
+	record, err := c.Bytes()
+	assert.Nil(t, err)
+	return record
+}
+
+// accessUnit prefixes the NALUs with 4 byte lengths.
+func accessUnit(nalus ...[]byte) []byte {
+	var data []byte
+	for _, nalu := range nalus {
+		n := len(nalu)
+		data = append(data, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		data = append(data, nalu...)
+	}
+
+	return data
+}
+
+func TestParser(t *testing.T) {
+	p := NewParser()
+	idr := accessUnit(sliceNalu(NAL_IDR_W_RADL, SLICE_I, 0))
+	_, err := p.ParseNalus(idr)
+	assert.Equal(t, ErrNoConfig, err)
+
+	vf, err := p.ParseConfig(hvccRecord(t, 4))
+	assert.Nil(t, err)
+	assert.Equal(t, "seq", vf.CodecType)
+	assert.Len(t, vf.VPS, 1)
+	assert.Len(t, vf.SPS, 1)
+	assert.Len(t, vf.PPS, 1)
+	assert.Equal(t, 4, p.Config().NaluSize)
+	assert.Equal(t, 93, p.VPS(0).ProfileTierLevel.GeneralLevelIdc)
+	assert.Equal(t, 1920, p.SPS(0).Width())
+	assert.Equal(t, 0, p.PPS(0).SPSId)
+
+	vf, err = p.ParseNalus(idr)
+	assert.Nil(t, err)
+	assert.Equal(t, NAL_IDR_W_RADL, vf.NaluInfo.NalUnitType)
+	assert.Equal(t, "I", vf.Type)
+	assert.True(t, vf.Slice.IsIDR())
+	assert.Equal(t, 0, vf.Slice.PicOrderCnt)
+	assert.Equal(t, "code: nalu\ttype: I\tpoc:0\tnalu: |layer: 0|tid: 0|type: idr_w_radl", vf.String())
+
+	// in-band parameter sets and a suffix SEI around a trailing picture
+	vf, err = p.ParseNalus(accessUnit(x265PPS, sliceNalu(NAL_TRAIL_R, SLICE_P, 4), []byte{0x50, 0x01, 0x80}))
+	assert.Nil(t, err)
+	assert.Len(t, vf.Nalus, 3)
+	assert.Len(t, vf.PPS, 1)
+	assert.Equal(t, "P", vf.Type)
+	assert.Equal(t, 4, vf.Slice.PicOrderCnt)
+	assert.Equal(t, 15, vf.Nalus[1].Offset)
+
+	// end of sequence, the next CRA picture starts over
+	_, err = p.ParseNalus(accessUnit([]byte{0x48, 0x01}))
+	assert.Nil(t, err)
+	vf, err = p.ParseNalus(accessUnit(sliceNalu(NAL_CRA_NUT, SLICE_I, 200)))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, vf.Slice.PicOrderCnt)
+
+	_, err = p.ParseNalus([]byte{0, 0, 0, 9, 0x02, 0x01})
+	assert.True(t, errors.Is(err, ErrInvalidNalu))
+	var ne *NaluError
+	assert.True(t, errors.As(err, &ne))
+	assert.Equal(t, 9, ne.Size)
+
+	_, err = p.ParseNalus(accessUnit(sliceNalu(NAL_TRAIL_R, 5, 0)))
+	assert.True(t, errors.Is(err, ErrInvalidSlice))
+}
+
+func TestParserConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := NewParser()
+			_, err := p.ParseConfig(hvccRecord(t, 4))
+			assert.Nil(t, err)
+
+			for j := 0; j < 50; j++ {
+				vf, err := p.ParseNalus(accessUnit(sliceNalu(NAL_IDR_N_LP, SLICE_I, 0)))
+				assert.Nil(t, err)
+				assert.True(t, vf.Slice.IsIRAP())
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,96 @@
+package h265
+
+import (
+	"errors"
+
+	"media-go/core"
+)
+
+var ErrInvalidPPS = errors.New("h265: invalid PPS")
+
+// DecodePPS parses a PPS NALU, header included, 7.3.2.3.1. Extensions are
+// not read.
+func DecodePPS(data []byte) (*PPS, error) {
+	nalu := decodeNalu(data)
+	if nalu.NalUnitType != NAL_PPS {
+		return nil, ErrInvalidPPS
+	}
+
+	r := core.NewBitReader(nalu.Rbsp[:nalu.RbspSize])
+	pps := &PPS{}
+	pps.PPSId = r.UE()
+	pps.SPSId = r.UE()
+	if pps.PPSId > 63 || pps.SPSId > 15 {
+		return nil, ErrInvalidPPS
+	}
+
+	pps.DependentSliceSegmentsEnabledFlag = r.U(1)
+	pps.OutputFlagPresentFlag = r.U(1)
+	pps.NumExtraSliceHeaderBits = r.U(3)
+	pps.SignDataHidingEnabledFlag = r.U(1)
+	pps.CabacInitPresentFlag = r.U(1)
+	pps.NumRefIdxL0DefaultActiveMinus1 = r.UE()
+	pps.NumRefIdxL1DefaultActiveMinus1 = r.UE()
+	pps.InitQpMinus26 = r.SE()
+	pps.ConstrainedIntraPredFlag = r.U(1)
+	pps.TransformSkipEnabledFlag = r.U(1)
+	if pps.CuQpDeltaEnabledFlag = r.U(1); pps.CuQpDeltaEnabledFlag == 1 {
+		pps.DiffCuQpDeltaDepth = r.UE()
+	}
+
+	pps.CbQpOffset = r.SE()
+	pps.CrQpOffset = r.SE()
+	pps.SliceChromaQpOffsetsPresentFlag = r.U(1)
+	pps.WeightedPredFlag = r.U(1)
+	pps.WeightedBipredFlag = r.U(1)
+	pps.TransquantBypassEnabledFlag = r.U(1)
+	pps.TilesEnabledFlag = r.U(1)
+	pps.EntropyCodingSyncEnabledFlag = r.U(1)
+	if pps.NumRefIdxL0DefaultActiveMinus1 > 14 || pps.NumRefIdxL1DefaultActiveMinus1 > 14 {
+		return nil, ErrInvalidPPS
+	}
+
+	if pps.TilesEnabledFlag == 1 {
+		pps.NumTileColumnsMinus1 = r.UE()
+		pps.NumTileRowsMinus1 = r.UE()
+		if pps.NumTileColumnsMinus1 > 19 || pps.NumTileRowsMinus1 > 21 {
+			return nil, ErrInvalidPPS
+		}
+
+		if pps.UniformSpacingFlag = r.U(1); pps.UniformSpacingFlag == 0 {
+			for i := 0; i < pps.NumTileColumnsMinus1; i++ {
+				pps.ColumnWidthMinus1 = append(pps.ColumnWidthMinus1, r.UE())
+			}
+
+			for i := 0; i < pps.NumTileRowsMinus1; i++ {
+				pps.RowHeightMinus1 = append(pps.RowHeightMinus1, r.UE())
+			}
+		}
+
+		pps.LoopFilterAcrossTilesEnabledFlag = r.U(1)
+	}
+
+	pps.LoopFilterAcrossSlicesEnabledFlag = r.U(1)
+	if pps.DeblockingFilterControlPresentFlag = r.U(1); pps.DeblockingFilterControlPresentFlag == 1 {
+		pps.DeblockingFilterOverrideEnabledFlag = r.U(1)
+		if pps.DeblockingFilterDisabledFlag = r.U(1); pps.DeblockingFilterDisabledFlag == 0 {
+			pps.BetaOffsetDiv2 = r.SE()
+			pps.TcOffsetDiv2 = r.SE()
+		}
+	}
+
+	if pps.ScalingListDataPresentFlag = r.U(1); pps.ScalingListDataPresentFlag == 1 {
+		skipScalingListData(r)
+	}
+
+	pps.ListsModificationPresentFlag = r.U(1)
+	pps.Log2ParallelMergeLevelMinus2 = r.UE()
+	pps.SliceSegmentHeaderExtensionPresentFlag = r.U(1)
+	pps.ExtensionPresentFlag = r.U(1)
+
+	if r.Err != nil {
+		return nil, ErrInvalidPPS
+	}
+
+	return pps, nil
+}
@@ -0,0 +1,86 @@
+package h265
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePPS(t *testing.T) {
+	pps, err := DecodePPS(x265PPS)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, pps.PPSId)
+	assert.Equal(t, 0, pps.SPSId)
+	assert.Equal(t, 1, pps.SignDataHidingEnabledFlag)
+	assert.Equal(t, 1, pps.CuQpDeltaEnabledFlag)
+	assert.Equal(t, 1, pps.DiffCuQpDeltaDepth)
+	assert.Equal(t, 1, pps.WeightedPredFlag)
+	assert.Equal(t, 1, pps.EntropyCodingSyncEnabledFlag)
+	assert.Equal(t, 1, pps.LoopFilterAcrossSlicesEnabledFlag)
+	assert.Equal(t, 0, pps.ExtensionPresentFlag)
+
+	_, err = DecodePPS(x265PPS[:4])
+	assert.Equal(t, ErrInvalidPPS, err)
+	_, err = DecodePPS(x265VPS)
+	assert.Equal(t, ErrInvalidPPS, err)
+}
+
+func TestDecodePPSTiles(t *testing.T) {
+	w := &bittest.Writer{}
+	writeHeader(w, NAL_PPS)
+	w.UE(3)
+	w.UE(1)
+	w.U(1, 1) // dependent_slice_segments_enabled_flag
+	w.U(1, 1)
+	w.U(3, 2) // num_extra_slice_header_bits
+	w.U(1, 0)
+	w.U(1, 0)
+	w.UE(2)
+	w.UE(1)
+	w.SE(-4) // init_qp_minus26
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.SE(-1)
+	w.SE(2)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 1) // tiles_enabled_flag
+	w.U(1, 0)
+	w.UE(2)
+	w.UE(1)
+	w.U(1, 0) // uniform_spacing_flag
+	w.UE(9)
+	w.UE(4)
+	w.UE(7)
+	w.U(1, 1)
+	w.U(1, 0)
+	w.U(1, 1) // deblocking_filter_control_present_flag
+	w.U(1, 1)
+	w.U(1, 0)
+	w.SE(-2)
+	w.SE(3)
+	w.U(1, 0)
+	w.U(1, 1)
+	w.UE(2)
+	w.U(1, 0)
+	w.U(1, 0)
+
+	pps, err := DecodePPS(w.NALU())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, pps.PPSId)
+	assert.Equal(t, 1, pps.SPSId)
+	assert.Equal(t, 2, pps.NumExtraSliceHeaderBits)
+	assert.Equal(t, -4, pps.InitQpMinus26)
+	assert.Equal(t, 2, pps.CrQpOffset)
+	assert.Equal(t, []int{9, 4}, pps.ColumnWidthMinus1)
+	assert.Equal(t, []int{7}, pps.RowHeightMinus1)
+	assert.Equal(t, -2, pps.BetaOffsetDiv2)
+	assert.Equal(t, 3, pps.TcOffsetDiv2)
+	assert.Equal(t, 1, pps.ListsModificationPresentFlag)
+	assert.Equal(t, 2, pps.Log2ParallelMergeLevelMinus2)
+}
@@ -0,0 +1,167 @@
+package h265
+
+import (
+	"errors"
+
+	"media-go/core"
+)
+
+// slice_type, Table 7-7
+const (
+	SLICE_B = 0
+	SLICE_P = 1
+	SLICE_I = 2
+)
+
+var sliceTypeMap = map[int]string{
+	SLICE_B: "B",
+	SLICE_P: "P",
+	SLICE_I: "I",
+}
+
+var ErrInvalidSlice = errors.New("h265: invalid slice segment header")
+
+// SliceHeader is the start of a slice_segment_header(), 7.3.6.1, up to the
+// picture order count, enough to classify and order the picture.
+type SliceHeader struct {
+	NalUnitType                int
+	TemporalId                 int
+	FirstSliceSegmentInPicFlag int
+	NoOutputOfPriorPicsFlag    int
+	PPSId                      int
+	DependentSliceSegmentFlag  int
+	SliceSegmentAddress        int
+	SliceType                  int
+	PicOutputFlag              int
+	ColourPlaneId              int
+	SlicePicOrderCntLsb        int
+	ShortTermRefPicSetSpsFlag  int
+	ShortTermRefPicSetIdx      int
+
+	PicOrderCnt int // PicOrderCntVal, 8.3.1
+}
+
+// TypeName returns "I", "P" or "B".
+func (sh *SliceHeader) TypeName() string { return sliceTypeMap[sh.SliceType] }
+
+// IsIRAP tells whether the slice belongs to an intra random access point.
+func (sh *SliceHeader) IsIRAP() bool { return IsIRAP(sh.NalUnitType) }
+
+// IsIDR tells whether the slice belongs to an IDR picture.
+func (sh *SliceHeader) IsIDR() bool { return IsIDR(sh.NalUnitType) }
+
+// IsCRA tells whether the slice belongs to a CRA picture.
+func (sh *SliceHeader) IsCRA() bool { return IsCRA(sh.NalUnitType) }
+
+// ceilLog2 is Ceil(Log2(n)).
+func ceilLog2(n int) int {
+	v := 0
+	for 1<<uint(v) < n {
+		v++
+	}
+
+	return v
+}
+
+// DecodeSliceHeader parses the start of a slice segment header, NAL header
+// included, with the parameter sets it refers to.
+func DecodeSliceHeader(data []byte, sps *SPS, pps *PPS) (*SliceHeader, error) {
+	nalu := decodeNalu(data)
+	if len(data) < 2 || !IsVCL(nalu.NalUnitType) {
+		return nil, ErrInvalidSlice
+	}
+
+	r := core.NewBitReader(nalu.Rbsp[:nalu.RbspSize])
+	sh := &SliceHeader{NalUnitType: nalu.NalUnitType, TemporalId: nalu.NuhTemporalIdPlus1 - 1, PicOutputFlag: 1}
+	sh.FirstSliceSegmentInPicFlag = r.U(1)
+	if sh.IsIRAP() {
+		sh.NoOutputOfPriorPicsFlag = r.U(1)
+	}
+
+	sh.PPSId = r.UE()
+	if sh.PPSId != pps.PPSId {
+		return nil, ErrInvalidSlice
+	}
+
+	if sh.FirstSliceSegmentInPicFlag == 0 {
+		if pps.DependentSliceSegmentsEnabledFlag == 1 {
+			sh.DependentSliceSegmentFlag = r.U(1)
+		}
+		sh.SliceSegmentAddress = r.U(ceilLog2(sps.picSizeInCtbs()))
+	}
+
+	if sh.DependentSliceSegmentFlag == 1 {
+		// the rest is taken from the preceding independent segment
+		if r.Err != nil {
+			return nil, ErrInvalidSlice
+		}
+		return sh, nil
+	}
+
+	r.U(pps.NumExtraSliceHeaderBits) // slice_reserved_flag
+	sh.SliceType = r.UE()
+	if sh.SliceType > SLICE_I {
+		return nil, ErrInvalidSlice
+	}
+
+	if pps.OutputFlagPresentFlag == 1 {
+		sh.PicOutputFlag = r.U(1)
+	}
+
+	if sps.SeparateColourPlaneFlag == 1 {
+		sh.ColourPlaneId = r.U(2)
+	}
+
+	if !sh.IsIDR() {
+		sh.SlicePicOrderCntLsb = r.U(sps.Log2MaxPicOrderCntLsbMinus4 + 4)
+		if sh.ShortTermRefPicSetSpsFlag = r.U(1); sh.ShortTermRefPicSetSpsFlag == 0 {
+			readShortTermRefPicSet(r, sps.NumShortTermRefPicSets, sps.numDeltaPocs, true)
+		} else if sps.NumShortTermRefPicSets > 1 {
+			sh.ShortTermRefPicSetIdx = r.U(ceilLog2(sps.NumShortTermRefPicSets))
+		}
+	}
+
+	if r.Err != nil {
+		return nil, ErrInvalidSlice
+	}
+
+	return sh, nil
+}
+
+// pocState carries the values of the previous TemporalId 0 picture the
+// order count derivation of 8.3.1 depends on.
+type pocState struct {
+	started  bool
+	prevMsb  int
+	prevLsb  int
+	afterEOS bool
+}
+
+// compute sets the order count of sh and updates the state, pictures must
+// be passed in decoding order. A CRA picture starts a new coded video
+// sequence only first in the stream or after an end of sequence.
+func (s *pocState) compute(sh *SliceHeader, sps *SPS) {
+	maxLsb := 1 << uint(sps.Log2MaxPicOrderCntLsbMinus4+4)
+	lsb, msb := sh.SlicePicOrderCntLsb, 0
+
+	noRaslOutput := sh.IsIDR() || IsBLA(sh.NalUnitType) || (sh.IsCRA() && (!s.started || s.afterEOS))
+	switch {
+	case sh.IsIRAP() && noRaslOutput:
+	case lsb < s.prevLsb && s.prevLsb-lsb >= maxLsb/2:
+		msb = s.prevMsb + maxLsb
+	case lsb > s.prevLsb && lsb-s.prevLsb > maxLsb/2:
+		msb = s.prevMsb - maxLsb
+	default:
+		msb = s.prevMsb
+	}
+
+	sh.PicOrderCnt = msb + lsb
+	s.started, s.afterEOS = true, false
+
+	// RASL, RADL and sub-layer non-reference pictures are skipped
+	typ := sh.NalUnitType
+	sublayerNonRef := typ <= NAL_RSV_VCL_N14 && typ%2 == 0
+	if sh.TemporalId == 0 && !(typ >= NAL_RADL_N && typ <= NAL_RASL_R) && !sublayerNonRef {
+		s.prevMsb, s.prevLsb = msb, lsb
+	}
+}
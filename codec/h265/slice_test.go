@@ -0,0 +1,105 @@
+package h265
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceNalu writes the start of the first slice segment of a picture for
+// main10SPS and x265PPS, lsb is ignored for IDR pictures.
+func sliceNalu(typ, sliceType, lsb int) []byte {
+	w := &bittest.Writer{}
+	writeHeader(w, typ)
+	w.U(1, 1) // first_slice_segment_in_pic_flag
+	if IsIRAP(typ) {
+		w.U(1, 0)
+	}
+	w.UE(0) // slice_pic_parameter_set_id
+	w.UE(sliceType)
+	if !IsIDR(typ) {
+		w.U(8, lsb)
+		w.U(1, 1) // short_term_ref_pic_set_sps_flag
+		w.U(1, 1)
+	}
+
+	// the rest of the header, enough bits for the parser
+	w.U(16, 0xaaaa)
+	return w.NALU()
+}
+
+func TestDecodeSliceHeader(t *testing.T) {
+	sps, err := DecodeSPS(main10SPS)
+	assert.Nil(t, err)
+	pps, err := DecodePPS(x265PPS)
+	assert.Nil(t, err)
+
+	sh, err := DecodeSliceHeader(sliceNalu(NAL_IDR_W_RADL, SLICE_I, 0), sps, pps)
+	assert.Nil(t, err)
+	assert.True(t, sh.IsIDR())
+	assert.True(t, sh.IsIRAP())
+	assert.False(t, sh.IsCRA())
+	assert.Equal(t, "I", sh.TypeName())
+
+	sh, err = DecodeSliceHeader(sliceNalu(NAL_CRA_NUT, SLICE_I, 32), sps, pps)
+	assert.Nil(t, err)
+	assert.True(t, sh.IsCRA())
+	assert.True(t, sh.IsIRAP())
+	assert.Equal(t, 32, sh.SlicePicOrderCntLsb)
+	assert.Equal(t, 1, sh.ShortTermRefPicSetIdx)
+
+	// an explicit set predicted from the last SPS set
+	w := &bittest.Writer{}
+	writeHeader(w, NAL_TRAIL_R)
+	w.U(1, 0) // first_slice_segment_in_pic_flag
+	w.UE(0)
+	w.U(9, 17) // slice_segment_address of 510 CTBs
+	w.UE(SLICE_B)
+	w.U(8, 3)
+	w.U(1, 0)
+	w.U(1, 1) // inter_ref_pic_set_prediction_flag
+	w.UE(0)
+	w.U(1, 1)
+	w.UE(1)
+	w.U(3, 7) // used_by_curr_pic_flag
+	w.U(8, 0xff)
+	sh, err = DecodeSliceHeader(w.NALU(), sps, pps)
+	assert.Nil(t, err)
+	assert.False(t, sh.IsIRAP())
+	assert.Equal(t, 17, sh.SliceSegmentAddress)
+	assert.Equal(t, "B", sh.TypeName())
+	assert.Equal(t, 3, sh.SlicePicOrderCntLsb)
+
+	_, err = DecodeSliceHeader(x265PPS, sps, pps)
+	assert.Equal(t, ErrInvalidSlice, err)
+	_, err = DecodeSliceHeader(sliceNalu(NAL_TRAIL_R, 3, 0), sps, pps)
+	assert.Equal(t, ErrInvalidSlice, err)
+}
+
+func TestPicOrderCnt(t *testing.T) {
+	sps := &SPS{Log2MaxPicOrderCntLsbMinus4: 4}
+	s := &pocState{}
+	pocs := []int{}
+	for _, pic := range []struct{ typ, lsb, tid int }{
+		{NAL_CRA_NUT, 200, 0}, // first in the stream
+		{NAL_TRAIL_R, 8, 0},   // wraps
+		{NAL_TRAIL_R, 250, 1},
+		{NAL_TRAIL_N, 100, 0}, // sub-layer non-reference, not kept
+		{NAL_TRAIL_R, 130, 0},
+		{NAL_CRA_NUT, 20, 0}, // continues the sequence
+		{NAL_IDR_N_LP, 0, 0},
+	} {
+		sh := &SliceHeader{NalUnitType: pic.typ, SlicePicOrderCntLsb: pic.lsb, TemporalId: pic.tid}
+		s.compute(sh, sps)
+		pocs = append(pocs, sh.PicOrderCnt)
+	}
+	assert.Equal(t, []int{200, 264, 250, 356, 386, 276, 0}, pocs)
+
+	// a CRA picture after an end of sequence starts over
+	s.prevMsb, s.prevLsb, s.afterEOS = 256, 250, true
+	sh := &SliceHeader{NalUnitType: NAL_CRA_NUT, SlicePicOrderCntLsb: 10}
+	s.compute(sh, sps)
+	assert.Equal(t, 10, sh.PicOrderCnt)
+}
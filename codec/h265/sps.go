@@ -0,0 +1,513 @@
+package h265
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"media-go/core"
+)
+
+var ErrInvalidSPS = errors.New("h265: invalid SPS")
+
+// readProfileTierLevel reads a profile_tier_level(1, maxSubLayersMinus1),
+// 7.3.3. The profiles of the sub-layers are skipped.
+func readProfileTierLevel(r *core.BitReader, ptl *ProfileTierLevel, maxSubLayersMinus1 int) {
+	ptl.GeneralProfileSpace = r.U(2)
+	ptl.GeneralTierFlag = r.U(1)
+	ptl.GeneralProfileIdc = r.U(5)
+	ptl.GeneralProfileCompatibilityFlags = uint32(r.U(16))<<16 | uint32(r.U(16))
+	ptl.GeneralConstraintIndicatorFlags = uint64(r.U(24))<<24 | uint64(r.U(24))
+	ptl.GeneralLevelIdc = r.U(8)
+
+	if maxSubLayersMinus1 == 0 {
+		return
+	}
+
+	ptl.SubLayerProfilePresentFlag = make([]int, maxSubLayersMinus1)
+	ptl.SubLayerLevelPresentFlag = make([]int, maxSubLayersMinus1)
+	ptl.SubLayerLevelIdc = make([]int, maxSubLayersMinus1)
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		ptl.SubLayerProfilePresentFlag[i] = r.U(1)
+		ptl.SubLayerLevelPresentFlag[i] = r.U(1)
+	}
+
+	for i := maxSubLayersMinus1; i < 8; i++ {
+		r.U(2) // reserved_zero_2bits
+	}
+
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		if ptl.SubLayerProfilePresentFlag[i] == 1 {
+			r.U(24) // sub_layer_profile_space to sub_layer_profile_compatibility_flag
+			r.U(32)
+			r.U(32) // sub_layer_progressive_source_flag to sub_layer_inbld_flag
+		}
+
+		if ptl.SubLayerLevelPresentFlag[i] == 1 {
+			ptl.SubLayerLevelIdc[i] = r.U(8)
+		}
+	}
+}
+
+// readSubLayerOrdering reads the sub-layer DPB sizes of a VPS or SPS, only
+// the highest sub-layer is signalled when present is 0.
+func readSubLayerOrdering(r *core.BitReader, present, maxSubLayersMinus1 int) (buffering, reorder, latency []int) {
+	first := maxSubLayersMinus1
+	if present == 1 {
+		first = 0
+	}
+
+	for i := first; i <= maxSubLayersMinus1; i++ {
+		buffering = append(buffering, r.UE())
+		reorder = append(reorder, r.UE())
+		latency = append(latency, r.UE())
+	}
+
+	return buffering, reorder, latency
+}
+
+// skipScalingListData reads past a scaling_list_data(), 7.3.4.
+func skipScalingListData(r *core.BitReader) {
+	for sizeId := 0; sizeId < 4; sizeId++ {
+		step := 1
+		if sizeId == 3 {
+			step = 3
+		}
+
+		for matrixId := 0; matrixId < 6; matrixId += step {
+			if r.U(1) == 0 { // scaling_list_pred_mode_flag
+				r.UE() // scaling_list_pred_matrix_id_delta
+				continue
+			}
+
+			coefNum := 1 << uint(4+sizeId<<1)
+			if coefNum > 64 {
+				coefNum = 64
+			}
+
+			if sizeId > 1 {
+				r.SE() // scaling_list_dc_coef_minus8
+			}
+
+			for i := 0; i < coefNum && r.Err == nil; i++ {
+				r.SE() // scaling_list_delta_coef
+			}
+		}
+	}
+}
+
+// readShortTermRefPicSet reads st_ref_pic_set(idx), 7.3.7, and returns its
+// NumDeltaPocs. numDeltaPocs holds those of the SPS sets, idx equals their
+// count in a slice header.
+func readShortTermRefPicSet(r *core.BitReader, idx int, numDeltaPocs []int, inSlice bool) int {
+	if idx != 0 && r.U(1) == 1 { // inter_ref_pic_set_prediction_flag
+		deltaIdxMinus1 := 0
+		if inSlice {
+			deltaIdxMinus1 = r.UE()
+		}
+
+		ref := idx - (deltaIdxMinus1 + 1)
+		if ref < 0 || ref >= len(numDeltaPocs) {
+			r.Err = ErrInvalidSPS
+			return 0
+		}
+
+		r.U(1) // delta_rps_sign
+		r.UE() // abs_delta_rps_minus1
+
+		count := 0
+		for j := 0; j <= numDeltaPocs[ref]; j++ {
+			used, useDelta := r.U(1), 1
+			if used == 0 {
+				useDelta = r.U(1)
+			}
+
+			count += useDelta
+		}
+
+		return count
+	}
+
+	negative, positive := r.UE(), r.UE()
+	if negative > 16 || positive > 16 {
+		r.Err = ErrInvalidSPS
+		return 0
+	}
+
+	for i := 0; i < negative+positive; i++ {
+		r.UE() // delta_poc_s0_minus1 or delta_poc_s1_minus1
+		r.U(1) // used_by_curr_pic_s0_flag or used_by_curr_pic_s1_flag
+	}
+
+	return negative + positive
+}
+
+// DecodeSPS parses an SPS NALU, header included, 7.3.2.2.1, up to the VUI.
+// Range and other extensions are not read.
+func DecodeSPS(data []byte) (*SPS, error) {
+	nalu := decodeNalu(data)
+	if nalu.NalUnitType != NAL_SPS {
+		return nil, ErrInvalidSPS
+	}
+
+	// unspecified video format and colour without a VUI, Table E-2 to E-5
+	r := core.NewBitReader(nalu.Rbsp[:nalu.RbspSize])
+	sps := &SPS{VideoFormat: 5, ColourPrimaries: 2, TransferCharacteristics: 2, MatrixCoeffs: 2}
+	sps.VPSId = r.U(4)
+	sps.MaxSubLayersMinus1 = r.U(3)
+	sps.TemporalIdNestingFlag = r.U(1)
+	if sps.MaxSubLayersMinus1 > 6 {
+		return nil, ErrInvalidSPS
+	}
+
+	readProfileTierLevel(r, &sps.ProfileTierLevel, sps.MaxSubLayersMinus1)
+
+	sps.SPSId = r.UE()
+	sps.ChromaFormatIdc = r.UE()
+	if sps.SPSId > 15 || sps.ChromaFormatIdc > 3 {
+		return nil, ErrInvalidSPS
+	}
+
+	if sps.ChromaFormatIdc == 3 {
+		sps.SeparateColourPlaneFlag = r.U(1)
+	}
+
+	sps.PicWidthInLumaSamples = r.UE()
+	sps.PicHeightInLumaSamples = r.UE()
+	if sps.ConformanceWindowFlag = r.U(1); sps.ConformanceWindowFlag == 1 {
+		sps.ConfWinLeftOffset = r.UE()
+		sps.ConfWinRightOffset = r.UE()
+		sps.ConfWinTopOffset = r.UE()
+		sps.ConfWinBottomOffset = r.UE()
+	}
+
+	sps.BitDepthLumaMinus8 = r.UE()
+	sps.BitDepthChromaMinus8 = r.UE()
+	sps.Log2MaxPicOrderCntLsbMinus4 = r.UE()
+	if sps.BitDepthLumaMinus8 > 8 || sps.BitDepthChromaMinus8 > 8 || sps.Log2MaxPicOrderCntLsbMinus4 > 12 {
+		return nil, ErrInvalidSPS
+	}
+
+	sps.SubLayerOrderingInfoPresentFlag = r.U(1)
+	sps.MaxDecPicBufferingMinus1, sps.MaxNumReorderPics, sps.MaxLatencyIncreasePlus1 =
+		readSubLayerOrdering(r, sps.SubLayerOrderingInfoPresentFlag, sps.MaxSubLayersMinus1)
+
+	sps.Log2MinLumaCodingBlockSizeMinus3 = r.UE()
+	sps.Log2DiffMaxMinLumaCodingBlockSize = r.UE()
+	sps.Log2MinLumaTransformBlockSizeMinus2 = r.UE()
+	sps.Log2DiffMaxMinLumaTransformBlockSize = r.UE()
+	sps.MaxTransformHierarchyDepthInter = r.UE()
+	sps.MaxTransformHierarchyDepthIntra = r.UE()
+	if sps.Log2MinLumaCodingBlockSizeMinus3+sps.Log2DiffMaxMinLumaCodingBlockSize > 3 {
+		return nil, ErrInvalidSPS
+	}
+
+	if sps.ScalingListEnabledFlag = r.U(1); sps.ScalingListEnabledFlag == 1 {
+		if sps.ScalingListDataPresentFlag = r.U(1); sps.ScalingListDataPresentFlag == 1 {
+			skipScalingListData(r)
+		}
+	}
+
+	sps.AmpEnabledFlag = r.U(1)
+	sps.SampleAdaptiveOffsetEnabledFlag = r.U(1)
+	if sps.PcmEnabledFlag = r.U(1); sps.PcmEnabledFlag == 1 {
+		sps.PcmSampleBitDepthLumaMinus1 = r.U(4)
+		sps.PcmSampleBitDepthChromaMinus1 = r.U(4)
+		sps.Log2MinPcmLumaCodingBlockSizeMinus3 = r.UE()
+		sps.Log2DiffMaxMinPcmLumaCodingBlockSize = r.UE()
+		sps.PcmLoopFilterDisabledFlag = r.U(1)
+	}
+
+	sps.NumShortTermRefPicSets = r.UE()
+	if sps.NumShortTermRefPicSets > 64 {
+		return nil, ErrInvalidSPS
+	}
+
+	for i := 0; i < sps.NumShortTermRefPicSets && r.Err == nil; i++ {
+		sps.numDeltaPocs = append(sps.numDeltaPocs, readShortTermRefPicSet(r, i, sps.numDeltaPocs, false))
+	}
+
+	if sps.LongTermRefPicsPresentFlag = r.U(1); sps.LongTermRefPicsPresentFlag == 1 {
+		sps.NumLongTermRefPicsSps = r.UE()
+		if sps.NumLongTermRefPicsSps > 32 {
+			return nil, ErrInvalidSPS
+		}
+
+		for i := 0; i < sps.NumLongTermRefPicsSps; i++ {
+			r.U(sps.Log2MaxPicOrderCntLsbMinus4 + 4) // lt_ref_pic_poc_lsb_sps
+			r.U(1)                                   // used_by_curr_pic_lt_sps_flag
+		}
+	}
+
+	sps.TemporalMvpEnabledFlag = r.U(1)
+	sps.StrongIntraSmoothingEnabledFlag = r.U(1)
+	if sps.VuiParametersPresentFlag = r.U(1); sps.VuiParametersPresentFlag == 1 {
+		readVui(r, sps)
+	}
+
+	if r.Err != nil {
+		return nil, ErrInvalidSPS
+	}
+
+	return sps, nil
+}
+
+// readVui reads the vui_parameters(), E.2.1.
+func readVui(r *core.BitReader, sps *SPS) {
+	if sps.AspectRatioInfoPresentFlag = r.U(1); sps.AspectRatioInfoPresentFlag == 1 {
+		sps.AspectRatioIdc = r.U(8)
+		if sps.AspectRatioIdc == 255 { // EXTENDED_SAR
+			sps.SarWidth = r.U(16)
+			sps.SarHeight = r.U(16)
+		}
+	}
+
+	if sps.OverscanInfoPresentFlag = r.U(1); sps.OverscanInfoPresentFlag == 1 {
+		sps.OverscanAppropriateFlag = r.U(1)
+	}
+
+	if sps.VideoSignalTypePresentFlag = r.U(1); sps.VideoSignalTypePresentFlag == 1 {
+		sps.VideoFormat = r.U(3)
+		sps.VideoFullRangeFlag = r.U(1)
+		if sps.ColourDescriptionPresentFlag = r.U(1); sps.ColourDescriptionPresentFlag == 1 {
+			sps.ColourPrimaries = r.U(8)
+			sps.TransferCharacteristics = r.U(8)
+			sps.MatrixCoeffs = r.U(8)
+		}
+	}
+
+	if sps.ChromaLocInfoPresentFlag = r.U(1); sps.ChromaLocInfoPresentFlag == 1 {
+		sps.ChromaSampleLocTypeTopField = r.UE()
+		sps.ChromaSampleLocTypeBottomField = r.UE()
+	}
+
+	sps.NeutralChromaIndicationFlag = r.U(1)
+	sps.FieldSeqFlag = r.U(1)
+	sps.FrameFieldInfoPresentFlag = r.U(1)
+	if sps.DefaultDisplayWindowFlag = r.U(1); sps.DefaultDisplayWindowFlag == 1 {
+		sps.DefDispWinLeftOffset = r.UE()
+		sps.DefDispWinRightOffset = r.UE()
+		sps.DefDispWinTopOffset = r.UE()
+		sps.DefDispWinBottomOffset = r.UE()
+	}
+
+	if sps.TimingInfoPresentFlag = r.U(1); sps.TimingInfoPresentFlag == 1 {
+		sps.NumUnitsInTick = r.U(32)
+		sps.TimeScale = r.U(32)
+		if sps.PocProportionalToTimingFlag = r.U(1); sps.PocProportionalToTimingFlag == 1 {
+			sps.NumTicksPocDiffOneMinus1 = r.UE()
+		}
+
+		if sps.HrdParametersPresentFlag = r.U(1); sps.HrdParametersPresentFlag == 1 {
+			skipHrd(r, true, sps.MaxSubLayersMinus1)
+		}
+	}
+
+	if sps.BitstreamRestrictionFlag = r.U(1); sps.BitstreamRestrictionFlag == 1 {
+		sps.TilesFixedStructureFlag = r.U(1)
+		sps.MotionVectorsOverPicBoundaries = r.U(1)
+		sps.RestrictedRefPicListsFlag = r.U(1)
+		sps.MinSpatialSegmentationIdc = r.UE()
+		sps.MaxBytesPerPicDenom = r.UE()
+		sps.MaxBitsPerMinCuDenom = r.UE()
+		sps.Log2MaxMvLengthHorizontal = r.UE()
+		sps.Log2MaxMvLengthVertical = r.UE()
+	}
+}
+
+// skipHrd reads past an hrd_parameters(), E.2.2.
+func skipHrd(r *core.BitReader, commonInfPresent bool, maxSubLayersMinus1 int) {
+	nal, vcl, subPic := 0, 0, 0
+	if commonInfPresent {
+		nal, vcl = r.U(1), r.U(1)
+		if nal == 1 || vcl == 1 {
+			if subPic = r.U(1); subPic == 1 {
+				r.U(8) // tick_divisor_minus2
+				r.U(5) // du_cpb_removal_delay_increment_length_minus1
+				r.U(1) // sub_pic_cpb_params_in_pic_timing_sei_flag
+				r.U(5) // dpb_output_delay_du_length_minus1
+			}
+
+			r.U(8) // bit_rate_scale, cpb_size_scale
+			if subPic == 1 {
+				r.U(4) // cpb_size_du_scale
+			}
+
+			r.U(15) // initial_cpb_removal_delay_length_minus1 to dpb_output_delay_length_minus1
+		}
+	}
+
+	for i := 0; i <= maxSubLayersMinus1 && r.Err == nil; i++ {
+		fixedWithinCvs := 1
+		if r.U(1) == 0 { // fixed_pic_rate_general_flag
+			fixedWithinCvs = r.U(1)
+		}
+
+		lowDelay := 0
+		if fixedWithinCvs == 1 {
+			r.UE() // elemental_duration_in_tc_minus1
+		} else {
+			lowDelay = r.U(1)
+		}
+
+		cpbCnt := 1
+		if lowDelay == 0 {
+			cpbCnt = r.UE() + 1
+			if cpbCnt > 32 {
+				r.Err = ErrInvalidSPS
+				return
+			}
+		}
+
+		for k := 0; k < nal+vcl; k++ {
+			// sub_layer_hrd_parameters()
+			for j := 0; j < cpbCnt; j++ {
+				r.UE() // bit_rate_value_minus1
+				r.UE() // cpb_size_value_minus1
+				if subPic == 1 {
+					r.UE() // cpb_size_du_value_minus1
+					r.UE() // bit_rate_du_value_minus1
+				}
+				r.U(1) // cbr_flag
+			}
+		}
+	}
+}
+
+// FrameRate is the frame rate signalled in the VUI, 0 if absent.
+func (sps *SPS) FrameRate() float64 {
+	if sps.TimingInfoPresentFlag == 0 || sps.NumUnitsInTick == 0 {
+		return 0
+	}
+
+	// one tick per picture, unlike H.264
+	return float64(sps.TimeScale) / float64(sps.NumUnitsInTick)
+}
+
+// chromaSubsampling returns SubWidthC and SubHeightC of Table 6-1.
+func (sps *SPS) chromaSubsampling() (int, int) {
+	if sps.SeparateColourPlaneFlag == 1 {
+		return 1, 1
+	}
+
+	switch sps.ChromaFormatIdc {
+	case 1:
+		return 2, 2
+	case 2:
+		return 2, 1
+	}
+
+	return 1, 1
+}
+
+// Width is the width of the conformance cropping window in luma samples.
+func (sps *SPS) Width() int {
+	x, _ := sps.chromaSubsampling()
+	return sps.PicWidthInLumaSamples - x*(sps.ConfWinLeftOffset+sps.ConfWinRightOffset)
+}
+
+// Height is the height of the conformance cropping window in luma samples.
+func (sps *SPS) Height() int {
+	_, y := sps.chromaSubsampling()
+	return sps.PicHeightInLumaSamples - y*(sps.ConfWinTopOffset+sps.ConfWinBottomOffset)
+}
+
+// BitDepth returns the bit depth of the luma samples.
+func (sps *SPS) BitDepth() int { return sps.BitDepthLumaMinus8 + 8 }
+
+// picSizeInCtbs is PicSizeInCtbsY, 7.4.3.2.1.
+func (sps *SPS) picSizeInCtbs() int {
+	ctb := 1 << uint(sps.Log2MinLumaCodingBlockSizeMinus3+3+sps.Log2DiffMaxMinLumaCodingBlockSize)
+	return ((sps.PicWidthInLumaSamples + ctb - 1) / ctb) * ((sps.PicHeightInLumaSamples + ctb - 1) / ctb)
+}
+
+// ProfileName names the general profile of Annex A.
+func (sps *SPS) ProfileName() string {
+	switch sps.ProfileTierLevel.GeneralProfileIdc {
+	case 1:
+		return "Main"
+	case 2:
+		return "Main 10"
+	case 3:
+		return "Main Still Picture"
+	case 4:
+		return "Format Range Extensions"
+	case 5:
+		return "High Throughput"
+	case 9:
+		return "Screen Content Coding Extensions"
+	}
+
+	return fmt.Sprintf("unknown(%d)", sps.ProfileTierLevel.GeneralProfileIdc)
+}
+
+// LevelName returns the level as written in Table A-8, general_level_idc is
+// 30 times the level.
+func (sps *SPS) LevelName() string {
+	level := sps.ProfileTierLevel.GeneralLevelIdc
+	if level%30 == 0 {
+		return fmt.Sprintf("%d", level/30)
+	}
+
+	return fmt.Sprintf("%d.%d", level/30, level%30/3)
+}
+
+// TierName returns "Main" or "High".
+func (sps *SPS) TierName() string {
+	if sps.ProfileTierLevel.GeneralTierFlag == 1 {
+		return "High"
+	}
+
+	return "Main"
+}
+
+// Codecs returns the RFC 6381 codecs parameter of ISO/IEC 14496-15 E.3,
+// e.g. "hvc1.1.6.L93.B0", used in HLS and DASH manifests.
+func (sps *SPS) Codecs() string {
+	ptl := &sps.ProfileTierLevel
+
+	var b strings.Builder
+	b.WriteString("hvc1.")
+	if ptl.GeneralProfileSpace > 0 {
+		b.WriteByte(byte('A' + ptl.GeneralProfileSpace - 1))
+	}
+
+	// the compatibility flags in reverse bit order
+	compat := uint32(0)
+	for i := uint(0); i < 32; i++ {
+		compat |= (ptl.GeneralProfileCompatibilityFlags >> i & 1) << (31 - i)
+	}
+
+	tier := 'L'
+	if ptl.GeneralTierFlag == 1 {
+		tier = 'H'
+	}
+	fmt.Fprintf(&b, "%d.%x.%c%d", ptl.GeneralProfileIdc, compat, tier, ptl.GeneralLevelIdc)
+
+	// constraint bytes, trailing zero bytes left out
+	flags := make([]byte, 6)
+	for i := range flags {
+		flags[i] = byte(ptl.GeneralConstraintIndicatorFlags >> uint(40-8*i))
+	}
+
+	n := len(flags)
+	for n > 0 && flags[n-1] == 0 {
+		n--
+	}
+
+	for _, f := range flags[:n] {
+		fmt.Fprintf(&b, ".%X", f)
+	}
+
+	return b.String()
+}
+
+func (sps *SPS) String() string {
+	s := fmt.Sprintf("%s %s@%s %s %dx%d %dbit", sps.Codecs(), sps.ProfileName(), sps.LevelName(),
+		sps.TierName(), sps.Width(), sps.Height(), sps.BitDepth())
+	if fps := sps.FrameRate(); fps > 0 {
+		s += fmt.Sprintf(" %.3gfps", fps)
+	}
+
+	return s
+}
@@ -0,0 +1,231 @@
+package h265
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeHeader writes the NAL unit header with TemporalId 0.
+func writeHeader(w *bittest.Writer, typ int) {
+	w.U(16, typ<<9|1)
+}
+
+// writePTL writes a profile_tier_level(1, 0) of the given profile and level.
+func writePTL(w *bittest.Writer, profile, level int) {
+	w.U(2, 0)
+	w.U(1, 0)
+	w.U(5, profile)
+	w.U(32, 1<<uint(31-profile))
+	w.U(24, 0x900000) // progressive, frame only
+	w.U(24, 0)
+	w.U(8, level)
+}
+
+// main10SPS is a 1920x1080 Main 10 SPS at level 4.1 with two short-term
+// reference picture sets, the second predicted from the first, and a VUI
+// with BT.709 colour, 59.94fps timing and NAL HRD parameters.
+var main10SPS = func() []byte {
+	w := &bittest.Writer{}
+	writeHeader(w, NAL_SPS)
+	w.U(4, 0) // sps_video_parameter_set_id
+	w.U(3, 0) // sps_max_sub_layers_minus1
+	w.U(1, 1)
+	writePTL(w, 2, 123)
+	w.UE(0) // sps_seq_parameter_set_id
+	w.UE(1) // chroma_format_idc
+	w.UE(1920)
+	w.UE(1088)
+	w.U(1, 1) // conformance_window_flag
+	w.UE(0)
+	w.UE(0)
+	w.UE(0)
+	w.UE(4)
+	w.UE(2) // bit_depth_luma_minus8
+	w.UE(2)
+	w.UE(4) // log2_max_pic_order_cnt_lsb_minus4
+	w.U(1, 1)
+	w.UE(4)
+	w.UE(2)
+	w.UE(5)
+	w.UE(0) // log2_min_luma_coding_block_size_minus3
+	w.UE(3)
+	w.UE(0)
+	w.UE(3)
+	w.UE(1)
+	w.UE(1)
+	w.U(1, 0) // scaling_list_enabled_flag
+	w.U(1, 0) // amp_enabled_flag
+	w.U(1, 1)
+	w.U(1, 0) // pcm_enabled_flag
+
+	w.UE(2) // num_short_term_ref_pic_sets
+	w.UE(1) // num_negative_pics
+	w.UE(0)
+	w.UE(0)
+	w.U(1, 1)
+	w.U(1, 1) // inter_ref_pic_set_prediction_flag
+	w.U(1, 0)
+	w.UE(0)
+	w.U(1, 1) // used_by_curr_pic_flag
+	w.U(1, 0)
+	w.U(1, 1) // use_delta_flag
+
+	w.U(1, 0) // long_term_ref_pics_present_flag
+	w.U(1, 1)
+	w.U(1, 1)
+	w.U(1, 1) // vui_parameters_present_flag
+
+	w.U(1, 1)
+	w.U(8, 1) // aspect_ratio_idc
+	w.U(1, 0)
+	w.U(1, 1)
+	w.U(3, 5)
+	w.U(1, 0)
+	w.U(1, 1)
+	w.U(8, 1)
+	w.U(8, 1)
+	w.U(8, 1)
+	w.U(1, 0) // chroma_loc_info_present_flag
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0) // default_display_window_flag
+	w.U(1, 1) // vui_timing_info_present_flag
+	w.U(32, 1001)
+	w.U(32, 60000)
+	w.U(1, 0)
+	w.U(1, 1) // vui_hrd_parameters_present_flag
+	w.U(1, 1) // nal_hrd_parameters_present_flag
+	w.U(1, 0)
+	w.U(1, 0) // sub_pic_hrd_params_present_flag
+	w.U(8, 0x23)
+	w.U(15, 0x7fff)
+	w.U(1, 1) // fixed_pic_rate_general_flag
+	w.UE(0)
+	w.UE(0) // cpb_cnt_minus1
+	w.UE(9999)
+	w.UE(9999)
+	w.U(1, 0)
+	w.U(1, 1) // bitstream_restriction_flag
+	w.U(1, 0)
+	w.U(1, 1)
+	w.U(1, 1)
+	w.UE(0)
+	w.UE(2)
+	w.UE(1)
+	w.UE(15)
+	w.UE(15)
+	return w.NALU()
+}()
+
+func TestDecodeSPS(t *testing.T) {
+	sps, err := DecodeSPS(main10SPS)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sps.ProfileTierLevel.GeneralProfileIdc)
+	assert.Equal(t, 123, sps.ProfileTierLevel.GeneralLevelIdc)
+	assert.Equal(t, 1, sps.ProfileTierLevel.ProgressiveSource())
+	assert.Equal(t, 0, sps.ProfileTierLevel.InterlacedSource())
+	assert.Equal(t, 1920, sps.Width())
+	assert.Equal(t, 1080, sps.Height())
+	assert.Equal(t, 10, sps.BitDepth())
+	assert.Equal(t, []int{4}, sps.MaxDecPicBufferingMinus1)
+	assert.Equal(t, []int{2}, sps.MaxNumReorderPics)
+	assert.Equal(t, []int{1, 2}, sps.numDeltaPocs)
+	assert.Equal(t, 1, sps.ColourPrimaries)
+	assert.Equal(t, 1, sps.HrdParametersPresentFlag)
+	assert.Equal(t, 1, sps.RestrictedRefPicListsFlag)
+	assert.Equal(t, 15, sps.Log2MaxMvLengthVertical)
+	assert.InDelta(t, 59.94, sps.FrameRate(), 0.01)
+	assert.Equal(t, 510, sps.picSizeInCtbs())
+
+	assert.Equal(t, "Main 10", sps.ProfileName())
+	assert.Equal(t, "4.1", sps.LevelName())
+	assert.Equal(t, "hvc1.2.4.L123.90", sps.Codecs())
+	assert.Equal(t, "hvc1.2.4.L123.90 Main 10@4.1 Main 1920x1080 10bit 59.9fps", sps.String())
+
+	_, err = DecodeSPS(main10SPS[:20])
+	assert.Equal(t, ErrInvalidSPS, err)
+	_, err = DecodeSPS([]byte{0x40, 0x01, 0x0c})
+	assert.Equal(t, ErrInvalidSPS, err)
+}
+
+func TestDecodeSPSDefaults(t *testing.T) {
+	w := &bittest.Writer{}
+	writeHeader(w, NAL_SPS)
+	w.U(4, 0)
+	w.U(3, 1) // two sub-layers
+	w.U(1, 1)
+	writePTL(w, 1, 93)
+	w.U(1, 0) // sub_layer_profile_present_flag
+	w.U(1, 1)
+	w.U(14, 0)
+	w.U(8, 90) // sub_layer_level_idc
+	w.UE(1)
+	w.UE(1)
+	w.UE(1280)
+	w.UE(720)
+	w.U(1, 0)
+	w.UE(0)
+	w.UE(0)
+	w.UE(0)
+	w.U(1, 0) // only the highest sub-layer
+	w.UE(3)
+	w.UE(1)
+	w.UE(0)
+	w.UE(1)
+	w.UE(2)
+	w.UE(0)
+	w.UE(3)
+	w.UE(0)
+	w.UE(0)
+	w.U(1, 1) // scaling_list_enabled_flag
+	w.U(1, 1)
+	for sizeId := 0; sizeId < 4; sizeId++ {
+		for matrixId := 0; matrixId < 6; matrixId += 1 + 2*(sizeId/3) {
+			if sizeId == 2 && matrixId == 0 {
+				w.U(1, 1) // explicit coefficients, DC first
+				w.SE(8)
+				for i := 0; i < 64; i++ {
+					w.SE(0)
+				}
+				continue
+			}
+			w.U(1, 0)
+			w.UE(0)
+		}
+	}
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 1) // pcm_enabled_flag
+	w.U(4, 7)
+	w.U(4, 7)
+	w.UE(0)
+	w.UE(1)
+	w.U(1, 0)
+	w.UE(0)
+	w.U(1, 1) // long_term_ref_pics_present_flag
+	w.UE(2)
+	w.U(4, 3)
+	w.U(1, 1)
+	w.U(4, 5)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0)
+	w.U(1, 0) // no VUI
+
+	sps, err := DecodeSPS(w.NALU())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sps.SPSId)
+	assert.Equal(t, []int{90}, sps.ProfileTierLevel.SubLayerLevelIdc)
+	assert.Equal(t, []int{3}, sps.MaxDecPicBufferingMinus1)
+	assert.Equal(t, 7, sps.PcmSampleBitDepthLumaMinus1)
+	assert.Equal(t, 2, sps.NumLongTermRefPicsSps)
+	assert.Equal(t, 0.0, sps.FrameRate())
+	assert.Equal(t, 2, sps.ColourPrimaries)
+	assert.Equal(t, "Main", sps.ProfileName())
+	assert.Equal(t, "3.1", sps.LevelName())
+	assert.Equal(t, "hvc1.1.2.L93.90 Main@3.1 Main 1280x720 8bit", sps.String())
+}
@@ -0,0 +1,63 @@
+package h265
+
+import (
+	"errors"
+
+	"media-go/core"
+)
+
+var ErrInvalidVPS = errors.New("h265: invalid VPS")
+
+// DecodeVPS parses a VPS NALU, header included, 7.3.2.1, up to the timing
+// information. The HRD parameters and extensions are not read.
+func DecodeVPS(data []byte) (*VPS, error) {
+	nalu := decodeNalu(data)
+	if nalu.NalUnitType != NAL_VPS {
+		return nil, ErrInvalidVPS
+	}
+
+	r := core.NewBitReader(nalu.Rbsp[:nalu.RbspSize])
+	vps := &VPS{}
+	vps.VPSId = r.U(4)
+	vps.BaseLayerInternalFlag = r.U(1)
+	vps.BaseLayerAvailableFlag = r.U(1)
+	vps.MaxLayersMinus1 = r.U(6)
+	vps.MaxSubLayersMinus1 = r.U(3)
+	vps.TemporalIdNestingFlag = r.U(1)
+	if r.U(16) != 0xffff || vps.MaxSubLayersMinus1 > 6 { // vps_reserved_0xffff_16bits
+		return nil, ErrInvalidVPS
+	}
+
+	readProfileTierLevel(r, &vps.ProfileTierLevel, vps.MaxSubLayersMinus1)
+
+	vps.SubLayerOrderingInfoPresent = r.U(1)
+	vps.MaxDecPicBufferingMinus1, vps.MaxNumReorderPics, vps.MaxLatencyIncreasePlus1 =
+		readSubLayerOrdering(r, vps.SubLayerOrderingInfoPresent, vps.MaxSubLayersMinus1)
+
+	vps.MaxLayerId = r.U(6)
+	vps.NumLayerSetsMinus1 = r.UE()
+	if vps.NumLayerSetsMinus1 > 1023 {
+		return nil, ErrInvalidVPS
+	}
+
+	for i := 1; i <= vps.NumLayerSetsMinus1 && r.Err == nil; i++ {
+		for j := 0; j <= vps.MaxLayerId; j++ {
+			r.U(1) // layer_id_included_flag
+		}
+	}
+
+	if vps.TimingInfoPresentFlag = r.U(1); vps.TimingInfoPresentFlag == 1 {
+		vps.NumUnitsInTick = r.U(32)
+		vps.TimeScale = r.U(32)
+		if vps.PocProportionalToTimingFlag = r.U(1); vps.PocProportionalToTimingFlag == 1 {
+			vps.NumTicksPocDiffOneMinus1 = r.UE()
+		}
+		vps.NumHrdParameters = r.UE()
+	}
+
+	if r.Err != nil {
+		return nil, ErrInvalidVPS
+	}
+
+	return vps, nil
+}
@@ -0,0 +1,78 @@
+package h265
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// x265VPS and x265PPS come from an x265 encode at level 3.1.
+var (
+	x265VPS = []byte{0x40, 0x01, 0x0c, 0x01, 0xff, 0xff, 0x01, 0x60, 0x00, 0x00, 0x03, 0x00, 0x90,
+		0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x5d, 0x95, 0x98, 0x09}
+	x265PPS = []byte{0x44, 0x01, 0xc1, 0x72, 0xb4, 0x62, 0x40}
+)
+
+func TestDecodeVPS(t *testing.T) {
+	vps, err := DecodeVPS(x265VPS)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, vps.VPSId)
+	assert.Equal(t, 1, vps.BaseLayerInternalFlag)
+	assert.Equal(t, 0, vps.MaxSubLayersMinus1)
+	assert.Equal(t, 1, vps.ProfileTierLevel.GeneralProfileIdc)
+	assert.Equal(t, uint32(0x60000000), vps.ProfileTierLevel.GeneralProfileCompatibilityFlags)
+	assert.Equal(t, uint64(0x900000000000), vps.ProfileTierLevel.GeneralConstraintIndicatorFlags)
+	assert.Equal(t, 93, vps.ProfileTierLevel.GeneralLevelIdc)
+	assert.Equal(t, []int{4}, vps.MaxDecPicBufferingMinus1)
+	assert.Equal(t, []int{2}, vps.MaxNumReorderPics)
+	assert.Equal(t, []int{5}, vps.MaxLatencyIncreasePlus1)
+	assert.Equal(t, 0, vps.TimingInfoPresentFlag)
+
+	_, err = DecodeVPS(x265VPS[:12])
+	assert.Equal(t, ErrInvalidVPS, err)
+
+	// vps_reserved_0xffff_16bits
+	bad := append([]byte{}, x265VPS...)
+	bad[4] = 0
+	_, err = DecodeVPS(bad)
+	assert.Equal(t, ErrInvalidVPS, err)
+
+	_, err = DecodeVPS(x265PPS)
+	assert.Equal(t, ErrInvalidVPS, err)
+}
+
+func TestDecodeVPSTiming(t *testing.T) {
+	w := &bittest.Writer{}
+	writeHeader(w, NAL_VPS)
+	w.U(4, 1)
+	w.U(2, 3)
+	w.U(6, 0)
+	w.U(3, 0)
+	w.U(1, 1)
+	w.U(16, 0xffff)
+	writePTL(w, 1, 120)
+	w.U(1, 1)
+	w.UE(1)
+	w.UE(0)
+	w.UE(0)
+	w.U(6, 1) // vps_max_layer_id
+	w.UE(1)
+	w.U(2, 3) // layer_id_included_flag
+	w.U(1, 1) // vps_timing_info_present_flag
+	w.U(32, 1)
+	w.U(32, 25)
+	w.U(1, 1)
+	w.UE(0)
+	w.UE(0)
+	w.U(1, 0) // vps_extension_flag
+
+	vps, err := DecodeVPS(w.NALU())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, vps.VPSId)
+	assert.Equal(t, 1, vps.NumLayerSetsMinus1)
+	assert.Equal(t, 1, vps.NumUnitsInTick)
+	assert.Equal(t, 25, vps.TimeScale)
+	assert.Equal(t, 1, vps.PocProportionalToTimingFlag)
+}
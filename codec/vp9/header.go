@@ -0,0 +1,218 @@
+package vp9
+
+import (
+	"fmt"
+
+	"media-go/core"
+)
+
+const frameSyncCode = 0x498342
+
+// FrameHeader is the start of an uncompressed_header(), 6.2, up to the frame
+// and render size. The size and colour config of inter frames and of shown
+// existing frames come from earlier frames, a Parser fills them in.
+type FrameHeader struct {
+	Profile            int    `json:"profile"`
+	ShowExistingFrame  int    `json:"show_existing_frame"`
+	FrameToShowMapIdx  int    `json:"frame_to_show_map_idx"`
+	FrameType          int    `json:"frame_type"`
+	ShowFrame          int    `json:"show_frame"`
+	ErrorResilientMode int    `json:"error_resilient_mode"`
+	IntraOnly          int    `json:"intra_only"`
+	ResetFrameContext  int    `json:"reset_frame_context"`
+	BitDepth           int    `json:"bit_depth"`
+	ColorSpace         int    `json:"color_space"`
+	ColorRange         int    `json:"color_range"`
+	SubsamplingX       int    `json:"subsampling_x"`
+	SubsamplingY       int    `json:"subsampling_y"`
+	RefreshFrameFlags  int    `json:"refresh_frame_flags"`
+	RefFrameIdx        [3]int `json:"ref_frame_idx"`
+	FoundRef           int    `json:"found_ref"` // index into RefFrameIdx of the frame giving the size, -1 if coded
+	Width              int    `json:"width"`
+	Height             int    `json:"height"`
+	RenderWidth        int    `json:"render_width"`
+	RenderHeight       int    `json:"render_height"`
+	Size               int    `json:"size"` // bytes of the frame
+}
+
+// IsIntra is FrameIsIntra.
+func (fh *FrameHeader) IsIntra() bool {
+	return fh.ShowExistingFrame == 0 && (fh.FrameType == KEY_FRAME || fh.IntraOnly == 1)
+}
+
+// IsKeyFrame reports a key frame, which can be decoded on its own and resets
+// all reference slots.
+func (fh *FrameHeader) IsKeyFrame() bool {
+	return fh.ShowExistingFrame == 0 && fh.FrameType == KEY_FRAME
+}
+
+// IsShown reports if the frame is output, either now or as an existing frame.
+func (fh *FrameHeader) IsShown() bool {
+	return fh.ShowFrame == 1 || fh.ShowExistingFrame == 1
+}
+
+// TypeName names the frame: "key", "intra_only", "inter" or "show_existing".
+func (fh *FrameHeader) TypeName() string {
+	switch {
+	case fh.ShowExistingFrame == 1:
+		return "show_existing"
+	case fh.FrameType == KEY_FRAME:
+		return "key"
+	case fh.IntraOnly == 1:
+		return "intra_only"
+	}
+
+	return "inter"
+}
+
+// ColorSpaceName names color_space.
+func (fh *FrameHeader) ColorSpaceName() string {
+	return colorSpaceMap[fh.ColorSpace]
+}
+
+func (fh *FrameHeader) String() string {
+	return fmt.Sprintf("|type: %s|show: %d|%dx%d|size: %d", fh.TypeName(), fh.ShowFrame, fh.Width, fh.Height, fh.Size)
+}
+
+// DecodeFrameHeader parses the uncompressed header of one frame, not a
+// superframe.
+func DecodeFrameHeader(data []byte) (*FrameHeader, error) {
+	r := core.NewBitReader(data)
+	fh := &FrameHeader{FoundRef: -1, Size: len(data)}
+	if r.U(2) != 2 { // frame_marker
+		return nil, ErrInvalidFrame
+	}
+
+	low := r.U(1)
+	fh.Profile = r.U(1)<<1 | low
+	if fh.Profile == 3 && r.U(1) != 0 {
+		return nil, ErrInvalidFrame
+	}
+
+	if fh.ShowExistingFrame = r.U(1); fh.ShowExistingFrame == 1 {
+		fh.FrameToShowMapIdx = r.U(3)
+		if r.Err != nil {
+			return nil, ErrInvalidFrame
+		}
+
+		return fh, nil
+	}
+
+	fh.FrameType = r.U(1)
+	fh.ShowFrame = r.U(1)
+	fh.ErrorResilientMode = r.U(1)
+	if fh.FrameType == KEY_FRAME {
+		if r.U(24) != frameSyncCode {
+			return nil, ErrInvalidFrame
+		}
+
+		if err := readColorConfig(r, fh); err != nil {
+			return nil, err
+		}
+
+		readFrameSize(r, fh)
+		fh.RefreshFrameFlags = 1<<NUM_REF_FRAMES - 1
+	} else {
+		if fh.ShowFrame == 0 {
+			fh.IntraOnly = r.U(1)
+		}
+
+		if fh.ErrorResilientMode == 0 {
+			fh.ResetFrameContext = r.U(2)
+		}
+
+		if fh.IntraOnly == 1 {
+			if r.U(24) != frameSyncCode {
+				return nil, ErrInvalidFrame
+			}
+
+			if fh.Profile > 0 {
+				if err := readColorConfig(r, fh); err != nil {
+					return nil, err
+				}
+			} else {
+				fh.ColorSpace = CS_BT_601
+				fh.SubsamplingX, fh.SubsamplingY = 1, 1
+				fh.BitDepth = 8
+			}
+
+			fh.RefreshFrameFlags = r.U(8)
+			readFrameSize(r, fh)
+		} else {
+			fh.RefreshFrameFlags = r.U(8)
+			for i := 0; i < 3; i++ {
+				fh.RefFrameIdx[i] = r.U(3)
+				r.U(1) // ref_frame_sign_bias
+			}
+
+			// frame_size_with_refs
+			for i := 0; i < 3 && fh.FoundRef < 0; i++ {
+				if r.U(1) == 1 {
+					fh.FoundRef = i
+				}
+			}
+
+			if fh.FoundRef < 0 {
+				readFrameSize(r, fh)
+			} else {
+				readRenderSize(r, fh)
+			}
+		}
+	}
+
+	if r.Err != nil {
+		return nil, ErrInvalidFrame
+	}
+
+	return fh, nil
+}
+
+// readColorConfig reads a color_config(), 6.2.2.
+func readColorConfig(r *core.BitReader, fh *FrameHeader) error {
+	fh.BitDepth = 8
+	if fh.Profile >= 2 {
+		fh.BitDepth = 10 + 2*r.U(1)
+	}
+
+	fh.ColorSpace = r.U(3)
+	if fh.ColorSpace != CS_RGB {
+		fh.ColorRange = r.U(1)
+		if fh.Profile == 1 || fh.Profile == 3 {
+			fh.SubsamplingX = r.U(1)
+			fh.SubsamplingY = r.U(1)
+			if r.U(1) != 0 {
+				return ErrInvalidFrame
+			}
+		} else {
+			fh.SubsamplingX, fh.SubsamplingY = 1, 1
+		}
+	} else {
+		fh.ColorRange = 1
+		if fh.Profile == 1 || fh.Profile == 3 {
+			if r.U(1) != 0 {
+				return ErrInvalidFrame
+			}
+		} else {
+			// 4:4:4 RGB needs profile 1 or 3
+			return ErrInvalidFrame
+		}
+	}
+
+	return nil
+}
+
+// readFrameSize reads a frame_size() and the render_size() after it.
+func readFrameSize(r *core.BitReader, fh *FrameHeader) {
+	fh.Width = r.U(16) + 1
+	fh.Height = r.U(16) + 1
+	readRenderSize(r, fh)
+}
+
+func readRenderSize(r *core.BitReader, fh *FrameHeader) {
+	if r.U(1) == 1 { // render_and_frame_size_different
+		fh.RenderWidth = r.U(16) + 1
+		fh.RenderHeight = r.U(16) + 1
+	} else {
+		fh.RenderWidth, fh.RenderHeight = fh.Width, fh.Height
+	}
+}
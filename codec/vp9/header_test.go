@@ -0,0 +1,129 @@
+package vp9
+
+import (
+	"testing"
+
+	"media-go/internal/bittest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// keyFrame is a shown 352x288 profile 0 key frame header.
+var keyFrame = []byte{0x82, 0x49, 0x83, 0x42, 0x00, 0x15, 0xf0, 0x11, 0xf0}
+
+// interFrame writes a profile 0 inter frame header that takes its size from
+// the first reference and refreshes the slots of refresh.
+func interFrame(show, refresh int) []byte {
+	w := &bittest.Writer{}
+	w.U(2, 2)
+	w.U(2, 0) // profile
+	w.U(1, 0) // show_existing_frame
+	w.U(1, NON_KEY_FRAME)
+	w.U(1, show)
+	w.U(1, 0) // error_resilient_mode
+	if show == 0 {
+		w.U(1, 0) // intra_only
+	}
+	w.U(2, 0) // reset_frame_context
+	w.U(8, refresh)
+	w.U(12, 0x012) // ref_frame_idx 0, 0, 1 and sign bias
+	w.U(1, 1)      // found_ref
+	w.U(1, 0)      // render_and_frame_size_different
+	w.U(8, 0)
+	return w.Bytes()
+}
+
+func showExisting(idx int) []byte {
+	w := &bittest.Writer{}
+	w.U(2, 2)
+	w.U(2, 0)
+	w.U(1, 1)
+	w.U(3, idx)
+	return w.Bytes()
+}
+
+func TestDecodeFrameHeader(t *testing.T) {
+	fh, err := DecodeFrameHeader(keyFrame)
+	assert.Nil(t, err)
+	assert.True(t, fh.IsKeyFrame())
+	assert.True(t, fh.IsShown())
+	assert.Equal(t, 0, fh.Profile)
+	assert.Equal(t, 8, fh.BitDepth)
+	assert.Equal(t, "unknown", fh.ColorSpaceName())
+	assert.Equal(t, 1, fh.SubsamplingX)
+	assert.Equal(t, 352, fh.Width)
+	assert.Equal(t, 288, fh.RenderHeight)
+	assert.Equal(t, 0xff, fh.RefreshFrameFlags)
+	assert.Equal(t, "|type: key|show: 1|352x288|size: 9", fh.String())
+
+	fh, err = DecodeFrameHeader(interFrame(0, 0x04))
+	assert.Nil(t, err)
+	assert.False(t, fh.IsShown())
+	assert.Equal(t, "inter", fh.TypeName())
+	assert.Equal(t, 0x04, fh.RefreshFrameFlags)
+	assert.Equal(t, [3]int{0, 0, 1}, fh.RefFrameIdx)
+	assert.Equal(t, 0, fh.FoundRef)
+	assert.Equal(t, 0, fh.Width)
+
+	fh, err = DecodeFrameHeader(showExisting(2))
+	assert.Nil(t, err)
+	assert.True(t, fh.IsShown())
+	assert.False(t, fh.IsKeyFrame())
+	assert.Equal(t, 2, fh.FrameToShowMapIdx)
+	assert.Equal(t, "show_existing", fh.TypeName())
+
+	_, err = DecodeFrameHeader(keyFrame[:6])
+	assert.Equal(t, ErrInvalidFrame, err)
+	_, err = DecodeFrameHeader([]byte{0x42, 0x49, 0x83, 0x42})
+	assert.Equal(t, ErrInvalidFrame, err)
+	_, err = DecodeFrameHeader([]byte{0x82, 0x49, 0x83, 0x43, 0x00, 0x15, 0xf0, 0x11, 0xf0})
+	assert.Equal(t, ErrInvalidFrame, err)
+}
+
+func TestColorConfig(t *testing.T) {
+	// profile 2 12 bit BT.2020 full range, then profile 1 4:4:4 RGB
+	w := &bittest.Writer{}
+	w.U(2, 2)
+	w.U(2, 1)   // profile_low_bit 0, profile_high_bit 1
+	w.U(4, 0x2) // key frame, shown
+	w.U(24, frameSyncCode)
+	w.U(1, 1) // ten_or_twelve_bit
+	w.U(3, CS_BT_2020)
+	w.U(1, 1)
+	w.U(32, 1919<<16|1079)
+	w.U(1, 1)
+	w.U(32, 1279<<16|719)
+	fh, err := DecodeFrameHeader(w.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fh.Profile)
+	assert.Equal(t, 12, fh.BitDepth)
+	assert.Equal(t, "bt2020", fh.ColorSpaceName())
+	assert.Equal(t, 1, fh.ColorRange)
+	assert.Equal(t, 1920, fh.Width)
+	assert.Equal(t, 1280, fh.RenderWidth)
+
+	w = &bittest.Writer{}
+	w.U(2, 2)
+	w.U(2, 2)   // profile_low_bit 1, profile_high_bit 0
+	w.U(4, 0x2) // key frame, shown
+	w.U(24, frameSyncCode)
+	w.U(3, CS_RGB)
+	w.U(1, 0) // reserved_zero
+	w.U(33, 0)
+	fh, err = DecodeFrameHeader(w.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, fh.Profile)
+	assert.Equal(t, 0, fh.SubsamplingX)
+	assert.Equal(t, 1, fh.ColorRange)
+
+	// RGB needs 4:4:4
+	w = &bittest.Writer{}
+	w.U(2, 2)
+	w.U(2, 0)
+	w.U(4, 0x2) // key frame, shown
+	w.U(24, frameSyncCode)
+	w.U(3, CS_RGB)
+	w.U(33, 0)
+	_, err = DecodeFrameHeader(w.Bytes())
+	assert.Equal(t, ErrInvalidFrame, err)
+}
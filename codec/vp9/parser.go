@@ -0,0 +1,120 @@
+package vp9
+
+import (
+	"fmt"
+)
+
+// FrameError reports a frame of a superframe that could not be decoded,
+// Index is its position in the superframe.
+type FrameError struct {
+	Index int
+	Size  int
+	Err   error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("%v: frame %d of %d bytes", e.Err, e.Index, e.Size)
+}
+
+func (e *FrameError) Unwrap() error { return e.Err }
+
+// refFrame is what a reference slot keeps for later headers.
+type refFrame struct {
+	width, height int
+}
+
+// Parser decodes the VP9 video of one stream. It keeps the configuration
+// record, the colour config of the last intra frame and the sizes of the
+// reference slots, so every stream needs its own Parser.
+type Parser struct {
+	config *VPCConfig
+	color  *FrameHeader
+	refs   [NUM_REF_FRAMES]refFrame
+}
+
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+// Config returns the configuration record, nil before the first sequence
+// start. Unlike H.264 it is not needed to parse frames.
+func (p *Parser) Config() *VPCConfig { return p.config }
+
+// ParseConfig decodes a VPCodecConfigurationRecord.
+func (p *Parser) ParseConfig(record []byte) (*VideoFrameInfo, error) {
+	config, err := ParseVPCConfig(record)
+	if err != nil {
+		return nil, err
+	}
+
+	p.config = config
+	return &VideoFrameInfo{CodecType: "seq", Type: "seq header", Config: config}, nil
+}
+
+// ParseFrame decodes the headers of one sample, a frame or a superframe.
+// Samples must be passed in decoding order.
+func (p *Parser) ParseFrame(data []byte) (*VideoFrameInfo, error) {
+	frames, err := SplitSuperframe(data)
+	if err != nil {
+		return nil, err
+	}
+
+	vf := &VideoFrameInfo{CodecType: "frame"}
+	for i, frame := range frames {
+		fh, err := DecodeFrameHeader(frame)
+		if err != nil {
+			return nil, &FrameError{Index: i, Size: len(frame), Err: err}
+		}
+
+		p.update(fh)
+		vf.Frames = append(vf.Frames, fh)
+		vf.KeyFrame = vf.KeyFrame || fh.IsKeyFrame()
+	}
+
+	vf.Type = "hidden"
+	for _, fh := range vf.Frames {
+		if fh.IsShown() {
+			vf.Type = fh.TypeName()
+		}
+	}
+
+	if vf.KeyFrame {
+		vf.Type = "key"
+	}
+
+	return vf, nil
+}
+
+// update fills in what the header takes from earlier frames and refreshes
+// the reference slots.
+func (p *Parser) update(fh *FrameHeader) {
+	ref := -1
+	switch {
+	case fh.ShowExistingFrame == 1:
+		ref = fh.FrameToShowMapIdx
+	case fh.FoundRef >= 0:
+		ref = fh.RefFrameIdx[fh.FoundRef]
+	}
+
+	if ref >= 0 {
+		fh.Width, fh.Height = p.refs[ref].width, p.refs[ref].height
+		if fh.RenderWidth == 0 {
+			fh.RenderWidth, fh.RenderHeight = fh.Width, fh.Height
+		}
+	}
+
+	if fh.IsIntra() {
+		p.color = fh
+	} else if p.color != nil {
+		fh.BitDepth = p.color.BitDepth
+		fh.ColorSpace = p.color.ColorSpace
+		fh.ColorRange = p.color.ColorRange
+		fh.SubsamplingX, fh.SubsamplingY = p.color.SubsamplingX, p.color.SubsamplingY
+	}
+
+	for i := 0; i < NUM_REF_FRAMES; i++ {
+		if fh.RefreshFrameFlags>>uint(i)&1 == 1 {
+			p.refs[i] = refFrame{width: fh.Width, height: fh.Height}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package vp9
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParser(t *testing.T) {
+	p := NewParser()
+	vf, err := p.ParseConfig([]byte{1, 0, 0, 0, 0, 31, 0x82, 1, 1, 1, 0, 0})
+	assert.Nil(t, err)
+	assert.Equal(t, "seq", vf.CodecType)
+	assert.Equal(t, 31, p.Config().Level)
+
+	vf, err = p.ParseFrame(keyFrame)
+	assert.Nil(t, err)
+	assert.True(t, vf.KeyFrame)
+	assert.Equal(t, "key", vf.Type)
+	assert.Equal(t, "code: frame\ttype: key\tframe: |type: key|show: 1|352x288|size: 9", vf.String())
+
+	// a hidden alt-ref in slot 2 and a shown inter frame in one superframe
+	data, err := AppendSuperframe(nil, interFrame(0, 0x04), interFrame(1, 0x01))
+	assert.Nil(t, err)
+	vf, err = p.ParseFrame(data)
+	assert.Nil(t, err)
+	assert.False(t, vf.KeyFrame)
+	assert.Equal(t, "inter", vf.Type)
+	assert.Len(t, vf.Frames, 2)
+	assert.Equal(t, 352, vf.Frames[1].Width)
+	assert.Equal(t, 288, vf.Frames[1].RenderHeight)
+	assert.Equal(t, 8, vf.Frames[1].BitDepth)
+
+	vf, err = p.ParseFrame(interFrame(0, 0x08))
+	assert.Nil(t, err)
+	assert.Equal(t, "hidden", vf.Type)
+
+	vf, err = p.ParseFrame(showExisting(2))
+	assert.Nil(t, err)
+	assert.Equal(t, "show_existing", vf.Type)
+	assert.Equal(t, 352, vf.Frames[0].Width)
+
+	data, err = AppendSuperframe(nil, keyFrame, keyFrame[:4])
+	assert.Nil(t, err)
+	_, err = p.ParseFrame(data)
+	var fe *FrameError
+	assert.True(t, errors.As(err, &fe))
+	assert.Equal(t, 1, fe.Index)
+	assert.True(t, errors.Is(err, ErrInvalidFrame))
+}
@@ -0,0 +1,151 @@
+package vp9
+
+import (
+	"errors"
+	"fmt"
+)
+
+// frame_type, VP9 7.2
+const (
+	KEY_FRAME     = 0
+	NON_KEY_FRAME = 1
+)
+
+// color_space, 7.2.2
+const (
+	CS_UNKNOWN   = 0
+	CS_BT_601    = 1
+	CS_BT_709    = 2
+	CS_SMPTE_170 = 3
+	CS_SMPTE_240 = 4
+	CS_BT_2020   = 5
+	CS_RESERVED  = 6
+	CS_RGB       = 7
+)
+
+var colorSpaceMap = map[int]string{
+	CS_UNKNOWN:   "unknown",
+	CS_BT_601:    "bt601",
+	CS_BT_709:    "bt709",
+	CS_SMPTE_170: "smpte170",
+	CS_SMPTE_240: "smpte240",
+	CS_BT_2020:   "bt2020",
+	CS_RESERVED:  "reserved",
+	CS_RGB:       "rgb",
+}
+
+const NUM_REF_FRAMES = 8
+
+var (
+	ErrInvalidSuperframe = errors.New("vp9: invalid superframe index")
+	ErrInvalidFrame      = errors.New("vp9: invalid uncompressed header")
+)
+
+// SplitSuperframe splits a superframe into its frames using the index of
+// Annex B at its end. Data without an index is returned as the only frame.
+func SplitSuperframe(data []byte) ([][]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidSuperframe
+	}
+
+	marker := data[len(data)-1]
+	if marker&0xe0 != 0xc0 {
+		return [][]byte{data}, nil
+	}
+
+	bytesPerSize := int(marker>>3&0x03) + 1
+	count := int(marker&0x07) + 1
+	indexSize := 2 + bytesPerSize*count
+	if len(data) < indexSize || data[len(data)-indexSize] != marker {
+		return [][]byte{data}, nil
+	}
+
+	frames := make([][]byte, 0, count)
+	pos, index := 0, data[len(data)-indexSize+1:]
+	for i := 0; i < count; i++ {
+		size := 0
+		for j := bytesPerSize - 1; j >= 0; j-- {
+			size = size<<8 | int(index[i*bytesPerSize+j])
+		}
+
+		if size == 0 || size > len(data)-indexSize-pos {
+			return nil, ErrInvalidSuperframe
+		}
+
+		frames = append(frames, data[pos:pos+size])
+		pos += size
+	}
+
+	return frames, nil
+}
+
+// AppendSuperframe appends the frames and their superframe index, a single
+// frame is appended as is.
+func AppendSuperframe(data []byte, frames ...[]byte) ([]byte, error) {
+	if len(frames) == 0 || len(frames) > 8 {
+		return nil, ErrInvalidSuperframe
+	}
+
+	if len(frames) == 1 {
+		return append(data, frames[0]...), nil
+	}
+
+	max := 0
+	for _, f := range frames {
+		if len(f) == 0 {
+			return nil, ErrInvalidSuperframe
+		}
+
+		if len(f) > max {
+			max = len(f)
+		}
+
+		data = append(data, f...)
+	}
+
+	bytesPerSize := 1
+	for max >= 1<<uint(8*bytesPerSize) {
+		bytesPerSize++
+	}
+
+	if bytesPerSize > 4 {
+		return nil, ErrInvalidSuperframe
+	}
+
+	marker := byte(0xc0 | (bytesPerSize-1)<<3 | (len(frames) - 1))
+	data = append(data, marker)
+	for _, f := range frames {
+		for j := 0; j < bytesPerSize; j++ {
+			data = append(data, byte(len(f)>>uint(8*j)))
+		}
+	}
+
+	return append(data, marker), nil
+}
+
+// VideoFrameInfo describes a sequence start or one sample, a frame or a
+// superframe.
+type VideoFrameInfo struct {
+	CodecType string
+	Type      string
+	Config    *VPCConfig // seq header only
+	Frames    []*FrameHeader
+	KeyFrame  bool
+}
+
+func (f *VideoFrameInfo) frames() string {
+	s := ""
+	for _, fh := range f.Frames {
+		s += fh.String()
+	}
+
+	return s
+}
+
+func (f *VideoFrameInfo) String() string {
+	if len(f.Frames) == 0 {
+		return fmt.Sprintf("code: %s\ttype: %s", f.CodecType, f.Type)
+	}
+
+	return fmt.Sprintf("code: %s\ttype: %s\tframe: %s", f.CodecType, f.Type, f.frames())
+}
@@ -0,0 +1,39 @@
+package vp9
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitSuperframe(t *testing.T) {
+	a, b := []byte{0x86, 1, 2}, make([]byte, 300)
+	data, err := AppendSuperframe(nil, a, b)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xc9, 3, 0, 0x2c, 0x01, 0xc9}, data[len(a)+len(b):])
+
+	frames, err := SplitSuperframe(data)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{a, b}, frames)
+
+	// no index, or a marker byte without a matching index
+	frames, err = SplitSuperframe(a)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{a}, frames)
+	frames, err = SplitSuperframe([]byte{0x82, 0xc0})
+	assert.Nil(t, err)
+	assert.Len(t, frames, 1)
+
+	data, err = AppendSuperframe(nil, a)
+	assert.Nil(t, err)
+	assert.Equal(t, a, data)
+
+	_, err = SplitSuperframe([]byte{0x82, 0xc0, 2, 0xc0})
+	assert.Equal(t, ErrInvalidSuperframe, err)
+	_, err = SplitSuperframe([]byte{0x82, 0xc0, 0, 0xc0})
+	assert.Equal(t, ErrInvalidSuperframe, err)
+	_, err = SplitSuperframe(nil)
+	assert.Equal(t, ErrInvalidSuperframe, err)
+	_, err = AppendSuperframe(nil, a, nil)
+	assert.Equal(t, ErrInvalidSuperframe, err)
+}
@@ -0,0 +1,88 @@
+package vp9
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidConfig = errors.New("vp9: invalid VPCodecConfigurationRecord")
+
+const vpccHeaderSize = 12
+
+// chromaSubsampling of the record
+const (
+	CHROMA_420_VERTICAL   = 0
+	CHROMA_420_COLLOCATED = 1
+	CHROMA_422            = 2
+	CHROMA_444            = 3
+)
+
+// VPCConfig is the VPCodecConfigurationRecord of the VP codec ISO media file
+// format binding, the payload of the MP4 vpcC box and the vp09 sequence start
+// of Enhanced FLV, version and flags included. Level is the level times ten,
+// e.g. 31 for 3.1.
+type VPCConfig struct {
+	Version                 int
+	Flags                   int
+	Profile                 int
+	Level                   int
+	BitDepth                int
+	ChromaSubsampling       int
+	VideoFullRangeFlag      int
+	ColourPrimaries         int
+	TransferCharacteristics int
+	MatrixCoefficients      int
+	CodecInitializationData []byte
+}
+
+// ParseVPCConfig parses a record, CodecInitializationData shares data.
+func ParseVPCConfig(data []byte) (*VPCConfig, error) {
+	if len(data) < vpccHeaderSize {
+		return nil, ErrInvalidConfig
+	}
+
+	c := &VPCConfig{}
+	c.Version = int(data[0])
+	c.Flags = int(binary.BigEndian.Uint32(data[0:]) & 0xffffff)
+	c.Profile = int(data[4])
+	c.Level = int(data[5])
+	c.BitDepth = int(data[6] >> 4)
+	c.ChromaSubsampling = int(data[6]>>1) & 0x07
+	c.VideoFullRangeFlag = int(data[6] & 0x01)
+	c.ColourPrimaries = int(data[7])
+	c.TransferCharacteristics = int(data[8])
+	c.MatrixCoefficients = int(data[9])
+
+	size := int(binary.BigEndian.Uint16(data[10:]))
+	if size > len(data)-vpccHeaderSize {
+		return nil, ErrInvalidConfig
+	}
+
+	c.CodecInitializationData = data[vpccHeaderSize : vpccHeaderSize+size]
+	return c, nil
+}
+
+// Bytes serializes the record.
+func (c *VPCConfig) Bytes() ([]byte, error) {
+	if len(c.CodecInitializationData) > 0xffff {
+		return nil, ErrInvalidConfig
+	}
+
+	data := make([]byte, vpccHeaderSize, vpccHeaderSize+len(c.CodecInitializationData))
+	binary.BigEndian.PutUint32(data, uint32(c.Version)<<24|uint32(c.Flags&0xffffff))
+	data[4] = byte(c.Profile)
+	data[5] = byte(c.Level)
+	data[6] = byte(c.BitDepth<<4 | (c.ChromaSubsampling&0x07)<<1 | c.VideoFullRangeFlag&0x01)
+	data[7] = byte(c.ColourPrimaries)
+	data[8] = byte(c.TransferCharacteristics)
+	data[9] = byte(c.MatrixCoefficients)
+	binary.BigEndian.PutUint16(data[10:], uint16(len(c.CodecInitializationData)))
+	return append(data, c.CodecInitializationData...), nil
+}
+
+// Codecs returns the codecs parameter "vp09.PP.LL.DD" used in HLS and DASH
+// manifests.
+func (c *VPCConfig) Codecs() string {
+	return fmt.Sprintf("vp09.%02d.%02d.%02d", c.Profile, c.Level, c.BitDepth)
+}
@@ -0,0 +1,37 @@
+package vp9
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVPCConfig(t *testing.T) {
+	// profile 0 level 3.1 8 bit 4:2:0 BT.709
+	data := []byte{1, 0, 0, 0, 0, 31, 0x82, 1, 1, 1, 0, 0}
+	c, err := ParseVPCConfig(data)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, c.Version)
+	assert.Equal(t, 31, c.Level)
+	assert.Equal(t, 8, c.BitDepth)
+	assert.Equal(t, CHROMA_420_COLLOCATED, c.ChromaSubsampling)
+	assert.Equal(t, 0, c.VideoFullRangeFlag)
+	assert.Equal(t, 1, c.MatrixCoefficients)
+	assert.Equal(t, "vp09.00.31.08", c.Codecs())
+
+	c.Profile, c.BitDepth, c.VideoFullRangeFlag = 2, 10, 1
+	c.CodecInitializationData = []byte{7}
+	out, err := c.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 0, 0, 0, 2, 31, 0xa3, 1, 1, 1, 0, 1, 7}, out)
+
+	parsed, err := ParseVPCConfig(out)
+	assert.Nil(t, err)
+	assert.Equal(t, c, parsed)
+	assert.Equal(t, "vp09.02.31.10", parsed.Codecs())
+
+	_, err = ParseVPCConfig(out[:12])
+	assert.Equal(t, ErrInvalidConfig, err)
+	_, err = ParseVPCConfig(data[:11])
+	assert.Equal(t, ErrInvalidConfig, err)
+}
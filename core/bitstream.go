@@ -1,6 +1,13 @@
 package core
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+// ErrInvalidCode reports an Exp-Golomb code that runs out of data or is
+// longer than 32 bits.
+var ErrInvalidCode = errors.New("core: invalid Exp-Golomb code")
 
 type BitStream struct {
 	data      []byte
@@ -42,3 +49,128 @@ func (bs *BitStream) ReadByte() (byte, error) {
 
 	return byte(rc), nil
 }
+
+// ReadBits reads n (at most 32) bits MSB first.
+func (bs *BitStream) ReadBits(n int) (int, error) {
+	var rc int
+
+	for i := 0; i < n; i++ {
+		x := bs.Next()
+		if x == -1 {
+			return rc, io.EOF
+		}
+
+		rc = rc<<1 | x
+	}
+
+	return rc, nil
+}
+
+// ReadUE reads an Exp-Golomb code, ue(v).
+func (bs *BitStream) ReadUE() (int, error) {
+	leadingZerosBit := 0
+	for {
+		x := bs.Next()
+		if x == 1 {
+			break
+		}
+
+		if x == -1 {
+			return 0, ErrInvalidCode
+		}
+
+		leadingZerosBit++
+		if leadingZerosBit >= 32 {
+			return 0, ErrInvalidCode
+		}
+	}
+
+	v, err := bs.ReadBits(leadingZerosBit)
+	if err != nil {
+		return 0, ErrInvalidCode
+	}
+
+	return (1 << leadingZerosBit) - 1 + v, nil
+}
+
+// ReadSE reads a signed Exp-Golomb code, se(v).
+func (bs *BitStream) ReadSE() (int, error) {
+	k, err := bs.ReadUE()
+	if err != nil {
+		return 0, err
+	}
+
+	if k&1 == 1 {
+		return (k + 1) / 2, nil
+	}
+
+	return -k / 2, nil
+}
+
+// Left returns the number of unread bits.
+func (bs *BitStream) Left() int {
+	if bs.offset >= len(bs.data) {
+		return 0
+	}
+
+	return (len(bs.data)-bs.offset-1)*8 + bs.offsetBit + 1
+}
+
+// Pos returns the number of bits read so far.
+func (bs *BitStream) Pos() int {
+	return bs.offset*8 + 7 - bs.offsetBit
+}
+
+// BitReader reads the syntax elements of a bitstream and keeps the first
+// error, later reads return zeros. Parsers check Err once after the fields
+// that belong together.
+type BitReader struct {
+	*BitStream
+	Err error
+}
+
+func NewBitReader(data []byte) *BitReader {
+	return &BitReader{BitStream: NewBitStream(data)}
+}
+
+// U reads n bits, at most 32: u(n) of H.264 and H.265, f(n) of AV1 and VP9.
+func (r *BitReader) U(n int) int {
+	if r.Err != nil {
+		return 0
+	}
+
+	v, err := r.ReadBits(n)
+	if err != nil {
+		r.Err = err
+	}
+
+	return v
+}
+
+// UE reads ue(v).
+func (r *BitReader) UE() int {
+	if r.Err != nil {
+		return 0
+	}
+
+	v, err := r.ReadUE()
+	if err != nil {
+		r.Err = err
+	}
+
+	return v
+}
+
+// SE reads se(v).
+func (r *BitReader) SE() int {
+	if r.Err != nil {
+		return 0
+	}
+
+	v, err := r.ReadSE()
+	if err != nil {
+		r.Err = err
+	}
+
+	return v
+}
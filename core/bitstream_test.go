@@ -1,6 +1,7 @@
 package core
 
 import (
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,3 +33,36 @@ func TestNext(t *testing.T) {
 
 	assert.Equal(t, bs.Next(), -1)
 }
+
+func TestReadBits(t *testing.T) {
+	bs := NewBitStream([]byte{0x12, 0x34, 0x56})
+	assert.Equal(t, 24, bs.Left())
+
+	v, err := bs.ReadBits(4)
+	assert.Nil(t, err)
+	assert.Equal(t, 0x1, v)
+
+	v, err = bs.ReadBits(12)
+	assert.Nil(t, err)
+	assert.Equal(t, 0x234, v)
+	assert.Equal(t, 16, bs.Pos())
+	assert.Equal(t, 8, bs.Left())
+
+	_, err = bs.ReadBits(9)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, bs.Left())
+}
+
+func TestBitReader(t *testing.T) {
+	// u(3) 5, ue 2, se -1, then past the end
+	r := NewBitReader([]byte{0xad, 0x80})
+	assert.Equal(t, 5, r.U(3))
+	assert.Equal(t, 2, r.UE())
+	assert.Equal(t, -1, r.SE())
+	assert.Nil(t, r.Err)
+
+	assert.Equal(t, 0, r.UE())
+	assert.Equal(t, ErrInvalidCode, r.Err)
+	assert.Equal(t, 0, r.U(1))
+	assert.Equal(t, ErrInvalidCode, r.Err)
+}
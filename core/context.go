@@ -28,6 +28,8 @@ type Context struct {
 	FrameCb FrameCallback
 	SR      StreamReader
 	Done    bool
+
+	FrameRate float64 // timestamps of raw video inputs, 0 uses the stream timing
 }
 
 func NewContext() *Context {
@@ -37,3 +39,30 @@ func NewContext() *Context {
 func (ctx *Context) SetFrameCallback(cb FrameCallback)   { ctx.FrameCb = cb }
 func (ctx *Context) SetPktCallback(cb PktCallback)       { ctx.PktCb = cb }
 func (ctx *Context) SetStreamReader(reader StreamReader) { ctx.SR = reader }
+
+// CbResult may be returned by a PktCallback or FrameCallback to control the
+// pipeline. A nil result continues, an error stops the stream with that error.
+type CbResult int
+
+const (
+	CbContinue CbResult = iota
+	CbDrop              // skip this packet and go on
+	CbStop              // stop the stream after this packet
+)
+
+// Control applies a callback result: it reports whether the packet is kept
+// and marks the context done on CbStop or an error.
+func (ctx *Context) Control(rc interface{}) (bool, error) {
+	switch v := rc.(type) {
+	case CbResult:
+		if v == CbStop {
+			ctx.Done = true
+		}
+		return v != CbDrop, nil
+	case error:
+		ctx.Done = true
+		return false, v
+	}
+
+	return true, nil
+}
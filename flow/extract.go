@@ -0,0 +1,55 @@
+package flow
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"media-go/codec/caption"
+	"media-go/muxer/flv"
+)
+
+// Extract writes one elementary stream of the FLV file src to dst, kind is
+// "audio" for an ADTS AAC file, "video" for an Annex B H.264 file or "cc1"
+// to "cc4" for the closed captions, WebVTT if dst ends in .vtt, SRT
+// otherwise.
+func Extract(src, dst, kind string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var extract func(r io.Reader, w io.Writer) error
+	switch kind {
+	case "audio":
+		extract = flv.ExtractAAC
+	case "video":
+		extract = flv.ExtractH264
+	case "cc1", "cc2", "cc3", "cc4":
+		channel := int(kind[2] - '0')
+		extract = func(r io.Reader, w io.Writer) error {
+			var cw caption.CueWriter = caption.NewSRTWriter(w)
+			if strings.EqualFold(filepath.Ext(dst), ".vtt") {
+				cw = caption.NewWebVTTWriter(w)
+			}
+			return flv.ExtractCaptions(r, cw, channel)
+		}
+	default:
+		return fmt.Errorf("extract: unknown stream %q", kind)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := extract(in, out); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -1,22 +1,62 @@
 package flow
 
 import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"media-go/core"
+	"media-go/muxer/annexb"
 	"media-go/muxer/flv"
 	"media-go/reader"
 )
 
-func Run(ctx *core.Context) {
-	reader := reader.FileReader{Source: ctx.Source}
-	if err := reader.Open(); err != nil {
-		panic(err)
+type demuxer interface {
+	ReadPacket() (*flv.Packet, error)
+}
+
+// newDemuxer picks the input format from the file extension, FLV unless the
+// source is a raw H.264 stream.
+func newDemuxer(ctx *core.Context, r io.Reader) demuxer {
+	switch strings.ToLower(filepath.Ext(ctx.Source)) {
+	case ".h264", ".264":
+		d := annexb.NewDemuxer(r)
+		d.SetContext(ctx)
+		d.SetFrameRate(ctx.FrameRate)
+		return d
 	}
 
-	flv := flv.NewFLV(ctx)
+	d := flv.NewDemuxer(r)
+	d.SetContext(ctx)
+	return d
+}
 
-	for !reader.Done() && !ctx.Done {
-		reader.Read()
-		flv.Decode(reader.Buffer.Bytes())
-		reader.Buffer.Reset()
+// Run demuxes ctx.Source, or the data returned by ctx.SR when set, and
+// delivers every packet to the context callbacks until the stream ends or a
+// callback stops it.
+func Run(ctx *core.Context) error {
+	var r io.Reader
+	if ctx.SR != nil {
+		r = &reader.StreamReader{Ctx: ctx}
+	} else {
+		fd, err := os.Open(ctx.Source)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		r = fd
 	}
+
+	demuxer := newDemuxer(ctx, r)
+	for !ctx.Done {
+		if _, err := demuxer.ReadPacket(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
 }
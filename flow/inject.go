@@ -0,0 +1,28 @@
+package flow
+
+import (
+	"os"
+
+	"media-go/muxer/flv"
+)
+
+// Inject writes a copy of the FLV file src to dst with a rebuilt onMetaData.
+func Inject(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := flv.InjectMetadata(in, out); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
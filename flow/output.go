@@ -1,4 +1,208 @@
 package flow
 
-func PrintPkt() {
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"media-go/codec/av1"
+	"media-go/codec/h264"
+	"media-go/codec/h265"
+	"media-go/codec/vp9"
+	"media-go/core"
+	"media-go/muxer/flv"
+)
+
+// Printer prints the packets selected by ctx.Filter. It keeps the H.264 and
+// H.265 parameter sets, the AV1 sequence header and the VP9 reference frames
+// of the stream, so use one Printer per stream.
+type Printer struct {
+	avc  *h264.Parser
+	hevc *h265.Parser
+	av1  *av1.Parser
+	vp9  *vp9.Parser
+}
+
+func NewPrinter() *Printer {
+	return &Printer{avc: h264.NewParser(), hevc: h265.NewParser(), av1: av1.NewParser(), vp9: vp9.NewParser()}
+}
+
+// PrintPkt is a core.PktCallback.
+func (p *Printer) PrintPkt(ctx *core.Context, pkt *core.Packet) interface{} {
+	fp := pkt.Data.(*flv.Packet)
+
+	switch fp.Type {
+	case flv.PKT_HEADER:
+		fmt.Print(fp.Data.(*flv.FLVHeader).String())
+	case flv.PKT_SCRIPT:
+		if ctx.Filter == core.MetaData {
+			fmt.Print(fp.Data.(*flv.PacketScript).String())
+			return core.CbStop
+		}
+	case flv.PKT_VIDEO:
+		if ctx.Filter == core.Video {
+			video := fp.Data.(*flv.PacketVideo)
+			var err error
+			if !video.IsExHeader && video.CodecID == flv.FLV_CODECID_H264 {
+				err = p.printAVC(fp.Tag.Data[1:])
+			} else if config, nalus, ok := hevcPayload(video); ok {
+				err = p.printHEVC(config, nalus)
+			} else if video.IsExHeader && video.FourCC == flv.FOURCC_AV01 && video.Tracks == nil {
+				err = p.printAV1(video)
+			} else if video.IsExHeader && video.FourCC == flv.FOURCC_VP09 && video.Tracks == nil {
+				err = p.printVP9(video)
+			}
+
+			// one broken picture does not end the listing
+			if err != nil {
+				fmt.Printf("\terror: %v\n", err)
+			}
+			fmt.Println(video.String())
+		}
+	case flv.PKT_AUDIO:
+		if ctx.Filter == core.Audio {
+			fmt.Println(fp.Data.(*flv.PacketAudio).String())
+		}
+	}
+
+	return nil
+}
+
+func (p *Printer) printAVC(data []byte) error {
+	vf, err := p.avc.Parse(data)
+	if err == h264.ErrNoConfig {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, sps := range vf.SPS {
+		js, _ := json.Marshal(sps)
+		fmt.Printf("\tsps: \n%s\n", js)
+	}
+
+	for _, pps := range vf.PPS {
+		js, _ := json.Marshal(pps)
+		fmt.Printf("\tpps: \n%s\n", js)
+	}
+
+	for _, msg := range vf.SEI {
+		fmt.Printf("\tsei: %s", msg.String())
+		if ud, ok := msg.Payload.(*h264.UserDataUnregistered); ok && len(ud.X264Version()) > 0 {
+			fmt.Printf(" x264 %s", ud.X264Version())
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("\t%s\n", vf.String())
+	return nil
+}
+
+// hevcPayload returns the decoder configuration record or the length-prefixed
+// NALUs carried by a legacy H.265 or an enhanced hvc1 video packet.
+func hevcPayload(video *flv.PacketVideo) (config, nalus []byte, ok bool) {
+	switch {
+	case video.IsExHeader && video.FourCC == flv.FOURCC_HVC1 && video.Tracks == nil:
+		switch video.PacketType {
+		case flv.FLV_PACKETTYPE_SEQUENCE_START:
+			return video.Data, nil, true
+		case flv.FLV_PACKETTYPE_CODED_FRAMES, flv.FLV_PACKETTYPE_CODED_FRAMESX:
+			return nil, video.Data, true
+		}
+	case !video.IsExHeader && video.CodecID == flv.FLV_CODECID_H265:
+		switch video.AVCPacketType {
+		case h264.AVC_PKT_SEQ_HEADER:
+			return video.Data, nil, true
+		case h264.AVC_PKT_NALU:
+			return nil, video.Data, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+func (p *Printer) printHEVC(config, nalus []byte) error {
+	var vf *h265.VideoFrameInfo
+	var err error
+	if config != nil {
+		vf, err = p.hevc.ParseConfig(config)
+	} else {
+		vf, err = p.hevc.ParseNalus(nalus)
+	}
+
+	if err == h265.ErrNoConfig {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, vps := range vf.VPS {
+		js, _ := json.Marshal(vps)
+		fmt.Printf("\tvps: \n%s\n", js)
+	}
+
+	for _, sps := range vf.SPS {
+		js, _ := json.Marshal(sps)
+		fmt.Printf("\tsps: \n%s\n", js)
+	}
+
+	for _, pps := range vf.PPS {
+		js, _ := json.Marshal(pps)
+		fmt.Printf("\tpps: \n%s\n", js)
+	}
+
+	fmt.Printf("\t%s\n", vf.String())
+	return nil
+}
+
+func (p *Printer) printAV1(video *flv.PacketVideo) error {
+	var vf *av1.VideoFrameInfo
+	var err error
+	switch video.PacketType {
+	case flv.FLV_PACKETTYPE_SEQUENCE_START:
+		vf, err = p.av1.ParseConfig(video.Data)
+	case flv.FLV_PACKETTYPE_CODED_FRAMES, flv.FLV_PACKETTYPE_CODED_FRAMESX:
+		vf, err = p.av1.ParseOBUs(video.Data)
+	default:
+		return nil
+	}
+
+	if errors.Is(err, av1.ErrNoSequence) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if vf.Sequence != nil {
+		js, _ := json.Marshal(vf.Sequence)
+		fmt.Printf("\tsequence header: %s\n%s\n", vf.Sequence.String(), js)
+	}
+
+	fmt.Printf("\t%s\n", vf.String())
+	return nil
+}
+
+func (p *Printer) printVP9(video *flv.PacketVideo) error {
+	var vf *vp9.VideoFrameInfo
+	var err error
+	switch video.PacketType {
+	case flv.FLV_PACKETTYPE_SEQUENCE_START:
+		vf, err = p.vp9.ParseConfig(video.Data)
+	case flv.FLV_PACKETTYPE_CODED_FRAMES, flv.FLV_PACKETTYPE_CODED_FRAMESX:
+		vf, err = p.vp9.ParseFrame(video.Data)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if vf.Config != nil {
+		js, _ := json.Marshal(vf.Config)
+		fmt.Printf("\tvpcC: %s\n%s\n", vf.Config.Codecs(), js)
+	}
+
+	fmt.Printf("\t%s\n", vf.String())
+	return nil
 }
@@ -4,5 +4,4 @@ go 1.15
 
 require (
 	github.com/stretchr/testify v1.4.0
-	github.com/torresjeff/rtmp v0.0.0-20210303201626-9aba915a956e
 )
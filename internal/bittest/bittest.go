@@ -0,0 +1,73 @@
+// Package bittest writes the bitstreams the codec tests decode.
+package bittest
+
+// Writer appends bits MSB first.
+type Writer struct {
+	data []byte
+	bits int
+}
+
+// U writes the n low bits of v: u(n) of H.264 and H.265, f(n) of AV1 and VP9.
+func (w *Writer) U(n, v int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte((v>>uint(i))&1) << uint(7-w.bits%8)
+		w.bits++
+	}
+}
+
+// UE writes ue(v), which is also the uvlc() of AV1.
+func (w *Writer) UE(v int) {
+	n := 0
+	for x := v + 1; x > 1; x >>= 1 {
+		n++
+	}
+	w.U(n, 0)
+	w.U(n+1, v+1)
+}
+
+// SE writes se(v).
+func (w *Writer) SE(v int) {
+	if v > 0 {
+		w.UE(2*v - 1)
+	} else {
+		w.UE(-2 * v)
+	}
+}
+
+// Bytes returns the data written, the last byte padded with zero bits.
+func (w *Writer) Bytes() []byte { return w.data }
+
+// Trailing adds the AV1 trailing_bits and returns the payload.
+func (w *Writer) Trailing() []byte {
+	w.U(1, 1)
+	for w.bits%8 != 0 {
+		w.U(1, 0)
+	}
+
+	return w.data
+}
+
+// NALU adds the rbsp_trailing_bits and returns the NALU with emulation
+// prevention bytes.
+func (w *Writer) NALU() []byte {
+	w.Trailing()
+
+	nalu, zeros := []byte{}, 0
+	for _, b := range w.data {
+		if zeros >= 2 && b <= 3 {
+			nalu, zeros = append(nalu, 3), 0
+		}
+
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		nalu = append(nalu, b)
+	}
+
+	return nalu
+}
@@ -2,24 +2,67 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+
 	"media-go/core"
 	"media-go/flow"
 )
 
 var (
-	source = flag.String("i", "", "input file")
-	action = flag.String("filter", "", "video|audio|meta")
+	source  = flag.String("i", "", "input file")
+	action  = flag.String("filter", "", "video|audio|meta")
+	extract = flag.String("extract", "", "audio|video|cc1-cc4: write the elementary stream or the captions (.srt, .vtt) to -o")
+	output  = flag.String("o", "", "output file for -extract")
+	fps     = flag.Float64("fps", 0, "frame rate of .h264 inputs, default from the SPS or 25")
 )
 
+// inject subcommand: media-go inject -i in.flv -o out.flv
+func inject(args []string) {
+	fs := flag.NewFlagSet("inject", flag.ExitOnError)
+	src := fs.String("i", "", "input file")
+	dst := fs.String("o", "", "output file")
+	fs.Parse(args)
+
+	if len(*src) == 0 || len(*dst) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err := flow.Inject(*src, *dst); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "inject" {
+		inject(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 	if len(*source) == 0 {
 		flag.Usage()
 		return
 	}
 
+	if len(*extract) > 0 {
+		if len(*output) == 0 {
+			flag.Usage()
+			os.Exit(2)
+		}
+
+		if err := flow.Extract(*source, *output, *extract); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	ctx := core.NewContext()
 	ctx.Source = *source
+	ctx.FrameRate = *fps
 	switch *action {
 	case "video":
 		ctx.Filter = core.Video
@@ -31,5 +74,9 @@ func main() {
 		ctx.Filter = core.MetaData
 	}
 
-	flow.Run(ctx)
+	ctx.SetPktCallback(flow.NewPrinter().PrintPkt)
+	if err := flow.Run(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,487 @@
+package annexb
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+
+	"media-go/codec/golomb"
+	"media-go/codec/h264"
+	"media-go/core"
+	"media-go/muxer/flv"
+)
+
+const readChunkSize = 4096
+
+// DefaultFrameRate is used when neither SetFrameRate nor the SPS VUI give
+// one.
+const DefaultFrameRate = 25.0
+
+// Demuxer reads a raw H.264 Annex B stream and returns the packets the FLV
+// demuxer would return for the same video: a header packet, an AVC sequence
+// header whenever the parameter sets change, then one packet per access unit
+// with 4 byte NALU lengths. Decoding timestamps are synthesized from the frame
+// rate. Presentation timestamps follow the picture order counts, delayed by
+// the reorder depth of the SPS, so a picture is returned once the pictures
+// that may be shown before it were read.
+type Demuxer struct {
+	r      io.Reader
+	chunk  []byte
+	buf    []byte // stream data not split into NALUs yet
+	scan   int    // buf is known to hold no start code before scan
+	eof    bool
+	err    error
+	ctx    *core.Context
+	header bool
+
+	frameRate float64 // user supplied, 0 to use the VUI
+	vuiRate   float64
+	clock     float64 // dts of the next access unit in ms
+
+	sps     map[int][]byte
+	pps     map[int][]byte
+	avc     *h264.Parser // picture order, frame info for FrameCb
+	changed bool         // parameter sets differ from the last sequence header
+
+	au      [][]byte // NALUs of the access unit being collected
+	auVCL   bool
+	auKey   bool
+	held    []*unit // decoding order, the pictures wait for their Pts
+	dpb     []*unit // pictures without Pts, in decoding order
+	reorder int     // reorder depth of the last picture's SPS
+	slots   []int   // dts of the pictures in dpb, the Pts to hand out
+	pending []*unit
+}
+
+// unit is a packet with the frame info parsed from it.
+type unit struct {
+	pkt   *flv.Packet
+	frame *h264.VideoFrameInfo // nil if the access unit could not be parsed
+	ready bool                 // Pts is set and the tag built
+}
+
+func NewDemuxer(r io.Reader) *Demuxer {
+	return &Demuxer{
+		r:     r,
+		chunk: make([]byte, readChunkSize),
+		sps:   make(map[int][]byte),
+		pps:   make(map[int][]byte),
+		avc:   h264.NewParser(),
+	}
+}
+
+// SetContext routes every packet through the context callbacks before it is
+// returned, packets dropped by a callback are skipped.
+func (d *Demuxer) SetContext(ctx *core.Context) { d.ctx = ctx }
+
+// SetFrameRate overrides the frame rate used for timestamps.
+func (d *Demuxer) SetFrameRate(fps float64) { d.frameRate = fps }
+
+func (d *Demuxer) rate() float64 {
+	switch {
+	case d.frameRate > 0:
+		return d.frameRate
+	case d.vuiRate > 0:
+		return d.vuiRate
+	}
+
+	return DefaultFrameRate
+}
+
+func (d *Demuxer) fill() error {
+	n, err := d.r.Read(d.chunk)
+	d.buf = append(d.buf, d.chunk[:n]...)
+
+	if err == io.EOF {
+		d.eof = true
+		return nil
+	}
+
+	return err
+}
+
+// nextNalu returns the next NALU without its start code, io.EOF at the end
+// of the stream. Data before the first start code is skipped.
+func (d *Demuxer) nextNalu() ([]byte, error) {
+	for {
+		pos, size := h264.FindStartCode(d.buf, 0)
+		if pos < 0 {
+			if d.eof {
+				d.buf = nil
+				return nil, io.EOF
+			}
+
+			// keep a possible partial start code
+			if len(d.buf) > 3 {
+				d.buf = d.buf[len(d.buf)-3:]
+			}
+		} else {
+			start := pos + size
+			from := start
+			if d.scan > from {
+				from = d.scan
+			}
+
+			next, _ := h264.FindStartCode(d.buf, from)
+			if next < 0 {
+				d.scan = len(d.buf) - 3
+			}
+
+			if next >= 0 || d.eof {
+				end := next
+				if end < 0 {
+					end = len(d.buf)
+				}
+
+				nalu := bytes.TrimRight(d.buf[start:end], "\x00")
+				nalu = append([]byte(nil), nalu...)
+				d.buf = d.buf[end:]
+				d.scan = 0
+				if len(nalu) == 0 {
+					continue
+				}
+				return nalu, nil
+			}
+		}
+
+		if err := d.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isVCL(typ int) bool {
+	return typ >= h264.NAL_SLICE && typ <= h264.NAL_IDR_SLICE
+}
+
+// startsAccessUnit tells whether nalu begins a new access unit once the
+// current one holds a picture, 7.4.1.2.3.
+func (d *Demuxer) startsAccessUnit(nalu []byte) bool {
+	if !d.auVCL {
+		return false
+	}
+
+	typ := int(nalu[0] & 0x1f)
+	switch {
+	case typ == h264.NAL_AUD || typ == h264.NAL_SPS || typ == h264.NAL_PPS || typ == h264.NAL_SEI:
+		return true
+	case typ >= 14 && typ <= 18:
+		return true
+	case isVCL(typ):
+		first, err := h264.FirstMbInSlice(nalu)
+		return err == nil && first == 0
+	}
+
+	return false
+}
+
+func readID(rbsp []byte) int {
+	id, err := golomb.ReadUE(core.NewBitStream(rbsp))
+	if err != nil {
+		return -1
+	}
+
+	return id
+}
+
+func (d *Demuxer) add(nalu []byte) {
+	switch typ := int(nalu[0] & 0x1f); {
+	case typ == h264.NAL_SPS:
+		sps, err := h264.DecodeSPS(nalu)
+		if err != nil {
+			break
+		}
+
+		if rate := sps.FrameRate(); rate > 0 {
+			d.vuiRate = rate
+		}
+
+		if !bytes.Equal(d.sps[sps.SPSId], nalu) {
+			d.sps[sps.SPSId] = nalu
+			d.changed = true
+		}
+	case typ == h264.NAL_PPS:
+		if len(nalu) < 2 {
+			break
+		}
+
+		// pic_parameter_set_id is 0..255, as DecodePPS checks
+		id := readID(nalu[1:])
+		if id >= 0 && id <= 255 && !bytes.Equal(d.pps[id], nalu) {
+			d.pps[id] = nalu
+			d.changed = true
+		}
+	case isVCL(typ):
+		d.auVCL = true
+		d.auKey = d.auKey || typ == h264.NAL_IDR_SLICE
+	}
+
+	d.au = append(d.au, nalu)
+}
+
+func sortedSets(sets map[int][]byte) [][]byte {
+	ids := make([]int, 0, len(sets))
+	for id := range sets {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	list := make([][]byte, 0, len(sets))
+	for _, id := range ids {
+		list = append(list, sets[id])
+	}
+
+	return list
+}
+
+// seal builds the tag of a packet whose Pts is set.
+func (u *unit) seal() error {
+	tag, err := flv.NewTag(u.pkt)
+	if err != nil {
+		return err
+	}
+
+	u.pkt.Tag = tag
+	u.ready = true
+	return nil
+}
+
+// reorderFrames is the number of pictures that may follow a picture in
+// decoding order and precede it in output order.
+func (d *Demuxer) reorderFrames(sh *h264.SliceHeader) int {
+	pps := d.avc.PPS(sh.PPSId)
+	sps := d.avc.SPS(pps.SPSId)
+	if sps.PicOrderCntType == 2 {
+		// output order is decoding order, 8.2.1.3
+		return 0
+	}
+
+	return sps.ReorderFrames()
+}
+
+// order adds a picture to the reorder buffer and gives presentation times to
+// the pictures that are known to be output next.
+func (d *Demuxer) order(u *unit) error {
+	ordered := u.frame != nil && u.frame.Slice != nil
+	if !ordered || u.frame.Slice.ResetsOrder() {
+		// no picture that comes later is shown before the ones buffered
+		if err := d.bump(0); err != nil {
+			return err
+		}
+	}
+
+	if ordered {
+		d.reorder = d.reorderFrames(u.frame.Slice)
+	}
+
+	d.dpb = append(d.dpb, u)
+	d.slots = append(d.slots, u.pkt.Dts)
+	if !ordered {
+		return d.bump(0)
+	}
+
+	return d.bump(d.reorder)
+}
+
+// bump outputs the pictures of the lowest order count until window remain,
+// C.4.5.3. The k-th picture output is presented the reorder depth in frames
+// after the k-th picture decoded.
+func (d *Demuxer) bump(window int) error {
+	delay := int(math.Round(float64(d.reorder) * 1000 / d.rate()))
+	for len(d.dpb) > window {
+		// a picture without order count is alone in the buffer
+		next := 0
+		for i := 1; i < len(d.dpb); i++ {
+			if d.dpb[i].frame.Slice.PicOrderCnt < d.dpb[next].frame.Slice.PicOrderCnt {
+				next = i
+			}
+		}
+
+		u := d.dpb[next]
+		d.dpb = append(d.dpb[:next], d.dpb[next+1:]...)
+		u.pkt.Pts = d.slots[0] + delay
+		d.slots = d.slots[1:]
+
+		video := u.pkt.Data.(*flv.PacketVideo)
+		video.Cts = u.pkt.Pts - u.pkt.Dts
+		if u.frame != nil {
+			u.frame.Cts = video.Cts
+		}
+
+		if err := u.seal(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// release hands the packets up to the first picture still waiting for its
+// Pts to readPacket.
+func (d *Demuxer) release() {
+	for len(d.held) > 0 && d.held[0].ready {
+		d.pending = append(d.pending, d.held[0])
+		d.held = d.held[1:]
+	}
+}
+
+// finish turns the collected access unit into packets.
+func (d *Demuxer) finish() error {
+	dts := int(math.Round(d.clock))
+
+	if d.changed && len(d.sps) > 0 && len(d.pps) > 0 {
+		d.changed = false
+		config, err := h264.NewAVCConfig(sortedSets(d.sps), sortedSets(d.pps))
+		if err != nil {
+			return err
+		}
+
+		record, err := config.Bytes()
+		if err != nil {
+			return err
+		}
+
+		frame, _ := d.avc.ParseConfig(record)
+		u := &unit{pkt: &flv.Packet{Type: flv.PKT_VIDEO, Dts: dts, Pts: dts, Data: &flv.PacketVideo{
+			FrameType:     flv.FLV_FRAME_KEY,
+			CodecID:       flv.FLV_CODECID_H264,
+			AVCPacketType: h264.AVC_PKT_SEQ_HEADER,
+			Data:          record,
+		}}, frame: frame}
+
+		if err := u.seal(); err != nil {
+			return err
+		}
+		d.held = append(d.held, u)
+	}
+
+	if d.auVCL {
+		video := &flv.PacketVideo{
+			FrameType:     flv.FLV_FRAME_INTER,
+			CodecID:       flv.FLV_CODECID_H264,
+			AVCPacketType: h264.AVC_PKT_NALU,
+		}
+
+		if d.auKey {
+			video.FrameType = flv.FLV_FRAME_KEY
+		}
+
+		for _, nalu := range d.au {
+			n := len(nalu)
+			video.Data = append(video.Data, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+			video.Data = append(video.Data, nalu...)
+		}
+
+		// a picture that cannot be parsed keeps its decoding order
+		frame, _ := d.avc.ParseNalus(video.Data)
+		u := &unit{pkt: &flv.Packet{Type: flv.PKT_VIDEO, Dts: dts, Data: video}, frame: frame}
+		d.held = append(d.held, u)
+		if err := d.order(u); err != nil {
+			return err
+		}
+		d.clock += 1000 / d.rate()
+	}
+
+	d.au, d.auVCL, d.auKey = nil, false, false
+	d.release()
+	return nil
+}
+
+func (d *Demuxer) readPacket() (*unit, error) {
+	for {
+		if d.ctx != nil && d.ctx.Done {
+			return nil, io.EOF
+		}
+
+		if len(d.pending) > 0 {
+			u := d.pending[0]
+			d.pending = d.pending[1:]
+			return u, nil
+		}
+
+		if d.err != nil {
+			return nil, d.err
+		}
+
+		if !d.header {
+			d.header = true
+			return &unit{pkt: &flv.Packet{Type: flv.PKT_HEADER, Data: flv.NewHeader(false, true)}}, nil
+		}
+
+		nalu, err := d.nextNalu()
+		if err == io.EOF {
+			// the last access unit ends with the stream
+			if err = d.finish(); err == nil {
+				err = d.bump(0)
+				d.release()
+			}
+			if err == nil {
+				err = io.EOF
+			}
+		}
+
+		if err != nil {
+			d.err = err
+			continue
+		}
+
+		if d.startsAccessUnit(nalu) {
+			if err := d.finish(); err != nil {
+				d.err = err
+			}
+		}
+
+		d.add(nalu)
+	}
+}
+
+func (d *Demuxer) dispatch(u *unit) (bool, error) {
+	ctx := d.ctx
+	if ctx == nil {
+		return true, nil
+	}
+
+	if ctx.PktCb != nil {
+		typ := core.Video
+		if u.pkt.Type == flv.PKT_HEADER {
+			typ = core.MetaData
+		}
+
+		keep, err := ctx.Control(ctx.PktCb(ctx, &core.Packet{Type: typ, Data: u.pkt}))
+		if !keep || err != nil {
+			return false, err
+		}
+	}
+
+	if ctx.FrameCb != nil && u.frame != nil && u.frame.NaluInfo != nil {
+		return ctx.Control(ctx.FrameCb(ctx, u.frame))
+	}
+
+	return true, nil
+}
+
+// ReadPacket returns the header packet first, then the video packets. Once
+// a callback stopped the stream it returns io.EOF.
+func (d *Demuxer) ReadPacket() (*flv.Packet, error) {
+	for {
+		u, err := d.readPacket()
+		if err != nil {
+			return nil, err
+		}
+
+		keep, err := d.dispatch(u)
+		if err != nil {
+			d.err = err
+			return nil, err
+		}
+
+		if d.ctx != nil && d.ctx.Done {
+			d.err = io.EOF
+		}
+
+		if keep {
+			return u.pkt, nil
+		}
+	}
+}
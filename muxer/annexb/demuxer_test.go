@@ -0,0 +1,222 @@
+package annexb
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"media-go/codec/h264"
+	"media-go/core"
+	"media-go/muxer/flv"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 320x240 baseline, VUI timing at 30 fps
+var (
+	testSPS = []byte{0x67, 0x42, 0xc0, 0x1e, 0xda, 0x05, 0x07, 0xe8, 0x40, 0x00, 0x00, 0x03, 0x00, 0x40, 0x00, 0x00, 0x0f, 0x21}
+	testPPS = []byte{0x68, 0xce, 0x38, 0x80}
+)
+
+func buildStream() []byte {
+	var s []byte
+	add := func(sc []byte, nalu ...byte) {
+		s = append(s, sc...)
+		s = append(s, nalu...)
+	}
+
+	long, short := []byte{0, 0, 0, 1}, []byte{0, 0, 1}
+	add(long, testSPS...)
+	add(short, testPPS...)
+	add(short, 0x65, 0x88, 0x84)
+	add(short, 0x65, 0x30, 0x01) // second slice, first_mb_in_slice 5
+	add(long, 0x09, 0xf0)
+	add(short, 0x41, 0x9a, 0x02, 0x00) // trailing zero
+	add(long, 0x41, 0x9a, 0x03)
+	return s
+}
+
+func readAll(t *testing.T, d *Demuxer) []*flv.Packet {
+	var pkts []*flv.Packet
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			return pkts
+		}
+
+		assert.Nil(t, err)
+		if err != nil {
+			return pkts
+		}
+		pkts = append(pkts, pkt)
+	}
+}
+
+func TestDemuxer(t *testing.T) {
+	d := NewDemuxer(iotest.OneByteReader(bytes.NewReader(buildStream())))
+	pkts := readAll(t, d)
+	assert.Equal(t, 5, len(pkts))
+
+	assert.Equal(t, flv.PKT_HEADER, pkts[0].Type)
+	assert.True(t, pkts[0].Data.(*flv.FLVHeader).HasVideo())
+
+	seq := pkts[1].Data.(*flv.PacketVideo)
+	assert.True(t, seq.IsSeqHeader())
+	assert.Equal(t, append([]byte{0x17, 0, 0, 0, 0}, seq.Data...), pkts[1].Tag.Data)
+
+	key := pkts[2].Data.(*flv.PacketVideo)
+	assert.True(t, key.IsKeyFrame())
+	assert.Equal(t, 0, pkts[2].Dts)
+	assert.Equal(t, 4*4+len(testSPS)+len(testPPS)+3+3, len(key.Data))
+
+	inter := pkts[3].Data.(*flv.PacketVideo)
+	assert.False(t, inter.IsKeyFrame())
+	assert.Equal(t, []byte{0, 0, 0, 2, 0x09, 0xf0, 0, 0, 0, 3, 0x41, 0x9a, 0x02}, inter.Data)
+	assert.Equal(t, 33, pkts[3].Dts)
+	assert.Equal(t, 67, pkts[4].Dts)
+
+	// the FLV muxer takes the packets as they are
+	var out bytes.Buffer
+	m := flv.NewMuxer(&out)
+	for _, pkt := range pkts {
+		assert.Nil(t, m.WritePacket(pkt))
+	}
+
+	back := flv.NewDemuxer(&out)
+	for _, pkt := range pkts {
+		got, err := back.ReadPacket()
+		assert.Nil(t, err)
+		assert.Equal(t, pkt.Dts, got.Dts)
+	}
+}
+
+func TestDemuxerFrameRate(t *testing.T) {
+	d := NewDemuxer(bytes.NewReader(buildStream()))
+	d.SetFrameRate(10)
+	pkts := readAll(t, d)
+	assert.Equal(t, 200, pkts[4].Dts)
+
+	// a new rate sets the duration of the frames that follow
+	d = NewDemuxer(bytes.NewReader(buildStream()))
+	d.SetFrameRate(10)
+	for i := 0; i < 3; i++ {
+		_, err := d.ReadPacket()
+		assert.Nil(t, err)
+	}
+	d.SetFrameRate(20)
+	pkts = readAll(t, d)
+	assert.Equal(t, 2, len(pkts))
+	assert.Equal(t, 100, pkts[0].Dts)
+	assert.Equal(t, 150, pkts[1].Dts)
+
+	// no VUI timing and no user rate
+	stream := append([]byte{0, 0, 1}, 0x65, 0x88)
+	stream = append(stream, 0, 0, 1, 0x41, 0x9a)
+	pkts = readAll(t, NewDemuxer(bytes.NewReader(stream)))
+	assert.Equal(t, 3, len(pkts))
+	assert.Equal(t, 40, pkts[2].Dts)
+}
+
+func TestDemuxerReorder(t *testing.T) {
+	// main profile at 25 fps, one reorder frame, pic_order_cnt_lsb of 4 bits
+	sps := []byte{0x67, 0x4d, 0x40, 0x1e, 0xf6, 0x0a, 0x0f, 0xd0, 0x80, 0x00, 0x00, 0x03, 0x00, 0x80,
+		0x00, 0x00, 0x19, 0x47, 0x84, 0x42, 0x29, 0xc0}
+
+	// I0 P4 B2 P8 B6 by pic_order_cnt_lsb
+	var s []byte
+	for _, nalu := range [][]byte{
+		sps,
+		testPPS,
+		{0x65, 0x88, 0x84, 0x0a, 0xd4},
+		{0x41, 0x9a, 0x28, 0x2b, 0x50},
+		{0x01, 0x9e, 0x45, 0x15, 0xa8},
+		{0x41, 0x9a, 0x50, 0x2b, 0x50},
+		{0x01, 0x9e, 0x6d, 0x15, 0xa8},
+	} {
+		s = append(s, 0, 0, 0, 1)
+		s = append(s, nalu...)
+	}
+
+	ctx := core.NewContext()
+	var frames []*h264.VideoFrameInfo
+	ctx.SetFrameCallback(func(ctx *core.Context, frame interface{}) interface{} {
+		frames = append(frames, frame.(*h264.VideoFrameInfo))
+		return nil
+	})
+
+	d := NewDemuxer(bytes.NewReader(s))
+	d.SetContext(ctx)
+	pkts := readAll(t, d)
+	if !assert.Equal(t, 7, len(pkts)) {
+		return
+	}
+
+	var dts, pts, cts []int
+	for _, pkt := range pkts[2:] {
+		dts = append(dts, pkt.Dts)
+		pts = append(pts, pkt.Pts)
+		cts = append(cts, pkt.Data.(*flv.PacketVideo).Cts)
+	}
+	assert.Equal(t, []int{0, 40, 80, 120, 160}, dts)
+	assert.Equal(t, []int{40, 120, 80, 200, 160}, pts)
+	assert.Equal(t, []int{40, 80, 0, 80, 0}, cts)
+	assert.Equal(t, 5, len(frames))
+	assert.Equal(t, 80, frames[1].Cts)
+
+	// the composition time goes into the tags
+	var out bytes.Buffer
+	m := flv.NewMuxer(&out)
+	for _, pkt := range pkts {
+		assert.Nil(t, m.WritePacket(pkt))
+	}
+
+	back := flv.NewDemuxer(&out)
+	for _, pkt := range pkts {
+		got, err := back.ReadPacket()
+		assert.Nil(t, err)
+		assert.Equal(t, pkt.Pts, got.Pts)
+	}
+}
+
+func TestDemuxerCallbacks(t *testing.T) {
+	ctx := core.NewContext()
+	count := 0
+	ctx.SetPktCallback(func(ctx *core.Context, pkt *core.Packet) interface{} {
+		count++
+		if pkt.Type != core.Video {
+			return core.CbDrop
+		}
+		if count == 3 {
+			return core.CbStop
+		}
+		return nil
+	})
+
+	d := NewDemuxer(bytes.NewReader(buildStream()))
+	d.SetContext(ctx)
+	pkts := readAll(t, d)
+	assert.Equal(t, 2, len(pkts))
+	assert.True(t, ctx.Done)
+}
+
+func TestDemuxerPPSId(t *testing.T) {
+	// a PPS with an id of about 2^20 is dropped, the sets are kept in id order
+	var s []byte
+	for _, nalu := range [][]byte{
+		testSPS,
+		{0x68, 0x00, 0x00, 0x1f, 0xff, 0xff, 0x80},
+		{0x68, 0x5e, 0x38, 0x80}, // pps_id 1
+		testPPS,
+		{0x65, 0x88, 0x84},
+	} {
+		s = append(s, 0, 0, 0, 1)
+		s = append(s, nalu...)
+	}
+
+	pkts := readAll(t, NewDemuxer(bytes.NewReader(s)))
+	assert.Equal(t, 3, len(pkts))
+	config, err := h264.ParseAVCConfig(pkts[1].Data.(*flv.PacketVideo).Data)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{testPPS, {0x68, 0x5e, 0x38, 0x80}}, config.PPS)
+}
@@ -3,13 +3,14 @@ package flv
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"strconv"
 
+	"media-go/codec/aac"
+	"media-go/codec/amf"
 	"media-go/codec/h264"
 	"media-go/core"
-
-	"github.com/torresjeff/rtmp/amf/amf0"
 )
 
 const (
@@ -37,6 +38,7 @@ const (
 	FLV_CODECID_NELLYMOSER            = 6
 	FLV_CODECID_PCM_ALAW              = 7
 	FLV_CODECID_PCM_MULAW             = 8
+	FLV_CODECID_EX_HEADER             = 9 // Enhanced RTMP audio, FourCC follows
 	FLV_CODECID_AAC                   = 10
 	FLV_CODECID_SPEEX                 = 11
 )
@@ -51,6 +53,7 @@ var AudioCodec = map[int]string{
 	FLV_CODECID_NELLYMOSER:            "nellymoser",
 	FLV_CODECID_PCM_ALAW:              "pcm_alaw",
 	FLV_CODECID_PCM_MULAW:             "pcm_mulaw",
+	FLV_CODECID_EX_HEADER:             "ex_header",
 	FLV_CODECID_AAC:                   "aac",
 	FLV_CODECID_SPEEX:                 "speex",
 }
@@ -79,7 +82,7 @@ const (
 	FLV_CODECID_H264     = 7
 	FLV_CODECID_REALH263 = 8
 	FLV_CODECID_MPEG4    = 9
-	FLV_CODECID_H265     = 12
+	FLV_CODECID_H265     = 12 // non-standard, predates Enhanced RTMP hvc1
 )
 
 var VideoFrameType = map[int]string{
@@ -102,12 +105,35 @@ var VideoCodecMap = map[int]string{
 	FLV_CODECID_H265:     "h265",
 }
 
+var (
+	ErrInvalidHeader = errors.New("flv: invalid header")
+	ErrInvalidTag    = errors.New("flv: invalid tag")
+)
+
+// TagError reports a tag whose body could not be decoded.
+type TagError struct {
+	Offset int64
+	Type   int8
+	Err    error
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf("flv: tag 0x%02x at offset %d: %v", e.Type, e.Offset, e.Err)
+}
+
+func (e *TagError) Unwrap() error { return e.Err }
+
 type FlvParser struct {
 	Status    int
 	TagStatus int
 	buffer    bytes.Buffer
 	flv       *FLV
 	ctx       *core.Context
+	offset    int64 // stream offset of the first byte in buffer
+	skip      int
+	tags      []*FLVTag
+	avc       *h264.Parser
+	aacConfig *aac.AudioSpecificConfig
 }
 
 type FLVHeader struct {
@@ -117,29 +143,16 @@ type FLVHeader struct {
 	HeaderSize int32
 }
 
-type PacketType int
-
-type Packet struct {
-	Type PacketType
-	Data interface{}
-}
-
-type PacketMetaData map[string]interface{}
-
-type PacketVideo struct {
-	Type  int
-	Codec int
-}
+func (h *FLVHeader) HasAudio() bool { return h.Flags&0x04 != 0 }
+func (h *FLVHeader) HasVideo() bool { return h.Flags&0x01 != 0 }
 
-type PacketAudio struct {
-}
-
-func (h *FLVHeader) echo() {
-	fmt.Println("Header:")
-	fmt.Printf("\tmagic:		%v%s%s\n", string(h.Magic[0]), string(h.Magic[1]), string(h.Magic[2]))
-	fmt.Printf("\tversion:  	%d\n", int(h.Version))
-	fmt.Printf("\tflags:		%x\n", h.Flags)
-	fmt.Printf("\theadersize:	%d\n", h.HeaderSize)
+func (h *FLVHeader) String() string {
+	str := "Header:\n"
+	str += fmt.Sprintf("\tmagic:		%s\n", string(h.Magic[:]))
+	str += fmt.Sprintf("\tversion:  	%d\n", int(h.Version))
+	str += fmt.Sprintf("\tflags:		%x\n", h.Flags)
+	str += fmt.Sprintf("\theadersize:	%d\n", h.HeaderSize)
+	return str
 }
 
 type TagHeader struct {
@@ -150,6 +163,11 @@ type TagHeader struct {
 	StreamId    int
 }
 
+// Dts returns the full 32-bit tag timestamp in milliseconds.
+func (h *TagHeader) Dts() int {
+	return h.Timestamp | (h.TimestampEx << 24)
+}
+
 func (h *TagHeader) String() string {
 	types := ""
 	switch h.Type {
@@ -164,13 +182,14 @@ func (h *TagHeader) String() string {
 	}
 
 	str := fmt.Sprintf("%10s(0x%02x)|data size: %10d|dts: %10d",
-		types, h.Type, h.DataSize, h.Timestamp|(h.TimestampEx<<24))
+		types, h.Type, h.DataSize, h.Dts())
 	return str
 }
 
 type FLVTag struct {
 	Header TagHeader
 	Data   []byte
+	Offset int64 // stream offset of the tag header
 	String string
 }
 
@@ -184,39 +203,122 @@ type FLV struct {
 	Header *FLVHeader
 	Body   *FLVBody
 
-	Parser FlvParser
+	Parser     FlvParser
+	headerSent bool
 }
 
 func NewFLV(ctx *core.Context) *FLV {
 	flv := &FLV{Header: &FLVHeader{},
 		Body:   nil,
-		Parser: FlvParser{ctx: ctx}}
+		Ctx:    ctx,
+		Parser: FlvParser{ctx: ctx, avc: h264.NewParser()}}
 	flv.Parser.flv = flv
 	return flv
 }
 
-func (flv *FLV) Decode(data []byte) {
-	flv.Parser.Decode(data)
+// Decode feeds data into the parser. Without a context completed tags are
+// queued and can be fetched with NextTag, otherwise they are handed to the
+// context callbacks as soon as they are complete.
+func (flv *FLV) Decode(data []byte) error {
+	if err := flv.Parser.Decode(data); err != nil {
+		return err
+	}
+
+	if flv.Ctx == nil {
+		return nil
+	}
+
+	if flv.Parser.Status == PBODY && !flv.headerSent && !flv.Ctx.Done {
+		flv.headerSent = true
+		if _, err := flv.Parser.dispatch(&Packet{Type: PKT_HEADER, Data: flv.Header}); err != nil {
+			return err
+		}
+	}
+
+	for tag := flv.NextTag(); tag != nil && !flv.Ctx.Done; tag = flv.NextTag() {
+		pkt, err := flv.Parser.readPacket(tag)
+		if err != nil {
+			return err
+		}
+
+		if _, err := flv.Parser.dispatch(pkt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (flv *FLV) NextTag() *FLVTag {
+	return flv.Parser.NextTag()
 }
 
-func (fp *FlvParser) Decode(data []byte) {
+func (fp *FlvParser) Decode(data []byte) error {
 	fp.buffer.Write(data)
 
-	if fp.Status == PHEADER {
-		fp.parseHeader()
+	for {
+		var ok bool
+		var err error
+
+		switch {
+		case fp.Status == PHEADER:
+			ok, err = fp.parseHeader()
+		case fp.TagStatus == PTagHeader:
+			ok, err = fp.parseTagHeader()
+		default:
+			ok, err = fp.parseTagBody()
+		}
+
+		if err != nil || !ok {
+			return err
+		}
 	}
+}
 
-	if fp.Status == PBODY {
-		fp.parseBody()
+// NextTag pops the oldest completed tag, nil if none is pending.
+func (fp *FlvParser) NextTag() *FLVTag {
+	if len(fp.tags) == 0 {
+		return nil
 	}
+
+	tag := fp.tags[0]
+	fp.tags[0] = nil
+	fp.tags = fp.tags[1:]
+	return tag
+}
+
+// Buffered returns the number of bytes received but not yet consumed.
+func (fp *FlvParser) Buffered() int { return fp.buffer.Len() }
+
+// Offset returns the stream offset of the next unconsumed byte.
+func (fp *FlvParser) Offset() int64 { return fp.offset }
+
+// reset drops buffered data and expects the PreviousTagSize of a tag at
+// stream offset offset, used after the input was repositioned.
+func (fp *FlvParser) reset(offset int64) {
+	fp.buffer.Reset()
+	fp.offset = offset
+	fp.tags = nil
+	fp.skip = 0
+	fp.Status = PBODY
+	fp.TagStatus = PTagHeader
+}
+
+func (fp *FlvParser) next(n int) []byte {
+	fp.offset += int64(n)
+	return fp.buffer.Next(n)
 }
 
-func (fp *FlvParser) parseHeader() {
+func (fp *FlvParser) parseHeader() (bool, error) {
 	if fp.buffer.Len() < HeaderSize {
-		return
+		return false, nil
+	}
+
+	header := fp.next(HeaderSize)
+	if header[0] != 'F' || header[1] != 'L' || header[2] != 'V' {
+		return false, ErrInvalidHeader
 	}
 
-	header := fp.buffer.Next(HeaderSize)
 	var i int
 	for ; i < 3; i++ {
 		fp.flv.Header.Magic[i] = header[i]
@@ -229,20 +331,14 @@ func (fp *FlvParser) parseHeader() {
 	i++
 
 	fp.flv.Header.HeaderSize = int32(binary.BigEndian.Uint32(header[i:]))
+	if fp.flv.Header.HeaderSize < HeaderSize {
+		return false, ErrInvalidHeader
+	}
 
-	fp.flv.Header.echo()
+	fp.skip = int(fp.flv.Header.HeaderSize) - HeaderSize
 	fp.Status = PBODY
 	fp.TagStatus = PTagHeader
-}
-
-func (fp *FlvParser) parseBody() {
-	if fp.TagStatus == PTagHeader {
-		fp.parseTagHeader()
-	}
-
-	if fp.TagStatus == PTagBody {
-		fp.parseTagBody()
-	}
+	return true, nil
 }
 
 func bytesToInt64(bs []byte) uint64 {
@@ -254,154 +350,235 @@ func bytesToInt64(bs []byte) uint64 {
 	return x
 }
 
-func (fp *FlvParser) parseTagHeader() {
+func (fp *FlvParser) parseTagHeader() (bool, error) {
+	if fp.skip > 0 {
+		n := fp.skip
+		if n > fp.buffer.Len() {
+			n = fp.buffer.Len()
+		}
+
+		fp.next(n)
+		fp.skip -= n
+		if fp.skip > 0 {
+			return false, nil
+		}
+	}
+
 	if fp.buffer.Len() < TagHeaderSize+4 {
-		return
+		return false, nil
 	}
 
 	body := &FLVBody{}
 
-	body.PreviousTagSize = uint32(bytesToInt64(fp.buffer.Next(4)))
+	body.PreviousTagSize = uint32(bytesToInt64(fp.next(4)))
 
-	body.Tag.Header.Type = int8(bytesToInt64(fp.buffer.Next(1)))
-	body.Tag.Header.DataSize = int(bytesToInt64(fp.buffer.Next(3)))
-	body.Tag.Header.Timestamp = int(bytesToInt64(fp.buffer.Next(3)))
-	body.Tag.Header.TimestampEx = int(bytesToInt64(fp.buffer.Next(1)))
-	body.Tag.Header.StreamId = int(bytesToInt64(fp.buffer.Next(3)))
+	body.Tag.Offset = fp.offset
+	body.Tag.Header.Type = int8(bytesToInt64(fp.next(1)))
+	body.Tag.Header.DataSize = int(bytesToInt64(fp.next(3)))
+	body.Tag.Header.Timestamp = int(bytesToInt64(fp.next(3)))
+	body.Tag.Header.TimestampEx = int(bytesToInt64(fp.next(1)))
+	body.Tag.Header.StreamId = int(bytesToInt64(fp.next(3)))
 
 	fp.flv.Body = body
 	fp.TagStatus = PTagBody
-
-	// fmt.Printf("Tag: %s\n", fp.flv.Body.Tag.Header.String())
+	return true, nil
 }
 
-func (fp *FlvParser) parseTagBody() {
+func (fp *FlvParser) parseTagBody() (bool, error) {
 	if fp.buffer.Len() < fp.flv.Body.Tag.Header.DataSize {
-		return
+		return false, nil
 	}
 
-	fp.flv.Body.Tag.Data = fp.buffer.Next(fp.flv.Body.Tag.Header.DataSize)
+	// the buffer may be reused by the next write, keep a copy
+	data := fp.next(fp.flv.Body.Tag.Header.DataSize)
+	fp.flv.Body.Tag.Data = append([]byte(nil), data...)
 	fp.TagStatus = PTagHeader
 
-	fp.readPacket()
+	tag := fp.flv.Body.Tag
+	fp.tags = append(fp.tags, &tag)
+	return true, nil
 }
 
-func (fp *FlvParser) readPacket() {
-	if fp.flv.Body == nil {
-		return
-	}
+// readPacket decodes the body of a completed tag into a typed packet.
+func (fp *FlvParser) readPacket(tag *FLVTag) (*Packet, error) {
+	pkt := &Packet{Dts: tag.Header.Dts(), Tag: tag}
+	pkt.Pts = pkt.Dts
 
-	switch fp.flv.Body.Tag.Header.Type {
+	var err error
+	switch tag.Header.Type & 0x1f {
 	case MetaData:
-		meta := fp.readMetadata()
-		if fp.ctx.Filter == core.MetaData {
-			fp.ctx.Done = true
-			fmt.Print(meta)
-		}
+		pkt.Type = PKT_SCRIPT
+		pkt.Data, err = readScript(tag.Data)
 	case Video:
-		videoFrame := fp.readVideo()
-		if fp.ctx.Filter == core.Video {
-			fmt.Println(videoFrame.String())
+		var video *PacketVideo
+		pkt.Type = PKT_VIDEO
+		video, err = readVideo(tag.Data)
+		if err == nil {
+			pkt.Pts = pkt.Dts + video.Cts
 		}
+		pkt.Data = video
 	case Audio:
-		fp.readAudio()
+		var audio *PacketAudio
+		pkt.Type = PKT_AUDIO
+		audio, err = readAudio(tag.Data)
+		if err == nil {
+			fp.trackAAC(audio)
+		}
+		pkt.Data = audio
+	default:
+		pkt.Type = PKT_UNKNOWN
+		pkt.Data = tag.Data
 	}
 
-	// fmt.Println()
+	if err != nil {
+		return nil, &TagError{Offset: tag.Offset, Type: tag.Header.Type, Err: err}
+	}
+
+	return pkt, nil
 }
 
-func (fp *FlvParser) readMetadata() string {
-	packet := fp.flv.Body.Tag.Data
-	pos := 0
+// trackAAC remembers the last AAC configuration and describes raw frames
+// with it.
+func (fp *FlvParser) trackAAC(audio *PacketAudio) {
+	if audio.AACConfig != nil {
+		fp.aacConfig = audio.AACConfig
+	} else if audio.SoundFormat == FLV_CODECID_AAC && audio.AACPacketType == 1 && fp.aacConfig != nil {
+		audio.AACFrame = fp.aacConfig.Frame(audio.Data)
+	}
+}
 
-	var meta string
+// dispatch hands a packet to the context callbacks. It reports whether the
+// packet should be kept, a CbStop result marks the context done.
+func (fp *FlvParser) dispatch(pkt *Packet) (bool, error) {
+	ctx := fp.ctx
+	if ctx == nil {
+		return true, nil
+	}
 
-	for {
-		content, err := amf0.Decode(packet[pos:])
-		if err != nil {
-			panic(err)
-		}
+	// a picture the parser cannot decode gets no frame callback, the packet
+	// is still delivered and demuxing goes on
+	var frame *h264.VideoFrameInfo
+	if video, ok := pkt.Data.(*PacketVideo); ok && ctx.FrameCb != nil &&
+		!video.IsExHeader && video.CodecID == FLV_CODECID_H264 {
+		frame, _ = fp.avc.Parse(pkt.Tag.Data[1:])
+	}
 
-		pos += int(amf0.Size(content))
-		switch content.(type) {
-		case amf0.ECMAArray:
-			for key, value := range content.(amf0.ECMAArray) {
-				meta += fmt.Sprintf("\t\t%-20s:\t%v\n", key, value)
-			}
+	if ctx.PktCb != nil {
+		var typ core.PktType
+		switch pkt.Type {
+		case PKT_VIDEO:
+			typ = core.Video
+		case PKT_AUDIO:
+			typ = core.Audio
+		case PKT_HEADER, PKT_SCRIPT:
+			typ = core.MetaData
 		default:
-			meta += fmt.Sprintf("\t%v\n", content)
+			return true, nil
 		}
 
-		if pos >= len(packet) {
-			break
+		keep, err := fp.control(ctx.PktCb(ctx, &core.Packet{Type: typ, Data: pkt}))
+		if !keep || err != nil {
+			return false, err
 		}
 	}
 
-	return meta
+	if ctx.FrameCb != nil && frame != nil && frame.NaluInfo != nil {
+		return fp.control(ctx.FrameCb(ctx, frame))
+	}
+
+	return true, nil
 }
 
-type VideoFrame struct {
-	TypeInt  int
-	Type     string
-	CodecInt int
-	Codec    string
-	Frame    interface{}
+// control interprets a callback result, see core.CbResult.
+func (fp *FlvParser) control(rc interface{}) (bool, error) {
+	return fp.ctx.Control(rc)
 }
 
-func (vf *VideoFrame) String() string {
-	var str string
-	str = fmt.Sprintf("type: (%d|%8s) codec: (%d|%s)", vf.TypeInt, vf.Type, vf.CodecInt, vf.Codec)
+func readScript(packet []byte) (*PacketScript, error) {
+	pkt := &PacketScript{}
+	d := amf.NewDecoder(packet)
 
-	if vf.Frame != nil {
-		switch vf.Frame.(type) {
-		case h264.VideoFrameInfo:
-			str += fmt.Sprintf(" h264: (%s)", vf.Frame.(*h264.VideoFrameInfo).String())
+	for d.Len() > 0 {
+		content, err := d.Decode()
+		if err != nil {
+			return nil, err
 		}
+
+		if name, ok := content.(string); ok && len(pkt.Values) == 0 && len(pkt.Name) == 0 {
+			pkt.Name = name
+			continue
+		}
+
+		pkt.Values = append(pkt.Values, content)
 	}
 
-	return str
+	return pkt, nil
 }
 
-func (fp *FlvParser) readVideo() *VideoFrame {
-	packet := fp.flv.Body.Tag.Data
-	flag := int(packet[0])
+func readVideo(packet []byte) (*PacketVideo, error) {
+	if len(packet) < 1 {
+		return nil, ErrInvalidTag
+	}
 
-	vf := &VideoFrame{
-		TypeInt:  flag >> 4,
-		Type:     VideoFrameType[flag>>4],
-		CodecInt: flag & 0x0f,
-		Codec:    VideoCodecMap[flag&0x0f],
+	if packet[0]&FLV_VIDEO_EX_HEADER != 0 {
+		return readExVideo(packet)
 	}
 
-	if flag&0x0f == FLV_CODECID_H264 {
-		buffer := bytes.NewBuffer(packet[1:])
-		vf.Frame = h264.Parse(buffer)
+	flag := int(packet[0])
+	video := &PacketVideo{
+		FrameType: flag >> 4,
+		CodecID:   flag & 0x0f,
+		Data:      packet[1:],
 	}
 
-	return vf
-}
+	if video.CodecID == FLV_CODECID_H264 || video.CodecID == FLV_CODECID_H265 {
+		if len(packet) < 5 {
+			return nil, ErrInvalidTag
+		}
 
-func (fp *FlvParser) readAudio() string {
-	packet := fp.flv.Body.Tag.Data
-	flag := int(packet[0])
+		video.AVCPacketType = int(packet[1])
+		// SI24 composition time offset
+		video.Cts = int(int32(uint32(bytesToInt64(packet[2:5]))<<8) >> 8)
+		video.Data = packet[5:]
+	}
+
+	return video, nil
+}
 
-	codec := AudioCodec[flag>>4]
-	sampleRate := AudioSampleRate[(flag&0x0f)>>2]
+func readAudio(packet []byte) (*PacketAudio, error) {
+	if len(packet) < 1 {
+		return nil, ErrInvalidTag
+	}
 
-	var accuracy string
-	var audioType string
+	if int(packet[0]>>4) == FLV_CODECID_EX_HEADER {
+		return readExAudio(packet)
+	}
 
-	if flag&0x02 == 1 {
-		accuracy = "8bits"
-	} else {
-		accuracy = "16bits"
+	flag := int(packet[0])
+	audio := &PacketAudio{
+		SoundFormat: flag >> 4,
+		SoundRate:   (flag >> 2) & 0x03,
+		SoundSize:   (flag >> 1) & 0x01,
+		SoundType:   flag & 0x01,
+		Data:        packet[1:],
 	}
 
-	if flag&0x01 == 1 {
-		audioType = "sndMono"
-	} else {
-		audioType = "sndStereo"
+	if audio.SoundFormat == FLV_CODECID_AAC {
+		if len(packet) < 2 {
+			return nil, ErrInvalidTag
+		}
+
+		audio.AACPacketType = int(packet[1])
+		audio.Data = packet[2:]
+
+		if audio.AACPacketType == 0 && len(audio.Data) > 0 {
+			config, err := aac.ParseAudioSpecificConfig(audio.Data)
+			if err != nil {
+				return nil, err
+			}
+			audio.AACConfig = config
+		}
 	}
 
-	return fmt.Sprintf("|%s-%s-%s-%s", codec, sampleRate, accuracy, audioType)
+	return audio, nil
 }
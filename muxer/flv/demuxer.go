@@ -0,0 +1,188 @@
+package flv
+
+import (
+	"io"
+
+	"media-go/core"
+)
+
+const readChunkSize = 4096
+
+// Demuxer pulls FLV tags out of an io.Reader, using FlvParser as the
+// underlying state machine.
+type Demuxer struct {
+	flv    *FLV
+	r      io.Reader
+	buf    []byte
+	header bool // header packet already returned by ReadPacket
+	eof    bool
+	err    error
+	meta   *Metadata
+
+	index   []IndexEntry // key frames, built on the first seek without metadata
+	seqs    []seqHeader  // known sequence header positions, sorted
+	scanned int64        // every sequence header before this position is in seqs
+	pending []*Packet    // sequence headers to re-emit after a seek
+}
+
+func NewDemuxer(r io.Reader) *Demuxer {
+	return &Demuxer{
+		flv: NewFLV(nil),
+		r:   r,
+		buf: make([]byte, readChunkSize),
+	}
+}
+
+// SetContext routes every packet through the context callbacks before it is
+// returned, packets dropped by a callback are skipped.
+func (d *Demuxer) SetContext(ctx *core.Context) {
+	d.flv.Ctx = ctx
+	d.flv.Parser.ctx = ctx
+}
+
+// fill reads the next chunk from the underlying reader into the parser.
+func (d *Demuxer) fill() error {
+	if d.eof {
+		return io.EOF
+	}
+
+	n, err := d.r.Read(d.buf)
+	if n > 0 {
+		if perr := d.flv.Parser.Decode(d.buf[:n]); perr != nil {
+			return perr
+		}
+	}
+
+	if err == io.EOF {
+		d.eof = true
+		return nil
+	}
+
+	return err
+}
+
+// ReadHeader returns the FLV file header, reading it if necessary.
+func (d *Demuxer) ReadHeader() (*FLVHeader, error) {
+	if d.flv.Parser.Status != PHEADER {
+		return d.flv.Header, nil
+	}
+
+	for d.err == nil && d.flv.Parser.Status == PHEADER {
+		if d.eof {
+			d.err = io.ErrUnexpectedEOF
+			if d.flv.Parser.Buffered() == 0 {
+				d.err = io.EOF
+			}
+			break
+		}
+
+		d.err = d.fill()
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return d.flv.Header, nil
+}
+
+// ReadTag returns the next raw tag. It returns io.EOF once the stream ends
+// on a tag boundary and io.ErrUnexpectedEOF if it ends inside a tag.
+func (d *Demuxer) ReadTag() (*FLVTag, error) {
+	if _, err := d.ReadHeader(); err != nil {
+		return nil, err
+	}
+
+	for d.err == nil {
+		if tag := d.flv.NextTag(); tag != nil {
+			return tag, nil
+		}
+
+		if d.eof {
+			// the trailing PreviousTagSize is all that may be left over
+			d.err = io.EOF
+			if d.flv.Parser.TagStatus != PTagHeader || d.flv.Parser.Buffered() > 4 {
+				d.err = io.ErrUnexpectedEOF
+			}
+			break
+		}
+
+		d.err = d.fill()
+	}
+
+	return nil, d.err
+}
+
+// ReadPacket returns the file header as the first packet, then one typed
+// packet per tag. Once a callback stopped the stream it returns io.EOF.
+func (d *Demuxer) ReadPacket() (*Packet, error) {
+	for {
+		pkt, err := d.readPacket()
+		if err != nil {
+			return nil, err
+		}
+
+		keep, err := d.flv.Parser.dispatch(pkt)
+		if err != nil {
+			d.err = err
+			return nil, err
+		}
+
+		if d.flv.Ctx != nil && d.flv.Ctx.Done {
+			d.err = io.EOF
+		}
+
+		if keep {
+			return pkt, nil
+		}
+
+		if d.err != nil {
+			return nil, d.err
+		}
+	}
+}
+
+func (d *Demuxer) readPacket() (*Packet, error) {
+	if d.flv.Ctx != nil && d.flv.Ctx.Done {
+		return nil, io.EOF
+	}
+
+	if len(d.pending) > 0 {
+		pkt := d.pending[0]
+		d.pending = d.pending[1:]
+		return pkt, nil
+	}
+
+	if !d.header {
+		header, err := d.ReadHeader()
+		if err != nil {
+			return nil, err
+		}
+
+		d.header = true
+		return &Packet{Type: PKT_HEADER, Data: header}, nil
+	}
+
+	tag, err := d.ReadTag()
+	if err != nil {
+		return nil, err
+	}
+
+	pkt, err := d.flv.Parser.readPacket(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if script, ok := pkt.Data.(*PacketScript); ok && script.Name == "onMetaData" {
+		d.meta = ParseMetadata(script.MetaData())
+	}
+
+	if isSeqHeader(pkt) {
+		d.addSeqHeader(tag.Offset, pkt.Type == PKT_VIDEO)
+	}
+
+	return pkt, nil
+}
+
+// Metadata returns the last onMetaData seen by ReadPacket, nil if none.
+func (d *Demuxer) Metadata() *Metadata { return d.meta }
@@ -0,0 +1,174 @@
+package flv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"media-go/codec/aac"
+	"media-go/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildTag(typ byte, dts int, data []byte) []byte {
+	tag := []byte{typ,
+		byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data)),
+		byte(dts >> 16), byte(dts >> 8), byte(dts), byte(dts >> 24),
+		0, 0, 0}
+	tag = append(tag, data...)
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(tag)))
+	return append(tag, size[:]...)
+}
+
+func buildFLV() []byte {
+	data := []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, buildTag(Video, 0, []byte{0x17, 0, 0, 0, 0, 1, 0x64, 0, 0x1f})...)
+	data = append(data, buildTag(Audio, 0x01000010, []byte{0xaf, 1, 0x21})...)
+	data = append(data, buildTag(Video, 40, []byte{0x27, 1, 0xff, 0xff, 0xd8, 0, 0, 0, 1, 0x41})...)
+	return data
+}
+
+func TestDemuxerReadPacket(t *testing.T) {
+	d := NewDemuxer(bytes.NewReader(buildFLV()))
+
+	pkt, err := d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, PKT_HEADER, pkt.Type)
+	assert.True(t, pkt.Data.(*FLVHeader).HasAudio())
+	assert.True(t, pkt.Data.(*FLVHeader).HasVideo())
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, PKT_VIDEO, pkt.Type)
+	assert.Equal(t, int64(13), pkt.Tag.Offset)
+	video := pkt.Data.(*PacketVideo)
+	assert.True(t, video.IsKeyFrame())
+	assert.Equal(t, FLV_CODECID_H264, video.CodecID)
+	assert.Equal(t, 0, video.AVCPacketType)
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, PKT_AUDIO, pkt.Type)
+	assert.Equal(t, 0x01000010, pkt.Dts)
+	assert.Equal(t, []byte{0x21}, pkt.Data.(*PacketAudio).Data)
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, 40, pkt.Dts)
+	assert.Equal(t, 0, pkt.Pts)
+	assert.Equal(t, -40, pkt.Data.(*PacketVideo).Cts)
+
+	_, err = d.ReadPacket()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDemuxerErrors(t *testing.T) {
+	_, err := NewDemuxer(bytes.NewReader([]byte("NOTFLV123"))).ReadTag()
+	assert.Equal(t, ErrInvalidHeader, err)
+
+	data := buildFLV()
+	_, err = NewDemuxer(bytes.NewReader(data[:len(data)-8])).ReadTag()
+	assert.Nil(t, err)
+
+	d := NewDemuxer(bytes.NewReader(data[:len(data)-8]))
+	for err == nil {
+		_, err = d.ReadTag()
+	}
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDemuxerCallbacks(t *testing.T) {
+	ctx := core.NewContext()
+	var types []core.PktType
+	ctx.SetPktCallback(func(ctx *core.Context, p *core.Packet) interface{} {
+		types = append(types, p.Type)
+		switch p.Type {
+		case core.Audio:
+			return core.CbDrop
+		case core.Video:
+			if p.Data.(*Packet).Dts == 40 {
+				return core.CbStop
+			}
+		}
+		return nil
+	})
+
+	d := NewDemuxer(bytes.NewReader(buildFLV()))
+	d.SetContext(ctx)
+
+	var pkts []*Packet
+	for {
+		pkt, err := d.ReadPacket()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		pkts = append(pkts, pkt)
+	}
+
+	assert.Equal(t, []core.PktType{core.MetaData, core.Video, core.Audio, core.Video}, types)
+	assert.Equal(t, 3, len(pkts))
+	assert.Equal(t, PKT_VIDEO, pkts[2].Type)
+	assert.True(t, ctx.Done)
+}
+
+func TestDemuxerBadFrame(t *testing.T) {
+	ctx := core.NewContext()
+	frames := 0
+	ctx.SetFrameCallback(func(ctx *core.Context, f interface{}) interface{} {
+		frames++
+		return nil
+	})
+
+	// the sequence header is truncated and the slice has no configuration
+	d := NewDemuxer(bytes.NewReader(buildFLV()))
+	d.SetContext(ctx)
+
+	var pkts []*Packet
+	for {
+		pkt, err := d.ReadPacket()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		pkts = append(pkts, pkt)
+	}
+
+	assert.Equal(t, 4, len(pkts))
+	assert.Equal(t, 0, frames)
+}
+
+func TestDemuxerAACConfig(t *testing.T) {
+	data := []byte{'F', 'L', 'V', 1, 0x04, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, buildTag(Audio, 0, []byte{0xaf, 0, 0x11, 0x90})...)
+	data = append(data, buildTag(Audio, 21, []byte{0xaf, 1, 0x21, 0x00})...)
+
+	d := NewDemuxer(bytes.NewReader(data))
+	_, err := d.ReadPacket()
+	assert.Nil(t, err)
+
+	pkt, err := d.ReadPacket()
+	assert.Nil(t, err)
+	seq := pkt.Data.(*PacketAudio)
+	assert.NotNil(t, seq.AACConfig)
+	assert.Nil(t, seq.AACFrame)
+	assert.Equal(t, "|aac-48kHz-16bits-2ch|AAC LC", seq.String())
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	frame := pkt.Data.(*PacketAudio).AACFrame
+	assert.Equal(t, 48000, frame.SampleRate)
+	assert.Equal(t, 1024, frame.Samples)
+	assert.Equal(t, []byte{0x21, 0x00}, frame.Data)
+
+	d = NewDemuxer(bytes.NewReader(append(data[:13:13], buildTag(Audio, 0, []byte{0xaf, 0, 0x12})...)))
+	_, err = d.ReadPacket()
+	assert.Nil(t, err)
+	_, err = d.ReadPacket()
+	assert.True(t, errors.Is(err, aac.ErrInvalidConfig))
+}
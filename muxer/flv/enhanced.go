@@ -0,0 +1,448 @@
+package flv
+
+import (
+	"encoding/binary"
+)
+
+// Enhanced RTMP (v1/v2) extended video tag header, signalled by the top bit
+// of the first video tag byte.
+const FLV_VIDEO_EX_HEADER = 0x80
+
+const (
+	FLV_PACKETTYPE_SEQUENCE_START         = 0
+	FLV_PACKETTYPE_CODED_FRAMES           = 1
+	FLV_PACKETTYPE_SEQUENCE_END           = 2
+	FLV_PACKETTYPE_CODED_FRAMESX          = 3 // CodedFrames with an implied zero composition time
+	FLV_PACKETTYPE_METADATA               = 4
+	FLV_PACKETTYPE_MPEG2TS_SEQUENCE_START = 5
+	FLV_PACKETTYPE_MULTITRACK             = 6
+	FLV_PACKETTYPE_MODEX                  = 7
+)
+
+var PacketTypeMap = map[int]string{
+	FLV_PACKETTYPE_SEQUENCE_START:         "sequence start",
+	FLV_PACKETTYPE_CODED_FRAMES:           "coded frames",
+	FLV_PACKETTYPE_SEQUENCE_END:           "sequence end",
+	FLV_PACKETTYPE_CODED_FRAMESX:          "coded framesx",
+	FLV_PACKETTYPE_METADATA:               "metadata",
+	FLV_PACKETTYPE_MPEG2TS_SEQUENCE_START: "mpeg2ts sequence start",
+	FLV_PACKETTYPE_MULTITRACK:             "multitrack",
+	FLV_PACKETTYPE_MODEX:                  "modex",
+}
+
+const (
+	FLV_MULTITRACK_ONE_TRACK               = 0
+	FLV_MULTITRACK_MANY_TRACKS             = 1
+	FLV_MULTITRACK_MANY_TRACKS_MANY_CODECS = 2
+)
+
+const FLV_MODEX_TIMESTAMP_OFFSET_NANO = 0
+
+const (
+	FLV_VIDEO_COMMAND_START_SEEK = 0
+	FLV_VIDEO_COMMAND_END_SEEK   = 1
+)
+
+const (
+	FOURCC_AVC1 = "avc1"
+	FOURCC_HVC1 = "hvc1"
+	FOURCC_VP08 = "vp08"
+	FOURCC_VP09 = "vp09"
+	FOURCC_AV01 = "av01"
+)
+
+// Enhanced RTMP v2 audio, signalled by SoundFormat FLV_CODECID_EX_HEADER with
+// the packet type in the low nibble.
+const (
+	FLV_AUDIO_PACKETTYPE_SEQUENCE_START      = 0
+	FLV_AUDIO_PACKETTYPE_CODED_FRAMES        = 1
+	FLV_AUDIO_PACKETTYPE_SEQUENCE_END        = 2
+	FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG = 4
+	FLV_AUDIO_PACKETTYPE_MULTITRACK          = 5
+	FLV_AUDIO_PACKETTYPE_MODEX               = 7
+)
+
+var AudioPacketTypeMap = map[int]string{
+	FLV_AUDIO_PACKETTYPE_SEQUENCE_START:      "sequence start",
+	FLV_AUDIO_PACKETTYPE_CODED_FRAMES:        "coded frames",
+	FLV_AUDIO_PACKETTYPE_SEQUENCE_END:        "sequence end",
+	FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG: "multichannel config",
+	FLV_AUDIO_PACKETTYPE_MULTITRACK:          "multitrack",
+	FLV_AUDIO_PACKETTYPE_MODEX:               "modex",
+}
+
+const (
+	FLV_AUDIO_CHANNEL_ORDER_UNSPECIFIED = 0
+	FLV_AUDIO_CHANNEL_ORDER_NATIVE      = 1
+	FLV_AUDIO_CHANNEL_ORDER_CUSTOM      = 2
+)
+
+const (
+	FOURCC_OPUS = "Opus"
+	FOURCC_FLAC = "fLaC"
+	FOURCC_AC3  = "ac-3"
+	FOURCC_EAC3 = "ec-3"
+	FOURCC_MP3  = ".mp3"
+	FOURCC_MP4A = "mp4a"
+)
+
+// ChannelConfig is the body of a multichannel config audio packet. Mapping
+// is only set for the custom order, Flags for the native one, one bit per
+// speaker position.
+type ChannelConfig struct {
+	Order   int
+	Count   int
+	Mapping []int
+	Flags   uint32
+}
+
+// Track is one track of an enhanced packet. Packets that are not multitrack
+// carry a single track whose fields are also mirrored on the packet.
+type Track struct {
+	TrackID int
+	FourCC  string
+	Cts     int
+	Data    []byte
+}
+
+// exReader reads big-endian fields, the first out of range read sets err
+// and every later read returns zeros.
+type exReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *exReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		r.err = ErrInvalidTag
+		return make([]byte, n)
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *exReader) u8() int     { return int(r.bytes(1)[0]) }
+func (r *exReader) u16() int    { return int(binary.BigEndian.Uint16(r.bytes(2))) }
+func (r *exReader) u32() uint32 { return binary.BigEndian.Uint32(r.bytes(4)) }
+func (r *exReader) u24() int    { return int(bytesToInt64(r.bytes(3))) }
+func (r *exReader) si24() int {
+	return int(int32(uint32(r.u24())<<8) >> 8)
+}
+
+func (r *exReader) rest() []byte { return r.bytes(len(r.data) - r.pos) }
+
+// readModEx consumes ModEx prefixes and returns the real packet type.
+func (r *exReader) readModEx(packetType int, nano *int) int {
+	for packetType == FLV_PACKETTYPE_MODEX && r.err == nil {
+		size := r.u8() + 1
+		if size == 256 {
+			size = r.u16() + 1
+		}
+
+		data := r.bytes(size)
+		b := r.u8()
+		packetType = b & 0x0f
+		if b>>4 == FLV_MODEX_TIMESTAMP_OFFSET_NANO && len(data) >= 3 {
+			*nano = int(bytesToInt64(data[:3]))
+		}
+	}
+
+	return packetType
+}
+
+// exPacketType returns the packet type of an enhanced video or audio body
+// past the ModEx prefixes and the multitrack header, multitrack is the
+// multitrack packet type of the kind. ok is false if body is too short to
+// tell.
+func exPacketType(body []byte, multitrack int) (packetType int, ok bool) {
+	r := &exReader{data: body}
+	var nano int
+	packetType = r.readModEx(r.u8()&0x0f, &nano)
+	if packetType == multitrack {
+		packetType = r.u8() & 0x0f
+	}
+
+	return packetType, r.err == nil
+}
+
+// readTracks splits the single or multitrack body following the packet
+// type. hasCts tells whether a coded frames body of the given FourCC starts
+// with a composition time offset.
+func (r *exReader) readTracks(multitrack bool, multitrackType int, hasCts func(fourcc string) bool) []*Track {
+	var fourcc string
+	if !multitrack || multitrackType != FLV_MULTITRACK_MANY_TRACKS_MANY_CODECS {
+		fourcc = string(r.bytes(4))
+	}
+
+	tracks := make([]*Track, 0, 1)
+	for r.err == nil {
+		track := &Track{FourCC: fourcc}
+		end := len(r.data)
+
+		if multitrack {
+			if multitrackType == FLV_MULTITRACK_MANY_TRACKS_MANY_CODECS {
+				track.FourCC = string(r.bytes(4))
+			}
+
+			track.TrackID = r.u8()
+			if multitrackType != FLV_MULTITRACK_ONE_TRACK {
+				size := r.u24()
+				end = r.pos + size
+			}
+		}
+
+		if r.err != nil || end > len(r.data) {
+			r.err = ErrInvalidTag
+			break
+		}
+
+		body := &exReader{data: r.data[:end], pos: r.pos}
+		if hasCts(track.FourCC) {
+			track.Cts = body.si24()
+		}
+		track.Data = body.rest()
+		r.pos, r.err = body.pos, body.err
+
+		tracks = append(tracks, track)
+		if !multitrack || multitrackType == FLV_MULTITRACK_ONE_TRACK || r.pos == len(r.data) {
+			break
+		}
+	}
+
+	return tracks
+}
+
+func readExVideo(packet []byte) (*PacketVideo, error) {
+	r := &exReader{data: packet}
+	b := r.u8()
+
+	video := &PacketVideo{IsExHeader: true, FrameType: (b >> 4) & 0x07}
+	video.PacketType = r.readModEx(b&0x0f, &video.TimestampOffsetNano)
+
+	if video.FrameType == FLV_FRAME_VIDEO_INFO_CMD && video.PacketType != FLV_PACKETTYPE_METADATA {
+		video.Command = r.u8()
+		return video, r.err
+	}
+
+	multitrack := video.PacketType == FLV_PACKETTYPE_MULTITRACK
+	if multitrack {
+		b := r.u8()
+		video.MultitrackType = b >> 4
+		video.PacketType = b & 0x0f
+	}
+
+	codedFrames := video.PacketType == FLV_PACKETTYPE_CODED_FRAMES
+	tracks := r.readTracks(multitrack, video.MultitrackType, func(fourcc string) bool {
+		return codedFrames && (fourcc == FOURCC_AVC1 || fourcc == FOURCC_HVC1)
+	})
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if multitrack {
+		video.Tracks = tracks
+	}
+
+	video.FourCC = tracks[0].FourCC
+	video.Cts = tracks[0].Cts
+	video.Data = tracks[0].Data
+
+	if video.PacketType == FLV_PACKETTYPE_METADATA {
+		meta, err := readScript(video.Data)
+		if err != nil {
+			return nil, err
+		}
+		video.Meta = meta
+	}
+
+	return video, nil
+}
+
+type exWriter struct {
+	data []byte
+}
+
+func (w *exWriter) u8(v int)     { w.data = append(w.data, byte(v)) }
+func (w *exWriter) u16(v int)    { w.data = append(w.data, byte(v>>8), byte(v)) }
+func (w *exWriter) u24(v int)    { w.data = append(w.data, byte(v>>16), byte(v>>8), byte(v)) }
+func (w *exWriter) u32(v uint32) { w.u16(int(v >> 16)); w.u16(int(v)) }
+
+// writeModEx writes the ModEx prefix carrying a nanosecond timestamp offset,
+// the caller has set the packet type of the first byte to ModEx.
+func (w *exWriter) writeModEx(nano, packetType int) {
+	w.u8(3 - 1)
+	w.u24(nano)
+	w.u8(FLV_MODEX_TIMESTAMP_OFFSET_NANO<<4 | packetType)
+}
+
+func (w *exWriter) writeTracks(multitrack bool, multitrackType int, tracks []*Track, hasCts func(fourcc string) bool) {
+	if !multitrack || multitrackType != FLV_MULTITRACK_MANY_TRACKS_MANY_CODECS {
+		w.data = append(w.data, tracks[0].FourCC...)
+	}
+
+	for _, track := range tracks {
+		if multitrack {
+			if multitrackType == FLV_MULTITRACK_MANY_TRACKS_MANY_CODECS {
+				w.data = append(w.data, track.FourCC...)
+			}
+
+			w.u8(track.TrackID)
+		}
+
+		size := len(track.Data)
+		if hasCts(track.FourCC) {
+			size += 3
+		}
+
+		if multitrack && multitrackType != FLV_MULTITRACK_ONE_TRACK {
+			w.u24(size)
+		}
+
+		if hasCts(track.FourCC) {
+			w.u24(track.Cts & 0xffffff)
+		}
+		w.data = append(w.data, track.Data...)
+	}
+}
+
+func writeExVideo(pkt *PacketVideo) []byte {
+	w := &exWriter{}
+
+	packetType := pkt.PacketType
+	if pkt.Tracks != nil {
+		packetType = FLV_PACKETTYPE_MULTITRACK
+	}
+
+	if pkt.TimestampOffsetNano != 0 {
+		w.u8(FLV_VIDEO_EX_HEADER | pkt.FrameType<<4 | FLV_PACKETTYPE_MODEX)
+		w.writeModEx(pkt.TimestampOffsetNano, packetType)
+	} else {
+		w.u8(FLV_VIDEO_EX_HEADER | pkt.FrameType<<4 | packetType)
+	}
+
+	if pkt.FrameType == FLV_FRAME_VIDEO_INFO_CMD && pkt.PacketType != FLV_PACKETTYPE_METADATA {
+		w.u8(pkt.Command)
+		return w.data
+	}
+
+	tracks := pkt.Tracks
+	if tracks == nil {
+		tracks = []*Track{{FourCC: pkt.FourCC, Cts: pkt.Cts, Data: pkt.Data}}
+	} else {
+		w.u8(pkt.MultitrackType<<4 | pkt.PacketType)
+	}
+
+	codedFrames := pkt.PacketType == FLV_PACKETTYPE_CODED_FRAMES
+	w.writeTracks(pkt.Tracks != nil, pkt.MultitrackType, tracks, func(fourcc string) bool {
+		return codedFrames && (fourcc == FOURCC_AVC1 || fourcc == FOURCC_HVC1)
+	})
+
+	return w.data
+}
+
+func noCts(string) bool { return false }
+
+func readChannelConfig(data []byte) (*ChannelConfig, error) {
+	r := &exReader{data: data}
+	ch := &ChannelConfig{Order: r.u8(), Count: r.u8()}
+
+	switch ch.Order {
+	case FLV_AUDIO_CHANNEL_ORDER_CUSTOM:
+		for _, b := range r.bytes(ch.Count) {
+			ch.Mapping = append(ch.Mapping, int(b))
+		}
+	case FLV_AUDIO_CHANNEL_ORDER_NATIVE:
+		ch.Flags = r.u32()
+	}
+
+	return ch, r.err
+}
+
+func readExAudio(packet []byte) (*PacketAudio, error) {
+	r := &exReader{data: packet}
+	b := r.u8()
+
+	audio := &PacketAudio{IsExHeader: true, SoundFormat: b >> 4}
+	audio.PacketType = r.readModEx(b&0x0f, &audio.TimestampOffsetNano)
+
+	multitrack := audio.PacketType == FLV_AUDIO_PACKETTYPE_MULTITRACK
+	if multitrack {
+		b := r.u8()
+		audio.MultitrackType = b >> 4
+		audio.PacketType = b & 0x0f
+	}
+
+	tracks := r.readTracks(multitrack, audio.MultitrackType, noCts)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if multitrack {
+		audio.Tracks = tracks
+	}
+
+	audio.FourCC = tracks[0].FourCC
+	audio.Data = tracks[0].Data
+
+	if audio.PacketType == FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG {
+		ch, err := readChannelConfig(audio.Data)
+		if err != nil {
+			return nil, err
+		}
+		audio.Channels = ch
+	}
+
+	return audio, nil
+}
+
+func writeExAudio(pkt *PacketAudio) []byte {
+	w := &exWriter{}
+
+	packetType := pkt.PacketType
+	if pkt.Tracks != nil {
+		packetType = FLV_AUDIO_PACKETTYPE_MULTITRACK
+	}
+
+	if pkt.TimestampOffsetNano != 0 {
+		w.u8(FLV_CODECID_EX_HEADER<<4 | FLV_AUDIO_PACKETTYPE_MODEX)
+		w.writeModEx(pkt.TimestampOffsetNano, packetType)
+	} else {
+		w.u8(FLV_CODECID_EX_HEADER<<4 | packetType)
+	}
+
+	data := pkt.Data
+	if pkt.PacketType == FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG && pkt.Channels != nil {
+		data = writeChannelConfig(pkt.Channels)
+	}
+
+	tracks := pkt.Tracks
+	if tracks == nil {
+		tracks = []*Track{{FourCC: pkt.FourCC, Data: data}}
+	} else {
+		w.u8(pkt.MultitrackType<<4 | pkt.PacketType)
+	}
+
+	w.writeTracks(pkt.Tracks != nil, pkt.MultitrackType, tracks, noCts)
+	return w.data
+}
+
+func writeChannelConfig(ch *ChannelConfig) []byte {
+	w := &exWriter{}
+	w.u8(ch.Order)
+	w.u8(ch.Count)
+
+	switch ch.Order {
+	case FLV_AUDIO_CHANNEL_ORDER_CUSTOM:
+		for _, m := range ch.Mapping {
+			w.u8(m)
+		}
+	case FLV_AUDIO_CHANNEL_ORDER_NATIVE:
+		w.u32(ch.Flags)
+	}
+
+	return w.data
+}
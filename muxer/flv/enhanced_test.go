@@ -0,0 +1,113 @@
+package flv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExVideoSingleTrack(t *testing.T) {
+	// key frame, CodedFrames, hvc1, cts -2
+	data := []byte{0x90 | FLV_PACKETTYPE_CODED_FRAMES, 'h', 'v', 'c', '1', 0xff, 0xff, 0xfe, 0, 0, 0, 2, 0x26, 0x01}
+	video, err := readVideo(data)
+	assert.Nil(t, err)
+	assert.True(t, video.IsExHeader)
+	assert.True(t, video.IsKeyFrame())
+	assert.Equal(t, FOURCC_HVC1, video.FourCC)
+	assert.Equal(t, -2, video.Cts)
+	assert.Equal(t, []byte{0, 0, 0, 2, 0x26, 0x01}, video.Data)
+	assert.Nil(t, video.Tracks)
+	assert.Equal(t, data, writeVideo(video))
+
+	// av01 has no composition time
+	video, err = readVideo([]byte{0x90 | FLV_PACKETTYPE_SEQUENCE_START, 'a', 'v', '0', '1', 0x81, 0x00})
+	assert.Nil(t, err)
+	assert.True(t, video.IsSeqHeader())
+	assert.Equal(t, []byte{0x81, 0x00}, video.Data)
+
+	_, err = readVideo([]byte{0x90 | FLV_PACKETTYPE_CODED_FRAMES, 'h', 'v'})
+	assert.Equal(t, ErrInvalidTag, err)
+}
+
+func TestExVideoModEx(t *testing.T) {
+	data := []byte{0xa0 | FLV_PACKETTYPE_MODEX, 2, 0x00, 0x01, 0x00, FLV_PACKETTYPE_CODED_FRAMESX,
+		'v', 'p', '0', '9', 0x82}
+	video, err := readVideo(data)
+	assert.Nil(t, err)
+	assert.Equal(t, FLV_FRAME_INTER, video.FrameType)
+	assert.Equal(t, FLV_PACKETTYPE_CODED_FRAMESX, video.PacketType)
+	assert.Equal(t, 256, video.TimestampOffsetNano)
+	assert.Equal(t, FOURCC_VP09, video.FourCC)
+	assert.Equal(t, data, writeVideo(video))
+}
+
+func TestExVideoMultitrack(t *testing.T) {
+	data := []byte{0x90 | FLV_PACKETTYPE_MULTITRACK,
+		FLV_MULTITRACK_MANY_TRACKS_MANY_CODECS<<4 | FLV_PACKETTYPE_CODED_FRAMES,
+		'a', 'v', 'c', '1', 0, 0, 0, 5, 0, 0, 1, 0xaa, 0xbb,
+		'a', 'v', '0', '1', 1, 0, 0, 1, 0xcc}
+	video, err := readVideo(data)
+	assert.Nil(t, err)
+	assert.Equal(t, FLV_PACKETTYPE_CODED_FRAMES, video.PacketType)
+	assert.Equal(t, 2, len(video.Tracks))
+	assert.Equal(t, &Track{TrackID: 0, FourCC: FOURCC_AVC1, Cts: 1, Data: []byte{0xaa, 0xbb}}, video.Tracks[0])
+	assert.Equal(t, &Track{TrackID: 1, FourCC: FOURCC_AV01, Data: []byte{0xcc}}, video.Tracks[1])
+	assert.Equal(t, data, writeVideo(video))
+}
+
+func TestExAudio(t *testing.T) {
+	data := []byte{0x90 | FLV_AUDIO_PACKETTYPE_SEQUENCE_START, 'O', 'p', 'u', 's', 'O', 'p', 'u', 's', 'H', 'e', 'a', 'd'}
+	audio, err := readAudio(data)
+	assert.Nil(t, err)
+	assert.True(t, audio.IsExHeader)
+	assert.True(t, audio.IsSeqHeader())
+	assert.Equal(t, FOURCC_OPUS, audio.FourCC)
+	assert.Equal(t, []byte("OpusHead"), audio.Data)
+	assert.Equal(t, data, writeAudio(audio))
+
+	data = []byte{0x90 | FLV_AUDIO_PACKETTYPE_CODED_FRAMES, 'f', 'L', 'a', 'C', 0xff, 0xf8}
+	audio, err = readAudio(data)
+	assert.Nil(t, err)
+	assert.False(t, audio.IsSeqHeader())
+	assert.Equal(t, FOURCC_FLAC, audio.FourCC)
+	assert.Equal(t, []byte{0xff, 0xf8}, audio.Data)
+	assert.Equal(t, data, writeAudio(audio))
+
+	_, err = readAudio([]byte{0x90 | FLV_AUDIO_PACKETTYPE_CODED_FRAMES, 'a', 'c'})
+	assert.Equal(t, ErrInvalidTag, err)
+}
+
+func TestExAudioMultichannelConfig(t *testing.T) {
+	// 5.1 in native order
+	data := []byte{0x90 | FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG, 'a', 'c', '-', '3',
+		FLV_AUDIO_CHANNEL_ORDER_NATIVE, 6, 0, 0, 0, 0x3f}
+	audio, err := readAudio(data)
+	assert.Nil(t, err)
+	assert.Equal(t, &ChannelConfig{Order: FLV_AUDIO_CHANNEL_ORDER_NATIVE, Count: 6, Flags: 0x3f}, audio.Channels)
+	assert.Equal(t, data, writeAudio(audio))
+
+	data = []byte{0x90 | FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG, 'O', 'p', 'u', 's',
+		FLV_AUDIO_CHANNEL_ORDER_CUSTOM, 2, 1, 0}
+	audio, err = readAudio(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 0}, audio.Channels.Mapping)
+	assert.Equal(t, data, writeAudio(audio))
+
+	_, err = readAudio([]byte{0x90 | FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG, 'O', 'p', 'u', 's',
+		FLV_AUDIO_CHANNEL_ORDER_CUSTOM, 2, 1})
+	assert.Equal(t, ErrInvalidTag, err)
+}
+
+func TestExAudioMultitrack(t *testing.T) {
+	data := []byte{0x90 | FLV_AUDIO_PACKETTYPE_MODEX, 2, 0x00, 0x00, 0x10, FLV_AUDIO_PACKETTYPE_MULTITRACK,
+		FLV_MULTITRACK_MANY_TRACKS<<4 | FLV_AUDIO_PACKETTYPE_CODED_FRAMES, 'e', 'c', '-', '3',
+		0, 0, 0, 2, 0x0b, 0x77,
+		1, 0, 0, 1, 0x0b}
+	audio, err := readAudio(data)
+	assert.Nil(t, err)
+	assert.Equal(t, FLV_AUDIO_PACKETTYPE_CODED_FRAMES, audio.PacketType)
+	assert.Equal(t, 16, audio.TimestampOffsetNano)
+	assert.Equal(t, 2, len(audio.Tracks))
+	assert.Equal(t, &Track{TrackID: 1, FourCC: FOURCC_EAC3, Data: []byte{0x0b}}, audio.Tracks[1])
+	assert.Equal(t, data, writeAudio(audio))
+}
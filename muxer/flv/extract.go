@@ -0,0 +1,218 @@
+package flv
+
+import (
+	"errors"
+	"io"
+
+	"media-go/codec/aac"
+	"media-go/codec/caption"
+	"media-go/codec/h264"
+)
+
+var (
+	ErrNoAudio    = errors.New("flv: no AAC audio to extract")
+	ErrNoVideo    = errors.New("flv: no H.264 video to extract")
+	ErrNoCaptions = errors.New("flv: no captions to extract")
+)
+
+// aacPayload returns the AudioSpecificConfig or raw frame carried by a legacy
+// AAC or an enhanced mp4a audio packet.
+func aacPayload(audio *PacketAudio) (config *aac.AudioSpecificConfig, raw []byte, ok bool, err error) {
+	switch {
+	case audio.IsExHeader && audio.FourCC == FOURCC_MP4A:
+		switch audio.PacketType {
+		case FLV_AUDIO_PACKETTYPE_SEQUENCE_START:
+			config, err = aac.ParseAudioSpecificConfig(audio.Data)
+			return config, nil, true, err
+		case FLV_AUDIO_PACKETTYPE_CODED_FRAMES:
+			return nil, audio.Data, true, nil
+		}
+	case !audio.IsExHeader && audio.SoundFormat == FLV_CODECID_AAC:
+		if audio.AACConfig != nil {
+			return audio.AACConfig, nil, true, nil
+		}
+
+		if audio.AACPacketType == 1 {
+			return nil, audio.Data, true, nil
+		}
+	}
+
+	return nil, nil, false, nil
+}
+
+// ExtractAAC writes the AAC audio of the FLV in r to w as an ADTS stream.
+// Frames before the first sequence header are dropped.
+func ExtractAAC(r io.Reader, w io.Writer) error {
+	d := NewDemuxer(r)
+
+	var aw *aac.ADTSWriter
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		audio, ok := pkt.Data.(*PacketAudio)
+		if !ok {
+			continue
+		}
+
+		config, raw, ok, err := aacPayload(audio)
+		if err != nil {
+			return &TagError{Offset: pkt.Tag.Offset, Type: pkt.Tag.Header.Type, Err: err}
+		}
+
+		switch {
+		case !ok:
+			continue
+		case config != nil:
+			if aw, err = aac.NewADTSWriter(w, config); err != nil {
+				return err
+			}
+		case aw != nil:
+			if err := aw.WriteFrame(raw); err != nil {
+				return err
+			}
+		}
+	}
+
+	if aw == nil {
+		return ErrNoAudio
+	}
+
+	return nil
+}
+
+// avcPayload returns the decoder configuration record or the length-prefixed
+// NALUs carried by a legacy H.264 or an enhanced avc1 video packet.
+func avcPayload(video *PacketVideo) (config, nalus []byte, ok bool) {
+	switch {
+	case video.IsExHeader && video.FourCC == FOURCC_AVC1 && video.Tracks == nil:
+		switch video.PacketType {
+		case FLV_PACKETTYPE_SEQUENCE_START:
+			return video.Data, nil, true
+		case FLV_PACKETTYPE_CODED_FRAMES, FLV_PACKETTYPE_CODED_FRAMESX:
+			return nil, video.Data, true
+		}
+	case !video.IsExHeader && video.CodecID == FLV_CODECID_H264:
+		switch video.AVCPacketType {
+		case h264.AVC_PKT_SEQ_HEADER:
+			return video.Data, nil, true
+		case h264.AVC_PKT_NALU:
+			return nil, video.Data, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// ExtractH264 writes the H.264 video of the FLV in r to w as an Annex B
+// stream with SPS and PPS in front of every IDR picture. Frames before the
+// first sequence header are dropped.
+func ExtractH264(r io.Reader, w io.Writer) error {
+	d := NewDemuxer(r)
+	aw := h264.NewAnnexBWriter(w)
+
+	configured := false
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		video, ok := pkt.Data.(*PacketVideo)
+		if !ok {
+			continue
+		}
+
+		config, nalus, ok := avcPayload(video)
+		switch {
+		case !ok:
+			continue
+		case config != nil:
+			err = aw.WriteConfig(config)
+			configured = configured || err == nil
+		case configured:
+			err = aw.WriteFrame(nalus)
+		}
+
+		if err != nil {
+			return &TagError{Offset: pkt.Tag.Offset, Type: pkt.Tag.Header.Type, Err: err}
+		}
+	}
+
+	if !configured {
+		return ErrNoVideo
+	}
+
+	return nil
+}
+
+// ExtractCaptions writes the CEA-608 captions of channel 1 to 4 carried as
+// ATSC A/53 cc_data in the H.264 SEI of the FLV in r. Cue times are the
+// presentation times of the pictures, DTS plus composition time. Access units
+// that cannot be decoded are skipped.
+func ExtractCaptions(r io.Reader, w caption.CueWriter, channel int) error {
+	ex, err := caption.NewExtractor(channel, w)
+	if err != nil {
+		return err
+	}
+
+	d := NewDemuxer(r)
+	avc := h264.NewParser()
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		video, ok := pkt.Data.(*PacketVideo)
+		if !ok {
+			continue
+		}
+
+		config, nalus, ok := avcPayload(video)
+		switch {
+		case !ok:
+			continue
+		case config != nil:
+			_, err = avc.ParseConfig(config)
+		case avc.Config() != nil:
+			vf, perr := avc.ParseNalus(nalus)
+			if perr != nil {
+				continue
+			}
+			err = ex.Push(pkt.Pts, vf)
+		}
+
+		if err != nil {
+			return &TagError{Offset: pkt.Tag.Offset, Type: pkt.Tag.Header.Type, Err: err}
+		}
+	}
+
+	if err := ex.Flush(); err != nil {
+		return err
+	}
+
+	if avc.Config() == nil {
+		return ErrNoVideo
+	}
+
+	if ex.Cues() == 0 {
+		return ErrNoCaptions
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"media-go/codec/aac"
+	"media-go/codec/caption"
+	"media-go/codec/h264"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractAAC(t *testing.T) {
+	data := []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, buildTag(Audio, 0, []byte{0xaf, 1, 0xee})...) // before the config
+	data = append(data, buildTag(Audio, 0, []byte{0xaf, 0, 0x11, 0x90})...)
+	data = append(data, buildTag(Video, 0, []byte{0x17, 0, 0, 0, 0, 1, 0x64, 0, 0x1f})...)
+	data = append(data, buildTag(Audio, 21, []byte{0xaf, 1, 0x21, 0x00})...)
+
+	var out bytes.Buffer
+	assert.Nil(t, ExtractAAC(bytes.NewReader(data), &out))
+
+	r := aac.NewADTSReader(&out)
+	frame, err := r.ReadFrame()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, frame.Header.SampleRateIndex)
+	assert.Equal(t, []byte{0x21, 0x00}, frame.Data)
+	assert.Equal(t, 0, out.Len())
+
+	err = ExtractAAC(bytes.NewReader(buildFLV()[:13]), &out)
+	assert.Equal(t, ErrNoAudio, err)
+}
+
+func TestExtractEnhancedAAC(t *testing.T) {
+	data := []byte{'F', 'L', 'V', 1, 0x04, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, buildTag(Audio, 0, []byte{0x90 | FLV_AUDIO_PACKETTYPE_SEQUENCE_START, 'm', 'p', '4', 'a', 0x12, 0x10})...)
+	data = append(data, buildTag(Audio, 23, []byte{0x90 | FLV_AUDIO_PACKETTYPE_CODED_FRAMES, 'm', 'p', '4', 'a', 0x21})...)
+
+	var out bytes.Buffer
+	assert.Nil(t, ExtractAAC(bytes.NewReader(data), &out))
+
+	frame, err := aac.NewADTSReader(&out).ReadFrame()
+	assert.Nil(t, err)
+	assert.True(t, frame.Header.ProtectionAbsent)
+	assert.Equal(t, 4, frame.Header.SampleRateIndex)
+	assert.Equal(t, []byte{0x21}, frame.Data)
+}
+
+func TestExtractH264(t *testing.T) {
+	record := []byte{1, 0x64, 0, 0x1f, 0xff, 0xe1, 0, 2, 0x67, 0x64, 1, 0, 1, 0x68}
+	data := []byte{'F', 'L', 'V', 1, 0x01, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, buildTag(Video, 0, append([]byte{0x17, 0, 0, 0, 0}, record...))...)
+	data = append(data, buildTag(Video, 0, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 1, 0x65})...)
+	data = append(data, buildTag(Video, 40, []byte{0x27, 1, 0, 0, 0x28, 0, 0, 0, 1, 0x41})...)
+
+	var out bytes.Buffer
+	assert.Nil(t, ExtractH264(bytes.NewReader(data), &out))
+	assert.Equal(t, []byte{0, 0, 0, 1, 0x67, 0x64, 0, 0, 0, 1, 0x68,
+		0, 0, 0, 1, 0x65, 0, 0, 0, 1, 0x41}, out.Bytes())
+
+	// a NALU length past the end of the tag
+	data = append(data, buildTag(Video, 80, []byte{0x27, 1, 0, 0, 0, 0, 0, 0, 9, 0x41})...)
+	err := ExtractH264(bytes.NewReader(data), &out)
+	assert.True(t, errors.Is(err, h264.ErrInvalidNalu))
+
+	assert.Equal(t, ErrNoVideo, ExtractH264(bytes.NewReader(buildFLV()[:13]), &out))
+}
+
+func TestExtractCaptions(t *testing.T) {
+	var out bytes.Buffer
+	w := caption.NewSRTWriter(&out)
+	assert.Equal(t, caption.ErrInvalidChannel, ExtractCaptions(bytes.NewReader(buildFLV()[:13]), w, 0))
+	assert.Equal(t, ErrNoVideo, ExtractCaptions(bytes.NewReader(buildFLV()[:13]), w, 1))
+
+	// NALUs before the sequence header are skipped
+	data := []byte{'F', 'L', 'V', 1, 0x01, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, buildTag(Video, 0, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 1, 0x65})...)
+	assert.Equal(t, ErrNoVideo, ExtractCaptions(bytes.NewReader(data), w, 1))
+	assert.Equal(t, 0, out.Len())
+}
+
+// avcTag builds an AVC NALU tag body with 4 byte NALU lengths.
+func avcTag(key bool, cts int, nalus ...[]byte) []byte {
+	body := []byte{0x27, 1, byte(cts >> 16), byte(cts >> 8), byte(cts)}
+	if key {
+		body[0] = 0x17
+	}
+
+	for _, nalu := range nalus {
+		n := len(nalu)
+		body = append(body, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		body = append(body, nalu...)
+	}
+
+	return body
+}
+
+// ccSEI builds an SEI NALU with A/53 cc_data carrying the field 1 pairs.
+func ccSEI(pairs ...byte) []byte {
+	t35 := []byte{0xb5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03, 0x40 | byte(len(pairs)/2), 0xff}
+	for i := 0; i < len(pairs); i += 2 {
+		t35 = append(t35, 0xfc, pairs[i], pairs[i+1])
+	}
+	t35 = append(t35, 0xff)
+
+	sei := []byte{0x06, h264.SEI_USER_DATA_REGISTERED, byte(len(t35))}
+	return append(append(sei, t35...), 0x80)
+}
+
+func TestExtractCaptionsCues(t *testing.T) {
+	// 320x240 baseline, VUI timing at 30 fps
+	sps := []byte{0x67, 0x42, 0xc0, 0x1e, 0xda, 0x05, 0x07, 0xe8, 0x40, 0x00, 0x00, 0x03, 0x00, 0x40, 0x00, 0x00, 0x0f, 0x21}
+	config, err := h264.NewAVCConfig([][]byte{sps}, [][]byte{{0x68, 0xce, 0x38, 0x80}})
+	assert.Nil(t, err)
+	record, err := config.Bytes()
+	assert.Nil(t, err)
+
+	data := []byte{'F', 'L', 'V', 1, 0x01, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, buildTag(Video, 0, append([]byte{0x17, 0, 0, 0, 0}, record...))...)
+	// a pop-on caption loaded with the IDR picture, shown by the next one
+	// and erased a second later, all presented 80 ms after decoding
+	data = append(data, buildTag(Video, 0, avcTag(true, 80,
+		ccSEI(0x94, 0x20, 0x94, 0x70, 0xc8, 0x49), []byte{0x65, 0x88, 0x84, 0xc0}))...)
+	// a damaged access unit is skipped
+	data = append(data, buildTag(Video, 20, []byte{0x27, 1, 0, 0, 0x50, 0, 0, 0, 9, 0x41})...)
+	data = append(data, buildTag(Video, 40, avcTag(false, 80,
+		ccSEI(0x94, 0x2f), []byte{0x41, 0x9a, 0x23}))...)
+	data = append(data, buildTag(Video, 1000, avcTag(false, 80,
+		ccSEI(0x94, 0x2c), []byte{0x41, 0x9a, 0x43}))...)
+
+	var out bytes.Buffer
+	assert.Nil(t, ExtractCaptions(bytes.NewReader(data), caption.NewSRTWriter(&out), 1))
+	assert.Equal(t, "1\n00:00:00,120 --> 00:00:01,080\nHI\n\n", out.String())
+
+	// CC3 is empty
+	err = ExtractCaptions(bytes.NewReader(data), caption.NewSRTWriter(&out), 3)
+	assert.Equal(t, ErrNoCaptions, err)
+}
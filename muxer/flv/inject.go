@@ -0,0 +1,350 @@
+package flv
+
+import (
+	"encoding/binary"
+	"io"
+
+	"media-go/codec/aac"
+)
+
+const metadataCreator = "media-go"
+
+var soundRates = []float64{5512.5, 11025, 22050, 44100}
+
+// tagSize is the space a tag takes in the file, including PreviousTagSize.
+func tagSize(tag *FLVTag) int64 {
+	return int64(TagHeaderSize + len(tag.Data) + 4)
+}
+
+func isOnMetaData(pkt *Packet) bool {
+	script, ok := pkt.Data.(*PacketScript)
+	return ok && script.Name == "onMetaData"
+}
+
+// fourccID is the numeric codec id Enhanced RTMP puts in onMetaData for
+// FourCC codecs.
+func fourccID(fourcc string) int {
+	if len(fourcc) != 4 {
+		return 0
+	}
+
+	return int(binary.BigEndian.Uint32([]byte(fourcc)))
+}
+
+// audioFormat returns the output sample rate and channel count an audio
+// configuration packet gives, 0 for what it does not tell.
+func audioFormat(a *PacketAudio) (float64, int) {
+	if !a.IsExHeader {
+		if a.AACConfig != nil {
+			return float64(a.AACConfig.OutputSampleRate()), a.AACConfig.OutputChannels()
+		}
+		return 0, 0
+	}
+
+	switch {
+	case a.PacketType == FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG && a.Channels != nil:
+		return 0, a.Channels.Count
+	case a.PacketType != FLV_AUDIO_PACKETTYPE_SEQUENCE_START:
+		return 0, 0
+	}
+
+	switch a.FourCC {
+	case FOURCC_MP4A:
+		if c, err := aac.ParseAudioSpecificConfig(a.Data); err == nil {
+			return float64(c.OutputSampleRate()), c.OutputChannels()
+		}
+	case FOURCC_OPUS:
+		// OpusHead of RFC 7845 5.1, Opus always decodes at 48 kHz
+		if len(a.Data) >= 10 && string(a.Data[:8]) == "OpusHead" {
+			return 48000, int(a.Data[9])
+		}
+	}
+
+	return 0, 0
+}
+
+// injectStats collects what the scan pass learned about the file. Offsets
+// are relative to the first tag following onMetaData.
+type injectStats struct {
+	meta       *Metadata // source onMetaData, keys not computed here are kept
+	dataSize   int64
+	videoSize  int64
+	audioSize  int64
+	firstTs    int // -1 until the first tag
+	lastTs     int
+	lastKeyTs  int
+	lastKeyOff int64
+	video      frameSpan // coded pictures
+	audio      frameSpan // audio frames, configurations excluded
+	firstVideo *PacketVideo
+	firstAudio *PacketAudio
+	audioRate  float64 // from the first audio configuration, 0 if unknown
+	channels   int
+	lastVideo  *PacketVideo
+	keyTimes   []float64
+	keyOffsets []int64
+}
+
+// frameSpan tracks the timestamps of a run of frames.
+type frameSpan struct {
+	first, last, count int
+}
+
+func (s *frameSpan) add(dts int) {
+	if s.count == 0 {
+		s.first = dts
+	}
+	s.last = dts
+	s.count++
+}
+
+// interval returns the mean frame distance in milliseconds, 0 for fewer than
+// two frames.
+func (s *frameSpan) interval() float64 {
+	if s.count < 2 {
+		return 0
+	}
+	return float64(s.last-s.first) / float64(s.count-1)
+}
+
+func scanForInject(r io.Reader) (*injectStats, error) {
+	st := &injectStats{meta: &Metadata{Extra: make(map[string]interface{})}, firstTs: -1}
+	d := NewDemuxer(r)
+
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if pkt.Type == PKT_HEADER {
+			continue
+		}
+
+		if isOnMetaData(pkt) {
+			st.meta = d.Metadata()
+			continue
+		}
+
+		size := tagSize(pkt.Tag)
+		if st.firstTs < 0 || pkt.Dts < st.firstTs {
+			st.firstTs = pkt.Dts
+		}
+		if pkt.Dts > st.lastTs {
+			st.lastTs = pkt.Dts
+		}
+
+		switch data := pkt.Data.(type) {
+		case *PacketVideo:
+			st.videoSize += size
+			st.lastVideo = data
+			if st.firstVideo == nil {
+				st.firstVideo = data
+			}
+
+			if isSeqHeader(pkt) || data.FrameType == FLV_FRAME_VIDEO_INFO_CMD {
+				break
+			}
+
+			st.video.add(pkt.Dts)
+			if data.IsKeyFrame() {
+				st.lastKeyTs = pkt.Dts
+				st.lastKeyOff = st.dataSize
+				st.keyTimes = append(st.keyTimes, float64(pkt.Dts)/1000)
+				st.keyOffsets = append(st.keyOffsets, st.dataSize)
+			}
+		case *PacketAudio:
+			st.audioSize += size
+			if st.firstAudio == nil {
+				st.firstAudio = data
+			}
+
+			if !isSeqHeader(pkt) {
+				st.audio.add(pkt.Dts)
+			}
+
+			rate, channels := audioFormat(data)
+			if st.audioRate == 0 {
+				st.audioRate = rate
+			}
+			if st.channels == 0 {
+				st.channels = channels
+			}
+		}
+
+		st.dataSize += size
+	}
+
+	return st, nil
+}
+
+// duration returns the seconds from the first tag to the end of the last
+// frame, the last frame lasting one video or, without video, audio interval.
+func (st *injectStats) duration() float64 {
+	if st.firstTs < 0 {
+		return 0
+	}
+
+	interval := st.video.interval()
+	if interval == 0 {
+		interval = st.audio.interval()
+	}
+	return (float64(st.lastTs-st.firstTs) + interval) / 1000
+}
+
+// build returns the source metadata with the computed fields filled in, file
+// positions are offset by base.
+func (st *injectStats) build(base int64) *Metadata {
+	meta := *st.meta
+	m := &meta
+	m.Present = make(map[string]bool)
+	for key := range st.meta.Present {
+		m.Present[key] = true
+	}
+
+	m.SetPresent("lasttimestamp", "duration", "datasize", "videosize", "audiosize",
+		"hasVideo", "hasAudio", "hasMetadata", "hasKeyframes", "canSeekToEnd", "filesize")
+	m.LastTimestamp = float64(st.lastTs) / 1000
+	m.Duration = st.duration()
+	m.DataSize = st.dataSize
+	m.VideoSize = st.videoSize
+	m.AudioSize = st.audioSize
+	m.HasVideo = st.firstVideo != nil
+	m.HasAudio = st.firstAudio != nil
+	m.HasMetadata = true
+	m.HasKeyframes = len(st.keyTimes) > 0
+	m.CanSeekToEnd = st.lastVideo != nil && st.lastVideo.IsKeyFrame()
+	m.MetadataCreator = metadataCreator
+
+	if st.firstVideo != nil {
+		m.SetPresent("videocodecid")
+		m.VideoCodecID = st.firstVideo.CodecID
+		if st.firstVideo.IsExHeader {
+			m.VideoCodecID = fourccID(st.firstVideo.FourCC)
+		}
+		if m.Duration > 0 {
+			m.VideoDataRate = float64(m.VideoSize) * 8 / 1000 / m.Duration
+			if interval := st.video.interval(); m.FrameRate == 0 && interval > 0 {
+				m.FrameRate = 1000 / interval
+			}
+		}
+	}
+
+	if st.firstAudio != nil {
+		m.SetPresent("audiocodecid")
+		a := st.firstAudio
+		if a.IsExHeader {
+			// rate and channels live in the codec configuration
+			m.AudioCodecID = fourccID(a.FourCC)
+		} else {
+			m.SetPresent("audiosamplerate", "audiosamplesize", "stereo")
+			m.AudioCodecID = a.SoundFormat
+			m.AudioSampleRate = soundRates[a.SoundRate]
+			m.AudioSampleSize = 8
+			if a.SoundSize == 1 {
+				m.AudioSampleSize = 16
+			}
+			m.Stereo = a.SoundType == 1
+		}
+
+		// the legacy fields are fixed for AAC, the configuration tells
+		if st.audioRate > 0 {
+			m.SetPresent("audiosamplerate")
+			m.AudioSampleRate = st.audioRate
+		}
+		if st.channels > 0 {
+			m.SetPresent("stereo")
+			m.Stereo = st.channels == 2
+		}
+
+		if m.Duration > 0 {
+			m.AudioDataRate = float64(m.AudioSize) * 8 / 1000 / m.Duration
+		}
+	}
+
+	// values of the source would point into its layout
+	m.Keyframes = nil
+	m.LastKeyframeTimestamp = 0
+	m.LastKeyframeLocation = 0
+	delete(m.Present, "lastkeyframetimestamp")
+	delete(m.Present, "lastkeyframelocation")
+	if m.HasKeyframes {
+		m.Keyframes = &Keyframes{Times: st.keyTimes}
+		for _, off := range st.keyOffsets {
+			m.Keyframes.FilePositions = append(m.Keyframes.FilePositions, base+off)
+		}
+		m.SetPresent("lastkeyframetimestamp", "lastkeyframelocation")
+		m.LastKeyframeTimestamp = float64(st.lastKeyTs) / 1000
+		m.LastKeyframeLocation = base + st.lastKeyOff
+	}
+
+	m.FileSize = base + st.dataSize
+	return m
+}
+
+// InjectMetadata copies the FLV in r to w with a rebuilt onMetaData tag
+// carrying duration, file size, data rates and a keyframes index, the way
+// yamdi does. Existing onMetaData tags are replaced, unknown keys are kept.
+func InjectMetadata(r io.ReadSeeker, w io.Writer) error {
+	st, err := scanForInject(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	d := NewDemuxer(r)
+	header, err := d.ReadHeader()
+	if err != nil {
+		return err
+	}
+
+	// numbers have a fixed AMF0 size, so the metadata tag size does not
+	// depend on the positions it carries. Any non-zero base keeps the same
+	// set of keys as the final one.
+	probe := st.build(HeaderSize + 4).MetaData()
+	script, err := writeScript(&PacketScript{Name: "onMetaData", Values: []interface{}{probe}})
+	if err != nil {
+		return err
+	}
+
+	base := int64(HeaderSize+4) + int64(TagHeaderSize+len(script)+4)
+	meta := st.build(base)
+
+	out := NewHeader(meta.HasAudio, meta.HasVideo)
+	out.Version = header.Version
+
+	m := NewMuxer(w)
+	if err := m.WriteHeader(out); err != nil {
+		return err
+	}
+
+	if err := m.WriteMetadata(meta.MetaData()); err != nil {
+		return err
+	}
+
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if pkt.Type == PKT_HEADER || isOnMetaData(pkt) {
+			continue
+		}
+
+		if err := m.WriteTag(pkt.Tag); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectMetadata(t *testing.T) {
+	src := append(buildFLV(), buildTag(Video, 80, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 1, 0x65})...)
+
+	var out bytes.Buffer
+	assert.Nil(t, InjectMetadata(bytes.NewReader(src), &out))
+
+	d := NewDemuxer(bytes.NewReader(out.Bytes()))
+	var tags []*Packet
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		tags = append(tags, pkt)
+	}
+
+	md := d.Metadata()
+	assert.NotNil(t, md)
+	assert.Equal(t, int64(out.Len()), md.FileSize)
+	// up to the audio tag plus one 40ms frame interval
+	assert.Equal(t, float64(0x01000010+40)/1000, md.Duration)
+	assert.True(t, md.HasVideo)
+	assert.True(t, md.HasAudio)
+	assert.True(t, md.Stereo)
+	assert.Equal(t, FLV_CODECID_AAC, md.AudioCodecID)
+	assert.Equal(t, "media-go", md.MetadataCreator)
+
+	assert.True(t, md.CanSeekToEnd)
+
+	// header, onMetaData, the sequence header, audio, two frames
+	assert.Equal(t, 6, len(tags))
+	assert.Equal(t, []float64{0.08}, md.Keyframes.Times)
+	assert.Equal(t, []int64{tags[5].Tag.Offset}, md.Keyframes.FilePositions)
+	assert.Equal(t, tags[5].Tag.Offset, md.LastKeyframeLocation)
+}
+
+func TestInjectMetadataNoAudio(t *testing.T) {
+	src := []byte{'F', 'L', 'V', 1, 0x01, 0, 0, 0, 9, 0, 0, 0, 0}
+	src = append(src, buildTag(Video, 0, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 1, 0x65})...)
+
+	var out bytes.Buffer
+	assert.Nil(t, InjectMetadata(bytes.NewReader(src), &out))
+
+	d := NewDemuxer(bytes.NewReader(out.Bytes()))
+	for d.Metadata() == nil {
+		_, err := d.ReadPacket()
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	md := d.Metadata()
+	assert.False(t, md.HasAudio)
+	assert.True(t, md.Present["hasAudio"])
+	assert.True(t, md.Present["duration"])
+	assert.False(t, md.Present["audiocodecid"])
+}
+
+func TestInjectMetadataDuration(t *testing.T) {
+	src := []byte{'F', 'L', 'V', 1, 0x01, 0, 0, 0, 9, 0, 0, 0, 0}
+	for _, ts := range []int{1000, 1040, 1080} {
+		src = append(src, buildTag(Video, ts, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 1, 0x65})...)
+	}
+
+	var out bytes.Buffer
+	assert.Nil(t, InjectMetadata(bytes.NewReader(src), &out))
+
+	d := NewDemuxer(bytes.NewReader(out.Bytes()))
+	for d.Metadata() == nil {
+		_, err := d.ReadPacket()
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	md := d.Metadata()
+	assert.InDelta(t, 0.12, md.Duration, 1e-9)
+	assert.InDelta(t, 25, md.FrameRate, 1e-9)
+	assert.Equal(t, 1.08, md.LastTimestamp)
+}
+
+func TestInjectMetadataAudioConfig(t *testing.T) {
+	inject := func(tags ...[]byte) *Metadata {
+		src := []byte{'F', 'L', 'V', 1, 0x04, 0, 0, 0, 9, 0, 0, 0, 0}
+		for _, tag := range tags {
+			src = append(src, buildTag(Audio, 0, tag)...)
+		}
+
+		var out bytes.Buffer
+		assert.Nil(t, InjectMetadata(bytes.NewReader(src), &out))
+
+		d := NewDemuxer(bytes.NewReader(out.Bytes()))
+		for d.Metadata() == nil {
+			if _, err := d.ReadPacket(); err != nil {
+				return nil
+			}
+		}
+		return d.Metadata()
+	}
+
+	// HE-AAC, 24 kHz core signalled as 44 kHz stereo in the tag header
+	md := inject([]byte{0xaf, 0, 0x2b, 0x11, 0x88, 0x00})
+	assert.Equal(t, 48000.0, md.AudioSampleRate)
+	assert.True(t, md.Stereo)
+
+	// enhanced mp4a, 44.1 kHz mono
+	md = inject([]byte{0x90 | FLV_AUDIO_PACKETTYPE_SEQUENCE_START, 'm', 'p', '4', 'a', 0x12, 0x08})
+	assert.Equal(t, 44100.0, md.AudioSampleRate)
+	assert.False(t, md.Stereo)
+	assert.True(t, md.Present["stereo"])
+
+	// mono Opus
+	md = inject(append([]byte{0x90 | FLV_AUDIO_PACKETTYPE_SEQUENCE_START, 'O', 'p', 'u', 's'},
+		'O', 'p', 'u', 's', 'H', 'e', 'a', 'd', 1, 1, 0x38, 0x01, 0x80, 0xbb, 0, 0, 0, 0, 0))
+	assert.Equal(t, 48000.0, md.AudioSampleRate)
+	assert.False(t, md.Stereo)
+	assert.Equal(t, fourccID(FOURCC_OPUS), md.AudioCodecID)
+}
+
+func TestInjectMetadataStaleKeyframes(t *testing.T) {
+	var src bytes.Buffer
+	m := NewMuxer(&src)
+	assert.Nil(t, m.WriteHeader(NewHeader(true, false)))
+	assert.Nil(t, m.WriteMetadata(PacketMetaData{"lastkeyframelocation": 12345.0, "lastkeyframetimestamp": 4.0}))
+	data := append(src.Bytes(), buildTag(Audio, 0, []byte{0xaf, 1, 0x21})...)
+
+	var out bytes.Buffer
+	assert.Nil(t, InjectMetadata(bytes.NewReader(data), &out))
+
+	d := NewDemuxer(bytes.NewReader(out.Bytes()))
+	for d.Metadata() == nil {
+		_, err := d.ReadPacket()
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+
+	meta := d.Metadata().MetaData()
+	assert.NotContains(t, meta, "lastkeyframelocation")
+	assert.NotContains(t, meta, "lastkeyframetimestamp")
+	assert.NotContains(t, meta, "keyframes")
+	assert.Equal(t, false, meta["hasKeyframes"])
+}
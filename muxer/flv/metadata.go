@@ -0,0 +1,283 @@
+package flv
+
+import (
+	"sort"
+
+	"media-go/codec/amf"
+)
+
+// Keyframes is the seek index injected by yamdi/flvtool2, times are in
+// seconds and file positions point at the tag header of each key frame.
+type Keyframes struct {
+	Times         []float64
+	FilePositions []int64
+}
+
+// Lookup returns the index of the last key frame at or before t, -1 if t is
+// before the first one.
+func (k *Keyframes) Lookup(t float64) int {
+	return sort.Search(len(k.Times), func(i int) bool { return k.Times[i] > t }) - 1
+}
+
+// Metadata is the typed form of the onMetaData ECMA array. Unknown keys are
+// kept in Extra. Present holds the keys of the typed fields that are written
+// back even when zero, ParseMetadata sets every typed key it finds.
+type Metadata struct {
+	Duration              float64
+	FileSize              int64
+	Width                 int
+	Height                int
+	FrameRate             float64
+	VideoDataRate         float64
+	VideoCodecID          int
+	AudioDataRate         float64
+	AudioSampleRate       float64
+	AudioSampleSize       int
+	AudioCodecID          int
+	Stereo                bool
+	Encoder               string
+	MetadataCreator       string
+	HasVideo              bool
+	HasAudio              bool
+	HasMetadata           bool
+	HasKeyframes          bool
+	CanSeekToEnd          bool
+	DataSize              int64
+	VideoSize             int64
+	AudioSize             int64
+	LastTimestamp         float64
+	LastKeyframeTimestamp float64
+	LastKeyframeLocation  int64
+	Keyframes             *Keyframes
+	Extra                 map[string]interface{}
+	Present               map[string]bool
+}
+
+// SetPresent marks typed keys to be written even when zero.
+func (m *Metadata) SetPresent(keys ...string) {
+	if m.Present == nil {
+		m.Present = make(map[string]bool)
+	}
+
+	for _, key := range keys {
+		m.Present[key] = true
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case bool:
+		if n {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func toBool(v interface{}) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+
+	return toFloat(v) != 0
+}
+
+func toFloats(v interface{}) []float64 {
+	var items []interface{}
+	switch arr := v.(type) {
+	case []interface{}:
+		items = arr
+	case []float64:
+		return arr
+	}
+
+	values := make([]float64, 0, len(items))
+	for _, item := range items {
+		values = append(values, toFloat(item))
+	}
+
+	return values
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case amf.Object:
+		return m
+	case amf.ECMAArray:
+		return m
+	case PacketMetaData:
+		return m
+	case map[string]interface{}:
+		return m
+	}
+
+	return nil
+}
+
+func parseKeyframes(v interface{}) *Keyframes {
+	m := asMap(v)
+	if m == nil {
+		return nil
+	}
+
+	k := &Keyframes{Times: toFloats(m["times"])}
+	for _, pos := range toFloats(m["filepositions"]) {
+		k.FilePositions = append(k.FilePositions, int64(pos))
+	}
+
+	// keep the two lists aligned if the file is inconsistent
+	if len(k.Times) > len(k.FilePositions) {
+		k.Times = k.Times[:len(k.FilePositions)]
+	} else {
+		k.FilePositions = k.FilePositions[:len(k.Times)]
+	}
+
+	return k
+}
+
+// ParseMetadata converts an onMetaData array into Metadata.
+func ParseMetadata(meta PacketMetaData) *Metadata {
+	m := &Metadata{Extra: make(map[string]interface{}), Present: make(map[string]bool)}
+
+	for key, value := range meta {
+		m.Present[key] = true
+		switch key {
+		case "duration":
+			m.Duration = toFloat(value)
+		case "filesize":
+			m.FileSize = int64(toFloat(value))
+		case "width":
+			m.Width = int(toFloat(value))
+		case "height":
+			m.Height = int(toFloat(value))
+		case "framerate":
+			m.FrameRate = toFloat(value)
+		case "videodatarate":
+			m.VideoDataRate = toFloat(value)
+		case "videocodecid":
+			m.VideoCodecID = int(toFloat(value))
+		case "audiodatarate":
+			m.AudioDataRate = toFloat(value)
+		case "audiosamplerate":
+			m.AudioSampleRate = toFloat(value)
+		case "audiosamplesize":
+			m.AudioSampleSize = int(toFloat(value))
+		case "audiocodecid":
+			m.AudioCodecID = int(toFloat(value))
+		case "stereo":
+			m.Stereo = toBool(value)
+		case "encoder":
+			m.Encoder, _ = value.(string)
+		case "metadatacreator":
+			m.MetadataCreator, _ = value.(string)
+		case "hasVideo":
+			m.HasVideo = toBool(value)
+		case "hasAudio":
+			m.HasAudio = toBool(value)
+		case "hasMetadata":
+			m.HasMetadata = toBool(value)
+		case "hasKeyframes":
+			m.HasKeyframes = toBool(value)
+		case "canSeekToEnd":
+			m.CanSeekToEnd = toBool(value)
+		case "datasize":
+			m.DataSize = int64(toFloat(value))
+		case "videosize":
+			m.VideoSize = int64(toFloat(value))
+		case "audiosize":
+			m.AudioSize = int64(toFloat(value))
+		case "lasttimestamp":
+			m.LastTimestamp = toFloat(value)
+		case "lastkeyframetimestamp":
+			m.LastKeyframeTimestamp = toFloat(value)
+		case "lastkeyframelocation":
+			m.LastKeyframeLocation = int64(toFloat(value))
+		case "keyframes":
+			if m.Keyframes = parseKeyframes(value); m.Keyframes == nil {
+				delete(m.Present, key)
+				m.Extra[key] = value
+			}
+		default:
+			delete(m.Present, key)
+			m.Extra[key] = value
+		}
+	}
+
+	return m
+}
+
+// MetaData converts m back into an onMetaData array. Zero values of the typed
+// fields are left out unless their key is in Present.
+func (m *Metadata) MetaData() PacketMetaData {
+	meta := PacketMetaData{}
+	for key, value := range m.Extra {
+		meta[key] = value
+	}
+
+	numbers := map[string]float64{
+		"duration":              m.Duration,
+		"filesize":              float64(m.FileSize),
+		"width":                 float64(m.Width),
+		"height":                float64(m.Height),
+		"framerate":             m.FrameRate,
+		"videodatarate":         m.VideoDataRate,
+		"videocodecid":          float64(m.VideoCodecID),
+		"audiodatarate":         m.AudioDataRate,
+		"audiosamplerate":       m.AudioSampleRate,
+		"audiosamplesize":       float64(m.AudioSampleSize),
+		"audiocodecid":          float64(m.AudioCodecID),
+		"datasize":              float64(m.DataSize),
+		"videosize":             float64(m.VideoSize),
+		"audiosize":             float64(m.AudioSize),
+		"lasttimestamp":         m.LastTimestamp,
+		"lastkeyframetimestamp": m.LastKeyframeTimestamp,
+		"lastkeyframelocation":  float64(m.LastKeyframeLocation),
+	}
+	for key, value := range numbers {
+		if value != 0 || m.Present[key] {
+			meta[key] = value
+		}
+	}
+
+	flags := map[string]bool{
+		"stereo":       m.Stereo,
+		"hasVideo":     m.HasVideo,
+		"hasAudio":     m.HasAudio,
+		"hasMetadata":  m.HasMetadata,
+		"hasKeyframes": m.HasKeyframes,
+		"canSeekToEnd": m.CanSeekToEnd,
+	}
+	for key, value := range flags {
+		if value || m.Present[key] {
+			meta[key] = value
+		}
+	}
+
+	if len(m.Encoder) > 0 || m.Present["encoder"] {
+		meta["encoder"] = m.Encoder
+	}
+
+	if len(m.MetadataCreator) > 0 || m.Present["metadatacreator"] {
+		meta["metadatacreator"] = m.MetadataCreator
+	}
+
+	if m.Keyframes != nil {
+		times := make([]interface{}, len(m.Keyframes.Times))
+		positions := make([]interface{}, len(m.Keyframes.FilePositions))
+		for i, t := range m.Keyframes.Times {
+			times[i] = t
+		}
+		for i, pos := range m.Keyframes.FilePositions {
+			positions[i] = float64(pos)
+		}
+
+		meta["keyframes"] = amf.Object{"times": times, "filepositions": positions}
+	}
+
+	return meta
+}
@@ -0,0 +1,78 @@
+package flv
+
+import (
+	"bytes"
+	"testing"
+
+	"media-go/codec/amf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	meta := PacketMetaData{
+		"duration":     600.177,
+		"width":        1280.0,
+		"height":       720.0,
+		"framerate":    23.976,
+		"audiocodecid": 10.0,
+		"stereo":       true,
+		"encoder":      "Lavf58.45.100",
+		"major_brand":  "isom",
+		"keyframes": amf.Object{
+			"times":         []interface{}{0.0, 2.0, 4.0},
+			"filepositions": []interface{}{13.0, 5000.0, 9000.0},
+		},
+	}
+
+	var out bytes.Buffer
+	m := NewMuxer(&out)
+	assert.Nil(t, m.WriteMetadata(meta))
+
+	d := NewDemuxer(bytes.NewReader(out.Bytes()))
+	for d.Metadata() == nil {
+		_, err := d.ReadPacket()
+		require.NoError(t, err)
+	}
+
+	md := d.Metadata()
+	assert.Equal(t, 600.177, md.Duration)
+	assert.Equal(t, 1280, md.Width)
+	assert.Equal(t, 720, md.Height)
+	assert.Equal(t, FLV_CODECID_AAC, md.AudioCodecID)
+	assert.True(t, md.Stereo)
+	assert.Equal(t, "Lavf58.45.100", md.Encoder)
+	assert.Equal(t, "isom", md.Extra["major_brand"])
+	assert.Equal(t, []int64{13, 5000, 9000}, md.Keyframes.FilePositions)
+
+	assert.Equal(t, -1, md.Keyframes.Lookup(-1))
+	assert.Equal(t, 0, md.Keyframes.Lookup(1.9))
+	assert.Equal(t, 1, md.Keyframes.Lookup(2))
+	assert.Equal(t, 2, md.Keyframes.Lookup(100))
+
+	assert.Equal(t, md, ParseMetadata(md.MetaData()))
+}
+
+func TestMetadataZeroValues(t *testing.T) {
+	// Linear PCM, no video, mono
+	meta := PacketMetaData{
+		"duration":     0.0,
+		"audiocodecid": 0.0,
+		"hasVideo":     false,
+		"hasAudio":     true,
+		"stereo":       false,
+		"encoder":      "",
+	}
+
+	md := ParseMetadata(meta)
+	assert.Equal(t, meta, md.MetaData())
+
+	// fields never set stay out
+	md.Width = 0
+	_, ok := md.MetaData()["width"]
+	assert.False(t, ok)
+
+	md.SetPresent("width")
+	assert.Equal(t, 0.0, md.MetaData()["width"])
+}
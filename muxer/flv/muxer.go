@@ -0,0 +1,211 @@
+package flv
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"media-go/codec/amf"
+)
+
+var ErrHeaderWritten = errors.New("flv: header already written")
+
+// Muxer writes an FLV stream: the file header followed by tags, each one
+// trailed by its PreviousTagSize.
+type Muxer struct {
+	w      io.Writer
+	header bool
+	offset int64
+}
+
+func NewMuxer(w io.Writer) *Muxer {
+	return &Muxer{w: w}
+}
+
+// NewHeader builds a version 1 file header with the given stream flags.
+func NewHeader(hasAudio, hasVideo bool) *FLVHeader {
+	h := &FLVHeader{Magic: [3]byte{'F', 'L', 'V'}, Version: 1, HeaderSize: HeaderSize}
+	if hasAudio {
+		h.Flags |= 0x04
+	}
+	if hasVideo {
+		h.Flags |= 0x01
+	}
+
+	return h
+}
+
+// Offset returns the number of bytes written so far, which is the file
+// position of the next tag.
+func (m *Muxer) Offset() int64 { return m.offset }
+
+func (m *Muxer) write(data []byte) error {
+	n, err := m.w.Write(data)
+	m.offset += int64(n)
+	return err
+}
+
+// WriteHeader writes the 9 bytes file header and PreviousTagSize0.
+func (m *Muxer) WriteHeader(h *FLVHeader) error {
+	if m.header {
+		return ErrHeaderWritten
+	}
+
+	version := h.Version
+	if version == 0 {
+		version = 1
+	}
+
+	buf := make([]byte, HeaderSize+4)
+	copy(buf, "FLV")
+	buf[3] = version
+	buf[4] = h.Flags & 0x05
+	binary.BigEndian.PutUint32(buf[5:], HeaderSize)
+
+	m.header = true
+	return m.write(buf)
+}
+
+// WriteTag writes a tag, the data size is taken from Data.
+func (m *Muxer) WriteTag(tag *FLVTag) error {
+	if !m.header {
+		if err := m.WriteHeader(NewHeader(true, true)); err != nil {
+			return err
+		}
+	}
+
+	size := len(tag.Data)
+	if size >= 1<<24 {
+		return ErrInvalidTag
+	}
+
+	ts := tag.Header.Dts()
+	buf := make([]byte, TagHeaderSize, TagHeaderSize+size+4)
+	buf[0] = byte(tag.Header.Type)
+	putUint24(buf[1:], size)
+	putUint24(buf[4:], ts&0xffffff)
+	buf[7] = byte(ts >> 24)
+	putUint24(buf[8:], tag.Header.StreamId)
+	buf = append(buf, tag.Data...)
+
+	var prev [4]byte
+	binary.BigEndian.PutUint32(prev[:], uint32(TagHeaderSize+size))
+	buf = append(buf, prev[:]...)
+
+	return m.write(buf)
+}
+
+// WritePacket writes a demuxed or synthesized packet at pkt.Dts. When the
+// packet still carries its source tag the original body is copied, otherwise
+// the body is built from Data.
+func (m *Muxer) WritePacket(pkt *Packet) error {
+	if header, ok := pkt.Data.(*FLVHeader); ok {
+		return m.WriteHeader(header)
+	}
+
+	if pkt.Tag == nil {
+		tag, err := NewTag(pkt)
+		if err != nil {
+			return err
+		}
+		return m.WriteTag(tag)
+	}
+
+	tag := &FLVTag{}
+	tag.Header.Type = pkt.Tag.Header.Type
+	tag.Header.StreamId = pkt.Tag.Header.StreamId
+	tag.Data = pkt.Tag.Data
+
+	tag.Header.Timestamp = pkt.Dts & 0xffffff
+	tag.Header.TimestampEx = (pkt.Dts >> 24) & 0xff
+	return m.WriteTag(tag)
+}
+
+// NewTag builds the tag of a synthesized packet from its Data, at pkt.Dts.
+func NewTag(pkt *Packet) (*FLVTag, error) {
+	tag := &FLVTag{}
+
+	var err error
+	switch data := pkt.Data.(type) {
+	case *PacketScript:
+		tag.Header.Type = MetaData
+		tag.Data, err = writeScript(data)
+	case *PacketVideo:
+		tag.Header.Type = Video
+		tag.Data = writeVideo(data)
+	case *PacketAudio:
+		tag.Header.Type = Audio
+		tag.Data = writeAudio(data)
+	default:
+		return nil, ErrInvalidTag
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	tag.Header.DataSize = len(tag.Data)
+	tag.Header.Timestamp = pkt.Dts & 0xffffff
+	tag.Header.TimestampEx = (pkt.Dts >> 24) & 0xff
+	return tag, nil
+}
+
+// WriteMetadata writes an onMetaData script tag at timestamp 0.
+func (m *Muxer) WriteMetadata(meta PacketMetaData) error {
+	return m.WritePacket(&Packet{
+		Type: PKT_SCRIPT,
+		Data: &PacketScript{Name: "onMetaData", Values: []interface{}{amf.ECMAArray(meta)}},
+	})
+}
+
+func putUint24(b []byte, v int) {
+	b[0] = byte(v >> 16)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v)
+}
+
+func writeScript(pkt *PacketScript) ([]byte, error) {
+	e := amf.NewEncoder()
+	if err := e.Encode(pkt.Name); err != nil {
+		return nil, err
+	}
+
+	for _, v := range pkt.Values {
+		if meta, ok := v.(PacketMetaData); ok {
+			v = amf.ECMAArray(meta)
+		}
+
+		if err := e.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.Bytes(), nil
+}
+
+func writeVideo(pkt *PacketVideo) []byte {
+	if pkt.IsExHeader {
+		return writeExVideo(pkt)
+	}
+
+	data := []byte{byte(pkt.FrameType<<4 | pkt.CodecID&0x0f)}
+	if pkt.CodecID == FLV_CODECID_H264 || pkt.CodecID == FLV_CODECID_H265 {
+		data = append(data, byte(pkt.AVCPacketType), 0, 0, 0)
+		putUint24(data[2:], pkt.Cts&0xffffff)
+	}
+
+	return append(data, pkt.Data...)
+}
+
+func writeAudio(pkt *PacketAudio) []byte {
+	if pkt.IsExHeader {
+		return writeExAudio(pkt)
+	}
+
+	data := []byte{byte(pkt.SoundFormat<<4 | (pkt.SoundRate&0x03)<<2 | (pkt.SoundSize&0x01)<<1 | pkt.SoundType&0x01)}
+	if pkt.SoundFormat == FLV_CODECID_AAC {
+		data = append(data, byte(pkt.AACPacketType))
+	}
+
+	return append(data, pkt.Data...)
+}
@@ -0,0 +1,60 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMuxerRoundTrip(t *testing.T) {
+	src := buildFLV()
+	d := NewDemuxer(bytes.NewReader(src))
+
+	var out bytes.Buffer
+	m := NewMuxer(&out)
+	for {
+		pkt, err := d.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		assert.Nil(t, m.WritePacket(pkt))
+	}
+
+	assert.Equal(t, src, out.Bytes())
+}
+
+func TestMuxerSynthesized(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMuxer(&out)
+	assert.Nil(t, m.WriteHeader(NewHeader(false, true)))
+	assert.Nil(t, m.WriteMetadata(PacketMetaData{"duration": 12.5}))
+	assert.Nil(t, m.WritePacket(&Packet{Dts: 0x01000020, Data: &PacketVideo{
+		FrameType: FLV_FRAME_KEY, CodecID: FLV_CODECID_H264, AVCPacketType: 1, Cts: -20, Data: []byte{0, 0, 0, 1, 0x65},
+	}}))
+	assert.Equal(t, ErrHeaderWritten, m.WriteHeader(NewHeader(true, true)))
+
+	d := NewDemuxer(bytes.NewReader(out.Bytes()))
+	header, err := d.ReadHeader()
+	assert.Nil(t, err)
+	assert.False(t, header.HasAudio())
+	assert.True(t, header.HasVideo())
+
+	d.ReadPacket()
+	pkt, err := d.ReadPacket()
+	assert.Nil(t, err)
+	script := pkt.Data.(*PacketScript)
+	assert.Equal(t, "onMetaData", script.Name)
+	assert.Equal(t, 12.5, script.MetaData()["duration"])
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, 0x01000020, pkt.Dts)
+	assert.Equal(t, 0x01000020-20, pkt.Pts)
+	assert.True(t, pkt.Data.(*PacketVideo).IsKeyFrame())
+
+	_, err = d.ReadPacket()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,185 @@
+package flv
+
+import (
+	"fmt"
+
+	"media-go/codec/aac"
+	"media-go/codec/amf"
+)
+
+type PacketType int
+
+const (
+	PKT_HEADER PacketType = iota
+	PKT_SCRIPT
+	PKT_AUDIO
+	PKT_VIDEO
+	PKT_UNKNOWN
+)
+
+// Packet is a demuxed FLV unit. Data holds *FLVHeader, *PacketScript,
+// *PacketAudio, *PacketVideo or the raw tag body for unknown tag types.
+type Packet struct {
+	Type PacketType
+	Dts  int
+	Pts  int
+	Tag  *FLVTag
+	Data interface{}
+}
+
+type PacketMetaData map[string]interface{}
+
+type PacketScript struct {
+	Name   string
+	Values []interface{}
+}
+
+// MetaData returns the first object or ECMA array carried by the script tag.
+func (p *PacketScript) MetaData() PacketMetaData {
+	for _, v := range p.Values {
+		switch m := v.(type) {
+		case amf.ECMAArray:
+			return PacketMetaData(m)
+		case amf.Object:
+			return PacketMetaData(m)
+		}
+	}
+
+	return nil
+}
+
+func (p *PacketScript) String() string {
+	str := fmt.Sprintf("\t%s\n", p.Name)
+	for _, v := range p.Values {
+		switch m := v.(type) {
+		case amf.ECMAArray:
+			for key, value := range m {
+				str += fmt.Sprintf("\t\t%-20s:\t%v\n", key, value)
+			}
+		default:
+			str += fmt.Sprintf("\t%v\n", v)
+		}
+	}
+
+	return str
+}
+
+type PacketVideo struct {
+	FrameType     int
+	CodecID       int
+	AVCPacketType int
+	Cts           int
+	Data          []byte // codec payload following the video tag header
+
+	// Enhanced RTMP, CodecID and AVCPacketType are unused
+	IsExHeader          bool
+	PacketType          int // FLV_PACKETTYPE_*
+	FourCC              string
+	Command             int // FLV_VIDEO_COMMAND_* of a command frame
+	MultitrackType      int
+	Tracks              []*Track // only set for multitrack packets
+	TimestampOffsetNano int
+	Meta                *PacketScript // decoded FLV_PACKETTYPE_METADATA body
+}
+
+func (p *PacketVideo) IsKeyFrame() bool { return p.FrameType == FLV_FRAME_KEY }
+
+// IsSeqHeader reports a decoder configuration packet, legacy AVC/HEVC or
+// enhanced SequenceStart.
+func (p *PacketVideo) IsSeqHeader() bool {
+	if p.IsExHeader {
+		return p.PacketType == FLV_PACKETTYPE_SEQUENCE_START
+	}
+
+	return p.AVCPacketType == 0 && (p.CodecID == FLV_CODECID_H264 || p.CodecID == FLV_CODECID_H265)
+}
+
+func (p *PacketVideo) String() string {
+	if p.IsExHeader {
+		return fmt.Sprintf("type: (%d|%8s) fourcc: %s packet: (%d|%s)",
+			p.FrameType, VideoFrameType[p.FrameType], p.FourCC, p.PacketType, PacketTypeMap[p.PacketType])
+	}
+
+	return fmt.Sprintf("type: (%d|%8s) codec: (%d|%s)",
+		p.FrameType, VideoFrameType[p.FrameType], p.CodecID, VideoCodecMap[p.CodecID])
+}
+
+type PacketAudio struct {
+	SoundFormat   int
+	SoundRate     int
+	SoundSize     int
+	SoundType     int
+	AACPacketType int
+	Data          []byte // codec payload following the audio tag header
+
+	// AAC only, AACConfig is set on sequence headers and AACFrame on raw
+	// frames once a sequence header was seen
+	AACConfig *aac.AudioSpecificConfig
+	AACFrame  *aac.Frame
+
+	// Enhanced RTMP, SoundFormat is FLV_CODECID_EX_HEADER and the other
+	// legacy fields are unused
+	IsExHeader          bool
+	PacketType          int // FLV_AUDIO_PACKETTYPE_*
+	FourCC              string
+	MultitrackType      int
+	Tracks              []*Track // only set for multitrack packets
+	TimestampOffsetNano int
+	Channels            *ChannelConfig // FLV_AUDIO_PACKETTYPE_MULTICHANNEL_CONFIG body
+}
+
+// IsSeqHeader reports a decoder configuration packet, legacy AAC or enhanced
+// SequenceStart.
+func (p *PacketAudio) IsSeqHeader() bool {
+	if p.IsExHeader {
+		return p.PacketType == FLV_AUDIO_PACKETTYPE_SEQUENCE_START
+	}
+
+	return p.SoundFormat == FLV_CODECID_AAC && p.AACPacketType == 0
+}
+
+func (p *PacketAudio) String() string {
+	if p.IsExHeader {
+		return fmt.Sprintf("|%s-%s", p.FourCC, AudioPacketTypeMap[p.PacketType])
+	}
+
+	accuracy := "8bits"
+	if p.SoundSize == 1 {
+		accuracy = "16bits"
+	}
+
+	audioType := "sndMono"
+	if p.SoundType == 1 {
+		audioType = "sndStereo"
+	}
+
+	// the FLV header fields are fixed for AAC, report the real ones
+	rate := AudioSampleRate[p.SoundRate]
+	switch {
+	case p.AACConfig != nil:
+		rate = fmt.Sprintf("%gkHz", float64(p.AACConfig.OutputSampleRate())/1000)
+		audioType = fmt.Sprintf("%dch", p.AACConfig.OutputChannels())
+	case p.AACFrame != nil:
+		rate = fmt.Sprintf("%gkHz", float64(p.AACFrame.SampleRate)/1000)
+		audioType = fmt.Sprintf("%dch", p.AACFrame.Channels)
+	}
+
+	str := fmt.Sprintf("|%s-%s-%s-%s", AudioCodec[p.SoundFormat], rate, accuracy, audioType)
+	if p.AACConfig != nil {
+		str += "|" + p.AACConfig.Profile()
+	}
+
+	return str
+}
+
+// isSeqHeader reports video and audio sequence header packets.
+func isSeqHeader(pkt *Packet) bool {
+	switch data := pkt.Data.(type) {
+	case *PacketVideo:
+		return data.IsSeqHeader()
+	case *PacketAudio:
+		return data.IsSeqHeader()
+	}
+
+	return false
+}
@@ -0,0 +1,470 @@
+package flv
+
+import (
+	"errors"
+	"io"
+	"sort"
+)
+
+var (
+	ErrNotSeekable = errors.New("flv: reader is not seekable")
+	ErrNoKeyframe  = errors.New("flv: no key frame to seek to")
+)
+
+// IndexEntry locates a video key frame, Offset is the tag header position.
+type IndexEntry struct {
+	Dts    int
+	Offset int64
+}
+
+// tagInfo is what a scan learns from a tag header and the first two body
+// bytes, without reading the rest of the body. Enhanced bodies with ModEx
+// prefixes or a multitrack header are read up to the real packet type.
+type tagInfo struct {
+	header TagHeader
+	offset int64
+	body   []byte
+}
+
+func (t *tagInfo) isVideo() bool { return t.header.Type&0x1f == Video && len(t.body) > 0 }
+
+func (t *tagInfo) isExVideo() bool { return t.isVideo() && t.body[0]&FLV_VIDEO_EX_HEADER != 0 }
+
+func (t *tagInfo) isExAudio() bool {
+	return t.header.Type&0x1f == Audio && len(t.body) > 0 && int(t.body[0]>>4) == FLV_CODECID_EX_HEADER
+}
+
+// multitrack returns the multitrack packet type of an enhanced tag, -1 for
+// other tags.
+func (t *tagInfo) multitrack() int {
+	switch {
+	case t.isExVideo():
+		return FLV_PACKETTYPE_MULTITRACK
+	case t.isExAudio():
+		return FLV_AUDIO_PACKETTYPE_MULTITRACK
+	}
+
+	return -1
+}
+
+// wrapped reports an enhanced tag whose first byte does not hold the real
+// packet type.
+func (t *tagInfo) wrapped() bool {
+	multitrack := t.multitrack()
+	if multitrack < 0 {
+		return false
+	}
+
+	packetType := int(t.body[0] & 0x0f)
+	return packetType == FLV_PACKETTYPE_MODEX || packetType == multitrack
+}
+
+// packetType returns the packet type of an enhanced tag, -1 if the body is
+// too short to tell.
+func (t *tagInfo) packetType() int {
+	packetType, ok := exPacketType(t.body, t.multitrack())
+	if !ok {
+		return -1
+	}
+
+	return packetType
+}
+
+func (t *tagInfo) frameType() int {
+	if t.isExVideo() {
+		return int(t.body[0]>>4) & 0x07
+	}
+
+	return int(t.body[0] >> 4)
+}
+
+func (t *tagInfo) isKeyFrame() bool {
+	return t.isVideo() && t.frameType() == FLV_FRAME_KEY && !t.isSeqHeader()
+}
+
+// isSeqHeader reports video and audio sequence headers.
+func (t *tagInfo) isSeqHeader() bool {
+	if t.isExVideo() {
+		return t.packetType() == FLV_PACKETTYPE_SEQUENCE_START
+	}
+
+	if t.isExAudio() {
+		return t.packetType() == FLV_AUDIO_PACKETTYPE_SEQUENCE_START
+	}
+
+	if len(t.body) < 2 || t.body[1] != 0 {
+		return false
+	}
+
+	switch t.header.Type & 0x1f {
+	case Video:
+		codec := int(t.body[0] & 0x0f)
+		return codec == FLV_CODECID_H264 || codec == FLV_CODECID_H265
+	case Audio:
+		return int(t.body[0]>>4) == FLV_CODECID_AAC
+	}
+
+	return false
+}
+
+func (t *tagInfo) next() int64 {
+	return t.offset + int64(TagHeaderSize+t.header.DataSize+4)
+}
+
+// exPrefixSize is the body prefix read for wrapped enhanced tags.
+const exPrefixSize = 32
+
+type seqHeader struct {
+	offset int64
+	video  bool
+}
+
+func (d *Demuxer) seeker() (io.ReadSeeker, error) {
+	rs, ok := d.r.(io.ReadSeeker)
+	if !ok {
+		return nil, ErrNotSeekable
+	}
+
+	return rs, nil
+}
+
+// firstTag returns the position of the first tag header.
+func (d *Demuxer) firstTag() int64 {
+	return int64(d.flv.Header.HeaderSize) + 4
+}
+
+func (d *Demuxer) scanTag(rs io.ReadSeeker, offset int64) (*tagInfo, error) {
+	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, TagHeaderSize+2)
+	n, err := io.ReadFull(rs, buf)
+	if n < TagHeaderSize {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	t := &tagInfo{offset: offset}
+	t.header.Type = int8(buf[0])
+	t.header.DataSize = int(bytesToInt64(buf[1:4]))
+	t.header.Timestamp = int(bytesToInt64(buf[4:7]))
+	t.header.TimestampEx = int(buf[7])
+	t.header.StreamId = int(bytesToInt64(buf[8:11]))
+
+	body := n - TagHeaderSize
+	if body > t.header.DataSize {
+		body = t.header.DataSize
+	}
+	t.body = buf[TagHeaderSize : TagHeaderSize+body]
+
+	// a short prefix holds the usual ModEx and multitrack headers, larger
+	// ModEx data needs the whole body
+	if t.wrapped() {
+		if err := t.readBody(rs, exPrefixSize); err != nil {
+			return nil, err
+		}
+
+		if t.packetType() < 0 {
+			if err := t.readBody(rs, t.header.DataSize); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// readBody extends the body read by scanTag to n bytes, rs must still be
+// positioned after what was read.
+func (t *tagInfo) readBody(rs io.Reader, n int) error {
+	if n > t.header.DataSize {
+		n = t.header.DataSize
+	}
+
+	if n <= len(t.body) {
+		return nil
+	}
+
+	body := make([]byte, n)
+	copy(body, t.body)
+	if _, err := io.ReadFull(rs, body[len(t.body):]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	t.body = body
+	return nil
+}
+
+// readTagAt reads a complete tag at the given tag header position.
+func (d *Demuxer) readTagAt(rs io.ReadSeeker, offset int64) (*FLVTag, error) {
+	t, err := d.scanTag(rs, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &FLVTag{Header: t.header, Offset: offset, Data: make([]byte, t.header.DataSize)}
+	if _, err := rs.Seek(offset+TagHeaderSize, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(rs, tag.Data); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return tag, nil
+}
+
+// addSeqHeader records a sequence header position, keeping d.seqs sorted.
+func (d *Demuxer) addSeqHeader(offset int64, video bool) {
+	i := sort.Search(len(d.seqs), func(i int) bool { return d.seqs[i].offset >= offset })
+	if i < len(d.seqs) && d.seqs[i].offset == offset {
+		return
+	}
+
+	d.seqs = append(d.seqs, seqHeader{})
+	copy(d.seqs[i+1:], d.seqs[i:])
+	d.seqs[i] = seqHeader{offset: offset, video: video}
+}
+
+// scan walks tag headers from the tag at offset, which must be the first tag
+// or d.scanned, skipping the bodies, until stop returns true or the file
+// ends. Sequence headers are recorded on the way.
+func (d *Demuxer) scan(rs io.ReadSeeker, offset int64, stop func(t *tagInfo) bool) error {
+	for {
+		t, err := d.scanTag(rs, offset)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// a truncated last tag is not worth failing the index for
+			if offset > d.scanned {
+				d.scanned = offset
+			}
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if t.isSeqHeader() {
+			d.addSeqHeader(t.offset, t.isVideo())
+		}
+
+		if t.next() > d.scanned {
+			d.scanned = t.next()
+		}
+
+		if stop(t) {
+			return nil
+		}
+
+		offset = t.next()
+	}
+}
+
+// BuildIndex scans the whole file for video key frames, the read position
+// is left unchanged.
+func (d *Demuxer) BuildIndex() ([]IndexEntry, error) {
+	rs, err := d.seeker()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.ReadHeader(); err != nil {
+		return nil, err
+	}
+
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Seek(pos, io.SeekStart)
+
+	index := make([]IndexEntry, 0)
+	err = d.scan(rs, d.firstTag(), func(t *tagInfo) bool {
+		if t.isKeyFrame() {
+			index = append(index, IndexEntry{Dts: t.header.Dts(), Offset: t.offset})
+		}
+		return false
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.index = index
+	return index, nil
+}
+
+// probeMetadata reads onMetaData from the first tag if it was not seen yet.
+func (d *Demuxer) probeMetadata(rs io.ReadSeeker) error {
+	if d.meta != nil {
+		return nil
+	}
+
+	tag, err := d.readTagAt(rs, d.firstTag())
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+
+	if err != nil || tag.Header.Type&0x1f != MetaData {
+		return err
+	}
+
+	if script, err := readScript(tag.Data); err == nil && script.Name == "onMetaData" {
+		d.meta = ParseMetadata(script.MetaData())
+	}
+
+	return nil
+}
+
+// keyframeFromMetadata looks ts up in the keyframes object of onMetaData and
+// checks that the file position really holds a video key frame.
+func (d *Demuxer) keyframeFromMetadata(rs io.ReadSeeker, ts int) (*IndexEntry, bool) {
+	if d.meta == nil || d.meta.Keyframes == nil || len(d.meta.Keyframes.Times) == 0 {
+		return nil, false
+	}
+
+	i := d.meta.Keyframes.Lookup(float64(ts) / 1000)
+	if i < 0 {
+		i = 0
+	}
+
+	t, err := d.scanTag(rs, d.meta.Keyframes.FilePositions[i])
+	if err != nil || !t.isVideo() || t.frameType() != FLV_FRAME_KEY {
+		return nil, false
+	}
+
+	return &IndexEntry{Dts: t.header.Dts(), Offset: t.offset}, true
+}
+
+func (d *Demuxer) keyframeFromIndex(ts int) (*IndexEntry, error) {
+	if len(d.index) == 0 {
+		return nil, ErrNoKeyframe
+	}
+
+	i := 0
+	for i+1 < len(d.index) && d.index[i+1].Dts <= ts {
+		i++
+	}
+
+	return &d.index[i], nil
+}
+
+// SeekTime positions the demuxer on the last video key frame at or before ts
+// (milliseconds), or the first one if ts precedes it, and returns its DTS.
+// The keyframes index of onMetaData is used when present, otherwise the tag
+// headers are scanned once. The sequence headers in effect at that point are
+// returned by ReadPacket before the key frame so decoding can resume. On
+// error the demuxer reads on from where it was.
+func (d *Demuxer) SeekTime(ts int) (int, error) {
+	rs, err := d.seeker()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := d.ReadHeader(); err != nil {
+		return 0, err
+	}
+
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	aacConfig := d.flv.Parser.aacConfig
+	dts, err := d.seekTime(rs, ts)
+	if err != nil {
+		// the parser still holds what was read before pos
+		rs.Seek(pos, io.SeekStart)
+		d.flv.Parser.aacConfig = aacConfig
+		return 0, err
+	}
+
+	return dts, nil
+}
+
+func (d *Demuxer) seekTime(rs io.ReadSeeker, ts int) (int, error) {
+	if err := d.probeMetadata(rs); err != nil {
+		return 0, err
+	}
+
+	var err error
+	key, ok := d.keyframeFromMetadata(rs, ts)
+	if !ok {
+		if d.index == nil {
+			if _, err := d.BuildIndex(); err != nil {
+				return 0, err
+			}
+		}
+
+		if key, err = d.keyframeFromIndex(ts); err != nil {
+			return 0, err
+		}
+	}
+
+	if d.scanned < key.Offset {
+		// only reachable with a metadata index, record every audio and
+		// video sequence header up to the key frame
+		from := d.scanned
+		if from < d.firstTag() {
+			from = d.firstTag()
+		}
+
+		err := d.scan(rs, from, func(t *tagInfo) bool { return t.offset >= key.Offset })
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var pending []*Packet
+	for _, video := range []bool{true, false} {
+		seq := d.lastSeqHeader(key.Offset, video)
+		if seq < 0 {
+			continue
+		}
+
+		tag, err := d.readTagAt(rs, seq)
+		if err != nil {
+			return 0, err
+		}
+
+		tag.Header.Timestamp = key.Dts & 0xffffff
+		tag.Header.TimestampEx = (key.Dts >> 24) & 0xff
+		pkt, err := d.flv.Parser.readPacket(tag)
+		if err != nil {
+			return 0, err
+		}
+
+		pending = append(pending, pkt)
+	}
+
+	if _, err := rs.Seek(key.Offset-4, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	d.flv.Parser.reset(key.Offset - 4)
+	d.pending = pending
+	d.header = true
+	d.eof = false
+	d.err = nil
+	return key.Dts, nil
+}
+
+// lastSeqHeader returns the position of the last known sequence header of
+// the given kind before offset, -1 if none.
+func (d *Demuxer) lastSeqHeader(before int64, video bool) int64 {
+	offset := int64(-1)
+	for _, seq := range d.seqs {
+		if seq.offset >= before {
+			break
+		}
+
+		if seq.video == video {
+			offset = seq.offset
+		}
+	}
+
+	return offset
+}
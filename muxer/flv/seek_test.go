@@ -0,0 +1,169 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildSeekFLV returns a stream with a key frame every second and an inter
+// frame in between.
+func buildSeekFLV() []byte {
+	data := buildFLV()
+	for i := 1; i <= 5; i++ {
+		data = append(data, buildTag(Video, i*1000, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 1, 0x65})...)
+		data = append(data, buildTag(Video, i*1000+500, []byte{0x27, 1, 0, 0, 0, 0, 0, 0, 1, 0x41})...)
+	}
+	return data
+}
+
+func testSeek(t *testing.T, data []byte) {
+	d := NewDemuxer(bytes.NewReader(data))
+
+	dts, err := d.SeekTime(3700)
+	assert.Nil(t, err)
+	assert.Equal(t, 3000, dts)
+
+	pkt, err := d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, PKT_VIDEO, pkt.Type)
+	assert.Equal(t, 0, pkt.Data.(*PacketVideo).AVCPacketType)
+	assert.Equal(t, 3000, pkt.Dts)
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, 3000, pkt.Dts)
+	assert.True(t, pkt.Data.(*PacketVideo).IsKeyFrame())
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, 3500, pkt.Dts)
+
+	// before the first key frame lands on it
+	dts, err = d.SeekTime(10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, dts)
+
+	dts, err = d.SeekTime(1 << 30)
+	assert.Nil(t, err)
+	assert.Equal(t, 5000, dts)
+}
+
+func TestSeekTimeScan(t *testing.T) {
+	testSeek(t, buildSeekFLV())
+
+	d := NewDemuxer(bytes.NewReader(buildSeekFLV()))
+	index, err := d.BuildIndex()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(index))
+}
+
+func TestSeekTimeMetadata(t *testing.T) {
+	var out bytes.Buffer
+	assert.Nil(t, InjectMetadata(bytes.NewReader(buildSeekFLV()), &out))
+	testSeek(t, out.Bytes())
+}
+
+func TestSeekWrappedSeqHeaders(t *testing.T) {
+	data := []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}
+	// multitrack, ModEx and ModEx around multitrack video sequence starts,
+	// a multitrack Opus sequence start and a ModEx key frame
+	data = append(data, buildTag(Video, 0, []byte{0x96, 0x00, 'a', 'v', 'c', '1', 0, 1, 0x64, 0, 0x1f})...)
+	data = append(data, buildTag(Video, 0, []byte{0x97, 0x02, 0, 0, 5, 0x00, 'a', 'v', 'c', '1', 1, 0x64, 0, 0x1f})...)
+	data = append(data, buildTag(Video, 0, []byte{0x97, 0x02, 0, 0, 5, 0x06, 0x00, 'a', 'v', 'c', '1', 0, 1, 0x64})...)
+	data = append(data, buildTag(Audio, 0, []byte{0x95, 0x00, 'O', 'p', 'u', 's', 0, 'O', 'p', 'u', 's'})...)
+	data = append(data, buildTag(Video, 40, []byte{0x97, 0x02, 0, 0, 5, 0x01, 'a', 'v', 'c', '1', 0, 0, 0, 0, 0, 0, 1, 0x65})...)
+
+	d := NewDemuxer(bytes.NewReader(data))
+	index, err := d.BuildIndex()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(index))
+	assert.Equal(t, 40, index[0].Dts)
+	assert.Equal(t, 4, len(d.seqs))
+	assert.False(t, d.seqs[3].video)
+
+	// ModEx data larger than the scanned prefix
+	big := append([]byte{0x97, 0xff, 0x00, 0x3f}, make([]byte, 64)...)
+	big = append(big, 0x00, 'a', 'v', 'c', '1', 1, 0x64)
+	info := &tagInfo{header: TagHeader{Type: Video, DataSize: len(big)}, body: big[:2]}
+	assert.True(t, info.wrapped())
+	assert.Equal(t, -1, info.packetType())
+	assert.Nil(t, info.readBody(bytes.NewReader(big[2:]), len(big)))
+	assert.True(t, info.isSeqHeader())
+}
+
+func TestSeekTimeMetadataSeqHeaders(t *testing.T) {
+	data := []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, buildTag(Video, 0, []byte{0x17, 0, 0, 0, 0, 1, 0x42, 0, 0x1e})...)
+	for i := 1; i <= 5; i++ {
+		if i == 2 {
+			// an audio sequence header after the video one
+			data = append(data, buildTag(Audio, 1900, []byte{0xaf, 0, 0x12, 0x10})...)
+		}
+		if i == 3 {
+			// a new video configuration
+			data = append(data, buildTag(Video, 2900, []byte{0x17, 0, 0, 0, 0, 1, 0x64, 0, 0x28})...)
+		}
+		data = append(data, buildTag(Video, i*1000, []byte{0x17, 1, 0, 0, 0, 0, 0, 0, 1, 0x65})...)
+	}
+
+	var out bytes.Buffer
+	assert.Nil(t, InjectMetadata(bytes.NewReader(data), &out))
+	d := NewDemuxer(bytes.NewReader(out.Bytes()))
+
+	// the first seek only scans up to its key frame
+	dts, err := d.SeekTime(1000)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, dts)
+	pkt, err := d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 0x42, 0, 0x1e}, pkt.Data.(*PacketVideo).Data)
+
+	dts, err = d.SeekTime(3700)
+	assert.Nil(t, err)
+	assert.Equal(t, 3000, dts)
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 0x64, 0, 0x28}, pkt.Data.(*PacketVideo).Data)
+	assert.Equal(t, 3000, pkt.Dts)
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, PKT_AUDIO, pkt.Type)
+	assert.Equal(t, 3000, pkt.Dts)
+
+	pkt, err = d.ReadPacket()
+	assert.Nil(t, err)
+	assert.True(t, pkt.Data.(*PacketVideo).IsKeyFrame())
+}
+
+func TestSeekTimeFailure(t *testing.T) {
+	// audio only, larger than a read chunk
+	data := []byte{'F', 'L', 'V', 1, 0x04, 0, 0, 0, 9, 0, 0, 0, 0}
+	for i := 0; i < 100; i++ {
+		data = append(data, buildTag(Audio, i*23, append([]byte{0xaf, 1}, make([]byte, 100)...))...)
+	}
+
+	d := NewDemuxer(bytes.NewReader(data))
+	for i := 0; i < 2; i++ {
+		_, err := d.ReadPacket()
+		assert.Nil(t, err)
+	}
+
+	_, err := d.SeekTime(1000)
+	assert.Equal(t, ErrNoKeyframe, err)
+
+	for i := 1; i < 100; i++ {
+		pkt, err := d.ReadPacket()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, i*23, pkt.Dts)
+	}
+
+	_, err = d.ReadPacket()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,38 @@
+package reader
+
+import (
+	"io"
+
+	"media-go/core"
+)
+
+// maxEmptyReads bounds the callbacks in a row that return no data and no
+// error, like bufio does for io.Reader.
+const maxEmptyReads = 100
+
+// StreamReader adapts a core.StreamReader callback to io.Reader.
+type StreamReader struct {
+	Ctx    *core.Context
+	buffer []byte
+	err    error
+}
+
+// Read returns io.ErrNoProgress when the callback keeps returning neither
+// data nor an error.
+func (re *StreamReader) Read(p []byte) (int, error) {
+	for i := 0; len(re.buffer) == 0; i++ {
+		if re.err != nil {
+			return 0, re.err
+		}
+
+		if i == maxEmptyReads {
+			return 0, io.ErrNoProgress
+		}
+
+		re.buffer, re.err = re.Ctx.SR(re.Ctx)
+	}
+
+	n := copy(p, re.buffer)
+	re.buffer = re.buffer[n:]
+	return n, nil
+}